@@ -7,9 +7,12 @@ import (
 	"time"
 
 	"crypto-portfolio-backend/internal/config"
+	"crypto-portfolio-backend/internal/exchange"
 	"crypto-portfolio-backend/internal/fx"
 	"crypto-portfolio-backend/internal/handlers"
+	"crypto-portfolio-backend/internal/portfolio"
 	"crypto-portfolio-backend/internal/prices"
+	"crypto-portfolio-backend/internal/wallet"
 )
 
 func main() {
@@ -22,20 +25,70 @@ func main() {
 	}
 
 	// Initialize price service
-	priceService := prices.NewService("data/coins_meta.json", "data/cmc_coins_meta.json", "data/cmc_coingecko_map.json")
+	priceService := prices.NewService(prices.Config{
+		MetaPath:           "data/coins_meta.json",
+		CMCMetaPath:        "data/cmc_coins_meta.json",
+		CMCMapPath:         "data/cmc_coingecko_map.json",
+		CMCOverridesPath:   "data/cmc_mapping_overrides.json",
+		ContractsPath:      "data/coin_contracts.json",
+		CoinDetailPath:     "data/coin_details.json",
+		ImageDir:           "data/images",
+		LifecyclePath:      "data/coin_lifecycle.json",
+		HistoryArchivePath: "data/history_archive.json",
+		AssetsPath:         "data/assets.json",
+	})
 	priceHandler := handlers.NewPriceHandler(priceService)
 	fxService := fx.NewService()
 	fxHandler := handlers.NewFXHandler(fxService)
+	portfolioHandler := handlers.NewPortfolioHandler(portfolio.NewService(priceService, fxService))
+	walletService := wallet.NewService(priceService, wallet.Config{
+		EsploraURL:      os.Getenv("ESPLORA_URL"),
+		EtherscanURL:    os.Getenv("ETHERSCAN_URL"),
+		EtherscanAPIKey: os.Getenv("ETHERSCAN_API_KEY"),
+		RPCURLs:         wallet.ParseRPCURLs(os.Getenv("EVM_RPC_URLS")),
+	})
+	importHandler := handlers.NewImportHandler(walletService)
+	exchangeHandler := handlers.NewExchangeHandler(exchange.NewService(exchange.Config{
+		StorePath:      "data/exchange_connections.json",
+		CredentialsKey: os.Getenv("EXCHANGE_CREDENTIALS_KEY"),
+		BaseURLs: map[string]string{
+			exchange.Binance:  os.Getenv("BINANCE_API_URL"),
+			exchange.Kraken:   os.Getenv("KRAKEN_API_URL"),
+			exchange.Bitflyer: os.Getenv("BITFLYER_API_URL"),
+		},
+	}))
+	adminHandler := handlers.NewAdminHandler(priceService, os.Getenv("ADMIN_TOKEN"))
 
 	// Register routes
 	http.HandleFunc("/coins/meta", priceHandler.HandleGetCoinMeta)
 	http.HandleFunc("/cmc/coins/meta", priceHandler.HandleGetCMCCoinMeta)
+	http.HandleFunc("/cmc/map", priceHandler.HandleGetCMCMap)
+	http.HandleFunc("/assets", priceHandler.HandleGetAssets)
+	http.HandleFunc("/coins/search", priceHandler.HandleSearchCoins)
+	http.HandleFunc("/coins/resolve", priceHandler.HandleResolveSymbols)
+	http.HandleFunc("/coins/by-contract", priceHandler.HandleLookupContract)
+	http.HandleFunc("/coins/{id}", priceHandler.HandleGetCoinDetail)
+	http.HandleFunc("/coins/{id}/image", priceHandler.HandleGetCoinImage)
 	http.HandleFunc("/prices/latest", priceHandler.HandleGetLatestPrices)
 	http.HandleFunc("/cmc/prices/latest", priceHandler.HandleGetCMCLatestPrices)
+	http.HandleFunc("/prices/stream", priceHandler.HandlePriceStream)
 	// Register more specific routes before less specific ones to avoid path conflicts
 	http.HandleFunc("/prices/history/batch", priceHandler.HandleGetHistoryBatch)
 	http.HandleFunc("/prices/history", priceHandler.HandleGetHistory)
 	http.HandleFunc("/fx", fxHandler.HandleGetRates)
+	http.HandleFunc("/portfolio/value", portfolioHandler.HandleValue)
+	http.HandleFunc("/portfolio/history", portfolioHandler.HandleHistory)
+	http.HandleFunc("/portfolio/cost-basis", portfolioHandler.HandleCostBasis)
+	http.HandleFunc("/portfolio/tax-report", portfolioHandler.HandleTaxReport)
+	http.HandleFunc("/import/csv", importHandler.HandleImportCSV)
+	http.HandleFunc("/import/csv/template", importHandler.HandleImportTemplate)
+	http.HandleFunc("/import/csv/preview", importHandler.HandlePreviewCSV)
+	http.HandleFunc("/import/wallet/btc", importHandler.HandleImportBitcoin)
+	http.HandleFunc("/import/wallet/evm", importHandler.HandleImportEVM)
+	http.HandleFunc("/import/normalize", importHandler.HandleNormalize)
+	http.HandleFunc("/exchange/connections", exchangeHandler.HandleConnections)
+	http.HandleFunc("/exchange/sync", exchangeHandler.HandleSync)
+	http.HandleFunc("/admin/cmc/map/overrides", adminHandler.HandleMappingOverrides)
 	http.HandleFunc("/health", priceHandler.HandleHealth)
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != "/" {
@@ -60,18 +113,41 @@ func main() {
 	log.Printf("📊 Endpoints:")
 	log.Printf("   GET /coins/meta  - Get coin metadata (cached 7d)")
 	log.Printf("   GET /cmc/coins/meta  - Get CMC coin metadata (cached 7d)")
+	log.Printf("   GET /cmc/map  - Get the CMC to CoinGecko id mapping")
+	log.Printf("   GET /assets  - Get unified asset ids with their provider ids")
+	log.Printf("   GET /coins/search  - Search coins by symbol, name or id")
+	log.Printf("   GET /coins/resolve  - Resolve symbols to CMC and CoinGecko ids")
+	log.Printf("   GET /coins/by-contract  - Look up coins by chain and token contract address")
+	log.Printf("   GET /coins/{id}  - Get coin details, supply and ATH/ATL (cached 1d)")
+	log.Printf("   GET /coins/{id}/image  - Get a resized coin logo (size=32|64|128, cached 30d)")
 	log.Printf("   GET /prices/latest  - Get current prices for ids (cached 5m)")
 	log.Printf("   GET /cmc/prices/latest  - Get CMC latest prices (cached 5m)")
+	log.Printf("   GET /prices/stream  - Stream CMC latest price diffs (SSE or WebSocket)")
 	log.Printf("   GET /prices/history - Get historical prices (cached 1d)")
 	log.Printf("   GET /prices/history/batch - Get historical prices (cached 1d)")
+	log.Printf("   POST /prices/history/batch - Get historical prices per item (days, interval, from/to)")
 	log.Printf("   GET /fx     - Get latest FX rates from ECB (cached 24h)")
+	log.Printf("   POST /portfolio/value  - Value holdings in a fiat currency (nothing stored)")
+	log.Printf("   POST /portfolio/history  - Daily value, cost basis and PnL from transactions")
+	log.Printf("   POST /portfolio/cost-basis  - Lots, disposals and PnL under FIFO, LIFO, HIFO or average cost")
+	log.Printf("   POST /portfolio/tax-report  - Capital gains per disposal (US Form 8949, Japan total average; JSON or CSV)")
+	log.Printf("   POST /import/csv  - Binance, Coinbase, Kraken or bitFlyer CSV export to transactions")
+	log.Printf("   POST /import/csv/template  - Any CSV layout to transactions with a JSON column mapping")
+	log.Printf("   POST /import/csv/preview  - Dry run of a templated import, suggesting a mapping from headers")
+	log.Printf("   POST /import/wallet/btc  - Bitcoin xpub/ypub/zpub or address history via Esplora")
+	log.Printf("   POST /import/wallet/evm  - EVM native and ERC-20 history via an Etherscan-compatible API or JSON-RPC")
+	log.Printf("   POST /import/normalize  - Classify imported transactions as swaps, transfers, rewards, airdrops and fees")
+	log.Printf("   GET|POST|DELETE /exchange/connections  - Store a read-only Binance, Kraken or bitFlyer API key (EXCHANGE_CREDENTIALS_KEY)")
+	log.Printf("   POST /exchange/sync  - Trades, deposits and withdrawals since the last sync")
 	log.Printf("   GET /health - Health check")
+	log.Printf("   GET|POST|DELETE /admin/cmc/map/overrides - Manage CMC mapping overrides (ADMIN_TOKEN)")
 	log.Printf("")
 	log.Printf("💡 Example:")
 	log.Printf("   curl http://localhost:%s/coins/meta", port)
 	log.Printf("   curl http://localhost:%s/cmc/coins/meta", port)
 	log.Printf("   curl http://localhost:%s/prices/latest?ids=bitcoin,ethereum", port)
 	log.Printf("   curl http://localhost:%s/cmc/prices/latest?ids=1,1027", port)
+	log.Printf("   curl -N http://localhost:%s/prices/stream?ids=1,1027", port)
 	log.Printf("   curl http://localhost:%s/prices/history?id=bitcoin&days=7&interval=hourly", port)
 
 	go func() {
@@ -4,10 +4,18 @@ go 1.25.0
 
 require golang.org/x/sync v0.19.0
 
-require github.com/tursodatabase/libsql-client-go v0.0.0-20251219100830-236aa1ff8acc
+require (
+	github.com/coder/websocket v1.8.12
+	github.com/fxamacker/cbor/v2 v2.7.0
+	github.com/klauspost/compress v1.18.0
+	github.com/tursodatabase/libsql-client-go v0.0.0-20251219100830-236aa1ff8acc
+	golang.org/x/crypto v0.46.0
+	golang.org/x/image v0.25.0
+)
 
 require (
 	github.com/antlr4-go/antlr/v4 v4.13.0 // indirect
-	github.com/coder/websocket v1.8.12 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
 	golang.org/x/exp v0.0.0-20240325151524-a685a6edb6d8 // indirect
+	golang.org/x/sys v0.39.0 // indirect
 )
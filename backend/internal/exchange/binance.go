@@ -0,0 +1,335 @@
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"crypto-portfolio-backend/internal/httpclient"
+	"crypto-portfolio-backend/internal/importer"
+)
+
+const (
+	defaultBinanceURL = "https://api.binance.com"
+	binanceTradeLimit = 1000
+	binanceRecvWindow = "10000"
+	// binanceWindow is the longest range the deposit and withdrawal
+	// history endpoints accept.
+	binanceWindow = 90 * 24 * time.Hour
+)
+
+// binanceEpoch is before Binance's launch; transfer history starts here.
+var binanceEpoch = time.Date(2017, time.July, 1, 0, 0, 0, 0, time.UTC)
+
+// Binance deposit status 1 is success and 6 is credited to the account
+// but not yet withdrawable; withdrawal status 6 is completed. Deposits 0
+// (pending) and withdrawals 0, 2 and 4 are still in flight.
+const (
+	binanceDepositSuccess      = 1
+	binanceDepositCredited     = 6
+	binanceWithdrawalCompleted = 6
+)
+
+// binanceClient reads spot trades and transfers with HMAC-SHA256 signed
+// requests. Spot trade history is per symbol, so connections list their
+// markets.
+type binanceClient struct {
+	baseURL    string
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+func newBinanceClient(baseURL string) *binanceClient {
+	return &binanceClient{baseURL: baseURL, httpClient: httpclient.New(requestTimeout), now: time.Now}
+}
+
+type binanceError struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+type binanceRestrictions struct {
+	EnableReading              bool `json:"enableReading"`
+	EnableWithdrawals          bool `json:"enableWithdrawals"`
+	EnableInternalTransfer     bool `json:"enableInternalTransfer"`
+	EnableSpotAndMarginTrading bool `json:"enableSpotAndMarginTrading"`
+	EnableMargin               bool `json:"enableMargin"`
+	EnableFutures              bool `json:"enableFutures"`
+	EnableVanillaOptions       bool `json:"enableVanillaOptions"`
+	PermitsUniversalTransfer   bool `json:"permitsUniversalTransfer"`
+}
+
+type binanceTrade struct {
+	ID              int64  `json:"id"`
+	Symbol          string `json:"symbol"`
+	Price           string `json:"price"`
+	Qty             string `json:"qty"`
+	Commission      string `json:"commission"`
+	CommissionAsset string `json:"commissionAsset"`
+	Time            int64  `json:"time"`
+	IsBuyer         bool   `json:"isBuyer"`
+}
+
+type binanceDeposit struct {
+	ID         json.Number `json:"id"`
+	Amount     string      `json:"amount"`
+	Coin       string      `json:"coin"`
+	Status     int         `json:"status"`
+	Address    string      `json:"address"`
+	TxID       string      `json:"txId"`
+	InsertTime int64       `json:"insertTime"`
+}
+
+type binanceWithdrawal struct {
+	ID             string `json:"id"`
+	Amount         string `json:"amount"`
+	TransactionFee string `json:"transactionFee"`
+	Coin           string `json:"coin"`
+	Status         int    `json:"status"`
+	Address        string `json:"address"`
+	TxID           string `json:"txId"`
+	ApplyTime      string `json:"applyTime"`
+}
+
+// verify rejects keys that can do more than read.
+func (c *binanceClient) verify(ctx context.Context, credentials Credentials) error {
+	var restrictions binanceRestrictions
+	if err := c.get(ctx, credentials, "/sapi/v1/account/apiRestrictions", url.Values{}, &restrictions); err != nil {
+		return err
+	}
+	if !restrictions.EnableReading {
+		return fmt.Errorf("%w: the key cannot read account data", ErrUnauthorized)
+	}
+	if restrictions.EnableWithdrawals || restrictions.EnableInternalTransfer || restrictions.EnableSpotAndMarginTrading ||
+		restrictions.EnableMargin || restrictions.EnableFutures || restrictions.EnableVanillaOptions || restrictions.PermitsUniversalTransfer {
+		return fmt.Errorf("%w: the key can trade or move funds; create a read-only key", ErrUnauthorized)
+	}
+	return nil
+}
+
+func (c *binanceClient) fetch(ctx context.Context, credentials Credentials, symbols []string, cursors map[string]string) (*batch, error) {
+	fetched := &batch{cursors: maps.Clone(cursors)}
+	for _, symbol := range symbols {
+		if err := c.fetchTrades(ctx, credentials, symbol, fetched); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.fetchDeposits(ctx, credentials, fetched); err != nil {
+		return nil, err
+	}
+	if err := c.fetchWithdrawals(ctx, credentials, fetched); err != nil {
+		return nil, err
+	}
+	return fetched, nil
+}
+
+// fetchTrades pages myTrades by id; the cursor is the next id to read.
+func (c *binanceClient) fetchTrades(ctx context.Context, credentials Credentials, symbol string, fetched *batch) error {
+	key := "trades:" + symbol
+	base, quote, err := importer.SplitPair(Binance, symbol)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	fromID, _ := strconv.ParseInt(fetched.cursors[key], 10, 64)
+
+	for page := 0; page < maxPages; page++ {
+		params := url.Values{}
+		params.Set("symbol", symbol)
+		params.Set("fromId", strconv.FormatInt(fromID, 10))
+		params.Set("limit", strconv.Itoa(binanceTradeLimit))
+		var trades []binanceTrade
+		if err := c.get(ctx, credentials, "/api/v3/myTrades", params, &trades); err != nil {
+			return err
+		}
+
+		for _, trade := range trades {
+			side := "SELL"
+			if trade.IsBuyer {
+				side = "BUY"
+			}
+			fetched.trades = append(fetched.trades, importer.Trade{
+				Timestamp:   time.UnixMilli(trade.Time).UTC(),
+				Side:        side,
+				Base:        base,
+				Quote:       quote,
+				Amount:      parseAmount(trade.Qty),
+				Price:       parseAmount(trade.Price),
+				Fee:         parseAmount(trade.Commission),
+				FeeCurrency: trade.CommissionAsset,
+				ExternalID:  symbol + "-" + strconv.FormatInt(trade.ID, 10),
+			})
+			fromID = max(fromID, trade.ID+1)
+		}
+		if fromID > 0 {
+			fetched.cursors[key] = strconv.FormatInt(fromID, 10)
+		}
+		if len(trades) < binanceTradeLimit {
+			break
+		}
+	}
+	return nil
+}
+
+// fetchDeposits walks 90-day windows from the cursor to now. The cursor
+// stops at the oldest deposit still in flight so it is picked up once it
+// completes; the device drops the repeats by external id.
+func (c *binanceClient) fetchDeposits(ctx context.Context, credentials Credentials, fetched *batch) error {
+	return c.walkWindows(fetched, "deposits", func(start, end int64) (int64, error) {
+		pending := int64(-1)
+		for offset := 0; offset < maxPages*binanceTradeLimit; offset += binanceTradeLimit {
+			var deposits []binanceDeposit
+			if err := c.get(ctx, credentials, "/sapi/v1/capital/deposit/hisrec", windowParams(start, end, offset), &deposits); err != nil {
+				return 0, err
+			}
+			for _, deposit := range deposits {
+				switch deposit.Status {
+				case binanceDepositSuccess, binanceDepositCredited:
+					fetched.transfers = append(fetched.transfers, Transfer{
+						Kind:       TransferDeposit,
+						Asset:      importer.Asset(Binance, deposit.Coin),
+						Amount:     parseAmount(deposit.Amount),
+						Address:    deposit.Address,
+						TxHash:     deposit.TxID,
+						ExternalID: "binance:deposit:" + deposit.ID.String(),
+						Timestamp:  deposit.InsertTime,
+					})
+				case 0:
+					pending = earliest(pending, deposit.InsertTime)
+				}
+			}
+			if len(deposits) < binanceTradeLimit {
+				break
+			}
+		}
+		return pending, nil
+	})
+}
+
+// fetchWithdrawals works like fetchDeposits, keyed by the apply time.
+func (c *binanceClient) fetchWithdrawals(ctx context.Context, credentials Credentials, fetched *batch) error {
+	return c.walkWindows(fetched, "withdrawals", func(start, end int64) (int64, error) {
+		pending := int64(-1)
+		for offset := 0; offset < maxPages*binanceTradeLimit; offset += binanceTradeLimit {
+			var withdrawals []binanceWithdrawal
+			if err := c.get(ctx, credentials, "/sapi/v1/capital/withdraw/history", windowParams(start, end, offset), &withdrawals); err != nil {
+				return 0, err
+			}
+			for _, withdrawal := range withdrawals {
+				applied, err := time.Parse(time.DateTime, withdrawal.ApplyTime)
+				if err != nil {
+					return 0, fmt.Errorf("binance withdrawal %s: invalid applyTime %q", withdrawal.ID, withdrawal.ApplyTime)
+				}
+				switch withdrawal.Status {
+				case binanceWithdrawalCompleted:
+					fetched.transfers = append(fetched.transfers, Transfer{
+						Kind:       TransferWithdrawal,
+						Asset:      importer.Asset(Binance, withdrawal.Coin),
+						Amount:     parseAmount(withdrawal.Amount),
+						Fee:        parseAmount(withdrawal.TransactionFee),
+						Address:    withdrawal.Address,
+						TxHash:     withdrawal.TxID,
+						ExternalID: "binance:withdrawal:" + withdrawal.ID,
+						Timestamp:  applied.UnixMilli(),
+					})
+				case 0, 2, 4:
+					pending = earliest(pending, applied.UnixMilli())
+				}
+			}
+			if len(withdrawals) < binanceTradeLimit {
+				break
+			}
+		}
+		return pending, nil
+	})
+}
+
+// walkWindows calls read for each window from the cursor to now and sets
+// the cursor to the next start, or to the earliest pending time read
+// returned.
+func (c *binanceClient) walkWindows(fetched *batch, key string, read func(start, end int64) (int64, error)) error {
+	start := binanceEpoch.UnixMilli()
+	if cursor, err := strconv.ParseInt(fetched.cursors[key], 10, 64); err == nil {
+		start = cursor
+	}
+	now := c.now().UnixMilli()
+	next, pending := start, int64(-1)
+	for start <= now {
+		end := min(start+binanceWindow.Milliseconds()-1, now)
+		windowPending, err := read(start, end)
+		if err != nil {
+			return err
+		}
+		if windowPending >= 0 {
+			pending = earliest(pending, windowPending)
+		}
+		start = end + 1
+		next = start
+	}
+	if pending >= 0 {
+		next = min(next, pending)
+	}
+	fetched.cursors[key] = strconv.FormatInt(next, 10)
+	return nil
+}
+
+func windowParams(start, end int64, offset int) url.Values {
+	params := url.Values{}
+	params.Set("startTime", strconv.FormatInt(start, 10))
+	params.Set("endTime", strconv.FormatInt(end, 10))
+	params.Set("offset", strconv.Itoa(offset))
+	params.Set("limit", strconv.Itoa(binanceTradeLimit))
+	return params
+}
+
+// get sends a signed GET: the query with timestamp and recvWindow is signed
+// with HMAC-SHA256 of the secret and the signature appended last.
+func (c *binanceClient) get(ctx context.Context, credentials Credentials, path string, params url.Values, out any) error {
+	resp, err := httpclient.Do(c.httpClient, func() (*http.Request, error) {
+		query := url.Values{}
+		for name, values := range params {
+			query[name] = values
+		}
+		query.Set("timestamp", strconv.FormatInt(c.now().UnixMilli(), 10))
+		query.Set("recvWindow", binanceRecvWindow)
+		encoded := query.Encode()
+		encoded += "&signature=" + hmacSHA256(credentials.APISecret, encoded)
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path+"?"+encoded, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("X-MBX-APIKEY", credentials.APIKey)
+		return req, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to fetch binance %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		var apiErr binanceError
+		json.Unmarshal(body, &apiErr)
+		switch {
+		case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden ||
+			apiErr.Code == -2014 || apiErr.Code == -2015 || apiErr.Code == -1022:
+			return fmt.Errorf("%w: %s", ErrUnauthorized, strings.TrimSpace(apiErr.Msg))
+		case apiErr.Code == -1121:
+			return fmt.Errorf("%w: %s", ErrInvalidRequest, apiErr.Msg)
+		}
+		return fmt.Errorf("binance API error: status %d, body: %s", resp.StatusCode, string(body))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode binance %s response: %w", path, err)
+	}
+	return nil
+}
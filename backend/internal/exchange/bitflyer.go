@@ -0,0 +1,277 @@
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"crypto-portfolio-backend/internal/httpclient"
+	"crypto-portfolio-backend/internal/importer"
+)
+
+const (
+	defaultBitflyerURL = "https://api.bitflyer.com"
+	bitflyerPageSize   = 500
+	bitflyerCompleted  = "COMPLETED"
+)
+
+// bitflyerWritePaths are the private endpoints a read-only key must not
+// be allowed to call.
+var bitflyerWritePaths = []string{
+	"/v1/me/sendchildorder", "/v1/me/sendparentorder", "/v1/me/cancelchildorder",
+	"/v1/me/cancelparentorder", "/v1/me/cancelallchildorders", "/v1/me/withdraw",
+}
+
+// bitflyerClient reads executions per product and crypto deposits and
+// withdrawals with HMAC-SHA256 signed requests. Lists are newest first and
+// paged by id.
+type bitflyerClient struct {
+	baseURL    string
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+func newBitflyerClient(baseURL string) *bitflyerClient {
+	return &bitflyerClient{baseURL: baseURL, httpClient: httpclient.New(requestTimeout), now: time.Now}
+}
+
+type bitflyerError struct {
+	Status       int    `json:"status"`
+	ErrorMessage string `json:"error_message"`
+}
+
+type bitflyerExecution struct {
+	ID         int64   `json:"id"`
+	Side       string  `json:"side"`
+	Price      float64 `json:"price"`
+	Size       float64 `json:"size"`
+	Commission float64 `json:"commission"`
+	ExecDate   string  `json:"exec_date"`
+}
+
+type bitflyerCoinTransfer struct {
+	ID            int64   `json:"id"`
+	CurrencyCode  string  `json:"currency_code"`
+	Amount        float64 `json:"amount"`
+	Address       string  `json:"address"`
+	TxHash        string  `json:"tx_hash"`
+	Fee           float64 `json:"fee"`
+	AdditionalFee float64 `json:"additional_fee"`
+	Status        string  `json:"status"`
+	EventDate     string  `json:"event_date"`
+}
+
+// verify rejects keys that may order or withdraw.
+func (c *bitflyerClient) verify(ctx context.Context, credentials Credentials) error {
+	var permissions []string
+	if err := c.get(ctx, credentials, "/v1/me/getpermissions", nil, &permissions); err != nil {
+		return err
+	}
+	for _, path := range bitflyerWritePaths {
+		if slices.Contains(permissions, path) {
+			return fmt.Errorf("%w: the key can trade or withdraw; create a read-only key", ErrUnauthorized)
+		}
+	}
+	if !slices.Contains(permissions, "/v1/me/getexecutions") {
+		return fmt.Errorf("%w: the key cannot read executions", ErrUnauthorized)
+	}
+	return nil
+}
+
+func (c *bitflyerClient) fetch(ctx context.Context, credentials Credentials, symbols []string, cursors map[string]string) (*batch, error) {
+	fetched := &batch{cursors: maps.Clone(cursors)}
+	for _, product := range symbols {
+		if err := c.fetchExecutions(ctx, credentials, product, fetched); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.fetchTransfers(ctx, credentials, TransferDeposit, "/v1/me/getcoinins", fetched); err != nil {
+		return nil, err
+	}
+	if err := c.fetchTransfers(ctx, credentials, TransferWithdrawal, "/v1/me/getcoinouts", fetched); err != nil {
+		return nil, err
+	}
+	return fetched, nil
+}
+
+// fetchExecutions reads executions after the cursor, the highest id seen.
+func (c *bitflyerClient) fetchExecutions(ctx context.Context, credentials Credentials, product string, fetched *batch) error {
+	base, quote, err := importer.SplitPair(Bitflyer, product)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	query := url.Values{}
+	query.Set("product_code", product)
+	executions, err := bitflyerPages(ctx, c, credentials, "/v1/me/getexecutions", query, "trades:"+product, fetched.cursors,
+		func(e bitflyerExecution) (int64, bool) { return e.ID, false })
+	if err != nil {
+		return err
+	}
+
+	for _, execution := range executions {
+		timestamp, err := parseBitflyerTime(execution.ExecDate)
+		if err != nil {
+			return fmt.Errorf("bitflyer execution %d: %w", execution.ID, err)
+		}
+		fetched.trades = append(fetched.trades, importer.Trade{
+			Timestamp:   timestamp,
+			Side:        execution.Side,
+			Base:        base,
+			Quote:       quote,
+			Amount:      execution.Size,
+			Price:       execution.Price,
+			Fee:         execution.Commission,
+			FeeCurrency: base,
+			ExternalID:  strconv.FormatInt(execution.ID, 10),
+		})
+	}
+	return nil
+}
+
+// fetchTransfers reads completed coin deposits or withdrawals.
+func (c *bitflyerClient) fetchTransfers(ctx context.Context, credentials Credentials, kind, path string, fetched *batch) error {
+	transfers, err := bitflyerPages(ctx, c, credentials, path, url.Values{}, kind+"s", fetched.cursors,
+		func(t bitflyerCoinTransfer) (int64, bool) { return t.ID, t.Status != bitflyerCompleted })
+	if err != nil {
+		return err
+	}
+
+	for _, transfer := range transfers {
+		if transfer.Status != bitflyerCompleted {
+			continue
+		}
+		timestamp, err := parseBitflyerTime(transfer.EventDate)
+		if err != nil {
+			return fmt.Errorf("bitflyer %s %d: %w", kind, transfer.ID, err)
+		}
+		fetched.transfers = append(fetched.transfers, Transfer{
+			Kind:       kind,
+			Asset:      importer.Asset(Bitflyer, transfer.CurrencyCode),
+			Amount:     transfer.Amount,
+			Fee:        transfer.Fee + transfer.AdditionalFee,
+			Address:    transfer.Address,
+			TxHash:     transfer.TxHash,
+			ExternalID: "bitflyer:" + kind + ":" + strconv.FormatInt(transfer.ID, 10),
+			Timestamp:  timestamp.UnixMilli(),
+		})
+	}
+	return nil
+}
+
+// bitflyerPages reads the records with an id above the cursor key, paging
+// backwards from the newest with before, and advances the cursor to the
+// highest id. The cursor stays below the oldest pending record so it is
+// read again once it completes; the device drops the repeats by external
+// id. When maxPages runs out the read stops at "<key>:before" and the next
+// sync fills the gap before moving the cursor.
+func bitflyerPages[T any](ctx context.Context, c *bitflyerClient, credentials Credentials, path string, query url.Values, key string, cursors map[string]string, describe func(T) (int64, bool)) ([]T, error) {
+	after, _ := strconv.ParseInt(cursors[key], 10, 64)
+	before, _ := strconv.ParseInt(cursors[key+":before"], 10, 64)
+	latest := after
+	if before > 0 {
+		latest, _ = strconv.ParseInt(cursors[key+":latest"], 10, 64)
+	}
+
+	var records []T
+	pending, complete := int64(-1), false
+	for pages := 0; pages < maxPages; pages++ {
+		params := maps.Clone(query)
+		params.Set("count", strconv.Itoa(bitflyerPageSize))
+		if after > 0 {
+			params.Set("after", strconv.FormatInt(after, 10))
+		}
+		if before > 0 {
+			params.Set("before", strconv.FormatInt(before, 10))
+		}
+		var page []T
+		if err := c.get(ctx, credentials, path, params, &page); err != nil {
+			return nil, err
+		}
+		records = append(records, page...)
+		for _, record := range page {
+			id, isPending := describe(record)
+			latest = max(latest, id)
+			if isPending {
+				pending = earliest(pending, id)
+			}
+		}
+		if len(page) < bitflyerPageSize {
+			complete = true
+			break
+		}
+		before, _ = describe(page[len(page)-1])
+	}
+	if pending >= 0 {
+		latest = min(latest, pending-1)
+	}
+
+	if !complete {
+		cursors[key+":before"] = strconv.FormatInt(before, 10)
+		cursors[key+":latest"] = strconv.FormatInt(latest, 10)
+		return records, nil
+	}
+	if latest > 0 {
+		cursors[key] = strconv.FormatInt(latest, 10)
+	}
+	delete(cursors, key+":before")
+	delete(cursors, key+":latest")
+	return records, nil
+}
+
+// get sends a signed GET. ACCESS-SIGN is the hex HMAC-SHA256 of the
+// timestamp, method, path with query and body.
+func (c *bitflyerClient) get(ctx context.Context, credentials Credentials, path string, params url.Values, out any) error {
+	if len(params) > 0 {
+		path += "?" + params.Encode()
+	}
+	resp, err := httpclient.Do(c.httpClient, func() (*http.Request, error) {
+		timestamp := strconv.FormatInt(c.now().Unix(), 10)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("ACCESS-KEY", credentials.APIKey)
+		req.Header.Set("ACCESS-TIMESTAMP", timestamp)
+		req.Header.Set("ACCESS-SIGN", hmacSHA256(credentials.APISecret, timestamp+http.MethodGet+path))
+		return req, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to fetch bitflyer %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			var apiErr bitflyerError
+			json.Unmarshal(body, &apiErr)
+			return fmt.Errorf("%w: %s", ErrUnauthorized, strings.TrimSpace(apiErr.ErrorMessage))
+		}
+		return fmt.Errorf("bitflyer API error: status %d, body: %s", resp.StatusCode, string(body))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode bitflyer %s response: %w", path, err)
+	}
+	return nil
+}
+
+// parseBitflyerTime parses bitFlyer dates, which are UTC with or without
+// a zone suffix.
+func parseBitflyerTime(value string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999"} {
+		if parsed, err := time.ParseInLocation(layout, strings.TrimSpace(value), time.UTC); err == nil {
+			return parsed.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
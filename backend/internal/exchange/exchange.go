@@ -0,0 +1,331 @@
+// Package exchange syncs trades, deposits and withdrawals from exchange
+// REST APIs with read-only API keys. Connections are kept on disk with
+// their secrets sealed; each sync returns only what happened since the
+// previous one.
+package exchange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"crypto-portfolio-backend/internal/importer"
+	"crypto-portfolio-backend/internal/portfolio"
+)
+
+// Exchanges with API sync.
+const (
+	Binance  = "binance"
+	Kraken   = "kraken"
+	Bitflyer = "bitflyer"
+)
+
+// maxSymbols bounds the markets synced per connection.
+const maxSymbols = 50
+
+// MaxSyncDuration bounds the exchange requests of one sync; a first sync
+// of a long history is cut by maxPages and resumed by the next one.
+const MaxSyncDuration = 2 * time.Minute
+
+// Transfer kinds.
+const (
+	TransferDeposit    = "deposit"
+	TransferWithdrawal = "withdrawal"
+)
+
+var (
+	// ErrInvalidRequest is wrapped by errors caused by the request itself.
+	ErrInvalidRequest = errors.New("invalid exchange request")
+	// ErrNotFound is returned for unknown connection ids.
+	ErrNotFound = errors.New("connection not found")
+	// ErrDisabled is returned when no credentials key is configured.
+	ErrDisabled = errors.New("exchange sync is disabled: EXCHANGE_CREDENTIALS_KEY is not set")
+	// ErrUnauthorized wraps errors of keys the exchange rejects or that
+	// are not read-only.
+	ErrUnauthorized = errors.New("exchange rejected the API key")
+)
+
+// Credentials is an API key pair. It is only ever stored sealed.
+type Credentials struct {
+	APIKey    string `json:"api_key"`
+	APISecret string `json:"api_secret"`
+}
+
+// ConnectRequest is the body of POST /exchange/connections.
+type ConnectRequest struct {
+	Exchange string `json:"exchange"`
+	Label    string `json:"label,omitempty"`
+	Credentials
+	// Symbols lists the markets to sync where the exchange has no
+	// account-wide trade history: Binance symbols (BTCUSDT) or bitFlyer
+	// product codes (BTC_JPY, the default).
+	Symbols []string `json:"symbols,omitempty"`
+}
+
+// Connection is a stored API key without its secrets. The id is random
+// and acts as the bearer of the connection, so the app keeps it private.
+type Connection struct {
+	ID        string    `json:"id"`
+	Exchange  string    `json:"exchange"`
+	Label     string    `json:"label,omitempty"`
+	Symbols   []string  `json:"symbols,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// Cursors hold the sync position of each stream (trades per symbol,
+	// deposits, withdrawals) in the exchange's own terms.
+	Cursors  map[string]string `json:"cursors,omitempty"`
+	SyncedAt *time.Time        `json:"synced_at,omitempty"`
+}
+
+// Transfer is a completed deposit to or withdrawal from the exchange.
+// Amount is positive; Fee is charged in Asset.
+type Transfer struct {
+	Kind       string  `json:"kind"`
+	Asset      string  `json:"asset"`
+	Amount     float64 `json:"amount"`
+	Fee        float64 `json:"fee,omitempty"`
+	Address    string  `json:"address,omitempty"`
+	TxHash     string  `json:"tx_hash,omitempty"`
+	ExternalID string  `json:"external_id"`
+	Timestamp  int64   `json:"timestamp"`
+}
+
+// SyncResult is returned for POST /exchange/sync. Trades are transactions
+// in the mobile shape; trades the schema cannot hold (crypto-quoted) or on
+// unknown pairs are listed in Errors.
+type SyncResult struct {
+	Connection   Connection              `json:"connection"`
+	Transactions []portfolio.Transaction `json:"transactions"`
+	Transfers    []Transfer              `json:"transfers"`
+	Errors       []string                `json:"errors,omitempty"`
+}
+
+// Config configures the service; BaseURLs maps exchanges to API base URLs
+// and defaults to the production endpoints.
+type Config struct {
+	StorePath string
+	// CredentialsKey is a base64 or hex encoded 32-byte AES key that seals
+	// API secrets at rest. Sync is disabled without it.
+	CredentialsKey string
+	BaseURLs       map[string]string
+}
+
+// batch is what a client fetched since the connection's cursors.
+type batch struct {
+	trades    []importer.Trade
+	transfers []Transfer
+	cursors   map[string]string
+	// errors lists records that were read but cannot be imported.
+	errors []string
+}
+
+// client talks to one exchange API.
+type client interface {
+	// verify checks the key works and, where the exchange reports it,
+	// that it cannot trade or withdraw.
+	verify(ctx context.Context, credentials Credentials) error
+	// fetch returns everything after cursors and the advanced cursors.
+	fetch(ctx context.Context, credentials Credentials, symbols []string, cursors map[string]string) (*batch, error)
+}
+
+// Service manages connections and syncs them.
+type Service struct {
+	store   *store
+	clients map[string]client
+	// syncing holds a *sync.Mutex per connection id so concurrent syncs
+	// of one connection never read from the same cursors.
+	syncing sync.Map
+}
+
+// NewService creates a new exchange service. An invalid credentials key is
+// logged and leaves sync disabled.
+func NewService(config Config) *Service {
+	sealer, err := newSealer(config.CredentialsKey)
+	if err != nil {
+		log.Printf("Exchange sync disabled: %v", err)
+	}
+	baseURL := func(exchange, fallback string) string {
+		if url := strings.TrimRight(config.BaseURLs[exchange], "/"); url != "" {
+			return url
+		}
+		return fallback
+	}
+	return &Service{
+		store: newStore(config.StorePath, sealer),
+		clients: map[string]client{
+			Binance:  newBinanceClient(baseURL(Binance, defaultBinanceURL)),
+			Kraken:   newKrakenClient(baseURL(Kraken, defaultKrakenURL)),
+			Bitflyer: newBitflyerClient(baseURL(Bitflyer, defaultBitflyerURL)),
+		},
+	}
+}
+
+// Exchanges lists the exchanges with API sync.
+func Exchanges() []string {
+	return []string{Binance, Kraken, Bitflyer}
+}
+
+// Connect verifies a key with the exchange and stores it.
+func (s *Service) Connect(ctx context.Context, request ConnectRequest) (*Connection, error) {
+	if !s.store.Enabled() {
+		return nil, ErrDisabled
+	}
+	exchange := strings.ToLower(strings.TrimSpace(request.Exchange))
+	client, found := s.clients[exchange]
+	if !found {
+		return nil, fmt.Errorf("%w: unsupported exchange %q (supported: %s)", ErrInvalidRequest, request.Exchange, strings.Join(Exchanges(), ", "))
+	}
+	credentials := Credentials{APIKey: strings.TrimSpace(request.APIKey), APISecret: strings.TrimSpace(request.APISecret)}
+	if credentials.APIKey == "" || credentials.APISecret == "" {
+		return nil, fmt.Errorf("%w: api_key and api_secret are required", ErrInvalidRequest)
+	}
+	symbols, err := normalizeSymbols(exchange, request.Symbols)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.verify(ctx, credentials); err != nil {
+		return nil, err
+	}
+
+	connection := Connection{
+		Exchange:  exchange,
+		Label:     strings.TrimSpace(request.Label),
+		Symbols:   symbols,
+		CreatedAt: time.Now().UTC(),
+	}
+	return s.store.Create(connection, credentials)
+}
+
+// Get returns a connection without its secrets.
+func (s *Service) Get(id string) (*Connection, error) {
+	if !s.store.Enabled() {
+		return nil, ErrDisabled
+	}
+	connection, _, err := s.store.Get(id)
+	return connection, err
+}
+
+// Delete removes a connection and its sealed secrets.
+func (s *Service) Delete(id string) error {
+	if !s.store.Enabled() {
+		return ErrDisabled
+	}
+	if err := s.store.Delete(id); err != nil {
+		return err
+	}
+	s.syncing.Delete(id)
+	return nil
+}
+
+// Sync fetches everything since the connection's cursors and advances
+// them once the whole batch was fetched, so a failed sync is retried from
+// the same position. Reset starts over from the beginning. Syncs of one
+// connection run one at a time. The sync stops when ctx is done or after
+// MaxSyncDuration, waiting for an earlier sync included.
+func (s *Service) Sync(ctx context.Context, id string, reset bool) (*SyncResult, error) {
+	if !s.store.Enabled() {
+		return nil, ErrDisabled
+	}
+	if _, _, err := s.store.Get(id); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, MaxSyncDuration)
+	defer cancel()
+	lock, _ := s.syncing.LoadOrStore(id, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	// Read the cursors again: a sync that held the lock has moved them.
+	connection, credentials, err := s.store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	cursors := connection.Cursors
+	if reset || cursors == nil {
+		cursors = make(map[string]string)
+	}
+
+	fetched, err := s.clients[connection.Exchange].fetch(ctx, *credentials, connection.Symbols, cursors)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SyncResult{Transactions: []portfolio.Transaction{}, Transfers: fetched.transfers, Errors: fetched.errors}
+	for _, trade := range fetched.trades {
+		tx, err := trade.Transaction(connection.Exchange)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("trade %s: %v", trade.ExternalID, err))
+			continue
+		}
+		result.Transactions = append(result.Transactions, tx)
+	}
+	sort.SliceStable(result.Transactions, func(i, j int) bool {
+		return result.Transactions[i].Timestamp < result.Transactions[j].Timestamp
+	})
+	if result.Transfers == nil {
+		result.Transfers = []Transfer{}
+	}
+	sort.SliceStable(result.Transfers, func(i, j int) bool {
+		return result.Transfers[i].Timestamp < result.Transfers[j].Timestamp
+	})
+
+	updated, err := s.store.SaveCursors(id, fetched.cursors, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	result.Connection = *updated
+	return result, nil
+}
+
+// normalizeSymbols validates the markets of a connection.
+func normalizeSymbols(exchange string, symbols []string) ([]string, error) {
+	var normalized []string
+	seen := make(map[string]struct{})
+	for _, symbol := range symbols {
+		symbol = strings.ToUpper(strings.TrimSpace(symbol))
+		if symbol == "" {
+			continue
+		}
+		if _, _, err := importer.SplitPair(exchange, symbol); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+		}
+		if _, found := seen[symbol]; !found {
+			seen[symbol] = struct{}{}
+			normalized = append(normalized, symbol)
+		}
+	}
+	if len(normalized) > maxSymbols {
+		return nil, fmt.Errorf("%w: at most %d symbols are allowed", ErrInvalidRequest, maxSymbols)
+	}
+
+	switch {
+	case exchange == Binance && len(normalized) == 0:
+		return nil, fmt.Errorf("%w: symbols are required for Binance, e.g. [\"BTCUSDT\"]", ErrInvalidRequest)
+	case exchange == Bitflyer && len(normalized) == 0:
+		normalized = []string{"BTC_JPY"}
+	case exchange == Kraken:
+		// Kraken's trade history covers every pair.
+		normalized = nil
+	}
+	return normalized, nil
+}
+
+// earliest returns the smaller of two times, treating a negative current
+// as unset.
+func earliest(current, candidate int64) int64 {
+	if current < 0 || candidate < current {
+		return candidate
+	}
+	return current
+}
+
+// parseAmount parses a decimal string; exchanges send amounts as strings.
+func parseAmount(value string) float64 {
+	amount, _ := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	return amount
+}
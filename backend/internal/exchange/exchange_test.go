@@ -0,0 +1,490 @@
+package exchange
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"crypto-portfolio-backend/internal/portfolio"
+)
+
+const (
+	testAPIKey    = "read-only-key"
+	testAPISecret = "very-secret"
+)
+
+var (
+	testCredentialsKey = base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+	testNow            = time.Date(2024, time.January, 10, 0, 0, 0, 0, time.UTC)
+)
+
+func newTestService(t *testing.T, exchange string, handler http.HandlerFunc) *Service {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	service := NewService(Config{
+		StorePath:      filepath.Join(t.TempDir(), "exchange_connections.json"),
+		CredentialsKey: testCredentialsKey,
+		BaseURLs:       map[string]string{exchange: server.URL + "/"},
+	})
+	now := func() time.Time { return testNow }
+	service.clients[Binance].(*binanceClient).now = now
+	service.clients[Kraken].(*krakenClient).now = now
+	service.clients[Bitflyer].(*bitflyerClient).now = now
+	return service
+}
+
+func writeJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
+
+func describeTransactions(transactions []portfolio.Transaction) []string {
+	var lines []string
+	for _, tx := range transactions {
+		line := fmt.Sprintf("%s %s %s %s @ %s %s", *tx.ExternalID, tx.Type, tx.AssetSymbol,
+			strconv.FormatFloat(tx.Amount, 'f', -1, 64), strconv.FormatFloat(tx.PricePerUnitFiat, 'f', -1, 64), tx.FiatCurrency)
+		if tx.FeeAmount != nil {
+			line += " fee " + strconv.FormatFloat(*tx.FeeAmount, 'f', -1, 64) + " " + *tx.FeeCurrency
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func describeTransfers(transfers []Transfer) []string {
+	var lines []string
+	for _, transfer := range transfers {
+		lines = append(lines, fmt.Sprintf("%s %s %s %s fee %s", transfer.ExternalID, transfer.Kind, transfer.Asset,
+			strconv.FormatFloat(transfer.Amount, 'f', -1, 64), strconv.FormatFloat(transfer.Fee, 'f', -1, 64)))
+	}
+	return lines
+}
+
+func binanceFake(t *testing.T, restrictions binanceRestrictions) http.HandlerFunc {
+	depositTime := time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+	pendingTime := time.Date(2024, time.January, 9, 0, 0, 0, 0, time.UTC).UnixMilli()
+	return func(w http.ResponseWriter, r *http.Request) {
+		query, signature, _ := strings.Cut(r.URL.RawQuery, "&signature=")
+		if r.Header.Get("X-MBX-APIKEY") != testAPIKey || signature != hmacSHA256(testAPISecret, query) {
+			w.WriteHeader(http.StatusUnauthorized)
+			writeJSON(w, binanceError{Code: -2015, Msg: "Invalid API-key, IP, or permissions for action."})
+			return
+		}
+		params := r.URL.Query()
+		if params.Get("timestamp") != strconv.FormatInt(testNow.UnixMilli(), 10) {
+			t.Errorf("unexpected timestamp %s", params.Get("timestamp"))
+		}
+		inWindow := func(at int64) bool {
+			start, _ := strconv.ParseInt(params.Get("startTime"), 10, 64)
+			end, _ := strconv.ParseInt(params.Get("endTime"), 10, 64)
+			if end-start >= binanceWindow.Milliseconds() {
+				t.Errorf("window too long: %s", r.URL.RawQuery)
+			}
+			return start <= at && at <= end && params.Get("offset") == "0"
+		}
+
+		switch r.URL.Path {
+		case "/sapi/v1/account/apiRestrictions":
+			writeJSON(w, restrictions)
+		case "/api/v3/myTrades":
+			trades := []binanceTrade{}
+			if params.Get("symbol") == "BTCUSDT" && params.Get("fromId") == "0" {
+				trades = []binanceTrade{
+					{ID: 7, Price: "40000", Qty: "0.5", Commission: "0.0005", CommissionAsset: "BTC", Time: depositTime + 1000, IsBuyer: true},
+					{ID: 9, Price: "42000", Qty: "0.2", Commission: "8.4", CommissionAsset: "USDT", Time: depositTime + 2000},
+				}
+			}
+			writeJSON(w, trades)
+		case "/sapi/v1/capital/deposit/hisrec":
+			deposits := []map[string]any{}
+			if inWindow(depositTime) {
+				deposits = append(deposits, map[string]any{"id": "101", "amount": "1", "coin": "BTC", "status": 1, "address": "bc1qdeposit", "txId": "0xdep", "insertTime": depositTime})
+				// Credited but not yet withdrawable: already in the account.
+				deposits = append(deposits, map[string]any{"id": "103", "amount": "5", "coin": "SOL", "status": 6, "insertTime": depositTime + 1000})
+			}
+			if inWindow(pendingTime) {
+				deposits = append(deposits, map[string]any{"id": "102", "amount": "2", "coin": "ETH", "status": 0, "insertTime": pendingTime})
+			}
+			writeJSON(w, deposits)
+		case "/sapi/v1/capital/withdraw/history":
+			withdrawals := []binanceWithdrawal{}
+			if inWindow(depositTime + 3000) {
+				withdrawals = append(withdrawals, binanceWithdrawal{ID: "w1", Amount: "0.1", TransactionFee: "0.0001", Coin: "BTC", Status: 6, Address: "bc1qout", TxID: "0xwd", ApplyTime: "2023-03-01 00:00:03"})
+			}
+			writeJSON(w, withdrawals)
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
+
+func TestSyncBinance(t *testing.T) {
+	service := newTestService(t, Binance, binanceFake(t, binanceRestrictions{EnableReading: true}))
+	connection, err := service.Connect(context.Background(), ConnectRequest{
+		Exchange:    "Binance",
+		Credentials: Credentials{APIKey: testAPIKey, APISecret: testAPISecret},
+		Symbols:     []string{"btcusdt"},
+	})
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	result, err := service.Sync(context.Background(), connection.ID, false)
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	wantTransactions := []string{
+		"binance:BTCUSDT-7 BUY BTC 0.5 @ 40000 USD fee 0.0005 BTC",
+		"binance:BTCUSDT-9 SELL BTC -0.2 @ 42000 USD fee 8.4 USD",
+	}
+	if got := describeTransactions(result.Transactions); !reflect.DeepEqual(got, wantTransactions) {
+		t.Errorf("transactions:\n got %q\nwant %q", got, wantTransactions)
+	}
+	wantTransfers := []string{
+		"binance:deposit:101 deposit BTC 1 fee 0",
+		"binance:deposit:103 deposit SOL 5 fee 0",
+		"binance:withdrawal:w1 withdrawal BTC 0.1 fee 0.0001",
+	}
+	if got := describeTransfers(result.Transfers); !reflect.DeepEqual(got, wantTransfers) {
+		t.Errorf("transfers:\n got %q\nwant %q", got, wantTransfers)
+	}
+
+	// The deposits cursor waits at the pending deposit.
+	wantCursors := map[string]string{
+		"trades:BTCUSDT": "10",
+		"deposits":       strconv.FormatInt(time.Date(2024, time.January, 9, 0, 0, 0, 0, time.UTC).UnixMilli(), 10),
+		"withdrawals":    strconv.FormatInt(testNow.UnixMilli()+1, 10),
+	}
+	if !reflect.DeepEqual(result.Connection.Cursors, wantCursors) {
+		t.Errorf("cursors:\n got %v\nwant %v", result.Connection.Cursors, wantCursors)
+	}
+
+	result, err = service.Sync(context.Background(), connection.ID, false)
+	if err != nil {
+		t.Fatalf("second Sync: %v", err)
+	}
+	if len(result.Transactions) != 0 || len(result.Transfers) != 0 {
+		t.Errorf("expected nothing new, got %v %v", result.Transactions, result.Transfers)
+	}
+
+	result, err = service.Sync(context.Background(), connection.ID, true)
+	if err != nil {
+		t.Fatalf("reset Sync: %v", err)
+	}
+	if len(result.Transactions) != 2 {
+		t.Errorf("expected a reset to read everything again, got %d transactions", len(result.Transactions))
+	}
+}
+
+func TestSyncStopsWithContext(t *testing.T) {
+	service := newTestService(t, Binance, binanceFake(t, binanceRestrictions{EnableReading: true}))
+	connection, err := service.Connect(context.Background(), ConnectRequest{
+		Exchange:    Binance,
+		Credentials: Credentials{APIKey: testAPIKey, APISecret: testAPISecret},
+		Symbols:     []string{"BTCUSDT"},
+	})
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := service.Sync(ctx, connection.ID, false); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if stored, err := service.Get(connection.ID); err != nil || stored.Cursors != nil {
+		t.Errorf("expected the cursors to stay unset, got %v (err %v)", stored, err)
+	}
+}
+
+func TestSyncSerializesAConnection(t *testing.T) {
+	service := newTestService(t, Binance, binanceFake(t, binanceRestrictions{EnableReading: true}))
+	connection, err := service.Connect(context.Background(), ConnectRequest{
+		Exchange:    Binance,
+		Credentials: Credentials{APIKey: testAPIKey, APISecret: testAPISecret},
+		Symbols:     []string{"BTCUSDT"},
+	})
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	// Concurrent syncs must not both read from the initial cursors.
+	var wg sync.WaitGroup
+	counts := make([]int, 4)
+	for i := range counts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := service.Sync(context.Background(), connection.ID, false)
+			if err != nil {
+				t.Errorf("Sync: %v", err)
+				return
+			}
+			counts[i] = len(result.Transactions)
+		}()
+	}
+	wg.Wait()
+
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+	if total != 2 {
+		t.Errorf("concurrent syncs returned %d transactions in total (%v), want 2", total, counts)
+	}
+}
+
+func TestConnectBinanceRejectsTradingKeys(t *testing.T) {
+	service := newTestService(t, Binance, binanceFake(t, binanceRestrictions{EnableReading: true, EnableSpotAndMarginTrading: true}))
+	_, err := service.Connect(context.Background(), ConnectRequest{Exchange: Binance, Credentials: Credentials{APIKey: testAPIKey, APISecret: testAPISecret}, Symbols: []string{"BTCUSDT"}})
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("expected ErrUnauthorized for a trading key, got %v", err)
+	}
+
+	_, err = service.Connect(context.Background(), ConnectRequest{Exchange: Binance, Credentials: Credentials{APIKey: testAPIKey, APISecret: "wrong"}, Symbols: []string{"BTCUSDT"}})
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("expected ErrUnauthorized for a bad signature, got %v", err)
+	}
+}
+
+func TestSyncKraken(t *testing.T) {
+	secret := base64.StdEncoding.EncodeToString([]byte(testAPISecret))
+	service := newTestService(t, Kraken, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		form, _ := url.ParseQuery(string(body))
+		if r.Header.Get("API-Key") != testAPIKey || r.Header.Get("API-Sign") != krakenSignature([]byte(testAPISecret), r.URL.Path, form.Get("nonce"), string(body)) {
+			writeJSON(w, map[string]any{"error": []string{"EAPI:Invalid signature"}})
+			return
+		}
+		start := form.Get("start")
+
+		var result any
+		switch {
+		case r.URL.Path == "/0/private/TradesHistory" && start == "":
+			result = map[string]any{"count": 3, "trades": map[string]any{
+				"TX1": map[string]any{"pair": "XXBTZEUR", "time": 1688667796.8802, "type": "buy", "price": "30000", "vol": "0.1", "fee": "4.8"},
+				"TX2": map[string]any{"pair": "XETHZUSD", "time": 1688667800.1, "type": "sell", "price": "1900", "vol": "1", "fee": "3"},
+				"TX3": map[string]any{"pair": "FOOBAR", "time": 1688667805.5, "type": "buy", "price": "1", "vol": "1", "fee": "0"},
+			}}
+		case r.URL.Path == "/0/private/TradesHistory":
+			if start != "1688667805.5" && start != strconv.FormatInt(testNow.Unix(), 10) {
+				t.Errorf("unexpected trades start %q", start)
+			}
+			result = map[string]any{"count": 0, "trades": map[string]any{}}
+		case r.URL.Path == "/0/private/Ledgers" && start == "" && form.Get("type") == TransferDeposit:
+			result = map[string]any{"count": 1, "ledger": map[string]any{
+				"L1": map[string]any{"refid": "R1", "time": 1688000000.5, "type": "deposit", "asset": "XXBT", "amount": "0.5", "fee": "0"},
+			}}
+		case r.URL.Path == "/0/private/Ledgers" && start == "" && form.Get("type") == TransferWithdrawal:
+			result = map[string]any{"count": 1, "ledger": map[string]any{
+				"L2": map[string]any{"refid": "R2", "time": 1688700000, "type": "withdrawal", "asset": "XETH", "amount": "-0.9950", "fee": "0.0050"},
+			}}
+		case r.URL.Path == "/0/private/Ledgers":
+			result = map[string]any{"count": 0, "ledger": map[string]any{}}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, map[string]any{"error": []string{}, "result": result})
+	})
+
+	if _, err := service.Connect(context.Background(), ConnectRequest{Exchange: Kraken, Credentials: Credentials{APIKey: testAPIKey, APISecret: "not base64!"}}); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("expected ErrInvalidRequest for a malformed secret, got %v", err)
+	}
+	connection, err := service.Connect(context.Background(), ConnectRequest{Exchange: Kraken, Credentials: Credentials{APIKey: testAPIKey, APISecret: secret}, Symbols: []string{"XXBTZEUR"}})
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	if connection.Symbols != nil {
+		t.Errorf("expected no symbols for Kraken, got %v", connection.Symbols)
+	}
+
+	result, err := service.Sync(context.Background(), connection.ID, false)
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	wantTransactions := []string{
+		"kraken:TX1 BUY BTC 0.1 @ 30000 EUR fee 4.8 EUR",
+		"kraken:TX2 SELL ETH -1 @ 1900 USD fee 3 USD",
+	}
+	if got := describeTransactions(result.Transactions); !reflect.DeepEqual(got, wantTransactions) {
+		t.Errorf("transactions:\n got %q\nwant %q", got, wantTransactions)
+	}
+	if wantErrors := []string{`trade TX3: unknown pair "FOOBAR"`}; !reflect.DeepEqual(result.Errors, wantErrors) {
+		t.Errorf("errors: got %q, want %q", result.Errors, wantErrors)
+	}
+	wantTransfers := []string{
+		"kraken:deposit:L1 deposit BTC 0.5 fee 0",
+		"kraken:withdrawal:L2 withdrawal ETH 0.995 fee 0.005",
+	}
+	if got := describeTransfers(result.Transfers); !reflect.DeepEqual(got, wantTransfers) {
+		t.Errorf("transfers:\n got %q\nwant %q", got, wantTransfers)
+	}
+	wantCursors := map[string]string{"trades": "1688667805.5", "deposits": "1688000000.5", "withdrawals": "1688700000"}
+	if !reflect.DeepEqual(result.Connection.Cursors, wantCursors) {
+		t.Errorf("cursors:\n got %v\nwant %v", result.Connection.Cursors, wantCursors)
+	}
+
+	result, err = service.Sync(context.Background(), connection.ID, false)
+	if err != nil {
+		t.Fatalf("second Sync: %v", err)
+	}
+	if len(result.Transactions) != 0 || len(result.Transfers) != 0 {
+		t.Errorf("expected nothing new, got %v %v", result.Transactions, result.Transfers)
+	}
+}
+
+func TestSyncBitflyer(t *testing.T) {
+	permissions := []string{"/v1/me/getexecutions", "/v1/me/getcoinins", "/v1/me/getcoinouts"}
+	service := newTestService(t, Bitflyer, func(w http.ResponseWriter, r *http.Request) {
+		timestamp := r.Header.Get("ACCESS-TIMESTAMP")
+		if r.Header.Get("ACCESS-KEY") != testAPIKey || r.Header.Get("ACCESS-SIGN") != hmacSHA256(testAPISecret, timestamp+r.Method+r.URL.RequestURI()) {
+			w.WriteHeader(http.StatusUnauthorized)
+			writeJSON(w, bitflyerError{Status: -500, ErrorMessage: "Invalid signature"})
+			return
+		}
+		after := r.URL.Query().Get("after")
+
+		switch r.URL.Path {
+		case "/v1/me/getpermissions":
+			writeJSON(w, permissions)
+		case "/v1/me/getexecutions":
+			executions := []bitflyerExecution{}
+			if after == "" && r.URL.Query().Get("product_code") == "BTC_JPY" {
+				executions = []bitflyerExecution{
+					{ID: 38, Side: "SELL", Price: 6100000, Size: 0.01, Commission: 0.00001, ExecDate: "2024-01-05T10:00:00.5"},
+					{ID: 37, Side: "BUY", Price: 6000000, Size: 0.02, Commission: 0.00002, ExecDate: "2024-01-05T09:00:00"},
+				}
+			}
+			writeJSON(w, executions)
+		case "/v1/me/getcoinins":
+			coinins := []bitflyerCoinTransfer{}
+			if after == "" {
+				coinins = []bitflyerCoinTransfer{
+					{ID: 12, CurrencyCode: "ETH", Amount: 1, Status: "PENDING", EventDate: "2024-01-09T00:00:00"},
+					{ID: 10, CurrencyCode: "BTC", Amount: 0.5, Address: "1Deposit", TxHash: "abc", Status: "COMPLETED", EventDate: "2024-01-02T00:00:00"},
+				}
+			}
+			writeJSON(w, coinins)
+		case "/v1/me/getcoinouts":
+			writeJSON(w, []bitflyerCoinTransfer{})
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	connection, err := service.Connect(context.Background(), ConnectRequest{Exchange: Bitflyer, Credentials: Credentials{APIKey: testAPIKey, APISecret: testAPISecret}})
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	if !reflect.DeepEqual(connection.Symbols, []string{"BTC_JPY"}) {
+		t.Errorf("expected the default product, got %v", connection.Symbols)
+	}
+
+	result, err := service.Sync(context.Background(), connection.ID, false)
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	wantTransactions := []string{
+		"bitflyer:37 BUY BTC 0.02 @ 6000000 JPY fee 0.00002 BTC",
+		"bitflyer:38 SELL BTC -0.01 @ 6100000 JPY fee 0.00001 BTC",
+	}
+	if got := describeTransactions(result.Transactions); !reflect.DeepEqual(got, wantTransactions) {
+		t.Errorf("transactions:\n got %q\nwant %q", got, wantTransactions)
+	}
+	if got := describeTransfers(result.Transfers); !reflect.DeepEqual(got, []string{"bitflyer:deposit:10 deposit BTC 0.5 fee 0"}) {
+		t.Errorf("transfers: got %q", got)
+	}
+	// The deposits cursor stays below the pending deposit.
+	wantCursors := map[string]string{"trades:BTC_JPY": "38", "deposits": "11"}
+	if !reflect.DeepEqual(result.Connection.Cursors, wantCursors) {
+		t.Errorf("cursors:\n got %v\nwant %v", result.Connection.Cursors, wantCursors)
+	}
+
+	permissions = append(permissions, "/v1/me/sendchildorder")
+	if _, err := service.Connect(context.Background(), ConnectRequest{Exchange: Bitflyer, Credentials: Credentials{APIKey: testAPIKey, APISecret: testAPISecret}}); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("expected ErrUnauthorized for a trading key, got %v", err)
+	}
+}
+
+func TestConnectionsAreSealedAtRest(t *testing.T) {
+	service := newTestService(t, Binance, binanceFake(t, binanceRestrictions{EnableReading: true}))
+	connection, err := service.Connect(context.Background(), ConnectRequest{Exchange: Binance, Label: "main", Credentials: Credentials{APIKey: testAPIKey, APISecret: testAPISecret}, Symbols: []string{"BTCUSDT"}})
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	path := service.store.path
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read store: %v", err)
+	}
+	if strings.Contains(string(contents), testAPIKey) || strings.Contains(string(contents), testAPISecret) {
+		t.Errorf("store contains plaintext credentials:\n%s", contents)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm()&0o077 != 0 {
+		t.Errorf("expected an owner-only store file, got %v %v", info.Mode(), err)
+	}
+
+	// A fresh service reads the sealed key back.
+	reloaded := NewService(Config{StorePath: path, CredentialsKey: testCredentialsKey})
+	if _, credentials, err := reloaded.store.Get(connection.ID); err != nil || credentials.APISecret != testAPISecret {
+		t.Errorf("expected the credentials back, got %+v %v", credentials, err)
+	}
+	otherKey := base64.StdEncoding.EncodeToString([]byte("fedcba9876543210fedcba9876543210"))
+	if _, err := NewService(Config{StorePath: path, CredentialsKey: otherKey}).Get(connection.ID); err == nil {
+		t.Error("expected a different key to fail to open the credentials")
+	}
+
+	if err := service.Delete(connection.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := service.Get(connection.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after delete, got %v", err)
+	}
+}
+
+func TestConnectInvalid(t *testing.T) {
+	disabled := NewService(Config{StorePath: filepath.Join(t.TempDir(), "connections.json")})
+	if _, err := disabled.Connect(context.Background(), ConnectRequest{Exchange: Binance}); !errors.Is(err, ErrDisabled) {
+		t.Errorf("expected ErrDisabled without a key, got %v", err)
+	}
+
+	service := newTestService(t, Binance, binanceFake(t, binanceRestrictions{EnableReading: true}))
+	credentials := Credentials{APIKey: testAPIKey, APISecret: testAPISecret}
+	tests := []struct {
+		name    string
+		request ConnectRequest
+	}{
+		{name: "exchange", request: ConnectRequest{Exchange: "mtgox", Credentials: credentials}},
+		{name: "secret", request: ConnectRequest{Exchange: Binance, Credentials: Credentials{APIKey: testAPIKey}, Symbols: []string{"BTCUSDT"}}},
+		{name: "binance symbols", request: ConnectRequest{Exchange: Binance, Credentials: credentials}},
+		{name: "unknown market", request: ConnectRequest{Exchange: Binance, Credentials: credentials, Symbols: []string{"BTCXYZ"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := service.Connect(context.Background(), tt.request); !errors.Is(err, ErrInvalidRequest) {
+				t.Errorf("expected ErrInvalidRequest, got %v", err)
+			}
+		})
+	}
+	if _, err := service.Sync(context.Background(), "conn_missing", false); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
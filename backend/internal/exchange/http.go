@@ -0,0 +1,23 @@
+package exchange
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+const (
+	requestTimeout = 15 * time.Second
+	// maxPages bounds the pages read per stream in one sync; the cursor
+	// resumes the rest on the next sync.
+	maxPages = 100
+)
+
+// hmacSHA256 returns the hex HMAC-SHA256 of payload, the signature Binance
+// and bitFlyer use.
+func hmacSHA256(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,302 @@
+package exchange
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"crypto-portfolio-backend/internal/httpclient"
+	"crypto-portfolio-backend/internal/importer"
+)
+
+const defaultKrakenURL = "https://api.kraken.com"
+
+// krakenClient reads the account-wide trade history and ledger with
+// HMAC-SHA512 signed requests. Kraken does not report a key's permissions,
+// so verify only checks that the query permissions work.
+type krakenClient struct {
+	baseURL    string
+	httpClient *http.Client
+	now        func() time.Time
+
+	mu        sync.Mutex
+	lastNonce int64
+}
+
+func newKrakenClient(baseURL string) *krakenClient {
+	return &krakenClient{baseURL: baseURL, httpClient: httpclient.New(requestTimeout), now: time.Now}
+}
+
+type krakenResponse struct {
+	Error  []string        `json:"error"`
+	Result json.RawMessage `json:"result"`
+}
+
+type krakenTrade struct {
+	Pair  string      `json:"pair"`
+	Time  json.Number `json:"time"`
+	Type  string      `json:"type"`
+	Price string      `json:"price"`
+	Vol   string      `json:"vol"`
+	Fee   string      `json:"fee"`
+}
+
+type krakenTrades struct {
+	Trades map[string]krakenTrade `json:"trades"`
+	Count  int                    `json:"count"`
+}
+
+type krakenLedgerEntry struct {
+	RefID  string      `json:"refid"`
+	Time   json.Number `json:"time"`
+	Type   string      `json:"type"`
+	Asset  string      `json:"asset"`
+	Amount string      `json:"amount"`
+	Fee    string      `json:"fee"`
+}
+
+type krakenLedger struct {
+	Ledger map[string]krakenLedgerEntry `json:"ledger"`
+	Count  int                          `json:"count"`
+}
+
+func (c *krakenClient) verify(ctx context.Context, credentials Credentials) error {
+	// A start in the future returns nothing but still checks permissions.
+	params := url.Values{}
+	params.Set("start", strconv.FormatInt(c.now().Unix(), 10))
+	var trades krakenTrades
+	if err := c.post(ctx, credentials, "/0/private/TradesHistory", params, &trades); err != nil {
+		return err
+	}
+	var ledger krakenLedger
+	return c.post(ctx, credentials, "/0/private/Ledgers", params, &ledger)
+}
+
+func (c *krakenClient) fetch(ctx context.Context, credentials Credentials, _ []string, cursors map[string]string) (*batch, error) {
+	fetched := &batch{cursors: maps.Clone(cursors)}
+	if err := c.fetchTrades(ctx, credentials, fetched); err != nil {
+		return nil, err
+	}
+	for _, kind := range []string{TransferDeposit, TransferWithdrawal} {
+		if err := c.fetchLedger(ctx, credentials, kind, fetched); err != nil {
+			return nil, err
+		}
+	}
+	return fetched, nil
+}
+
+// fetchTrades reads trades after the cursor time.
+func (c *krakenClient) fetchTrades(ctx context.Context, credentials Credentials, fetched *batch) error {
+	return c.pages(ctx, credentials, "/0/private/TradesHistory", url.Values{}, "trades", fetched.cursors, func(raw json.RawMessage) ([]string, int, error) {
+		var result krakenTrades
+		if err := json.Unmarshal(raw, &result); err != nil {
+			return nil, 0, fmt.Errorf("failed to decode kraken trades: %w", err)
+		}
+		var times []string
+		for id, trade := range result.Trades {
+			// The cursor still moves past a trade on an unknown pair.
+			times = append(times, trade.Time.String())
+			base, quote, err := importer.SplitPair(Kraken, trade.Pair)
+			if err != nil {
+				fetched.errors = append(fetched.errors, fmt.Sprintf("trade %s: %v", id, err))
+				continue
+			}
+			seconds, _ := trade.Time.Float64()
+			fetched.trades = append(fetched.trades, importer.Trade{
+				Timestamp:   krakenTime(seconds),
+				Side:        trade.Type,
+				Base:        base,
+				Quote:       quote,
+				Amount:      parseAmount(trade.Vol),
+				Price:       parseAmount(trade.Price),
+				Fee:         parseAmount(trade.Fee),
+				FeeCurrency: quote,
+				ExternalID:  id,
+			})
+		}
+		return times, result.Count, nil
+	})
+}
+
+// fetchLedger reads completed deposits or withdrawals; Kraken only books
+// them in the ledger once they settle.
+func (c *krakenClient) fetchLedger(ctx context.Context, credentials Credentials, kind string, fetched *batch) error {
+	params := url.Values{}
+	params.Set("type", kind)
+	return c.pages(ctx, credentials, "/0/private/Ledgers", params, kind+"s", fetched.cursors, func(raw json.RawMessage) ([]string, int, error) {
+		var result krakenLedger
+		if err := json.Unmarshal(raw, &result); err != nil {
+			return nil, 0, fmt.Errorf("failed to decode kraken ledger: %w", err)
+		}
+		var times []string
+		for id, entry := range result.Ledger {
+			seconds, _ := entry.Time.Float64()
+			fetched.transfers = append(fetched.transfers, Transfer{
+				Kind:       kind,
+				Asset:      importer.Asset(Kraken, entry.Asset),
+				Amount:     math.Abs(parseAmount(entry.Amount)),
+				Fee:        parseAmount(entry.Fee),
+				ExternalID: "kraken:" + kind + ":" + id,
+				Timestamp:  krakenTime(seconds).UnixMilli(),
+			})
+			times = append(times, entry.Time.String())
+		}
+		return times, result.Count, nil
+	})
+}
+
+// pages reads a newest-first list after the cursor key, by offset. The
+// cursor is the latest time as Kraken sent it. When maxPages runs out the
+// read stops at the oldest time seen ("<key>:end") and the next sync
+// fills the gap before moving the cursor.
+func (c *krakenClient) pages(ctx context.Context, credentials Credentials, path string, params url.Values, key string, cursors map[string]string, read func(json.RawMessage) ([]string, int, error)) error {
+	start, end := cursors[key], cursors[key+":end"]
+	latest, oldest := start, ""
+	if end != "" {
+		latest = cursors[key+":latest"]
+	}
+
+	for offset, page := 0, 0; ; page++ {
+		if page == maxPages {
+			cursors[key+":end"] = oldest
+			cursors[key+":latest"] = latest
+			return nil
+		}
+		query := maps.Clone(params)
+		if start != "" {
+			query.Set("start", start)
+		}
+		if end != "" {
+			query.Set("end", end)
+		}
+		query.Set("ofs", strconv.Itoa(offset))
+		var raw json.RawMessage
+		if err := c.post(ctx, credentials, path, query, &raw); err != nil {
+			return err
+		}
+		times, count, err := read(raw)
+		if err != nil {
+			return err
+		}
+		for _, t := range times {
+			latest = laterKrakenTime(latest, t)
+			if oldest == "" || laterKrakenTime(t, oldest) == oldest {
+				oldest = t
+			}
+		}
+		offset += len(times)
+		if len(times) == 0 || offset >= count {
+			break
+		}
+	}
+
+	if latest != "" {
+		cursors[key] = latest
+	}
+	delete(cursors, key+":end")
+	delete(cursors, key+":latest")
+	return nil
+}
+
+// post sends a signed private call. API-Sign is the base64 HMAC-SHA512,
+// keyed by the decoded secret, of the path followed by
+// SHA256(nonce + form).
+func (c *krakenClient) post(ctx context.Context, credentials Credentials, path string, params url.Values, out any) error {
+	secret, err := base64.StdEncoding.DecodeString(credentials.APISecret)
+	if err != nil {
+		return fmt.Errorf("%w: the Kraken API secret must be base64", ErrInvalidRequest)
+	}
+
+	resp, err := httpclient.Do(c.httpClient, func() (*http.Request, error) {
+		form := maps.Clone(params)
+		nonce := strconv.FormatInt(c.nonce(), 10)
+		form.Set("nonce", nonce)
+		body := form.Encode()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, strings.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("API-Key", credentials.APIKey)
+		req.Header.Set("API-Sign", krakenSignature(secret, path, nonce, body))
+		return req, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to fetch kraken %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("kraken API error: status %d, body: %s", resp.StatusCode, string(body))
+	}
+
+	var response krakenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return fmt.Errorf("failed to decode kraken %s response: %w", path, err)
+	}
+	if len(response.Error) > 0 {
+		message := strings.Join(response.Error, "; ")
+		for _, code := range response.Error {
+			if strings.HasPrefix(code, "EAPI:Invalid key") || strings.HasPrefix(code, "EAPI:Invalid signature") ||
+				strings.HasPrefix(code, "EGeneral:Permission denied") {
+				return fmt.Errorf("%w: %s", ErrUnauthorized, message)
+			}
+		}
+		return fmt.Errorf("kraken %s failed: %s", path, message)
+	}
+	if err := json.Unmarshal(response.Result, out); err != nil {
+		return fmt.Errorf("failed to decode kraken %s result: %w", path, err)
+	}
+	return nil
+}
+
+// nonce returns a strictly increasing microsecond nonce.
+func (c *krakenClient) nonce() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastNonce = max(c.lastNonce+1, c.now().UnixMicro())
+	return c.lastNonce
+}
+
+func krakenSignature(secret []byte, path, nonce, body string) string {
+	digest := sha256.Sum256([]byte(nonce + body))
+	mac := hmac.New(sha512.New, secret)
+	mac.Write([]byte(path))
+	mac.Write(digest[:])
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// krakenTime converts fractional Unix seconds to a UTC time.
+func krakenTime(seconds float64) time.Time {
+	return time.UnixMilli(int64(math.Round(seconds * 1000))).UTC()
+}
+
+// laterKrakenTime returns the later of two fractional second strings,
+// keeping Kraken's own text so the cursor round-trips exactly.
+func laterKrakenTime(current, candidate string) string {
+	if current == "" {
+		return candidate
+	}
+	a, _ := strconv.ParseFloat(current, 64)
+	b, _ := strconv.ParseFloat(candidate, 64)
+	if b > a {
+		return candidate
+	}
+	return current
+}
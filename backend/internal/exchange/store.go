@@ -0,0 +1,277 @@
+package exchange
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sealer encrypts credentials with AES-256-GCM. The connection id is the
+// additional data, so a sealed secret cannot be moved to another record.
+type sealer struct {
+	aead cipher.AEAD
+}
+
+// newSealer decodes a 32-byte key given as base64 or hex. An empty key
+// returns a nil sealer.
+func newSealer(key string) (*sealer, error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return nil, errors.New("EXCHANGE_CREDENTIALS_KEY is not set")
+	}
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(raw) != 32 {
+		raw, err = hex.DecodeString(key)
+	}
+	if err != nil || len(raw) != 32 {
+		return nil, errors.New("EXCHANGE_CREDENTIALS_KEY must be 32 bytes, base64 or hex encoded")
+	}
+
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &sealer{aead: aead}, nil
+}
+
+func (s *sealer) seal(id string, credentials Credentials) (string, error) {
+	plaintext, err := json.Marshal(credentials)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := s.aead.Seal(nonce, nonce, plaintext, []byte(id))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (s *sealer) open(id, sealed string) (*Credentials, error) {
+	raw, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(raw) < s.aead.NonceSize() {
+		return nil, errors.New("malformed sealed credentials")
+	}
+	nonce, ciphertext := raw[:s.aead.NonceSize()], raw[s.aead.NonceSize():]
+	plaintext, err := s.aead.Open(nil, nonce, ciphertext, []byte(id))
+	if err != nil {
+		return nil, errors.New("cannot decrypt credentials; was EXCHANGE_CREDENTIALS_KEY changed?")
+	}
+	var credentials Credentials
+	if err := json.Unmarshal(plaintext, &credentials); err != nil {
+		return nil, fmt.Errorf("failed to decode credentials: %w", err)
+	}
+	return &credentials, nil
+}
+
+// storedConnection is a connection as written to disk.
+type storedConnection struct {
+	Connection
+	// Sealed holds the AES-GCM sealed credentials, base64 encoded.
+	Sealed string `json:"sealed_credentials"`
+}
+
+type connectionsFile struct {
+	Connections []storedConnection `json:"connections"`
+}
+
+// store keeps connections and their cursors in a JSON file readable only
+// by the server user. Secrets are sealed before they reach the file.
+type store struct {
+	mu          sync.Mutex
+	path        string
+	sealer      *sealer
+	connections map[string]storedConnection
+	loaded      bool
+}
+
+// newStore creates a store at path; without a sealer it is disabled.
+func newStore(path string, sealer *sealer) *store {
+	return &store{path: path, sealer: sealer}
+}
+
+// Enabled reports whether secrets can be sealed.
+func (s *store) Enabled() bool {
+	return s != nil && s.sealer != nil
+}
+
+// Create assigns an id to connection, seals credentials and persists it.
+func (s *store) Create(connection Connection, credentials Credentials) (*Connection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.ensureLoadedLocked(); err != nil {
+		return nil, err
+	}
+
+	id, err := newConnectionID()
+	if err != nil {
+		return nil, err
+	}
+	connection.ID = id
+	sealed, err := s.sealer.seal(id, credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	connections := maps.Clone(s.connections)
+	connections[id] = storedConnection{Connection: connection, Sealed: sealed}
+	if err := s.persistLocked(connections); err != nil {
+		return nil, err
+	}
+	return &connection, nil
+}
+
+// Get returns a connection and its opened credentials.
+func (s *store) Get(id string) (*Connection, *Credentials, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.ensureLoadedLocked(); err != nil {
+		return nil, nil, err
+	}
+
+	stored, found := s.connections[strings.TrimSpace(id)]
+	if !found {
+		return nil, nil, ErrNotFound
+	}
+	credentials, err := s.sealer.open(stored.ID, stored.Sealed)
+	if err != nil {
+		return nil, nil, err
+	}
+	connection := stored.Connection
+	connection.Cursors = maps.Clone(stored.Cursors)
+	return &connection, credentials, nil
+}
+
+// Delete removes a connection.
+func (s *store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.ensureLoadedLocked(); err != nil {
+		return err
+	}
+
+	id = strings.TrimSpace(id)
+	if _, found := s.connections[id]; !found {
+		return ErrNotFound
+	}
+	connections := maps.Clone(s.connections)
+	delete(connections, id)
+	return s.persistLocked(connections)
+}
+
+// SaveCursors replaces the cursors of a connection after a sync.
+func (s *store) SaveCursors(id string, cursors map[string]string, syncedAt time.Time) (*Connection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.ensureLoadedLocked(); err != nil {
+		return nil, err
+	}
+
+	stored, found := s.connections[id]
+	if !found {
+		return nil, ErrNotFound
+	}
+	stored.Cursors = maps.Clone(cursors)
+	stored.SyncedAt = &syncedAt
+
+	connections := maps.Clone(s.connections)
+	connections[id] = stored
+	if err := s.persistLocked(connections); err != nil {
+		return nil, err
+	}
+	connection := stored.Connection
+	return &connection, nil
+}
+
+func (s *store) ensureLoadedLocked() error {
+	if s.loaded {
+		return nil
+	}
+
+	s.connections = make(map[string]storedConnection)
+	bytes, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		s.loaded = true
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read connections file: %w", err)
+	}
+
+	var file connectionsFile
+	if err := json.Unmarshal(bytes, &file); err != nil {
+		return fmt.Errorf("failed to decode connections file: %w", err)
+	}
+	for _, stored := range file.Connections {
+		s.connections[stored.ID] = stored
+	}
+	s.loaded = true
+	return nil
+}
+
+// persistLocked writes connections atomically with owner-only permissions
+// and swaps them in once written.
+func (s *store) persistLocked(connections map[string]storedConnection) error {
+	file := connectionsFile{Connections: make([]storedConnection, 0, len(connections))}
+	for _, stored := range connections {
+		file.Connections = append(file.Connections, stored)
+	}
+	sort.Slice(file.Connections, func(i, j int) bool {
+		return file.Connections[i].ID < file.Connections[j].ID
+	})
+
+	bytes, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal connections: %w", err)
+	}
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create connections directory: %w", err)
+	}
+	tmpFile, err := os.CreateTemp(dir, "exchange_connections_*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create temp connections file: %w", err)
+	}
+	if _, err := tmpFile.Write(bytes); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpFile.Name())
+		return fmt.Errorf("failed to write connections file: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		os.Remove(tmpFile.Name())
+		return fmt.Errorf("failed to close connections file: %w", err)
+	}
+	if err := os.Rename(tmpFile.Name(), s.path); err != nil {
+		os.Remove(tmpFile.Name())
+		return fmt.Errorf("failed to move connections file: %w", err)
+	}
+
+	s.connections = connections
+	return nil
+}
+
+func newConnectionID() (string, error) {
+	raw := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, raw); err != nil {
+		return "", fmt.Errorf("failed to generate connection id: %w", err)
+	}
+	return "conn_" + hex.EncodeToString(raw), nil
+}
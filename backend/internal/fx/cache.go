@@ -11,8 +11,9 @@ const (
 
 // Cache provides thread-safe in-memory caching for FX rates.
 type Cache struct {
-	mu    sync.RWMutex
-	rates *RatesResponse
+	mu         sync.RWMutex
+	rates      *RatesResponse
+	historical *HistoricalRates
 }
 
 // NewCache creates a new in-memory cache.
@@ -52,6 +53,27 @@ func (c *Cache) SetRates(rates *RatesResponse) {
 	c.rates = rates
 }
 
+// GetHistoricalRates retrieves cached historical rates if they haven't
+// expired.
+func (c *Cache) GetHistoricalRates() (*HistoricalRates, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.historical == nil || time.Since(c.historical.UpdatedAt) > ratesTTL {
+		return nil, false
+	}
+	return c.historical, true
+}
+
+// SetHistoricalRates stores historical rates in cache.
+func (c *Cache) SetHistoricalRates(rates *HistoricalRates) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	rates.UpdatedAt = time.Now()
+	c.historical = rates
+}
+
 // cleanupExpired periodically removes expired rates from cache.
 func (c *Cache) cleanupExpired() {
 	ticker := time.NewTicker(30 * time.Minute)
@@ -62,6 +84,9 @@ func (c *Cache) cleanupExpired() {
 		if c.rates != nil && time.Since(c.rates.UpdatedAt) > ratesTTL {
 			c.rates = nil
 		}
+		if c.historical != nil && time.Since(c.historical.UpdatedAt) > ratesTTL {
+			c.historical = nil
+		}
 		c.mu.Unlock()
 	}
 }
@@ -6,14 +6,14 @@ import (
 	"io"
 	"net/http"
 	"time"
+
+	"crypto-portfolio-backend/internal/httpclient"
 )
 
 const (
 	ecbDailyURL    = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-daily.xml"
+	ecbHistoryURL  = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-hist.xml"
 	requestTimeout = 10 * time.Second
-	maxRetries     = 2
-	retryDelay     = 500 * time.Millisecond
-	userAgent      = "crypto-portfolio-backend/1.0"
 )
 
 // ECBClient fetches and parses FX rates from the ECB feed.
@@ -24,9 +24,7 @@ type ECBClient struct {
 // NewECBClient creates a new ECB client.
 func NewECBClient() *ECBClient {
 	return &ECBClient{
-		httpClient: &http.Client{
-			Timeout: requestTimeout,
-		},
+		httpClient: httpclient.New(requestTimeout),
 	}
 }
 
@@ -38,6 +36,14 @@ type ecbCube struct {
 	Cube ecbCubeTime `xml:"Cube"`
 }
 
+type ecbHistoryEnvelope struct {
+	Cube ecbHistoryCube `xml:"Cube"`
+}
+
+type ecbHistoryCube struct {
+	Days []ecbCubeTime `xml:"Cube"`
+}
+
 type ecbCubeTime struct {
 	Time  string        `xml:"time,attr"`
 	Rates []ecbCubeRate `xml:"Cube"`
@@ -50,7 +56,7 @@ type ecbCubeRate struct {
 
 // GetLatestRates fetches and parses the latest ECB daily rates.
 func (c *ECBClient) GetLatestRates() (*RatesResponse, error) {
-	resp, err := c.doRequest()
+	resp, err := c.doRequest(ecbDailyURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch ECB rates: %w", err)
 	}
@@ -80,33 +86,48 @@ func (c *ECBClient) GetLatestRates() (*RatesResponse, error) {
 	}, nil
 }
 
-func (c *ECBClient) doRequest() (*http.Response, error) {
-	var lastErr error
-	for attempt := 0; attempt <= maxRetries; attempt++ {
-		req, err := http.NewRequest(http.MethodGet, ecbDailyURL, nil)
-		if err != nil {
-			return nil, fmt.Errorf("failed to build request: %w", err)
-		}
-		req.Header.Set("User-Agent", userAgent)
-		req.Header.Set("Accept", "application/xml")
+// GetHistoricalRates fetches and parses every ECB reference rate since 1999.
+func (c *ECBClient) GetHistoricalRates() (*HistoricalRates, error) {
+	resp, err := c.doRequest(ecbHistoryURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch ECB historical rates: %w", err)
+	}
+	defer resp.Body.Close()
 
-		resp, err := c.httpClient.Do(req)
-		if err == nil {
-			if resp.StatusCode >= http.StatusInternalServerError {
-				io.Copy(io.Discard, resp.Body)
-				resp.Body.Close()
-				lastErr = fmt.Errorf("ECB API error: status %d", resp.StatusCode)
-			} else {
-				return resp, nil
-			}
-		} else {
-			lastErr = err
-		}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("ECB API error: status %d, body: %s", resp.StatusCode, string(body))
+	}
 
-		if attempt < maxRetries {
-			time.Sleep(retryDelay * time.Duration(attempt+1))
+	var envelope ecbHistoryEnvelope
+	if err := xml.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return nil, fmt.Errorf("failed to decode ECB historical response: %w", err)
+	}
+
+	days := make([]DailyRates, 0, len(envelope.Cube.Days))
+	for _, day := range envelope.Cube.Days {
+		rates := make(map[string]float64, len(day.Rates))
+		for _, rate := range day.Rates {
+			rates[rate.Currency] = rate.Rate
 		}
+		days = append(days, DailyRates{Date: day.Time, Rates: rates})
 	}
 
-	return nil, lastErr
+	return &HistoricalRates{
+		Base:      "EUR",
+		Days:      days,
+		Timestamp: time.Now().UnixMilli(),
+		UpdatedAt: time.Now(),
+	}, nil
+}
+
+func (c *ECBClient) doRequest(url string) (*http.Response, error) {
+	return httpclient.Do(c.httpClient, func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/xml")
+		return req, nil
+	})
 }
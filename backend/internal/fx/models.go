@@ -1,6 +1,24 @@
 package fx
 
-import "time"
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// fiats are the currencies the backend can convert with ECB rates.
+var fiats = map[string]struct{}{
+	"USD": {}, "EUR": {}, "JPY": {}, "GBP": {}, "AUD": {}, "CAD": {}, "CHF": {}, "CNY": {}, "HKD": {}, "KRW": {},
+	"SGD": {}, "NZD": {}, "SEK": {}, "NOK": {}, "DKK": {}, "PLN": {}, "CZK": {}, "HUF": {}, "TRY": {}, "BRL": {},
+	"MXN": {}, "INR": {}, "IDR": {}, "ZAR": {}, "THB": {}, "PHP": {}, "MYR": {}, "ILS": {}, "RON": {}, "BGN": {}, "ISK": {},
+}
+
+// IsFiat reports whether an upper-case currency code is a fiat currency
+// with ECB rates; stablecoins are not.
+func IsFiat(currency string) bool {
+	_, found := fiats[currency]
+	return found
+}
 
 // RatesResponse is returned to clients for FX rates.
 type RatesResponse struct {
@@ -10,3 +28,57 @@ type RatesResponse struct {
 	Cached    bool               `json:"cached"`
 	UpdatedAt time.Time          `json:"-"`
 }
+
+// ExpiresAt returns when the cached rates become stale.
+func (r *RatesResponse) ExpiresAt() time.Time {
+	return r.UpdatedAt.Add(ratesTTL)
+}
+
+// DailyRates are the reference rates published for one day (YYYY-MM-DD).
+type DailyRates struct {
+	Date  string             `json:"date"`
+	Rates map[string]float64 `json:"rates"`
+}
+
+// HistoricalRates are daily reference rates ordered by date.
+type HistoricalRates struct {
+	Base      string       `json:"base"`
+	Days      []DailyRates `json:"days"`
+	Timestamp int64        `json:"timestamp"`
+	UpdatedAt time.Time    `json:"-"`
+}
+
+// RatesOn returns the rates in effect on the day of t: that day's rates or,
+// on weekends and holidays, the last published ones before it.
+func (h *HistoricalRates) RatesOn(t time.Time) (map[string]float64, bool) {
+	if h == nil || len(h.Days) == 0 {
+		return nil, false
+	}
+
+	date := t.UTC().Format(time.DateOnly)
+	index := sort.Search(len(h.Days), func(i int) bool {
+		return h.Days[i].Date > date
+	})
+	if index == 0 {
+		return nil, false
+	}
+	return h.Days[index-1].Rates, true
+}
+
+// Convert converts amount from one currency to another at the rates in
+// effect on the day of t.
+func (h *HistoricalRates) Convert(amount float64, from, to string, t time.Time) (float64, error) {
+	if from == to {
+		return amount, nil
+	}
+
+	rates, found := h.RatesOn(t)
+	if !found {
+		return 0, fmt.Errorf("no FX rates for %s", t.UTC().Format(time.DateOnly))
+	}
+	fromRate, toRate := rates[from], rates[to]
+	if fromRate <= 0 || toRate <= 0 {
+		return 0, fmt.Errorf("no FX rate from %s to %s on %s", from, to, t.UTC().Format(time.DateOnly))
+	}
+	return amount / fromRate * toRate, nil
+}
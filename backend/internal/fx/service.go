@@ -3,6 +3,7 @@ package fx
 import (
 	"fmt"
 	"log"
+	"sort"
 
 	"golang.org/x/sync/singleflight"
 )
@@ -67,6 +68,63 @@ func (s *Service) GetRates() (*RatesResponse, error) {
 	return result.(*RatesResponse), nil
 }
 
+// GetHistoricalRates returns daily USD-based rates since 1999, cached for a
+// day.
+func (s *Service) GetHistoricalRates() (*HistoricalRates, error) {
+	result, err, _ := s.group.Do("fx-historical", func() (interface{}, error) {
+		if cached, found := s.cache.GetHistoricalRates(); found {
+			return cached, nil
+		}
+
+		log.Printf("Cache miss for historical FX rates, fetching from ECB")
+
+		rates, err := s.client.GetHistoricalRates()
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch historical FX rates: %w", err)
+		}
+
+		converted, err := historicalToUSD(rates)
+		if err != nil {
+			return nil, err
+		}
+
+		s.cache.SetHistoricalRates(converted)
+		return converted, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result.(*HistoricalRates), nil
+}
+
+// historicalToUSD rebases every day to USD and orders days by date. Days
+// without a USD rate are dropped.
+func historicalToUSD(rates *HistoricalRates) (*HistoricalRates, error) {
+	days := make([]DailyRates, 0, len(rates.Days))
+	for _, day := range rates.Days {
+		converted, err := convertToUSD(&RatesResponse{Base: rates.Base, Rates: day.Rates})
+		if err != nil {
+			continue
+		}
+		days = append(days, DailyRates{Date: day.Date, Rates: converted.Rates})
+	}
+	if len(days) == 0 {
+		return nil, fmt.Errorf("USD rate missing from ECB historical response")
+	}
+
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].Date < days[j].Date
+	})
+
+	return &HistoricalRates{
+		Base:      "USD",
+		Days:      days,
+		Timestamp: rates.Timestamp,
+		UpdatedAt: rates.UpdatedAt,
+	}, nil
+}
+
 func convertToUSD(rates *RatesResponse) (*RatesResponse, error) {
 	usdRate, ok := rates.Rates["USD"]
 	if !ok || usdRate <= 0 {
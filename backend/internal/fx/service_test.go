@@ -1,6 +1,10 @@
 package fx
 
-import "testing"
+import (
+	"net/http"
+	"testing"
+	"time"
+)
 
 func TestConvertToUSD(t *testing.T) {
 	input := &RatesResponse{
@@ -77,3 +81,52 @@ func TestServiceGetRates_ConvertsCachedBase(t *testing.T) {
 		t.Fatalf("expected cached response to remain cached")
 	}
 }
+
+func TestServiceGetHistoricalRates(t *testing.T) {
+	body := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+	<Cube>
+		<Cube time="2024-01-05"><Cube currency="USD" rate="1.25"/><Cube currency="JPY" rate="150"/></Cube>
+		<Cube time="2024-01-04"><Cube currency="USD" rate="1.0"/><Cube currency="JPY" rate="160"/></Cube>
+	</Cube>
+</gesmes:Envelope>`)
+
+	service := &Service{
+		client: &ECBClient{httpClient: &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			return newXMLResponse(body), nil
+		})}},
+		cache: NewCache(),
+	}
+
+	rates, err := service.GetHistoricalRates()
+	if err != nil {
+		t.Fatalf("GetHistoricalRates failed: %v", err)
+	}
+	if rates.Base != "USD" || len(rates.Days) != 2 || rates.Days[0].Date != "2024-01-04" {
+		t.Fatalf("unexpected historical rates: %+v", rates)
+	}
+
+	tests := []struct {
+		name  string
+		day   time.Time
+		want  float64
+		found bool
+	}{
+		{name: "published day", day: time.Date(2024, 1, 4, 18, 0, 0, 0, time.UTC), want: 160, found: true},
+		{name: "weekend uses friday", day: time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC), want: 120, found: true},
+		{name: "before history", day: time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			day, found := rates.RatesOn(tt.day)
+			if found != tt.found || day["JPY"] != tt.want {
+				t.Fatalf("RatesOn = %v (found %v), want JPY %f", day, found, tt.want)
+			}
+		})
+	}
+
+	converted, err := rates.Convert(100, "EUR", "JPY", time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC))
+	if err != nil || converted != 15000 {
+		t.Fatalf("Convert EUR->JPY = %f, %v", converted, err)
+	}
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"crypto-portfolio-backend/internal/prices"
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+)
+
+const maxAdminBodyBytes = 64 << 10
+
+// AdminHandler handles maintenance endpoints guarded by a bearer token.
+type AdminHandler struct {
+	service *prices.Service
+	token   string
+}
+
+// NewAdminHandler creates a new admin handler. An empty token disables all
+// admin endpoints.
+func NewAdminHandler(service *prices.Service, token string) *AdminHandler {
+	return &AdminHandler{
+		service: service,
+		token:   strings.TrimSpace(token),
+	}
+}
+
+// HandleMappingOverrides handles /admin/cmc/map/overrides
+// GET lists overrides and the change log, POST pins or blocks a mapping,
+// DELETE removes an override.
+// Example: DELETE /admin/cmc/map/overrides?cmc_id=7083&author=kaz&reason=fixed+upstream
+func (h *AdminHandler) HandleMappingOverrides(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+
+	if !h.authorize(w, r) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		response, err := h.service.ListMappingOverrides()
+		if err != nil {
+			log.Printf("Error listing mapping overrides: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeResponse(w, r, jsonFormat, response, nil)
+
+	case http.MethodPost:
+		var override prices.MappingOverride
+		decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAdminBodyBytes))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&override); err != nil {
+			http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		stored, err := h.service.SetMappingOverride(override)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeResponse(w, r, jsonFormat, stored, nil)
+
+	case http.MethodDelete:
+		query := r.URL.Query()
+		removed, err := h.service.RemoveMappingOverride(query.Get("cmc_id"), query.Get("author"), query.Get("reason"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !removed {
+			http.Error(w, "override not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// authorize checks the bearer token and writes an error response when the
+// request is not allowed.
+func (h *AdminHandler) authorize(w http.ResponseWriter, r *http.Request) bool {
+	if h.token == "" {
+		http.NotFound(w, r)
+		return false
+	}
+
+	header := r.Header.Get("Authorization")
+	provided, found := strings.CutPrefix(header, "Bearer ")
+	if !found || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(provided)), []byte(h.token)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cacheValidator derives HTTP validators from the cache entries that make up
+// a response: the ETag hashes every entry key and UpdatedAt, Last-Modified is
+// the newest entry and max-age is the time left before the first entry expires.
+type cacheValidator struct {
+	hash         hash.Hash64
+	lastModified time.Time
+	expiresAt    time.Time
+}
+
+func newCacheValidator() *cacheValidator {
+	return &cacheValidator{hash: fnv.New64a()}
+}
+
+// Add records a cache entry contributing to the response.
+func (v *cacheValidator) Add(key string, updatedAt, expiresAt time.Time) {
+	fmt.Fprintf(v.hash, "%s@%d;", key, updatedAt.UnixNano())
+
+	if updatedAt.After(v.lastModified) {
+		v.lastModified = updatedAt
+	}
+	if !expiresAt.IsZero() && (v.expiresAt.IsZero() || expiresAt.Before(v.expiresAt)) {
+		v.expiresAt = expiresAt
+	}
+}
+
+// AddVariant records the negotiated representation so that JSON and binary
+// bodies of the same entries get distinct ETags.
+func (v *cacheValidator) AddVariant(name string) {
+	fmt.Fprintf(v.hash, "variant:%s;", name)
+}
+
+// AddMissing records a key that has no cache entry (e.g. a per-id error) so
+// the ETag changes once it becomes available.
+func (v *cacheValidator) AddMissing(key string) {
+	fmt.Fprintf(v.hash, "%s@missing;", key)
+}
+
+func (v *cacheValidator) etag() string {
+	return `W/"` + strconv.FormatUint(v.hash.Sum64(), 16) + `"`
+}
+
+// WriteHeaders sets Cache-Control, ETag and Last-Modified and answers
+// conditional requests. It returns true when a 304 was written and the
+// caller must not write a body.
+func (v *cacheValidator) WriteHeaders(w http.ResponseWriter, r *http.Request) bool {
+	etag := v.etag()
+	maxAge := int64(0)
+	if !v.expiresAt.IsZero() {
+		if remaining := time.Until(v.expiresAt); remaining > 0 {
+			maxAge = int64(remaining / time.Second)
+		}
+	}
+
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", maxAge))
+	w.Header().Set("Vary", "Accept, Accept-Encoding")
+	w.Header().Set("ETag", etag)
+	if !v.lastModified.IsZero() {
+		w.Header().Set("Last-Modified", v.lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if !isConditionalMethod(r.Method) {
+		return false
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if etagMatches(inm, etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+		return false
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !v.lastModified.IsZero() {
+		since, err := http.ParseTime(ims)
+		if err == nil && !v.lastModified.Truncate(time.Second).After(since) {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+
+	return false
+}
+
+func isConditionalMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+// etagMatches performs the weak comparison used for If-None-Match.
+func etagMatches(header, etag string) bool {
+	target := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.TrimPrefix(candidate, "W/") == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCacheValidatorNotModified(t *testing.T) {
+	updatedAt := time.Now().Add(-time.Minute)
+	validator := newCacheValidator()
+	validator.Add("latest_prices", updatedAt, updatedAt.Add(5*time.Minute))
+
+	first := httptest.NewRecorder()
+	if validator.WriteHeaders(first, httptest.NewRequest(http.MethodGet, "/prices/latest", nil)) {
+		t.Fatalf("expected unconditional request to need a body")
+	}
+
+	etag := first.Header().Get("ETag")
+	if etag == "" {
+		t.Fatalf("expected ETag header")
+	}
+	if cc := first.Header().Get("Cache-Control"); cc != "public, max-age=239" && cc != "public, max-age=240" {
+		t.Fatalf("unexpected Cache-Control: %s", cc)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/prices/latest", nil)
+	req.Header.Set("If-None-Match", `"other", `+etag)
+	second := httptest.NewRecorder()
+	if !validator.WriteHeaders(second, req) || second.Code != http.StatusNotModified {
+		t.Fatalf("expected 304 for matching If-None-Match, got %d", second.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/prices/latest", nil)
+	req.Header.Set("If-Modified-Since", time.Now().UTC().Format(http.TimeFormat))
+	third := httptest.NewRecorder()
+	if !validator.WriteHeaders(third, req) {
+		t.Fatalf("expected 304 for If-Modified-Since after last update")
+	}
+}
+
+func TestCacheValidatorETagChangesWithEntries(t *testing.T) {
+	updatedAt := time.Now()
+
+	a := newCacheValidator()
+	a.Add("bitcoin", updatedAt, updatedAt.Add(time.Hour))
+	a.AddMissing("ethereum")
+
+	b := newCacheValidator()
+	b.Add("bitcoin", updatedAt, updatedAt.Add(time.Hour))
+	b.Add("ethereum", updatedAt, updatedAt.Add(time.Hour))
+
+	if a.etag() == b.etag() {
+		t.Fatalf("expected ETag to change when a missing entry becomes available")
+	}
+}
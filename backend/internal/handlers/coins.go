@@ -0,0 +1,381 @@
+package handlers
+
+import (
+	"crypto-portfolio-backend/internal/prices"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	coinImageMaxAge          = 24 * time.Hour
+	coinImageImmutableMaxAge = 365 * 24 * time.Hour
+)
+
+// HandleSearchCoins handles GET /coins/search
+// Example: /coins/search?q=eth&limit=10
+func (h *PriceHandler) HandleSearchCoins(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		http.Error(w, "q query parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	limit := 0
+	if limitParam := strings.TrimSpace(r.URL.Query().Get("limit")); limitParam != "" {
+		value, err := strconv.Atoi(limitParam)
+		if err != nil || value <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = value
+	}
+
+	log.Printf("Searching coins for %q", query)
+
+	results, err := h.service.SearchCoins(query, limit)
+	if err != nil {
+		log.Printf("Error searching coins: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	validator := newCacheValidator()
+	validator.Add("coins_search", results.SourcesUpdatedAt, time.Time{})
+	if validator.WriteHeaders(w, r) {
+		return
+	}
+
+	writeResponse(w, r, jsonFormat, results, nil)
+}
+
+// HandleResolveSymbols handles GET /coins/resolve
+// Example: /coins/resolve?symbols=BTC,ETH,UNI
+func (h *PriceHandler) HandleResolveSymbols(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	symbolsParam := strings.TrimSpace(r.URL.Query().Get("symbols"))
+	if symbolsParam == "" {
+		http.Error(w, "symbols query parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("Resolving symbols: %s", symbolsParam)
+
+	resolved, err := h.service.ResolveSymbols(strings.Split(symbolsParam, ","))
+	if err != nil {
+		if errors.Is(err, prices.ErrInvalidRequest) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Error resolving symbols: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	validator := newCacheValidator()
+	validator.Add("coins_resolve", resolved.SourcesUpdatedAt, time.Time{})
+	if validator.WriteHeaders(w, r) {
+		return
+	}
+
+	writeResponse(w, r, jsonFormat, resolved, nil)
+}
+
+// HandleGetCoinDetail handles GET /coins/{id}
+// Accepts a CoinGecko id or a numeric CMC id.
+// Example: /coins/bitcoin, /coins/1027
+func (h *PriceHandler) HandleGetCoinDetail(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := strings.TrimSpace(r.PathValue("id"))
+	if !isValidCoinID(id) {
+		http.Error(w, "invalid coin id", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("Fetching coin detail for %s", id)
+
+	detail, err := h.service.GetCoinDetail(id)
+	if err != nil {
+		if errors.Is(err, prices.ErrUnknownCoin) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		log.Printf("Error fetching coin detail: %v", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	validator := newCacheValidator()
+	validator.Add(detail.Coin.ID, detail.UpdatedAt, detail.ExpiresAt())
+	if validator.WriteHeaders(w, r) {
+		return
+	}
+
+	writeResponse(w, r, jsonFormat, detail, nil)
+}
+
+// HandleGetCoinImage handles GET /coins/{id}/image
+// Serves a resized logo. Requests carrying the current content hash as v are
+// immutable and cached for a year.
+// Example: /coins/bitcoin/image?size=64, /coins/1/image?size=128&v=<hash>
+func (h *PriceHandler) HandleGetCoinImage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := strings.TrimSpace(r.PathValue("id"))
+	if !isValidCoinID(id) {
+		http.Error(w, "invalid coin id", http.StatusBadRequest)
+		return
+	}
+
+	size := prices.DefaultCoinImageSize
+	if sizeParam := strings.TrimSpace(r.URL.Query().Get("size")); sizeParam != "" {
+		value, err := strconv.Atoi(sizeParam)
+		if err != nil || !prices.IsCoinImageSize(value) {
+			http.Error(w, fmt.Sprintf("size must be one of %v", prices.CoinImageSizes), http.StatusBadRequest)
+			return
+		}
+		size = value
+	}
+
+	image, err := h.service.GetCoinImage(id, size)
+	if err != nil {
+		if errors.Is(err, prices.ErrUnknownCoin) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		log.Printf("Error fetching coin image: %v", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	etag := `"` + image.Hash + `"`
+	if r.URL.Query().Get("v") == image.Hash {
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d, immutable", int64(coinImageImmutableMaxAge/time.Second)))
+	} else {
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int64(coinImageMaxAge/time.Second)))
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", image.UpdatedAt.UTC().Format(http.TimeFormat))
+	w.Header().Set("Content-Location", fmt.Sprintf("/coins/%s/image?size=%d&v=%s", url.PathEscape(id), size, image.Hash))
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" && etagMatches(inm, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", image.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(image.Data)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		w.Write(image.Data)
+	}
+}
+
+// HandleLookupContract handles GET /coins/by-contract
+// Example: /coins/by-contract?chain=ethereum&address=0x1f9840a85d5af5bf1d1762f925bdaddc4201f984
+func (h *PriceHandler) HandleLookupContract(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	chain := strings.TrimSpace(r.URL.Query().Get("chain"))
+	address := strings.TrimSpace(r.URL.Query().Get("address"))
+	if chain == "" || address == "" {
+		http.Error(w, "chain and address query parameters are required", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("Looking up contract %s on %s", address, chain)
+
+	result, err := h.service.LookupContract(chain, address)
+	if err != nil {
+		log.Printf("Error looking up contract: %v", err)
+		status := http.StatusBadRequest
+		if errors.Is(err, prices.ErrContractIndexUnavailable) {
+			status = http.StatusServiceUnavailable
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if len(result.Matches) == 0 {
+		http.Error(w, "no coin found for contract", http.StatusNotFound)
+		return
+	}
+
+	validator := newCacheValidator()
+	validator.Add("coins_by_contract", result.SourcesUpdatedAt, time.Time{})
+	if validator.WriteHeaders(w, r) {
+		return
+	}
+
+	writeResponse(w, r, jsonFormat, result, nil)
+}
+
+// HandleGetCMCMap handles GET /cmc/map
+// Returns the CMC->CoinGecko mapping, optionally filtered.
+// Example: /cmc/map, /cmc/map?cmc_ids=1,1027&coingecko_ids=solana&symbols=UNI
+func (h *PriceHandler) HandleGetCMCMap(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	cmcIDs := splitListParam(query.Get("cmc_ids"))
+	coinGeckoIDs := splitListParam(query.Get("coingecko_ids"))
+	symbols := splitListParam(query.Get("symbols"))
+
+	mapping, err := h.service.GetCMCMap(cmcIDs, coinGeckoIDs, symbols)
+	if err != nil {
+		log.Printf("Error fetching CMC mapping: %v", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	validator := newCacheValidator()
+	validator.Add("cmc_map", mapping.UpdatedAt, mapping.ExpiresAt())
+	validator.Add("assets", mapping.AssetsUpdatedAt, time.Time{})
+	if validator.WriteHeaders(w, r) {
+		return
+	}
+
+	writeResponse(w, r, jsonFormat, mapping, nil)
+}
+
+// HandleGetAssets handles GET /assets
+// Returns the unified asset registry, optionally limited to ids given as
+// asset, CMC or CoinGecko ids.
+// Example: /assets, /assets?ids=ast_1,1027,solana
+func (h *PriceHandler) HandleGetAssets(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	assets, err := h.service.GetAssets(splitListParam(r.URL.Query().Get("ids")))
+	if err != nil {
+		log.Printf("Error fetching assets: %v", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	validator := newCacheValidator()
+	validator.Add("assets", assets.UpdatedAt, assets.ExpiresAt())
+	if validator.WriteHeaders(w, r) {
+		return
+	}
+
+	writeResponse(w, r, jsonFormat, assets, nil)
+}
+
+// splitListParam splits a comma-separated query value, dropping blanks.
+func splitListParam(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// isValidCoinID accepts CoinGecko slugs and numeric CMC ids.
+func isValidCoinID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-' || r == '_' || r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
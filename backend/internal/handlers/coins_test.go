@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"crypto-portfolio-backend/internal/prices"
+)
+
+func TestGetCoinDetail(t *testing.T) {
+	dir := t.TempDir()
+	meta := prices.NewMetaStore(filepath.Join(dir, "coins_meta.json"))
+	if err := meta.Set(&prices.CoinMetaResponse{Coins: []prices.CoinMeta{{ID: "bitcoin", Symbol: "btc", Name: "Bitcoin"}}, UpdatedAt: time.Now()}); err != nil {
+		t.Fatalf("seed metadata: %v", err)
+	}
+	details := prices.NewCoinDetailStore(filepath.Join(dir, "coin_details.json"))
+	if err := details.Set("bitcoin", &prices.CoinDetailResponse{Coin: prices.CoinDetail{ID: "bitcoin", Symbol: "BTC"}}); err != nil {
+		t.Fatalf("seed details: %v", err)
+	}
+	handler := NewPriceHandler(prices.NewService(prices.Config{
+		MetaPath:       filepath.Join(dir, "coins_meta.json"),
+		CoinDetailPath: filepath.Join(dir, "coin_details.json"),
+		CMCMapPath:     filepath.Join(dir, "cmc_coingecko_map.json"),
+	}))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/coins/{id}", handler.HandleGetCoinDetail)
+
+	for _, tt := range []struct {
+		path string
+		want int
+	}{
+		{"/coins/bitcoin", http.StatusOK},
+		{"/coins/not-a-coin", http.StatusNotFound},
+		{"/coins/bad%20id", http.StatusBadRequest},
+	} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.path, rec.Code, tt.want, rec.Body.String())
+		}
+	}
+}
+
+func TestGetCoinImageUnknownID(t *testing.T) {
+	dir := t.TempDir()
+	meta := prices.NewMetaStore(filepath.Join(dir, "coins_meta.json"))
+	if err := meta.Set(&prices.CoinMetaResponse{Coins: []prices.CoinMeta{{ID: "bitcoin", Symbol: "btc", Name: "Bitcoin"}}, UpdatedAt: time.Now()}); err != nil {
+		t.Fatalf("seed metadata: %v", err)
+	}
+	handler := NewPriceHandler(prices.NewService(prices.Config{
+		MetaPath:   filepath.Join(dir, "coins_meta.json"),
+		CMCMapPath: filepath.Join(dir, "cmc_coingecko_map.json"),
+		ImageDir:   filepath.Join(dir, "images"),
+	}))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/coins/{id}/image", handler.HandleGetCoinImage)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/coins/not-a-coin/image?size=64", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404 (%s)", rec.Code, rec.Body.String())
+	}
+}
+
+func TestGetCMCMapETagCoversAssetIDs(t *testing.T) {
+	dir := t.TempDir()
+	if err := prices.NewCMCMapStore(filepath.Join(dir, "cmc_coingecko_map.json")).Set([]prices.CMCMappingEntry{
+		{CMCID: "1", Symbol: "BTC", Name: "Bitcoin", CoinGeckoID: "bitcoin"},
+	}); err != nil {
+		t.Fatalf("seed mapping: %v", err)
+	}
+	config := prices.Config{
+		MetaPath:   filepath.Join(dir, "coins_meta.json"),
+		CMCMapPath: filepath.Join(dir, "cmc_coingecko_map.json"),
+		AssetsPath: filepath.Join(dir, "assets.json"),
+	}
+	etag := func() string {
+		rec := httptest.NewRecorder()
+		NewPriceHandler(prices.NewService(config)).HandleGetCMCMap(rec, httptest.NewRequest(http.MethodGet, "/cmc/map", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d (%s)", rec.Code, rec.Body.String())
+		}
+		return rec.Header().Get("ETag")
+	}
+
+	before := etag()
+	if before != etag() {
+		t.Fatal("expected a stable ETag while nothing changes")
+	}
+
+	// New metadata changes the asset registry but not the mapping file.
+	meta := prices.NewMetaStore(config.MetaPath)
+	if err := meta.Set(&prices.CoinMetaResponse{Coins: []prices.CoinMeta{{ID: "ethereum", Symbol: "eth", Name: "Ethereum"}}, UpdatedAt: time.Now()}); err != nil {
+		t.Fatalf("seed metadata: %v", err)
+	}
+	if after := etag(); after == before {
+		t.Errorf("ETag %s did not change with the asset registry", after)
+	}
+}
+
+func TestSearchCoinsConditionalRequest(t *testing.T) {
+	dir := t.TempDir()
+	meta := prices.NewMetaStore(filepath.Join(dir, "coins_meta.json"))
+	if err := meta.Set(&prices.CoinMetaResponse{Coins: []prices.CoinMeta{{ID: "bitcoin", Symbol: "btc", Name: "Bitcoin"}}, UpdatedAt: time.Now()}); err != nil {
+		t.Fatalf("seed metadata: %v", err)
+	}
+	handler := NewPriceHandler(prices.NewService(prices.Config{
+		MetaPath:   filepath.Join(dir, "coins_meta.json"),
+		CMCMapPath: filepath.Join(dir, "cmc_coingecko_map.json"),
+	}))
+	search := func(etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/coins/search?q=btc", nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rec := httptest.NewRecorder()
+		handler.HandleSearchCoins(rec, req)
+		return rec
+	}
+
+	rec := search("")
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || etag == "" {
+		t.Fatalf("status = %d, ETag = %q (%s)", rec.Code, etag, rec.Body.String())
+	}
+	if rec := search(etag); rec.Code != http.StatusNotModified {
+		t.Errorf("unchanged metadata: status = %d, want 304", rec.Code)
+	}
+}
+
+func TestResolveSymbolsRejectsTooManySymbols(t *testing.T) {
+	dir := t.TempDir()
+	handler := NewPriceHandler(prices.NewService(prices.Config{
+		MetaPath:   filepath.Join(dir, "coins_meta.json"),
+		CMCMapPath: filepath.Join(dir, "cmc_coingecko_map.json"),
+	}))
+
+	symbols := strings.Repeat("BTC,", 200) + "ETH"
+	rec := httptest.NewRecorder()
+	handler.HandleResolveSymbols(rec, httptest.NewRequest(http.MethodGet, "/coins/resolve?symbols="+symbols, nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400 (%s)", rec.Code, rec.Body.String())
+	}
+}
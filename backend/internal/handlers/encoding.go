@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/klauspost/compress/zstd"
+)
+
+// minCompressSize skips compression for payloads where the framing overhead
+// outweighs the savings.
+const minCompressSize = 512
+
+// responseFormat is a body serialization selected from the Accept header.
+type responseFormat struct {
+	name        string
+	contentType string
+	marshal     func(any) ([]byte, error)
+	raw         func([]byte) any
+}
+
+var (
+	jsonFormat = responseFormat{
+		name:        "json",
+		contentType: "application/json",
+		marshal:     json.Marshal,
+		raw:         func(b []byte) any { return json.RawMessage(b) },
+	}
+	cborFormat = responseFormat{
+		name:        "cbor",
+		contentType: "application/cbor",
+		marshal:     cborEncMode.Marshal,
+		raw:         func(b []byte) any { return cbor.RawMessage(b) },
+	}
+)
+
+var cborEncMode = mustCBOREncMode()
+
+var zstdEncoder = mustZstdEncoder()
+
+func mustCBOREncMode() cbor.EncMode {
+	mode, err := cbor.EncOptions{ShortestFloat: cbor.ShortestFloat16}.EncMode()
+	if err != nil {
+		panic(fmt.Sprintf("failed to build CBOR encoder: %v", err))
+	}
+	return mode
+}
+
+func mustZstdEncoder() *zstd.Encoder {
+	encoder, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault))
+	if err != nil {
+		panic(fmt.Sprintf("failed to build zstd encoder: %v", err))
+	}
+	return encoder
+}
+
+// payloadMemo is implemented by cache entries that keep their encoded bytes.
+type payloadMemo interface {
+	EncodedPayload(variant string, encode func() ([]byte, error)) ([]byte, error)
+}
+
+// negotiateFormat picks CBOR when the client prefers it and binary bodies are
+// supported for the endpoint, JSON otherwise.
+func negotiateFormat(r *http.Request, allowBinary bool) responseFormat {
+	if !allowBinary {
+		return jsonFormat
+	}
+
+	best := jsonFormat
+	bestQ := -1.0
+	for _, accepted := range parseQualityList(r.Header.Get("Accept")) {
+		mediaType, _, err := mime.ParseMediaType(accepted.value)
+		if err != nil {
+			continue
+		}
+
+		var format responseFormat
+		switch mediaType {
+		case "application/cbor":
+			format = cborFormat
+		case "application/json", "application/*", "*/*":
+			format = jsonFormat
+		default:
+			continue
+		}
+
+		if accepted.q > bestQ {
+			best = format
+			bestQ = accepted.q
+		}
+	}
+
+	return best
+}
+
+// negotiateEncoding picks zstd or gzip from Accept-Encoding, preferring zstd
+// on equal quality. An empty string means identity.
+func negotiateEncoding(r *http.Request) string {
+	best := ""
+	bestQ := 0.0
+	for _, accepted := range parseQualityList(r.Header.Get("Accept-Encoding")) {
+		encoding := strings.ToLower(accepted.value)
+		if encoding != "zstd" && encoding != "gzip" {
+			continue
+		}
+		if accepted.q <= 0 {
+			continue
+		}
+		if accepted.q > bestQ || (accepted.q == bestQ && encoding == "zstd") {
+			best = encoding
+			bestQ = accepted.q
+		}
+	}
+	return best
+}
+
+type qualityValue struct {
+	value string
+	q     float64
+}
+
+func parseQualityList(header string) []qualityValue {
+	if header == "" {
+		return nil
+	}
+
+	values := make([]qualityValue, 0, 4)
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		value := strings.TrimSpace(fields[0])
+		if value == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+			if parsed, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+				q = parsed
+			}
+		}
+
+		values = append(values, qualityValue{value: value, q: q})
+	}
+	return values
+}
+
+func compressPayload(encoding string, body []byte) ([]byte, error) {
+	switch encoding {
+	case "zstd":
+		return zstdEncoder.EncodeAll(body, make([]byte, 0, len(body)/2)), nil
+	case "gzip":
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		if _, err := writer.Write(body); err != nil {
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return body, nil
+	}
+}
+
+// encodePayload serializes payload in format, reusing memoized bytes from the
+// cache entry when memo is set.
+func encodePayload(format responseFormat, payload any, memo payloadMemo) ([]byte, error) {
+	if memo == nil {
+		return format.marshal(payload)
+	}
+	return memo.EncodedPayload(format.name, func() ([]byte, error) {
+		return format.marshal(payload)
+	})
+}
+
+// writeResponse writes payload in the negotiated format and content encoding.
+// When memo is set the compressed bytes are memoized on the cache entry too.
+func writeResponse(w http.ResponseWriter, r *http.Request, format responseFormat, payload any, memo payloadMemo) {
+	encoding := negotiateEncoding(r)
+
+	body, err := encodePayload(format, payload, memo)
+	if err == nil {
+		switch {
+		case encoding == "" || len(body) < minCompressSize:
+			encoding = ""
+		case memo != nil:
+			raw := body
+			body, err = memo.EncodedPayload(format.name+"|"+encoding, func() ([]byte, error) {
+				return compressPayload(encoding, raw)
+			})
+		default:
+			body, err = compressPayload(encoding, body)
+		}
+	}
+
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.contentType)
+	if encoding != "" {
+		w.Header().Set("Content-Encoding", encoding)
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	if _, err := w.Write(body); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
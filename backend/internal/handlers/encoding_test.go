@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"gzip, deflate, br, zstd", "zstd"},
+		{"zstd;q=0.5, gzip", "gzip"},
+		{"zstd;q=0, identity", ""},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Encoding", tt.header)
+		if got := negotiateEncoding(req); got != tt.want {
+			t.Fatalf("negotiateEncoding(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestNegotiateFormat(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "application/json;q=0.5, application/cbor")
+	if got := negotiateFormat(req, true); got.name != "cbor" {
+		t.Fatalf("expected cbor, got %s", got.name)
+	}
+	if got := negotiateFormat(req, false); got.name != "json" {
+		t.Fatalf("expected json when binary is not allowed, got %s", got.name)
+	}
+}
+
+func TestWriteResponseCompressesAndEncodes(t *testing.T) {
+	payload := map[string]string{"data": strings.Repeat("bitcoin ", 200)}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	writeResponse(rec, req, jsonFormat, payload, nil)
+
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected gzip encoding, got %q", rec.Header().Get("Content-Encoding"))
+	}
+	reader, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("gzip reader: %v", err)
+	}
+	body, _ := io.ReadAll(reader)
+	if !strings.Contains(string(body), "bitcoin") {
+		t.Fatalf("unexpected gzip body: %s", body)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "zstd")
+	rec = httptest.NewRecorder()
+	writeResponse(rec, req, cborFormat, payload, nil)
+
+	if rec.Header().Get("Content-Type") != "application/cbor" {
+		t.Fatalf("expected cbor content type, got %q", rec.Header().Get("Content-Type"))
+	}
+	decoder, err := zstd.NewReader(nil)
+	if err != nil {
+		t.Fatalf("zstd reader: %v", err)
+	}
+	defer decoder.Close()
+	raw, err := decoder.DecodeAll(rec.Body.Bytes(), nil)
+	if err != nil {
+		t.Fatalf("zstd decode: %v", err)
+	}
+	var decoded map[string]string
+	if err := cbor.Unmarshal(raw, &decoded); err != nil {
+		t.Fatalf("cbor decode: %v", err)
+	}
+	if decoded["data"] != payload["data"] {
+		t.Fatalf("cbor round trip mismatch")
+	}
+}
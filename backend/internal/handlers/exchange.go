@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"crypto-portfolio-backend/internal/exchange"
+)
+
+const maxExchangeBody = 16 << 10
+
+// connectionIDHeader carries the connection id of GET and DELETE
+// /exchange/connections. The id is the bearer of the connection, so it is
+// kept out of URLs, which end up in logs and browser history.
+const connectionIDHeader = "X-Connection-ID"
+
+// ExchangeHandler manages read-only exchange API connections and syncs
+// them.
+type ExchangeHandler struct {
+	service *exchange.Service
+}
+
+// NewExchangeHandler creates a new exchange handler.
+func NewExchangeHandler(service *exchange.Service) *ExchangeHandler {
+	return &ExchangeHandler{service: service}
+}
+
+// syncRequest is the body of POST /exchange/sync.
+type syncRequest struct {
+	ID    string `json:"id"`
+	Reset bool   `json:"reset"`
+}
+
+// HandleConnections handles /exchange/connections
+// POST stores a verified read-only key:
+// {"exchange":"binance","api_key":"...","api_secret":"...","symbols":["BTCUSDT"]}
+// GET returns the connection named by the X-Connection-ID header and its
+// cursors, DELETE removes it.
+func (h *ExchangeHandler) HandleConnections(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+connectionIDHeader)
+	w.Header().Set("Cache-Control", "no-store")
+
+	switch r.Method {
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusOK)
+
+	case http.MethodPost:
+		var request exchange.ConnectRequest
+		if !decodeJSONRequest(w, r, &request, maxExchangeBody) {
+			return
+		}
+		connection, err := h.service.Connect(r.Context(), request)
+		if err != nil {
+			writeExchangeError(w, "connecting exchange", err)
+			return
+		}
+		log.Printf("Connected exchange (exchange=%s, symbols=%d)", connection.Exchange, len(connection.Symbols))
+		writeResponse(w, r, jsonFormat, connection, nil)
+
+	case http.MethodGet:
+		connection, err := h.service.Get(r.Header.Get(connectionIDHeader))
+		if err != nil {
+			writeExchangeError(w, "reading exchange connection", err)
+			return
+		}
+		writeResponse(w, r, jsonFormat, connection, nil)
+
+	case http.MethodDelete:
+		if err := h.service.Delete(r.Header.Get(connectionIDHeader)); err != nil {
+			writeExchangeError(w, "deleting exchange connection", err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleSync handles POST /exchange/sync
+// Body: {"id":"conn_...","reset":false}
+func (h *ExchangeHandler) HandleSync(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request syncRequest
+	if !decodeJSONRequest(w, r, &request, maxExchangeBody) {
+		return
+	}
+	extendWriteDeadline(w, exchange.MaxSyncDuration+importWriteMargin)
+
+	result, err := h.service.Sync(r.Context(), request.ID, request.Reset)
+	if err != nil {
+		writeExchangeError(w, "syncing exchange", err)
+		return
+	}
+
+	log.Printf("Synced exchange (exchange=%s, transactions=%d, transfers=%d, errors=%d)", result.Connection.Exchange, len(result.Transactions), len(result.Transfers), len(result.Errors))
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeResponse(w, r, jsonFormat, result, nil)
+}
+
+// writeExchangeError answers 503 while sync is disabled, 404 for unknown
+// connections, 400 for invalid requests, 403 for keys the exchange rejects
+// or that are not read-only, 504 when a sync outlives
+// exchange.MaxSyncDuration and 502 when the exchange cannot be reached.
+// Nothing is written once the client has gone away.
+func writeExchangeError(w http.ResponseWriter, action string, err error) {
+	switch {
+	case errors.Is(err, exchange.ErrDisabled):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case errors.Is(err, exchange.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, exchange.ErrUnauthorized):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, exchange.ErrInvalidRequest):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, context.Canceled):
+		log.Printf("Stopped %s: client went away", action)
+	case errors.Is(err, context.DeadlineExceeded):
+		log.Printf("Error %s: %v", action, err)
+		http.Error(w, fmt.Sprintf("Exchange sync took longer than %s", exchange.MaxSyncDuration), http.StatusGatewayTimeout)
+	default:
+		log.Printf("Error %s: %v", action, err)
+		http.Error(w, "Exchange API unavailable", http.StatusBadGateway)
+	}
+}
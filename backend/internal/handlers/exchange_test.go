@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"crypto-portfolio-backend/internal/exchange"
+)
+
+func TestExchangeConnections(t *testing.T) {
+	// Kraken accepts keys named "good" and rejects the rest.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("API-Key") != "good" {
+			w.Write([]byte(`{"error":["EAPI:Invalid key"]}`))
+			return
+		}
+		w.Write([]byte(`{"error":[],"result":{"trades":{},"ledger":{},"count":0}}`))
+	}))
+	defer server.Close()
+	handler := NewExchangeHandler(exchange.NewService(exchange.Config{
+		StorePath:      filepath.Join(t.TempDir(), "exchange_connections.json"),
+		CredentialsKey: base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef")),
+		BaseURLs:       map[string]string{exchange.Kraken: server.URL},
+	}))
+	secret := base64.StdEncoding.EncodeToString([]byte("secret"))
+	connect := func(key string) *httptest.ResponseRecorder {
+		body := `{"exchange":"kraken","api_key":"` + key + `","api_secret":"` + secret + `"}`
+		rec := httptest.NewRecorder()
+		handler.HandleConnections(rec, httptest.NewRequest(http.MethodPost, "/exchange/connections", strings.NewReader(body)))
+		return rec
+	}
+
+	if rec := connect("bad"); rec.Code != http.StatusForbidden {
+		t.Errorf("rejected key: status = %d, want 403 (%s)", rec.Code, rec.Body.String())
+	}
+
+	rec := connect("good")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("connect: status = %d (%s)", rec.Code, rec.Body.String())
+	}
+	var connection exchange.Connection
+	if err := json.Unmarshal(rec.Body.Bytes(), &connection); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		method string
+		target string
+		header string
+		want   int
+	}{
+		{"id in the header", http.MethodGet, "/exchange/connections", connection.ID, http.StatusOK},
+		{"id in the query", http.MethodGet, "/exchange/connections?id=" + connection.ID, "", http.StatusNotFound},
+		{"delete", http.MethodDelete, "/exchange/connections", connection.ID, http.StatusNoContent},
+		{"deleted", http.MethodGet, "/exchange/connections", connection.ID, http.StatusNotFound},
+	} {
+		req := httptest.NewRequest(tt.method, tt.target, nil)
+		if tt.header != "" {
+			req.Header.Set(connectionIDHeader, tt.header)
+		}
+		rec := httptest.NewRecorder()
+		handler.HandleConnections(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, rec.Code, tt.want, rec.Body.String())
+		}
+	}
+}
@@ -2,7 +2,6 @@ package handlers
 
 import (
 	"crypto-portfolio-backend/internal/fx"
-	"encoding/json"
 	"log"
 	"net/http"
 )
@@ -44,10 +43,11 @@ func (h *FXHandler) HandleGetRates(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(rates); err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	validator := newCacheValidator()
+	validator.Add("fx_rates", rates.UpdatedAt, rates.ExpiresAt())
+	if validator.WriteHeaders(w, r) {
 		return
 	}
+
+	writeResponse(w, r, jsonFormat, rates, nil)
 }
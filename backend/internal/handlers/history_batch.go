@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"crypto-portfolio-backend/internal/prices"
+
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	maxHistoryBatchItems    = 250
+	maxHistoryBatchBody     = 1 << 20
+	historyBatchConcurrency = 8
+)
+
+// historyBatchSource serves the items of a history batch from cache.
+type historyBatchSource interface {
+	ResolveCMCID(cmcID string) (string, error)
+	GetHistoryRangeCachedOnly(id, days, interval string, from, to int64) (*prices.HistoryResponse, error)
+}
+
+// historyBatchItem is a single entry of a POST /prices/history/batch body.
+type historyBatchItem struct {
+	Key      string `json:"key,omitempty"`
+	ID       string `json:"id,omitempty"`
+	CMCID    string `json:"cmc_id,omitempty"`
+	Days     string `json:"days,omitempty"`
+	Interval string `json:"interval,omitempty"`
+	From     int64  `json:"from,omitempty"`
+	To       int64  `json:"to,omitempty"`
+}
+
+type historyBatchRequest struct {
+	Items []historyBatchItem `json:"items"`
+}
+
+// handlePostHistoryBatch handles POST /prices/history/batch
+// Body: [{"cmc_id":"1","days":"365"},{"id":"ethereum","days":"30","from":1700000000000}]
+// Each item is keyed by key, id or cmc_id in the histories/errors maps.
+func (h *PriceHandler) handlePostHistoryBatch(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxHistoryBatchBody)
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Accept either a bare list of items or an {"items": [...]} object.
+	var request historyBatchRequest
+	var err error
+	if trimmed := strings.TrimSpace(string(raw)); strings.HasPrefix(trimmed, "[") {
+		err = json.Unmarshal(raw, &request.Items)
+	} else {
+		err = json.Unmarshal(raw, &request)
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if len(request.Items) == 0 {
+		http.Error(w, "items cannot be empty", http.StatusBadRequest)
+		return
+	}
+	if len(request.Items) > maxHistoryBatchItems {
+		http.Error(w, fmt.Sprintf("at most %d items are allowed", maxHistoryBatchItems), http.StatusBadRequest)
+		return
+	}
+
+	type batchJob struct {
+		key     string
+		item    historyBatchItem
+		history *prices.HistoryResponse
+		err     error
+	}
+	errors := make(map[string]string)
+	jobs := make([]batchJob, 0, len(request.Items))
+	seen := make(map[string]struct{}, len(request.Items))
+
+	for index, item := range request.Items {
+		item.ID = strings.TrimSpace(item.ID)
+		item.CMCID = strings.TrimSpace(item.CMCID)
+		item.Days = strings.TrimSpace(item.Days)
+		item.Interval = strings.TrimSpace(item.Interval)
+
+		key := strings.TrimSpace(item.Key)
+		if key == "" {
+			key = item.ID
+		}
+		if key == "" {
+			key = item.CMCID
+		}
+		if key == "" {
+			key = fmt.Sprintf("#%d", index)
+		}
+
+		if _, duplicate := seen[key]; duplicate {
+			errors[key] = "duplicate item key"
+			continue
+		}
+		seen[key] = struct{}{}
+
+		if err := validateHistoryBatchItem(&item); err != nil {
+			errors[key] = err.Error()
+			continue
+		}
+		jobs = append(jobs, batchJob{key: key, item: item})
+	}
+
+	// Each goroutine only writes its own job; results are merged after Wait.
+	var group errgroup.Group
+	group.SetLimit(historyBatchConcurrency)
+	for i := range jobs {
+		job := &jobs[i]
+		group.Go(func() error {
+			job.history, job.err = h.fetchHistoryBatchItem(job.item)
+			return nil
+		})
+	}
+	group.Wait()
+
+	histories := make(map[string]*prices.HistoryResponse, len(jobs))
+	for _, job := range jobs {
+		if job.err != nil {
+			errors[job.key] = job.err.Error()
+			continue
+		}
+		histories[job.key] = job.history
+	}
+
+	log.Printf("Fetched history batch from cache (items=%d, errors=%d)", len(request.Items), len(errors))
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeHistoryBatch(w, r, negotiateFormat(r, true), histories, errors)
+}
+
+func validateHistoryBatchItem(item *historyBatchItem) error {
+	if item.ID == "" && item.CMCID == "" {
+		return fmt.Errorf("id or cmc_id is required")
+	}
+	if item.ID != "" && item.CMCID != "" {
+		return fmt.Errorf("only one of id or cmc_id may be set")
+	}
+
+	if item.Days == "" {
+		if item.From > 0 || item.To > 0 {
+			item.Days = "365"
+		} else {
+			item.Days = "7"
+		}
+	}
+	if !isValidDays(item.Days) {
+		return fmt.Errorf("days must be one of: 7,30,90,365")
+	}
+	// Only daily history is cached; other intervals would silently come
+	// back daily.
+	switch strings.ToLower(item.Interval) {
+	case "", "daily":
+		item.Interval = "daily"
+	default:
+		return fmt.Errorf("interval must be daily")
+	}
+
+	if item.From < 0 || item.To < 0 {
+		return fmt.Errorf("from and to must be unix milliseconds")
+	}
+	if item.From > 0 && item.To > 0 && item.From > item.To {
+		return fmt.Errorf("from must be before to")
+	}
+
+	return nil
+}
+
+func (h *PriceHandler) fetchHistoryBatchItem(item historyBatchItem) (*prices.HistoryResponse, error) {
+	id := item.ID
+	if id == "" {
+		mappedID, err := h.history.ResolveCMCID(item.CMCID)
+		if err != nil {
+			return nil, err
+		}
+		id = mappedID
+	}
+
+	return h.history.GetHistoryRangeCachedOnly(id, item.Days, item.Interval, item.From, item.To)
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"crypto-portfolio-backend/internal/prices"
+)
+
+type fakeHistorySource struct {
+	mu       sync.Mutex
+	requests []string
+}
+
+func (f *fakeHistorySource) ResolveCMCID(cmcID string) (string, error) {
+	if cmcID == "1" {
+		return "bitcoin", nil
+	}
+	return "", fmt.Errorf("no coingecko mapping for cmc_id %s", cmcID)
+}
+
+func (f *fakeHistorySource) GetHistoryRangeCachedOnly(id, days, interval string, from, to int64) (*prices.HistoryResponse, error) {
+	f.mu.Lock()
+	f.requests = append(f.requests, fmt.Sprintf("%s %s %s %d %d", id, days, interval, from, to))
+	f.mu.Unlock()
+	if id == "uncached" {
+		return nil, fmt.Errorf("history not cached for %s", id)
+	}
+	return &prices.HistoryResponse{ID: id, Days: days, Interval: interval, Prices: []prices.HistoryPoint{}}, nil
+}
+
+func TestPostHistoryBatch(t *testing.T) {
+	source := &fakeHistorySource{}
+	handler := &PriceHandler{history: source}
+
+	body := `[
+		{"cmc_id":"1","days":"30"},
+		{"id":"ethereum","from":1700000000000},
+		{"id":"ethereum","days":"7"},
+		{"id":"uncached"},
+		{"cmc_id":"999"},
+		{"key":"both","id":"solana","cmc_id":"5426"},
+		{"id":"cardano","days":"14"},
+		{"id":"polkadot","interval":"hourly"}
+	]`
+	req := httptest.NewRequest(http.MethodPost, "/prices/history/batch", strings.NewReader(body))
+	req.Header.Set("Accept", "application/json")
+	rec := httptest.NewRecorder()
+	handler.HandleGetHistoryBatch(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	var response struct {
+		Histories map[string]prices.HistoryResponse `json:"histories"`
+		Errors    map[string]string                 `json:"errors"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	if len(response.Histories) != 2 || response.Histories["1"].ID != "bitcoin" || response.Histories["ethereum"].Days != "365" {
+		t.Errorf("histories = %+v", response.Histories)
+	}
+	wantErrors := map[string]string{
+		"ethereum": "duplicate item key",
+		"uncached": "history not cached for uncached",
+		"999":      "no coingecko mapping for cmc_id 999",
+		"both":     "only one of id or cmc_id may be set",
+		"cardano":  "days must be one of: 7,30,90,365",
+		"polkadot": "interval must be daily",
+	}
+	if !reflect.DeepEqual(response.Errors, wantErrors) {
+		t.Errorf("errors = %q, want %q", response.Errors, wantErrors)
+	}
+	if len(source.requests) != 3 {
+		t.Errorf("upstream requests = %q, want bitcoin, ethereum and uncached only", source.requests)
+	}
+}
+
+func TestPostHistoryBatchRejectsBadBodies(t *testing.T) {
+	handler := &PriceHandler{history: &fakeHistorySource{}}
+	for _, body := range []string{`{"items":[]}`, `not json`, `[` + strings.Repeat(`{"id":"x"},`, maxHistoryBatchItems) + `{"id":"y"}]`} {
+		rec := httptest.NewRecorder()
+		handler.HandleGetHistoryBatch(rec, httptest.NewRequest(http.MethodPost, "/prices/history/batch", strings.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("status = %d for %.20q, want 400", rec.Code, body)
+		}
+	}
+}
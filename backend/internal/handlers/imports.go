@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"crypto-portfolio-backend/internal/importer"
+	"crypto-portfolio-backend/internal/normalize"
+	"crypto-portfolio-backend/internal/wallet"
+)
+
+const (
+	maxImportBody = 16 << 20
+	maxWalletBody = 64 << 10
+	// importWriteMargin is left for writing a response after a scan that
+	// ran for its whole allowance.
+	importWriteMargin = 10 * time.Second
+)
+
+// ImportHandler converts exchange and wallet exports into transactions.
+type ImportHandler struct {
+	wallets *wallet.Service
+}
+
+// NewImportHandler creates a new import handler.
+func NewImportHandler(walletService *wallet.Service) *ImportHandler {
+	return &ImportHandler{wallets: walletService}
+}
+
+// HandleImportCSV handles POST /import/csv?exchange=binance|coinbase|kraken|bitflyer
+// Body: the exchange's CSV export; the exchange is detected from the header
+// when not given.
+func (h *ImportHandler) HandleImportCSV(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBody)
+	result, err := importer.ParseCSV(r.Body, r.URL.Query().Get("exchange"))
+	if err != nil {
+		writeImportError(w, "importing CSV", err)
+		return
+	}
+
+	log.Printf("Imported CSV (exchange=%s, rows=%d, transactions=%d, errors=%d)", result.Exchange, result.Rows, len(result.Transactions), len(result.Errors))
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeResponse(w, r, jsonFormat, result, nil)
+}
+
+// HandleImportTemplate handles POST /import/csv/template
+// Body: {"csv":"Date;Coin;Qty;Price\n...","template":{"delimiter":";","columns":{"timestamp":"Date","symbol":"Coin","amount":"Qty","price":"Price"},"date_format":"DD.MM.YYYY","decimal_separator":","}}
+func (h *ImportHandler) HandleImportTemplate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request importer.TemplateRequest
+	if !decodeJSONRequest(w, r, &request, maxImportBody) {
+		return
+	}
+	if request.Template == nil {
+		http.Error(w, "template is required; use /import/csv/preview for a suggestion", http.StatusBadRequest)
+		return
+	}
+
+	result, err := importer.ParseTemplateCSV(strings.NewReader(request.CSV), *request.Template)
+	if err != nil {
+		writeImportError(w, "importing templated CSV", err)
+		return
+	}
+
+	log.Printf("Imported templated CSV (rows=%d, transactions=%d, errors=%d)", result.Rows, len(result.Transactions), len(result.Errors))
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeResponse(w, r, jsonFormat, result, nil)
+}
+
+// HandlePreviewCSV handles POST /import/csv/preview
+// Body: same as /import/csv/template plus an optional "limit"; without a
+// template one is suggested from the header.
+func (h *ImportHandler) HandlePreviewCSV(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request importer.TemplateRequest
+	if !decodeJSONRequest(w, r, &request, maxImportBody) {
+		return
+	}
+
+	preview, err := importer.PreviewCSV(request)
+	if err != nil {
+		writeImportError(w, "previewing CSV", err)
+		return
+	}
+
+	log.Printf("Previewed CSV (suggested=%t, rows=%d, valid=%d, errors=%d)", preview.Suggested, preview.Rows, preview.Valid, len(preview.Errors))
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeResponse(w, r, jsonFormat, preview, nil)
+}
+
+// HandleImportBitcoin handles POST /import/wallet/btc
+// Body: {"xpub":"zpub...","gap_limit":20} or {"addresses":["bc1q..."]}
+func (h *ImportHandler) HandleImportBitcoin(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request wallet.BitcoinRequest
+	if !decodeJSONRequest(w, r, &request, maxWalletBody) {
+		return
+	}
+	extendWriteDeadline(w, wallet.MaxImportDuration+importWriteMargin)
+
+	result, err := h.wallets.ImportBitcoin(r.Context(), request)
+	if err != nil {
+		writeWalletError(w, "importing Bitcoin wallet", err)
+		return
+	}
+
+	log.Printf("Imported Bitcoin wallet (scanned=%d, used=%d, transactions=%d, unpriced=%d)", result.Scanned, len(result.Addresses), len(result.Transactions), len(result.Unpriced))
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeResponse(w, r, jsonFormat, result, nil)
+}
+
+// HandleImportEVM handles POST /import/wallet/evm
+// Body: {"address":"0x...","chain":"ethereum","provider":"explorer|rpc","from_block":0,"to_block":0}
+func (h *ImportHandler) HandleImportEVM(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request wallet.EVMRequest
+	if !decodeJSONRequest(w, r, &request, maxWalletBody) {
+		return
+	}
+	extendWriteDeadline(w, wallet.MaxImportDuration+importWriteMargin)
+
+	result, err := h.wallets.ImportEVM(r.Context(), request)
+	if err != nil {
+		writeWalletError(w, "importing EVM wallet", err)
+		return
+	}
+
+	log.Printf("Imported EVM wallet (chain=%s, transactions=%d, tokens=%d, unpriced=%d, errors=%d)", result.Chain, len(result.Transactions), len(result.Tokens), len(result.Unpriced), len(result.Errors))
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeResponse(w, r, jsonFormat, result, nil)
+}
+
+// HandleNormalize handles POST /import/normalize
+// Body: {"sources":[{"account":"binance","transactions":[...],"transfers":[...]},{"account":"ledger","events":[...]}],"transfer_window_hours":48}
+func (h *ImportHandler) HandleNormalize(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request normalize.Request
+	if !decodeJSONRequest(w, r, &request, maxImportBody) {
+		return
+	}
+
+	result, err := normalize.Normalize(request)
+	if err != nil {
+		if errors.Is(err, normalize.ErrInvalidRequest) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Error normalizing transactions: %v", err)
+		http.Error(w, "Failed to normalize transactions", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Normalized transactions (sources=%d, entries=%d, transactions=%d, unpriced=%d, duplicates=%d)", len(request.Sources), len(result.Entries), len(result.Transactions), len(result.Unpriced), len(result.Duplicates))
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeResponse(w, r, jsonFormat, result, nil)
+}
+
+// extendWriteDeadline lets a handler answer after work that outlasts the
+// server-wide write timeout.
+func extendWriteDeadline(w http.ResponseWriter, d time.Duration) {
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(d)); err != nil {
+		log.Printf("Error extending write deadline: %v", err)
+	}
+}
+
+// writeWalletError answers 400 for invalid keys and addresses, 504 when the
+// scan outlives wallet.MaxImportDuration and 503 when the explorer or node
+// cannot be reached. Nothing is written once the client has gone away.
+func writeWalletError(w http.ResponseWriter, action string, err error) {
+	switch {
+	case errors.Is(err, wallet.ErrInvalidRequest):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, context.Canceled):
+		log.Printf("Stopped %s: client went away", action)
+	case errors.Is(err, context.DeadlineExceeded):
+		log.Printf("Error %s: %v", action, err)
+		http.Error(w, fmt.Sprintf("Wallet scan took longer than %s; lower gap_limit or the block range", wallet.MaxImportDuration), http.StatusGatewayTimeout)
+	default:
+		log.Printf("Error %s: %v", action, err)
+		http.Error(w, "Wallet explorer unavailable", http.StatusServiceUnavailable)
+	}
+}
+
+// writeImportError answers 400 for unusable uploads and 500 otherwise.
+func writeImportError(w http.ResponseWriter, action string, err error) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, importer.ErrInvalidImport):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("Error %s: %v", action, err)
+		http.Error(w, "Failed to import file", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImportCSVTooLarge(t *testing.T) {
+	handler := NewImportHandler(nil)
+	for _, body := range []string{
+		// Over the limit before any header is found.
+		strings.Repeat("a", maxImportBody+1),
+		// Over the limit in the rows after the header.
+		"Date(UTC),Pair,Side,Price,Executed,Amount,Fee\n" + strings.Repeat("a", maxImportBody),
+	} {
+		rec := httptest.NewRecorder()
+		handler.HandleImportCSV(rec, httptest.NewRequest(http.MethodPost, "/import/csv", strings.NewReader(body)))
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("status = %d, want 413 (%.60s)", rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestExtendWriteDeadline(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		extendWriteDeadline(w, time.Second)
+		time.Sleep(100 * time.Millisecond)
+		io.WriteString(w, "done")
+	}))
+	server.Config.WriteTimeout = 20 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+	if body, err := io.ReadAll(resp.Body); err != nil || string(body) != "done" {
+		t.Errorf("body = %q, err = %v", body, err)
+	}
+}
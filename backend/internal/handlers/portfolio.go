@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"crypto-portfolio-backend/internal/portfolio"
+)
+
+const (
+	maxPortfolioBody        = 1 << 20
+	maxPortfolioHistoryBody = 16 << 20
+)
+
+// PortfolioHandler handles stateless portfolio calculations.
+type PortfolioHandler struct {
+	service *portfolio.Service
+}
+
+// NewPortfolioHandler creates a new portfolio handler.
+func NewPortfolioHandler(service *portfolio.Service) *PortfolioHandler {
+	return &PortfolioHandler{
+		service: service,
+	}
+}
+
+// HandleValue handles POST /portfolio/value
+// Body: {"holdings":[{"asset_id":"1","amount":0.5},{"asset_id":"ethereum","amount":2}],"currency":"EUR"}
+func (h *PortfolioHandler) HandleValue(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request portfolio.ValueRequest
+	if !decodeJSONRequest(w, r, &request, maxPortfolioBody) {
+		return
+	}
+
+	value, err := h.service.Value(request)
+	if err != nil {
+		writePortfolioError(w, "valuing portfolio", err)
+		return
+	}
+
+	log.Printf("Valued portfolio (holdings=%d, unpriced=%d, currency=%s)", len(request.Holdings), len(value.Unpriced), value.Currency)
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeResponse(w, r, jsonFormat, value, nil)
+}
+
+// HandleHistory handles POST /portfolio/history
+// Body: {"transactions":[<mobile Transaction>...],"currency":"EUR","days":365,"asset_ids":{"BTC":"1"}}
+func (h *PortfolioHandler) HandleHistory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request portfolio.HistoryRequest
+	if !decodeJSONRequest(w, r, &request, maxPortfolioHistoryBody) {
+		return
+	}
+
+	history, err := h.service.History(request)
+	if err != nil {
+		writePortfolioError(w, "building portfolio history", err)
+		return
+	}
+
+	log.Printf("Built portfolio history (transactions=%d, days=%d, currency=%s)", len(request.Transactions), history.Days, history.Currency)
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeResponse(w, r, negotiateFormat(r, true), history, nil)
+}
+
+// HandleCostBasis handles POST /portfolio/cost-basis
+// Body: {"transactions":[<mobile Transaction>...],"method":"hifo","currency":"EUR","asset_ids":{"BTC":"1"}}
+func (h *PortfolioHandler) HandleCostBasis(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request portfolio.CostBasisRequest
+	if !decodeJSONRequest(w, r, &request, maxPortfolioHistoryBody) {
+		return
+	}
+
+	costBasis, err := h.service.CostBasis(request)
+	if err != nil {
+		writePortfolioError(w, "computing cost basis", err)
+		return
+	}
+
+	log.Printf("Computed cost basis (transactions=%d, method=%s, currency=%s)", len(request.Transactions), costBasis.Method, costBasis.Currency)
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeResponse(w, r, negotiateFormat(r, true), costBasis, nil)
+}
+
+// HandleTaxReport handles POST /portfolio/tax-report?format=json|csv
+// Body: {"transactions":[<mobile Transaction>...],"jurisdiction":"us","year":2024,"method":"fifo"}
+func (h *PortfolioHandler) HandleTaxReport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		http.Error(w, "format must be json or csv", http.StatusBadRequest)
+		return
+	}
+
+	var request portfolio.TaxRequest
+	if !decodeJSONRequest(w, r, &request, maxPortfolioHistoryBody) {
+		return
+	}
+
+	report, err := h.service.TaxReport(request)
+	if err != nil {
+		writePortfolioError(w, "building tax report", err)
+		return
+	}
+
+	log.Printf("Built tax report (jurisdiction=%s, year=%d, rows=%d, format=%s)", report.Jurisdiction, report.Year, len(report.Rows), format)
+
+	w.Header().Set("Cache-Control", "no-store")
+	if format != "csv" {
+		writeResponse(w, r, jsonFormat, report, nil)
+		return
+	}
+
+	var body bytes.Buffer
+	if err := report.WriteCSV(&body); err != nil {
+		log.Printf("Error writing tax report CSV: %v", err)
+		http.Error(w, "Failed to write tax report", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", taxReportFilename(report)))
+	w.Write(body.Bytes())
+}
+
+// taxReportFilename names a CSV export, e.g. form-8949-2024.csv.
+func taxReportFilename(report *portfolio.TaxReport) string {
+	name := "tax-report-" + report.Jurisdiction
+	if report.Jurisdiction == portfolio.JurisdictionUS {
+		name = "form-8949"
+	}
+	if report.Year != 0 {
+		name += fmt.Sprintf("-%d", report.Year)
+	}
+	return name + ".csv"
+}
+
+// decodeJSONRequest reads a JSON body into request, answering 400 on
+// malformed input.
+func decodeJSONRequest(w http.ResponseWriter, r *http.Request, request any, limit int64) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(request); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// writePortfolioError answers 400 for invalid requests and 503 when prices
+// or rates are unavailable.
+func writePortfolioError(w http.ResponseWriter, action string, err error) {
+	if errors.Is(err, portfolio.ErrInvalidRequest) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Printf("Error %s: %v", action, err)
+	http.Error(w, err.Error(), http.StatusServiceUnavailable)
+}
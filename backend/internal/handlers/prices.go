@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -12,12 +13,14 @@ import (
 // PriceHandler handles price-related HTTP requests
 type PriceHandler struct {
 	service *prices.Service
+	history historyBatchSource
 }
 
 // NewPriceHandler creates a new price handler
 func NewPriceHandler(service *prices.Service) *PriceHandler {
 	return &PriceHandler{
 		service: service,
+		history: service,
 	}
 }
 
@@ -47,12 +50,13 @@ func (h *PriceHandler) HandleGetCoinMeta(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(coins); err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	validator := newCacheValidator()
+	validator.Add("coins_meta", coins.UpdatedAt, coins.ExpiresAt())
+	if validator.WriteHeaders(w, r) {
 		return
 	}
+
+	writeResponse(w, r, jsonFormat, coins, nil)
 }
 
 // HandleGetCMCCoinMeta handles GET /cmc/coins/meta
@@ -81,12 +85,13 @@ func (h *PriceHandler) HandleGetCMCCoinMeta(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(coins); err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	validator := newCacheValidator()
+	validator.Add("cmc_coins_meta", coins.UpdatedAt, coins.ExpiresAt())
+	if validator.WriteHeaders(w, r) {
 		return
 	}
+
+	writeResponse(w, r, jsonFormat, coins, nil)
 }
 
 // HandleGetLatestPrices handles GET /prices/latest
@@ -122,12 +127,15 @@ func (h *PriceHandler) HandleGetLatestPrices(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(pricesResp); err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	format := negotiateFormat(r, true)
+	validator := newCacheValidator()
+	validator.AddVariant(format.name)
+	validator.Add("latest_prices", pricesResp.UpdatedAt, pricesResp.ExpiresAt())
+	if validator.WriteHeaders(w, r) {
 		return
 	}
+
+	writeResponse(w, r, format, pricesResp, pricesResp)
 }
 
 // HandleGetCMCLatestPrices handles GET /cmc/prices/latest
@@ -163,12 +171,15 @@ func (h *PriceHandler) HandleGetCMCLatestPrices(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(pricesResp); err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	format := negotiateFormat(r, true)
+	validator := newCacheValidator()
+	validator.AddVariant(format.name)
+	validator.Add("cmc_latest_prices", pricesResp.UpdatedAt, pricesResp.ExpiresAt())
+	if validator.WriteHeaders(w, r) {
 		return
 	}
+
+	writeResponse(w, r, format, pricesResp, pricesResp)
 }
 
 // HandleGetHistory handles GET /prices/history
@@ -225,19 +236,22 @@ func (h *PriceHandler) HandleGetHistory(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(historyResp); err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	format := negotiateFormat(r, true)
+	validator := newCacheValidator()
+	validator.AddVariant(format.name)
+	validator.Add(historyResp.ID, historyResp.UpdatedAt, historyResp.ExpiresAt())
+	if validator.WriteHeaders(w, r) {
 		return
 	}
+
+	writeResponse(w, r, format, historyResp, historyResp)
 }
 
-// HandleGetHistoryBatch handles GET /prices/history/batch
+// HandleGetHistoryBatch handles GET and POST /prices/history/batch
 // Example: /prices/history/batch?cmc_ids=1,1027&days=365&interval=daily
 func (h *PriceHandler) HandleGetHistoryBatch(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
 	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
 
 	if r.Method == http.MethodOptions {
@@ -245,6 +259,11 @@ func (h *PriceHandler) HandleGetHistoryBatch(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	if r.Method == http.MethodPost {
+		h.handlePostHistoryBatch(w, r)
+		return
+	}
+
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -268,11 +287,6 @@ func (h *PriceHandler) HandleGetHistoryBatch(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	type batchResponse struct {
-		Histories map[string]*prices.HistoryResponse `json:"histories"`
-		Errors    map[string]string                `json:"errors,omitempty"`
-	}
-
 	histories := make(map[string]*prices.HistoryResponse)
 	errors := make(map[string]string)
 
@@ -317,15 +331,55 @@ func (h *PriceHandler) HandleGetHistoryBatch(w http.ResponseWriter, r *http.Requ
 		}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(batchResponse{
-		Histories: histories,
-		Errors:    errors,
-	}); err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	format := negotiateFormat(r, true)
+	validator := newCacheValidator()
+	validator.AddVariant(format.name)
+	for _, key := range sortedKeys(histories) {
+		history := histories[key]
+		validator.Add(key, history.UpdatedAt, history.ExpiresAt())
+	}
+	for _, key := range sortedKeys(errors) {
+		validator.AddMissing(key)
+	}
+	if validator.WriteHeaders(w, r) {
 		return
 	}
+
+	writeHistoryBatch(w, r, format, histories, errors)
+}
+
+type historyBatchResponse struct {
+	Histories map[string]any    `json:"histories"`
+	Errors    map[string]string `json:"errors,omitempty"`
+}
+
+func writeHistoryBatch(w http.ResponseWriter, r *http.Request, format responseFormat, histories map[string]*prices.HistoryResponse, errors map[string]string) {
+	// Each history is embedded from its memoized encoding so hot entries are
+	// serialized once per cache lifetime rather than once per batch request.
+	encoded := make(map[string]any, len(histories))
+	for key, history := range histories {
+		fragment, err := encodePayload(format, history, history)
+		if err != nil {
+			log.Printf("Error encoding history %s: %v", key, err)
+			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+			return
+		}
+		encoded[key] = format.raw(fragment)
+	}
+
+	writeResponse(w, r, format, historyBatchResponse{
+		Histories: encoded,
+		Errors:    errors,
+	}, nil)
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
 func isValidDays(days string) bool {
@@ -345,6 +399,7 @@ func isValidDays(days string) bool {
 // HandleHealth handles GET /health
 func (h *PriceHandler) HandleHealth(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Cache-Control", "no-store")
 	w.Header().Set("Content-Type", "application/json")
 
 	response := map[string]string{
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"crypto-portfolio-backend/internal/prices"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+)
+
+const (
+	streamHeartbeatInterval = 25 * time.Second
+	streamWriteTimeout      = 10 * time.Second
+)
+
+// HandlePriceStream handles GET /prices/stream
+// Streams CMC latest price diffs over Server-Sent Events, or over WebSocket
+// when the request asks for an upgrade.
+// Example: /prices/stream?ids=1,1027&last_event_id=42
+func (h *PriceHandler) HandlePriceStream(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Last-Event-ID")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	stream := h.service.PriceStream()
+	if stream == nil {
+		http.Error(w, "price stream not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	var ids []string
+	if idsParam := strings.TrimSpace(r.URL.Query().Get("ids")); idsParam != "" {
+		ids = strings.Split(idsParam, ",")
+	}
+
+	lastSeq, err := parseLastEventID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Streams outlive the server-wide write timeout; deadlines are set per write.
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("Error clearing stream write deadline: %v", err)
+	}
+
+	sub, replay := stream.Subscribe(ids, lastSeq)
+	defer stream.Unsubscribe(sub)
+
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		log.Printf("Opening price WebSocket stream (ids=%d, last_event_id=%d)", len(ids), lastSeq)
+		servePriceWebSocket(w, r, sub, replay)
+		return
+	}
+
+	log.Printf("Opening price SSE stream (ids=%d, last_event_id=%d)", len(ids), lastSeq)
+	servePriceSSE(w, r, sub, replay)
+}
+
+func servePriceSSE(w http.ResponseWriter, r *http.Request, sub *prices.PriceSubscription, replay []prices.PriceUpdate) {
+	controller := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	write := func(payload string) error {
+		controller.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		if _, err := fmt.Fprint(w, payload); err != nil {
+			return err
+		}
+		return controller.Flush()
+	}
+
+	writeUpdate := func(update prices.PriceUpdate) error {
+		data, err := json.Marshal(update)
+		if err != nil {
+			return err
+		}
+		return write(fmt.Sprintf("id: %d\nevent: prices\ndata: %s\n\n", update.Seq, data))
+	}
+
+	if err := write(fmt.Sprintf("retry: %d\n\n", streamHeartbeatInterval.Milliseconds())); err != nil {
+		return
+	}
+	for _, update := range replay {
+		if err := writeUpdate(update); err != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case update, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if err := writeUpdate(update); err != nil {
+				log.Printf("Error writing price SSE update: %v", err)
+				return
+			}
+		case <-heartbeat.C:
+			if err := write(": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func servePriceWebSocket(w http.ResponseWriter, r *http.Request, sub *prices.PriceSubscription, replay []prices.PriceUpdate) {
+	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{
+		OriginPatterns: []string{"*"},
+	})
+	if err != nil {
+		log.Printf("Error accepting price WebSocket: %v", err)
+		return
+	}
+	defer conn.CloseNow()
+
+	// Clients only receive; reading in the background handles pings and close frames.
+	ctx := conn.CloseRead(r.Context())
+
+	writeUpdate := func(update prices.PriceUpdate) error {
+		writeCtx, cancel := context.WithTimeout(ctx, streamWriteTimeout)
+		defer cancel()
+		return wsjson.Write(writeCtx, conn, update)
+	}
+
+	for _, update := range replay {
+		if err := writeUpdate(update); err != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case update, ok := <-sub.C:
+			if !ok {
+				conn.Close(websocket.StatusTryAgainLater, "subscriber too slow, resume with last_event_id")
+				return
+			}
+			if err := writeUpdate(update); err != nil {
+				log.Printf("Error writing price WebSocket update: %v", err)
+				return
+			}
+		case <-heartbeat.C:
+			pingCtx, cancel := context.WithTimeout(ctx, streamWriteTimeout)
+			err := conn.Ping(pingCtx)
+			cancel()
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+func parseLastEventID(r *http.Request) (uint64, error) {
+	raw := strings.TrimSpace(r.Header.Get("Last-Event-ID"))
+	if raw == "" {
+		raw = strings.TrimSpace(r.URL.Query().Get("last_event_id"))
+	}
+	if raw == "" {
+		return 0, nil
+	}
+
+	value, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("last_event_id must be a non-negative integer")
+	}
+	return value, nil
+}
@@ -0,0 +1,73 @@
+// Package httpclient holds the HTTP client settings and retry loop shared by
+// the clients of upstream APIs (FX, market data, explorers and exchanges).
+package httpclient
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	// UserAgent identifies the backend to upstream APIs.
+	UserAgent = "crypto-portfolio-backend/1.0"
+
+	maxRetries = 2
+	retryDelay = 500 * time.Millisecond
+)
+
+// New creates a client whose requests time out after timeout.
+func New(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+	}
+}
+
+// Do sends the request built by build, retrying on network errors, rate
+// limits and 5xx responses with a growing delay. Each attempt builds a
+// fresh request so bodies and signed timestamps or nonces stay valid.
+// When the retries run out on a rate limit or 5xx, that response is
+// returned for the caller to report like any other failed status. Retries
+// stop as soon as the request's context is done.
+func Do(client *http.Client, build func() (*http.Request, error)) (*http.Response, error) {
+	var lastErr error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		req, err := build()
+		if err != nil {
+			return nil, fmt.Errorf("failed to build request: %w", err)
+		}
+		if req.Header.Get("User-Agent") == "" {
+			req.Header.Set("User-Agent", UserAgent)
+		}
+
+		ctx := req.Context()
+		resp, err := client.Do(req)
+		switch {
+		case err != nil && ctx.Err() != nil:
+			return nil, ctx.Err()
+		case err != nil:
+			lastErr = err
+		case !retryable(resp.StatusCode) || attempt == maxRetries:
+			return resp, nil
+		default:
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			lastErr = fmt.Errorf("API error: status %d", resp.StatusCode)
+		}
+
+		if attempt < maxRetries {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(retryDelay * time.Duration(attempt+1)):
+			}
+		}
+	}
+
+	return nil, lastErr
+}
+
+func retryable(status int) bool {
+	return status >= http.StatusInternalServerError || status == http.StatusTooManyRequests
+}
@@ -0,0 +1,78 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDoRetriesThenSucceeds(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != UserAgent {
+			t.Errorf("User-Agent = %q", r.Header.Get("User-Agent"))
+		}
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	resp, err := Do(New(time.Second), func() (*http.Request, error) {
+		return http.NewRequest(http.MethodGet, server.URL, nil)
+	})
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent || calls.Load() != 2 {
+		t.Errorf("status = %d after %d calls, want 204 after 2", resp.StatusCode, calls.Load())
+	}
+}
+
+func TestDoStopsWithContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := 0
+	_, err := Do(New(time.Second), func() (*http.Request, error) {
+		attempts++
+		// Give up while the first retry waits.
+		if attempts == 1 {
+			time.AfterFunc(10*time.Millisecond, cancel)
+		}
+		return http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	})
+	if !errors.Is(err, context.Canceled) || attempts != 1 {
+		t.Errorf("err = %v after %d attempts, want context.Canceled after 1", err, attempts)
+	}
+}
+
+func TestDoReturnsTheLastFailedResponse(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	resp, err := Do(New(time.Second), func() (*http.Request, error) {
+		return http.NewRequest(http.MethodGet, server.URL, nil)
+	})
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadGateway || calls.Load() != maxRetries+1 {
+		t.Errorf("status = %d after %d calls, want 502 after %d", resp.StatusCode, calls.Load(), maxRetries+1)
+	}
+}
@@ -0,0 +1,75 @@
+package importer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// binanceFormat reads Binance spot trade history. Current exports suffix
+// amounts with their asset (Executed "0.5BTC", Amount "15000USDT", Fee
+// "0.0005BTC"); older ones have Market, Total and Fee Coin columns instead.
+var binanceFormat = format{
+	name: "binance",
+	headers: [][]string{
+		{"Date(UTC)", "Pair", "Side", "Price", "Executed", "Amount", "Fee"},
+		{"Date(UTC)", "Market", "Type", "Price", "Amount", "Total", "Fee", "Fee Coin"},
+	},
+	parse: parseBinance,
+}
+
+// binanceQuotes are the quote assets of Binance markets, longest first so
+// that e.g. BTCUSDT is not split as BTCUSD + T.
+var binanceQuotes = []string{"FDUSD", "USDT", "USDC", "BUSD", "TUSD", "USD", "EUR", "GBP", "JPY", "TRY", "BRL", "AUD", "BTC", "ETH", "BNB"}
+
+func parseBinance(fields row) (trade, error) {
+	timestamp, err := parseTime(fields.get("Date(UTC)"), time.UTC, "2006-01-02 15:04:05", "06-01-02 15:04:05")
+	if err != nil {
+		return trade{}, err
+	}
+	price, err := parseDecimal(fields.get("Price"))
+	if err != nil {
+		return trade{}, err
+	}
+
+	t := trade{timestamp: timestamp, price: price}
+	if executed := fields.get("Executed"); executed != "" {
+		t.side = fields.get("Side")
+		// The pair names both assets, which may start with digits (1INCH,
+		// 1000SATS); an unknown quote falls back to the amount suffixes.
+		base, quote, _ := splitBinanceMarket(fields.get("Pair"))
+		if t.amount, t.base, err = splitAmount(executed, base); err != nil {
+			return trade{}, err
+		}
+		if _, t.quote, err = splitAmount(fields.get("Amount"), quote); err != nil {
+			return trade{}, err
+		}
+		if t.fee, t.feeCurrency, err = splitAmount(fields.get("Fee"), base, quote); err != nil {
+			return trade{}, err
+		}
+		return t, nil
+	}
+
+	t.side = fields.get("Type")
+	if t.base, t.quote, err = splitBinanceMarket(fields.get("Market")); err != nil {
+		return trade{}, err
+	}
+	if t.amount, err = parseDecimal(fields.get("Amount")); err != nil {
+		return trade{}, err
+	}
+	if t.fee, err = parseDecimal(fields.get("Fee")); err != nil {
+		return trade{}, err
+	}
+	t.feeCurrency = fields.get("Fee Coin")
+	return t, nil
+}
+
+func splitBinanceMarket(market string) (string, string, error) {
+	market = strings.ToUpper(strings.TrimSpace(market))
+	for _, quote := range binanceQuotes {
+		if len(market) > len(quote) && strings.HasSuffix(market, quote) {
+			return strings.TrimSuffix(market, quote), quote, nil
+		}
+	}
+	return "", "", fmt.Errorf("unknown market %q", market)
+}
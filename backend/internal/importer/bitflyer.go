@@ -0,0 +1,72 @@
+package importer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// bitflyerFormat reads bitFlyer trade history in its Japanese or English
+// layout (UTF-8). Times are JST and fees are charged in the traded asset.
+var bitflyerFormat = format{
+	name: "bitflyer",
+	headers: [][]string{
+		{"取引日時", "通貨", "取引種別", "取引価格", "通貨1数量", "手数料"},
+		{"Trade Date", "Product", "Trade Type", "Traded Price", "Amount (Currency 1)", "Fee"},
+	},
+	parse: parseBitflyer,
+}
+
+var jst = time.FixedZone("JST", 9*60*60)
+
+func parseBitflyer(fields row) (trade, error) {
+	var side string
+	switch kind := fields.get("取引種別", "Trade Type"); strings.ToLower(kind) {
+	case "買い", "buy":
+		side = "BUY"
+	case "売り", "sell":
+		side = "SELL"
+	default:
+		return trade{}, fmt.Errorf("unsupported trade type %q", kind)
+	}
+
+	timestamp, err := parseTime(fields.get("取引日時", "Trade Date"), jst, "2006/01/02 15:04:05", "2006/01/02 15:04", "2006-01-02 15:04:05")
+	if err != nil {
+		return trade{}, err
+	}
+	base, quote, err := splitBitflyerProduct(fields.get("通貨", "Product"))
+	if err != nil {
+		return trade{}, err
+	}
+	price, err := parseDecimal(fields.get("取引価格", "Traded Price"))
+	if err != nil {
+		return trade{}, err
+	}
+	amount, err := parseDecimal(fields.get("通貨1数量", "Amount (Currency 1)"))
+	if err != nil {
+		return trade{}, err
+	}
+	fee, err := parseDecimal(fields.get("手数料", "Fee"))
+	if err != nil {
+		return trade{}, err
+	}
+
+	return trade{
+		timestamp:   timestamp,
+		side:        side,
+		base:        base,
+		quote:       quote,
+		amount:      amount,
+		price:       price,
+		fee:         fee,
+		feeCurrency: base,
+	}, nil
+}
+
+func splitBitflyerProduct(product string) (string, string, error) {
+	base, quote, found := strings.Cut(strings.ReplaceAll(strings.TrimSpace(product), "_", "/"), "/")
+	if !found || base == "" || quote == "" {
+		return "", "", fmt.Errorf("unknown product %q", product)
+	}
+	return strings.ToUpper(base), strings.ToUpper(quote), nil
+}
@@ -0,0 +1,59 @@
+package importer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// coinbaseFormat reads the Coinbase transaction history report. The report
+// starts with a preamble before the header; only buys and sells are
+// imported.
+var coinbaseFormat = format{
+	name: "coinbase",
+	headers: [][]string{
+		{"Timestamp", "Transaction Type", "Asset", "Quantity Transacted", "Spot Price Currency", "Spot Price at Transaction"},
+		{"Timestamp", "Transaction Type", "Asset", "Quantity Transacted", "Price Currency", "Price at Transaction"},
+	},
+	parse: parseCoinbase,
+}
+
+func parseCoinbase(fields row) (trade, error) {
+	var side string
+	switch kind := fields.get("Transaction Type"); strings.ToLower(kind) {
+	case "buy", "advanced trade buy":
+		side = "BUY"
+	case "sell", "advanced trade sell":
+		side = "SELL"
+	default:
+		return trade{}, fmt.Errorf("unsupported transaction type %q", kind)
+	}
+
+	timestamp, err := parseTime(fields.get("Timestamp"), time.UTC, time.RFC3339, "2006-01-02 15:04:05 MST", "2006-01-02 15:04:05")
+	if err != nil {
+		return trade{}, err
+	}
+	amount, err := parseDecimal(fields.get("Quantity Transacted"))
+	if err != nil {
+		return trade{}, err
+	}
+	price, err := parseDecimal(fields.get("Spot Price at Transaction", "Price at Transaction"))
+	if err != nil {
+		return trade{}, err
+	}
+	fee, err := parseDecimal(fields.get("Fees and/or Spread", "Fees"))
+	if err != nil {
+		return trade{}, err
+	}
+
+	return trade{
+		timestamp:  timestamp,
+		side:       side,
+		base:       fields.get("Asset"),
+		quote:      fields.get("Spot Price Currency", "Price Currency"),
+		amount:     amount,
+		price:      price,
+		fee:        fee,
+		externalID: fields.get("ID"),
+	}, nil
+}
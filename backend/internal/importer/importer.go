@@ -0,0 +1,483 @@
+// Package importer turns exchange exports into transactions in the mobile
+// Transaction shape. Nothing is stored; the device deduplicates imported
+// rows by external_id.
+package importer
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"crypto-portfolio-backend/internal/fx"
+	"crypto-portfolio-backend/internal/portfolio"
+)
+
+// MaxRows bounds the data rows of a single import.
+const MaxRows = portfolio.MaxTransactions
+
+// headerScanRows is how many leading records are searched for a header;
+// some exports start with a preamble.
+const headerScanRows = 20
+
+// ErrInvalidImport is wrapped by errors caused by the uploaded file itself.
+var ErrInvalidImport = errors.New("invalid import")
+
+// RowError explains why a row was not imported. Row is the 1-based line in
+// the file.
+type RowError struct {
+	Row     int    `json:"row"`
+	Message string `json:"message"`
+}
+
+// Result is returned to the mobile app for POST /import/csv.
+type Result struct {
+	Exchange     string                  `json:"exchange"`
+	Rows         int                     `json:"rows"`
+	Transactions []portfolio.Transaction `json:"transactions"`
+	Errors       []RowError              `json:"errors"`
+}
+
+// trade is one fill read from an export, before it is normalized.
+type trade struct {
+	timestamp   time.Time
+	side        string
+	base        string
+	quote       string
+	amount      float64
+	price       float64
+	fee         float64
+	feeCurrency string
+	notes       string
+	// externalID is the exchange's own id for the row, if it has one.
+	externalID string
+}
+
+// Trade is a fill fetched from an exchange API. It is normalized with the
+// same rules as exported rows.
+type Trade struct {
+	Timestamp   time.Time
+	Side        string
+	Base        string
+	Quote       string
+	Amount      float64
+	Price       float64
+	Fee         float64
+	FeeCurrency string
+	ExternalID  string
+}
+
+// Transaction converts the fill to the mobile shape with Source EXCHANGE
+// and external id "<exchange>:<id>".
+func (t Trade) Transaction(exchange string) (portfolio.Transaction, error) {
+	return trade{
+		timestamp:   t.Timestamp,
+		side:        t.Side,
+		base:        t.Base,
+		quote:       t.Quote,
+		amount:      t.Amount,
+		price:       t.Price,
+		fee:         t.Fee,
+		feeCurrency: t.FeeCurrency,
+		externalID:  t.ExternalID,
+	}.transaction(exchange, []string{t.Timestamp.String(), t.Base, t.Quote})
+}
+
+// SplitPair splits an exchange market symbol (BTCUSDT, XXBTZUSD, BTC_JPY)
+// into base and quote tickers.
+func SplitPair(exchange, pair string) (string, string, error) {
+	switch exchange {
+	case "binance":
+		return splitBinanceMarket(pair)
+	case "kraken":
+		return splitKrakenPair(pair)
+	case "bitflyer":
+		return splitBitflyerProduct(pair)
+	default:
+		return "", "", fmt.Errorf("unsupported exchange %q", exchange)
+	}
+}
+
+// Asset maps an exchange asset code to the common ticker (Kraken XXBT is
+// BTC).
+func Asset(exchange, code string) string {
+	code = normalizeCurrency(code)
+	if exchange == "kraken" {
+		return krakenAsset(code)
+	}
+	return code
+}
+
+// row is a data record keyed by header name.
+type row map[string]string
+
+// get returns the first non-empty column among names; exports rename
+// columns between versions and languages.
+func (r row) get(names ...string) string {
+	for _, name := range names {
+		if value := strings.TrimSpace(r[name]); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// format is a supported exchange export.
+type format struct {
+	name string
+	// headers lists alternative header sets; any complete set identifies
+	// the export.
+	headers [][]string
+	parse   func(row) (trade, error)
+}
+
+// formats are tried in order when the exchange is not given.
+var formats = []format{binanceFormat, coinbaseFormat, krakenFormat, bitflyerFormat}
+
+// Exchanges lists the names accepted by ParseCSV.
+func Exchanges() []string {
+	names := make([]string, 0, len(formats))
+	for _, candidate := range formats {
+		names = append(names, candidate.name)
+	}
+	return names
+}
+
+// ParseCSV reads an exchange export. The exchange is detected from the
+// header when empty.
+func ParseCSV(input io.Reader, exchange string) (*Result, error) {
+	exchange = strings.ToLower(strings.TrimSpace(exchange))
+	candidates := formats
+	if exchange != "" {
+		candidates = nil
+		for _, candidate := range formats {
+			if candidate.name == exchange {
+				candidates = []format{candidate}
+			}
+		}
+		if candidates == nil {
+			return nil, fmt.Errorf("%w: unsupported exchange %q (supported: %s)", ErrInvalidImport, exchange, strings.Join(Exchanges(), ", "))
+		}
+	}
+
+	reader := newCSVReader(input, ',')
+	var (
+		header []string
+		parser format
+	)
+	for scanned := 0; header == nil; scanned++ {
+		record, err := reader.Read()
+		if err == io.EOF || scanned >= headerScanRows {
+			return nil, fmt.Errorf("%w: no %s header found", ErrInvalidImport, describe(candidates))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidImport, err)
+		}
+		record = cleanHeader(record)
+		for _, candidate := range candidates {
+			if candidate.matches(record) {
+				header, parser = record, candidate
+				break
+			}
+		}
+	}
+
+	return readRows(reader, header, parser.name, portfolio.SourceExchange, parser.parse)
+}
+
+func newCSVReader(input io.Reader, delimiter rune) *csv.Reader {
+	reader := csv.NewReader(input)
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	return reader
+}
+
+// readRows parses the records after the header into transactions tagged
+// with source and external ids prefixed by name. Rows that fail are
+// reported with their line number instead of failing the whole file.
+func readRows(reader *csv.Reader, header []string, name, source string, parse func(row) (trade, error)) (*Result, error) {
+	result := &Result{
+		Exchange:     name,
+		Transactions: make([]portfolio.Transaction, 0),
+		Errors:       make([]RowError, 0),
+	}
+	seen := make(map[string]int)
+	occurrences := make(map[string]int)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, fmt.Errorf("failed to read CSV: %w", err)
+			}
+			result.Rows++
+			result.Errors = append(result.Errors, RowError{Row: parseErr.Line, Message: parseErr.Err.Error()})
+			continue
+		}
+		if isBlank(record) {
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+
+		result.Rows++
+		if result.Rows > MaxRows {
+			return nil, fmt.Errorf("%w: at most %d rows are allowed", ErrInvalidImport, MaxRows)
+		}
+
+		fields := make(row, len(header))
+		for i, name := range header {
+			if i < len(record) {
+				fields[name] = record[i]
+			}
+		}
+
+		parsed, err := parse(fields)
+		if err != nil {
+			result.Errors = append(result.Errors, RowError{Row: line, Message: err.Error()})
+			continue
+		}
+		tx, err := parsed.transaction(name, record)
+		if err != nil {
+			result.Errors = append(result.Errors, RowError{Row: line, Message: err.Error()})
+			continue
+		}
+
+		if parsed.externalID == "" {
+			// Rows without an exchange id are told apart by content only, so
+			// identical fills are separate trades: repeats get their
+			// occurrence number to keep ids distinct and stable.
+			occurrences[*tx.ExternalID]++
+			if count := occurrences[*tx.ExternalID]; count > 1 {
+				externalID := fmt.Sprintf("%s:%d", *tx.ExternalID, count)
+				tx.ExternalID = &externalID
+			}
+		} else if first, found := seen[*tx.ExternalID]; found {
+			result.Errors = append(result.Errors, RowError{Row: line, Message: fmt.Sprintf("duplicate of row %d", first)})
+			continue
+		} else {
+			seen[*tx.ExternalID] = line
+		}
+		tx.Source = source
+		result.Transactions = append(result.Transactions, tx)
+	}
+
+	return result, nil
+}
+
+func (f format) matches(header []string) bool {
+	present := make(map[string]struct{}, len(header))
+	for _, name := range header {
+		present[name] = struct{}{}
+	}
+	for _, set := range f.headers {
+		complete := true
+		for _, name := range set {
+			if _, found := present[name]; !found {
+				complete = false
+				break
+			}
+		}
+		if complete {
+			return true
+		}
+	}
+	return false
+}
+
+// transaction normalizes a trade into the mobile shape. Stablecoin quotes
+// are booked as USD; trades quoted in other crypto are rejected since the
+// mobile schema only has fiat prices.
+func (t trade) transaction(exchange string, record []string) (portfolio.Transaction, error) {
+	base := normalizeCurrency(t.base)
+	quote := normalizeCurrency(t.quote)
+	if stable, found := stablecoins[quote]; found {
+		quote = stable
+	}
+
+	switch {
+	case base == "":
+		return portfolio.Transaction{}, errors.New("missing asset")
+	case !isFiat(quote):
+		return portfolio.Transaction{}, fmt.Errorf("unsupported quote currency %q: only fiat and USD stablecoin pairs can be imported", t.quote)
+	case t.timestamp.IsZero():
+		return portfolio.Transaction{}, errors.New("missing date")
+	case t.amount == 0 || !isFinite(t.amount):
+		return portfolio.Transaction{}, errors.New("missing amount")
+	case t.price < 0 || !isFinite(t.price):
+		return portfolio.Transaction{}, errors.New("invalid price")
+	}
+
+	amount := math.Abs(t.amount)
+	kind := portfolio.TransactionBuy
+	switch strings.ToUpper(t.side) {
+	case "BUY":
+	case "SELL":
+		kind = portfolio.TransactionSell
+		amount = -amount
+	default:
+		return portfolio.Transaction{}, fmt.Errorf("unsupported side %q", t.side)
+	}
+
+	id := t.externalID
+	if id == "" {
+		id = rowHash(record)
+	}
+	externalID := exchange + ":" + id
+
+	tx := portfolio.Transaction{
+		AssetSymbol:      base,
+		Amount:           amount,
+		PricePerUnitFiat: t.price,
+		TotalFiat:        math.Abs(amount) * t.price,
+		FiatCurrency:     quote,
+		Type:             kind,
+		Source:           portfolio.SourceExchange,
+		ExternalID:       &externalID,
+		Timestamp:        t.timestamp.UnixMilli(),
+	}
+	if fee := math.Abs(t.fee); fee > 0 && isFinite(fee) {
+		feeCurrency := normalizeCurrency(t.feeCurrency)
+		if feeCurrency == "" {
+			feeCurrency = quote
+		}
+		if stable, found := stablecoins[feeCurrency]; found {
+			feeCurrency = stable
+		}
+		tx.FeeAmount = &fee
+		tx.FeeCurrency = &feeCurrency
+	}
+	if t.notes != "" {
+		notes := t.notes
+		tx.Notes = &notes
+	}
+	return tx, nil
+}
+
+// stablecoins are booked in the fiat they track.
+var stablecoins = map[string]string{
+	"USDT": "USD", "USDC": "USD", "BUSD": "USD", "FDUSD": "USD", "TUSD": "USD", "USDP": "USD", "DAI": "USD",
+}
+
+// IsFiat reports whether currency is a fiat currency; stablecoins are not.
+func IsFiat(currency string) bool {
+	return isFiat(normalizeCurrency(currency))
+}
+
+func isFiat(currency string) bool {
+	return fx.IsFiat(currency)
+}
+
+func normalizeCurrency(currency string) string {
+	return strings.ToUpper(strings.TrimSpace(currency))
+}
+
+// parseDecimal parses a number as exported by exchanges: thousands
+// separators, currency signs and surrounding spaces are ignored.
+func parseDecimal(value string) (float64, error) {
+	cleaned := strings.Map(func(r rune) rune {
+		switch r {
+		case ',', ' ', '$', '€', '£', '¥', '￥', '\u00a0':
+			return -1
+		}
+		return r
+	}, value)
+	if cleaned == "" {
+		return 0, nil
+	}
+	number, err := strconv.ParseFloat(cleaned, 64)
+	if err != nil || !isFinite(number) {
+		return 0, fmt.Errorf("invalid number %q", value)
+	}
+	return number, nil
+}
+
+// splitAmount parses an amount suffixed with its currency, e.g. 0.5BTC.
+// Currencies can start with digits (1INCH, 1000SATS), so the suffix is first
+// matched against the given currencies and otherwise read as the trailing
+// letters.
+func splitAmount(value string, currencies ...string) (float64, string, error) {
+	value = strings.TrimSpace(value)
+	upper := strings.ToUpper(value)
+	sort.SliceStable(currencies, func(i, j int) bool {
+		return len(currencies[i]) > len(currencies[j])
+	})
+	for _, currency := range currencies {
+		if currency == "" || len(upper) <= len(currency) || !strings.HasSuffix(upper, currency) {
+			continue
+		}
+		if amount, err := parseDecimal(value[:len(value)-len(currency)]); err == nil {
+			return amount, currency, nil
+		}
+	}
+
+	end := len(value)
+	for end > 0 {
+		c := value[end-1]
+		if (c < 'A' || c > 'Z') && (c < 'a' || c > 'z') {
+			break
+		}
+		end--
+	}
+	amount, err := parseDecimal(value[:end])
+	return amount, strings.ToUpper(value[end:]), err
+}
+
+// parseTime tries layouts in order, reading zone-less times in location.
+func parseTime(value string, location *time.Location, layouts ...string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range layouts {
+		if parsed, err := time.ParseInLocation(layout, value, location); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
+
+// rowHash identifies a row without an exchange id by its content.
+func rowHash(record []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(record, "\x1f")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// cleanHeader trims header names and a leading byte order mark.
+func cleanHeader(record []string) []string {
+	cleaned := make([]string, len(record))
+	for i, name := range record {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		cleaned[i] = strings.TrimSpace(name)
+	}
+	return cleaned
+}
+
+func isBlank(record []string) bool {
+	for _, field := range record {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func isFinite(value float64) bool {
+	return !math.IsNaN(value) && !math.IsInf(value, 0)
+}
+
+func describe(candidates []format) string {
+	if len(candidates) == 1 {
+		return candidates[0].name
+	}
+	return "supported exchange"
+}
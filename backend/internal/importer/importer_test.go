@@ -0,0 +1,204 @@
+package importer
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"crypto-portfolio-backend/internal/portfolio"
+)
+
+func TestParseCSV(t *testing.T) {
+	tests := []struct {
+		name     string
+		exchange string
+		input    string
+		want     string
+		// txs lists symbol, type, amount, price, fiat and fee per imported
+		// transaction.
+		txs    []string
+		errors []RowError
+		times  []string
+	}{
+		{
+			name: "binance trade history",
+			input: "\ufeffDate(UTC),Pair,Side,Price,Executed,Amount,Fee\n" +
+				"2024-03-01 12:00:00,BTCUSDT,BUY,50000,0.01BTC,500USDT,0.00001BTC\n" +
+				"2024-03-02 08:30:00,ETHBTC,SELL,0.05,1ETH,0.05BTC,0.00005BTC\n" +
+				"2024-03-03 09:00:00,ETHEUR,SELL,\"3,000\",0.5ETH,1500EUR,1.5EUR\n",
+			want: "binance",
+			txs: []string{
+				"BTC BUY 0.01 50000 USD 1e-05 BTC",
+				"ETH SELL -0.5 3000 EUR 1.5 EUR",
+			},
+			errors: []RowError{{Row: 3, Message: `unsupported quote currency "BTC": only fiat and USD stablecoin pairs can be imported`}},
+			times:  []string{"2024-03-01T12:00:00Z", "2024-03-03T09:00:00Z"},
+		},
+		{
+			name: "binance assets starting with digits and repeated fills",
+			input: "Date(UTC),Pair,Side,Price,Executed,Amount,Fee\n" +
+				"2024-03-04 10:00:00,1INCHUSDT,BUY,0.5,10.51INCH,5.25USDT,0.011INCH\n" +
+				"2024-03-04 11:00:00,1000SATSUSDT,SELL,0.0004,50001000SATS,2USDT,0.002USDT\n" +
+				"2024-03-04 11:00:00,1000SATSUSDT,SELL,0.0004,50001000SATS,2USDT,0.002USDT\n",
+			want: "binance",
+			txs: []string{
+				"1INCH BUY 10.5 0.5 USD 0.01 1INCH",
+				"1000SATS SELL -5000 0.0004 USD 0.002 USD",
+				"1000SATS SELL -5000 0.0004 USD 0.002 USD",
+			},
+		},
+		{
+			name:     "binance legacy columns",
+			exchange: "Binance",
+			input: "Date(UTC),Market,Type,Price,Amount,Total,Fee,Fee Coin\n" +
+				"2021-05-01 10:00:00,SOLBUSD,SELL,40,2,80,0.08,BUSD\n",
+			want: "binance",
+			txs:  []string{"SOL SELL -2 40 USD 0.08 USD"},
+		},
+		{
+			name: "coinbase report with preamble",
+			input: "You can use this transaction report to inform your likely tax obligations.\n" +
+				"\n" +
+				"Transactions\n" +
+				"User,someone@example.com,abc\n" +
+				"ID,Timestamp,Transaction Type,Asset,Quantity Transacted,Price Currency,Price at Transaction,Subtotal,Total (inclusive of fees and/or spread),Fees and/or Spread,Notes\n" +
+				"6571,2024-01-05 14:00:00 UTC,Buy,BTC,0.002,USD,$40000.00,$80.00,$81.99,$1.99,Bought 0.002 BTC\n" +
+				"6572,2024-01-06 14:00:00 UTC,Send,BTC,0.001,USD,$41000.00,,,,Sent to wallet\n" +
+				"6573,2024-02-01T09:15:00Z,Advanced Trade Sell,ETH,1,EUR,\"€2,100.50\",,,€3.10,\n",
+			want: "coinbase",
+			txs: []string{
+				"BTC BUY 0.002 40000 USD 1.99 USD",
+				"ETH SELL -1 2100.5 EUR 3.1 EUR",
+			},
+			errors: []RowError{{Row: 7, Message: `unsupported transaction type "Send"`}},
+			times:  []string{"2024-01-05T14:00:00Z", "2024-02-01T09:15:00Z"},
+		},
+		{
+			name: "kraken trades",
+			input: `"txid","ordertxid","pair","time","type","ordertype","price","cost","fee","vol","margin","misc","ledgers"` + "\n" +
+				`"TX1","O1","XXBTZEUR","2023-11-02 10:00:00.1234","buy","limit","30000.0","300.0","0.78","0.01","0.0","",""` + "\n" +
+				`"TX2","O2","SOLUSD","2023-11-03 10:00:00","sell","market","50.0","100.0","0.26","2","0.0","",""` + "\n" +
+				`"TX1","O1","XXBTZEUR","2023-11-02 10:00:00.1234","buy","limit","30000.0","300.0","0.78","0.01","0.0","",""` + "\n",
+			want: "kraken",
+			txs: []string{
+				"BTC BUY 0.01 30000 EUR 0.78 EUR",
+				"SOL SELL -2 50 USD 0.26 USD",
+			},
+			errors: []RowError{{Row: 4, Message: "duplicate of row 2"}},
+			times:  []string{"2023-11-02T10:00:00.123Z", "2023-11-03T10:00:00Z"},
+		},
+		{
+			name: "bitflyer japanese",
+			input: "取引日時,通貨,取引種別,取引価格,通貨1,通貨1数量,手数料,通貨1の対円レート,通貨2,通貨2数量,自己ポスト,注文 ID,備考\n" +
+				"2024/04/01 09:00:00,BTC/JPY,買い,\"10,000,000\",BTC,0.001,-0.0000015,\"10,000,000\",JPY,\"-10,000\",,JOR1,\n" +
+				"2024/04/02 09:00:00,BTC/JPY,入金,0,JPY,\"10,000\",0,1,,,,,\n",
+			want:   "bitflyer",
+			txs:    []string{"BTC BUY 0.001 1e+07 JPY 1.5e-06 BTC"},
+			errors: []RowError{{Row: 3, Message: `unsupported trade type "入金"`}},
+			times:  []string{"2024-04-01T00:00:00Z"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseCSV(strings.NewReader(tt.input), tt.exchange)
+			if err != nil {
+				t.Fatalf("ParseCSV: %v", err)
+			}
+			if result.Exchange != tt.want {
+				t.Errorf("exchange: got %s, want %s", result.Exchange, tt.want)
+			}
+
+			var txs, times []string
+			for _, tx := range result.Transactions {
+				txs = append(txs, describeTransaction(tx))
+				times = append(times, time.UnixMilli(tx.Timestamp).UTC().Format(time.RFC3339Nano))
+				if tx.Source != portfolio.SourceExchange || tx.ExternalID == nil || !strings.HasPrefix(*tx.ExternalID, tt.want+":") {
+					t.Errorf("unexpected source or external id: %s %v", tx.Source, tx.ExternalID)
+				}
+			}
+			if !reflect.DeepEqual(txs, tt.txs) {
+				t.Errorf("transactions:\n got %q\nwant %q", txs, tt.txs)
+			}
+			if tt.times != nil && !reflect.DeepEqual(times, tt.times) {
+				t.Errorf("times: got %v, want %v", times, tt.times)
+			}
+			if len(tt.errors) == 0 {
+				tt.errors = []RowError{}
+			}
+			if !reflect.DeepEqual(result.Errors, tt.errors) {
+				t.Errorf("errors: got %+v, want %+v", result.Errors, tt.errors)
+			}
+		})
+	}
+}
+
+func TestParseCSVExternalIDsAreStable(t *testing.T) {
+	input := "Date(UTC),Pair,Side,Price,Executed,Amount,Fee\n2024-03-01 12:00:00,BTCUSDT,BUY,50000,0.01BTC,500USDT,0.00001BTC\n"
+	first, err := ParseCSV(strings.NewReader(input), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := ParseCSV(strings.NewReader(input), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *first.Transactions[0].ExternalID != *second.Transactions[0].ExternalID {
+		t.Errorf("external ids differ between imports: %s vs %s", *first.Transactions[0].ExternalID, *second.Transactions[0].ExternalID)
+	}
+
+	// Identical rows without an exchange id are separate fills.
+	repeated, err := ParseCSV(strings.NewReader(input+strings.SplitN(input, "\n", 2)[1]), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repeated.Transactions) != 2 || *repeated.Transactions[0].ExternalID != *first.Transactions[0].ExternalID ||
+		*repeated.Transactions[1].ExternalID != *first.Transactions[0].ExternalID+":2" {
+		t.Errorf("repeated fills: %+v, errors %+v", repeated.Transactions, repeated.Errors)
+	}
+}
+
+func TestParseCSVInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		exchange string
+		input    string
+	}{
+		{name: "unknown exchange", exchange: "mtgox", input: "a,b\n"},
+		{name: "unknown header", input: "a,b,c\n1,2,3\n"},
+		{name: "wrong exchange", exchange: "kraken", input: "Date(UTC),Pair,Side,Price,Executed,Amount,Fee\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseCSV(strings.NewReader(tt.input), tt.exchange); !errors.Is(err, ErrInvalidImport) {
+				t.Errorf("expected ErrInvalidImport, got %v", err)
+			}
+		})
+	}
+}
+
+func TestAssetKrakenCodes(t *testing.T) {
+	for code, want := range map[string]string{
+		"XXBT": "BTC", "XBT": "BTC", "XXDG": "DOGE", "XETH": "ETH", "ZUSD": "USD", "ZEUR": "EUR",
+		"ZETA": "ZETA", "XCN": "XCN", "XTZ": "XTZ", "ZRX": "ZRX", "SOL": "SOL",
+	} {
+		if got := Asset("kraken", code); got != want {
+			t.Errorf("Asset(kraken, %s) = %s, want %s", code, got, want)
+		}
+	}
+}
+
+func describeTransaction(tx portfolio.Transaction) string {
+	description := strings.Join([]string{tx.AssetSymbol, tx.Type, formatFloat(tx.Amount), formatFloat(tx.PricePerUnitFiat), tx.FiatCurrency}, " ")
+	if tx.FeeAmount != nil {
+		description += " " + formatFloat(*tx.FeeAmount) + " " + *tx.FeeCurrency
+	}
+	return description
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,82 @@
+package importer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// krakenFormat reads Kraken trades.csv. Pairs use Kraken asset codes
+// (XXBTZUSD) and fees are charged in the quote currency.
+var krakenFormat = format{
+	name:    "kraken",
+	headers: [][]string{{"txid", "pair", "time", "type", "price", "cost", "fee", "vol"}},
+	parse:   parseKraken,
+}
+
+// krakenQuotes are the quote codes of Kraken pairs, longest first.
+var krakenQuotes = []string{"ZUSD", "ZEUR", "ZJPY", "ZGBP", "ZCAD", "XXBT", "XETH", "USDT", "USDC", "USD", "EUR", "JPY", "GBP", "CAD", "CHF", "AUD", "XBT", "ETH"}
+
+// krakenAssets maps Kraken codes that differ from common tickers: the X
+// (crypto) and Z (fiat) prefixed codes of assets listed before 2018 and
+// Kraken's own tickers. Newer four letter codes such as ZETA carry no
+// prefix.
+var krakenAssets = map[string]string{
+	"XBT": "BTC", "XDG": "DOGE",
+	"XXBT": "BTC", "XXDG": "DOGE", "XETH": "ETH", "XETC": "ETC", "XLTC": "LTC", "XMLN": "MLN", "XREP": "REP",
+	"XXLM": "XLM", "XXMR": "XMR", "XXRP": "XRP", "XZEC": "ZEC",
+	"ZUSD": "USD", "ZEUR": "EUR", "ZJPY": "JPY", "ZGBP": "GBP", "ZCAD": "CAD", "ZAUD": "AUD",
+}
+
+func parseKraken(fields row) (trade, error) {
+	timestamp, err := parseTime(fields.get("time"), time.UTC, "2006-01-02 15:04:05.9999", "2006-01-02 15:04:05")
+	if err != nil {
+		return trade{}, err
+	}
+	base, quote, err := splitKrakenPair(fields.get("pair"))
+	if err != nil {
+		return trade{}, err
+	}
+	price, err := parseDecimal(fields.get("price"))
+	if err != nil {
+		return trade{}, err
+	}
+	amount, err := parseDecimal(fields.get("vol"))
+	if err != nil {
+		return trade{}, err
+	}
+	fee, err := parseDecimal(fields.get("fee"))
+	if err != nil {
+		return trade{}, err
+	}
+
+	return trade{
+		timestamp:   timestamp,
+		side:        fields.get("type"),
+		base:        base,
+		quote:       quote,
+		amount:      amount,
+		price:       price,
+		fee:         fee,
+		feeCurrency: quote,
+		externalID:  fields.get("txid"),
+	}, nil
+}
+
+func splitKrakenPair(pair string) (string, string, error) {
+	pair = strings.ToUpper(strings.TrimSpace(strings.ReplaceAll(pair, "/", "")))
+	for _, quote := range krakenQuotes {
+		if len(pair) > len(quote) && strings.HasSuffix(pair, quote) {
+			return krakenAsset(strings.TrimSuffix(pair, quote)), krakenAsset(quote), nil
+		}
+	}
+	return "", "", fmt.Errorf("unknown pair %q", pair)
+}
+
+// krakenAsset maps legacy and Kraken-specific codes to common tickers.
+func krakenAsset(code string) string {
+	if mapped, found := krakenAssets[code]; found {
+		return mapped
+	}
+	return code
+}
@@ -0,0 +1,337 @@
+package importer
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// suggestSampleRows are the data rows inspected to guess formats.
+const suggestSampleRows = 20
+
+// Suggestion is a template guessed from a file's header and first rows.
+type Suggestion struct {
+	Template Template `json:"template"`
+	Headers  []string `json:"headers"`
+	// Missing lists required fields no header was matched to, and
+	// date_format when the dates fit several formats.
+	Missing []string `json:"missing,omitempty"`
+	// DateFormats lists the formats every sample date fits, such as
+	// DD/MM/YYYY and MM/DD/YYYY, when they cannot be told apart; the client
+	// picks one.
+	DateFormats []string `json:"date_formats,omitempty"`
+}
+
+// columnAliases are header names, lower-cased without punctuation, that
+// usually hold each field. Earlier aliases win.
+var columnAliases = []struct {
+	field   string
+	aliases []string
+}{
+	{"timestamp", []string{"timestamp", "datetime", "date", "time", "tradedate", "dateutc", "executedat", "createdat"}},
+	{"type", []string{"type", "side", "action", "direction", "transactiontype", "tradetype", "buysell", "operation"}},
+	{"symbol", []string{"symbol", "asset", "coin", "ticker", "token", "crypto", "cryptocurrency", "basecurrency", "base"}},
+	{"amount", []string{"amount", "quantity", "qty", "volume", "vol", "units", "size", "executed", "filled"}},
+	{"price", []string{"price", "unitprice", "priceperunit", "rate", "spotprice", "executionprice"}},
+	{"total", []string{"total", "value", "cost", "subtotal", "proceeds", "notional", "totalvalue"}},
+	{"fee_currency", []string{"feecurrency", "feeasset", "feecoin", "commissionasset", "feeunit"}},
+	{"fee", []string{"fee", "fees", "commission", "feeamount", "tradingfee"}},
+	{"fiat_currency", []string{"fiatcurrency", "fiat", "quotecurrency", "quote", "currency", "pricecurrency"}},
+	{"external_id", []string{"id", "txid", "transactionid", "tradeid", "orderid", "reference", "ref", "hash", "txhash"}},
+	{"notes", []string{"notes", "note", "comment", "comments", "description", "memo", "label"}},
+}
+
+var (
+	commaDecimal = regexp.MustCompile(`^-?\(?[0-9]{1,3}(\.[0-9]{3})*,[0-9]+\)?$|^-?\(?[0-9]+,[0-9]{1,2}\)?$|^-?\(?[0-9]+,[0-9]{4,}\)?$`)
+	// suggestLayouts are tried on sample dates, in token form.
+	suggestLayouts = []string{
+		"YYYY-MM-DDTHH:mm:ss", "YYYY-MM-DD HH:mm:ss", "YYYY-MM-DD HH:mm", "YYYY-MM-DD",
+		"YYYY/MM/DD HH:mm:ss", "YYYY/MM/DD HH:mm", "YYYY/MM/DD",
+		"DD.MM.YYYY HH:mm:ss", "DD.MM.YYYY HH:mm", "DD.MM.YYYY",
+		"DD/MM/YYYY HH:mm:ss", "DD/MM/YYYY HH:mm", "DD/MM/YYYY",
+		"MM/DD/YYYY HH:mm:ss", "MM/DD/YYYY HH:mm", "MM/DD/YYYY",
+	}
+)
+
+// Suggest guesses a template from the first rows of a file: the header row
+// after any preamble and its delimiter, columns from header names, the fiat
+// currency from a price or total header such as "Price (EUR)", and date
+// format, decimal separator and sign convention from sample values.
+func Suggest(input io.Reader) (*Suggestion, error) {
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	var lines []string
+	for len(lines) < headerScanRows+suggestSampleRows && scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	skipRows, delimiter := guessHeaderRow(lines)
+	reader := newCSVReader(strings.NewReader(strings.Join(lines, "\n")), delimiter)
+	header, err := readHeader(reader, skipRows)
+	if err != nil {
+		return nil, err
+	}
+
+	template := Template{SkipRows: skipRows}
+	if delimiter != ',' {
+		template.Delimiter = string(delimiter)
+	}
+	assigned := make(map[string]bool)
+	normalized := make(map[string]string, len(header))
+	for _, name := range header {
+		normalized[name] = normalizeHeader(name)
+	}
+	for _, column := range columnAliases {
+		for _, alias := range column.aliases {
+			if name := headerWithAlias(header, normalized, alias, assigned); name != "" {
+				assigned[name] = true
+				setColumn(&template.Columns, column.field, name)
+				break
+			}
+		}
+	}
+
+	if template.Columns.FiatCurrency == "" {
+		for _, name := range []string{template.Columns.Price, template.Columns.Total} {
+			if currency := headerCurrency(name); currency != "" {
+				template.FiatCurrency = currency
+				break
+			}
+		}
+	}
+
+	samples := make(map[string][]string)
+	for len(samples[header[0]]) < suggestSampleRows {
+		record, err := reader.Read()
+		if err != nil {
+			break
+		}
+		for i, name := range header {
+			if i < len(record) && strings.TrimSpace(record[i]) != "" {
+				samples[name] = append(samples[name], strings.TrimSpace(record[i]))
+			}
+		}
+	}
+
+	dateFormats := guessDateFormats(samples[template.Columns.Timestamp])
+	if len(dateFormats) == 1 {
+		template.DateFormat = dateFormats[0]
+	}
+	numbers := append(append([]string{}, samples[template.Columns.Amount]...), samples[template.Columns.Price]...)
+	if guessCommaDecimal(numbers) {
+		template.DecimalSeparator = ","
+	}
+	if template.Columns.Type != "" {
+		template.Sign = SignType
+	} else {
+		template.Sign = SignAmount
+	}
+
+	suggestion := &Suggestion{Template: template, Headers: header}
+	if len(dateFormats) > 1 {
+		suggestion.DateFormats = dateFormats
+		suggestion.Missing = append(suggestion.Missing, "date_format")
+	}
+	for field, name := range map[string]string{"timestamp": template.Columns.Timestamp, "symbol": template.Columns.Symbol, "amount": template.Columns.Amount} {
+		if name == "" {
+			suggestion.Missing = append(suggestion.Missing, field)
+		}
+	}
+	if template.Columns.Price == "" && template.Columns.Total == "" {
+		suggestion.Missing = append(suggestion.Missing, "price")
+	}
+	sort.Strings(suggestion.Missing)
+	return suggestion, nil
+}
+
+func headerWithAlias(header []string, normalized map[string]string, alias string, assigned map[string]bool) string {
+	for _, name := range header {
+		if !assigned[name] && normalized[name] == alias {
+			return name
+		}
+	}
+	return ""
+}
+
+func setColumn(columns *Columns, field, name string) {
+	switch field {
+	case "timestamp":
+		columns.Timestamp = name
+	case "type":
+		columns.Type = name
+	case "symbol":
+		columns.Symbol = name
+	case "amount":
+		columns.Amount = name
+	case "price":
+		columns.Price = name
+	case "total":
+		columns.Total = name
+	case "fee_currency":
+		columns.FeeCurrency = name
+	case "fee":
+		columns.Fee = name
+	case "fiat_currency":
+		columns.FiatCurrency = name
+	case "external_id":
+		columns.ExternalID = name
+	case "notes":
+		columns.Notes = name
+	}
+}
+
+// normalizeHeader lower-cases a header, drops a bracketed suffix such as
+// the currency in "Fee (EUR)" and keeps only letters and digits, so
+// "Fee (EUR)", "FEE" and "fee" compare equal.
+func normalizeHeader(name string) string {
+	if open := strings.IndexAny(name, "(["); open > 0 {
+		name = name[:open]
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return -1
+	}, name)
+}
+
+// headerCurrency returns the fiat currency in a bracketed header suffix,
+// e.g. EUR for "Price (EUR)".
+func headerCurrency(name string) string {
+	open := strings.IndexAny(name, "([")
+	if open <= 0 {
+		return ""
+	}
+	currency := normalizeCurrency(strings.Trim(name[open:], "()[] "))
+	if !isFiat(currency) {
+		return ""
+	}
+	return currency
+}
+
+// guessHeaderRow finds the header among the first rows as the row naming
+// the most known columns, and returns the non-blank rows before it and its
+// delimiter. Without a match the first row is the header.
+func guessHeaderRow(lines []string) (int, rune) {
+	skip, delimiter, best := 0, ',', 0
+	rows := 0
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if rows >= headerScanRows {
+			break
+		}
+		candidate := guessDelimiter(line)
+		if rows == 0 {
+			delimiter = candidate
+		}
+		record, err := newCSVReader(strings.NewReader(line), candidate).Read()
+		if err == nil {
+			if score := knownColumns(record); score > best && score >= 2 {
+				skip, delimiter, best = rows, candidate, score
+			}
+		}
+		rows++
+	}
+	return skip, delimiter
+}
+
+// knownColumns counts the cells of a row that are column aliases.
+func knownColumns(record []string) int {
+	count := 0
+	for _, cell := range record {
+		name := normalizeHeader(cell)
+		for _, column := range columnAliases {
+			if slices.Contains(column.aliases, name) {
+				count++
+				break
+			}
+		}
+	}
+	return count
+}
+
+// guessDelimiter picks the most frequent of comma, semicolon, tab and pipe.
+func guessDelimiter(line string) rune {
+	best, bestCount := ',', 0
+	for _, candidate := range []rune{',', ';', '\t', '|'} {
+		if count := strings.Count(line, string(candidate)); count > bestCount {
+			best, bestCount = candidate, count
+		}
+	}
+	return best
+}
+
+// guessDateFormats returns the formats that parse every sample, or
+// unix/unix_ms for epoch values. Day-first and month-first layouts are told
+// apart by the samples themselves; when no sample has a day past 12 both
+// are returned. No format is returned when the default formats apply.
+func guessDateFormats(samples []string) []string {
+	if len(samples) == 0 {
+		return nil
+	}
+	if allParse(samples, time.RFC3339Nano) {
+		// Handled by the default formats, including the offset.
+		return nil
+	}
+	if allDigits(samples) {
+		// Compact dates such as 20240131 are digits too but never plausible
+		// epoch values of the same length.
+		for _, format := range []string{"YYYYMMDD", "YYYYMMDDHHmmss"} {
+			if layout := dateTokens.Replace(format); len(samples[0]) == len(layout) && allParse(samples, layout) {
+				return []string{format}
+			}
+		}
+		if len(samples[0]) >= 13 {
+			return []string{DateUnixMs}
+		}
+		return []string{DateUnix}
+	}
+	var formats []string
+	for _, format := range suggestLayouts {
+		if allParse(samples, dateTokens.Replace(format)) {
+			formats = append(formats, format)
+		}
+	}
+	return formats
+}
+
+// guessCommaDecimal reports whether most numeric samples use a decimal
+// comma, as in 1.234,56 or 0,5.
+func guessCommaDecimal(samples []string) bool {
+	comma := 0
+	for _, sample := range samples {
+		if commaDecimal.MatchString(strings.ReplaceAll(sample, " ", "")) {
+			comma++
+		}
+	}
+	return comma > 0 && comma*2 >= len(samples)
+}
+
+func allDigits(samples []string) bool {
+	for _, sample := range samples {
+		for _, r := range sample {
+			if r < '0' || r > '9' {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func allParse(samples []string, layout string) bool {
+	for _, sample := range samples {
+		if _, err := time.Parse(layout, sample); err != nil {
+			return false
+		}
+	}
+	return true
+}
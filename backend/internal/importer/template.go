@@ -0,0 +1,462 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata"
+	"unicode/utf8"
+
+	"crypto-portfolio-backend/internal/portfolio"
+)
+
+// templateSource prefixes the external ids of template imports.
+const templateSource = "csv"
+
+// Sign conventions telling buys from sells.
+const (
+	// SignType reads the side from the type column.
+	SignType = "type"
+	// SignAmount treats negative amounts as sells.
+	SignAmount = "amount"
+	// SignCashFlow treats negative totals (cash paid) as buys and positive
+	// totals as sells.
+	SignCashFlow = "cash_flow"
+)
+
+// Date formats that are not layouts.
+const (
+	DateUnix   = "unix"
+	DateUnixMs = "unix_ms"
+)
+
+// Columns maps transaction fields to header names of the file.
+type Columns struct {
+	Timestamp    string `json:"timestamp"`
+	Symbol       string `json:"symbol"`
+	Amount       string `json:"amount"`
+	Price        string `json:"price,omitempty"`
+	Total        string `json:"total,omitempty"`
+	FiatCurrency string `json:"fiat_currency,omitempty"`
+	Fee          string `json:"fee,omitempty"`
+	FeeCurrency  string `json:"fee_currency,omitempty"`
+	Type         string `json:"type,omitempty"`
+	ExternalID   string `json:"external_id,omitempty"`
+	Notes        string `json:"notes,omitempty"`
+}
+
+// Template describes the layout of a spreadsheet export.
+type Template struct {
+	// Delimiter is a single character; "," by default.
+	Delimiter string `json:"delimiter,omitempty"`
+	// SkipRows are skipped before the header row.
+	SkipRows int     `json:"skip_rows,omitempty"`
+	Columns  Columns `json:"columns"`
+	// DateFormat uses YYYY, YY, MM, DD, HH, mm, ss and SSS tokens, or unix
+	// or unix_ms. Common ISO-like formats are tried when empty.
+	DateFormat string `json:"date_format,omitempty"`
+	// Timezone is an IANA name or a ±HH:MM offset for dates without one;
+	// UTC by default.
+	Timezone string `json:"timezone,omitempty"`
+	// DecimalSeparator is "." (default) or ","; the other one, spaces and
+	// apostrophes are read as thousands separators.
+	DecimalSeparator string `json:"decimal_separator,omitempty"`
+	// Sign is type, amount or cash_flow; type when a type column is mapped,
+	// else amount.
+	Sign string `json:"sign,omitempty"`
+	// BuyValues and SellValues are the type column values of each side,
+	// matched case-insensitively.
+	BuyValues  []string `json:"buy_values,omitempty"`
+	SellValues []string `json:"sell_values,omitempty"`
+	// FiatCurrency applies when no fiat column is mapped; USD by default.
+	FiatCurrency string `json:"fiat_currency,omitempty"`
+	// Source is MANUAL (default), WALLET or EXCHANGE.
+	Source string `json:"source,omitempty"`
+}
+
+var (
+	defaultBuyValues  = []string{"buy", "bought", "purchase"}
+	defaultSellValues = []string{"sell", "sold", "sale"}
+	// autoLayouts are tried in order when a template has no date format.
+	autoLayouts = []string{
+		time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02",
+		"2006/01/02 15:04:05", "2006/01/02 15:04", "2006/01/02",
+	}
+	dateTokens = strings.NewReplacer("YYYY", "2006", "YY", "06", "MM", "01", "DD", "02", "HH", "15", "mm", "04", "ss", "05", "SSS", "000")
+)
+
+// compiledTemplate is a validated template ready to parse rows.
+type compiledTemplate struct {
+	Template
+	delimiter rune
+	layouts   []string
+	location  *time.Location
+	buy       map[string]struct{}
+	sell      map[string]struct{}
+}
+
+// compile validates the template and fills its defaults.
+func (t Template) compile() (*compiledTemplate, error) {
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf("%w: template %s", ErrInvalidImport, fmt.Sprintf(format, args...))
+	}
+
+	compiled := &compiledTemplate{Template: t, delimiter: ',', location: time.UTC}
+	if t.Delimiter != "" {
+		if t.Delimiter == `\t` {
+			t.Delimiter = "\t"
+		}
+		r, size := utf8.DecodeRuneInString(t.Delimiter)
+		if size != len(t.Delimiter) || r == '"' || r == '\n' || r == '\r' {
+			return nil, invalid("delimiter must be a single character")
+		}
+		compiled.delimiter = r
+	}
+	if t.SkipRows < 0 || t.SkipRows > headerScanRows {
+		return nil, invalid("skip_rows must be between 0 and %d", headerScanRows)
+	}
+
+	columns := t.Columns
+	switch {
+	case columns.Timestamp == "" || columns.Symbol == "" || columns.Amount == "":
+		return nil, invalid("columns must map timestamp, symbol and amount")
+	case columns.Price == "" && columns.Total == "":
+		return nil, invalid("columns must map price or total")
+	}
+
+	switch t.DateFormat {
+	case "":
+		compiled.layouts = autoLayouts
+	case DateUnix, DateUnixMs:
+	default:
+		compiled.layouts = []string{dateTokens.Replace(t.DateFormat)}
+	}
+
+	if t.Timezone != "" {
+		location, err := parseLocation(t.Timezone)
+		if err != nil {
+			return nil, invalid("timezone %q is unknown", t.Timezone)
+		}
+		compiled.location = location
+	}
+
+	switch t.DecimalSeparator {
+	case "":
+		compiled.DecimalSeparator = "."
+	case ".", ",":
+	default:
+		return nil, invalid(`decimal_separator must be "." or ","`)
+	}
+
+	switch t.Sign {
+	case "":
+		compiled.Sign = SignAmount
+		if columns.Type != "" {
+			compiled.Sign = SignType
+		}
+	case SignType:
+		if columns.Type == "" {
+			return nil, invalid("sign type needs a type column")
+		}
+	case SignCashFlow:
+		if columns.Total == "" {
+			return nil, invalid("sign cash_flow needs a total column")
+		}
+	case SignAmount:
+	default:
+		return nil, invalid("sign must be %s, %s or %s", SignType, SignAmount, SignCashFlow)
+	}
+
+	compiled.buy = valueSet(t.BuyValues, defaultBuyValues)
+	compiled.sell = valueSet(t.SellValues, defaultSellValues)
+
+	compiled.FiatCurrency = normalizeCurrency(t.FiatCurrency)
+	if compiled.FiatCurrency == "" {
+		compiled.FiatCurrency = "USD"
+	}
+
+	switch source := strings.ToUpper(strings.TrimSpace(t.Source)); source {
+	case "":
+		compiled.Source = portfolio.SourceManual
+	case portfolio.SourceManual, portfolio.SourceWallet, portfolio.SourceExchange:
+		compiled.Source = source
+	default:
+		return nil, invalid("source must be MANUAL, WALLET or EXCHANGE")
+	}
+
+	return compiled, nil
+}
+
+// ParseTemplateCSV reads a spreadsheet export laid out as described by
+// template.
+func ParseTemplateCSV(input io.Reader, template Template) (*Result, error) {
+	compiled, err := template.compile()
+	if err != nil {
+		return nil, err
+	}
+	reader := newCSVReader(input, compiled.delimiter)
+
+	header, err := readHeader(reader, compiled.SkipRows)
+	if err != nil {
+		return nil, err
+	}
+	if missing := compiled.missingColumns(header); len(missing) > 0 {
+		return nil, fmt.Errorf("%w: columns not found in header: %s", ErrInvalidImport, strings.Join(missing, ", "))
+	}
+
+	return readRows(reader, header, templateSource, compiled.Source, compiled.parse)
+}
+
+// readHeader skips rows and blank lines and returns the header row.
+func readHeader(reader interface{ Read() ([]string, error) }, skip int) ([]string, error) {
+	for skipped := 0; ; {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil, fmt.Errorf("%w: no header row found", ErrInvalidImport)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidImport, err)
+		}
+		if skipped < skip {
+			skipped++
+			continue
+		}
+		if !isBlank(record) {
+			return cleanHeader(record), nil
+		}
+	}
+}
+
+// missingColumns lists mapped columns absent from the header.
+func (c *compiledTemplate) missingColumns(header []string) []string {
+	present := make(map[string]struct{}, len(header))
+	for _, name := range header {
+		present[name] = struct{}{}
+	}
+
+	var missing []string
+	for _, name := range c.Columns.names() {
+		if _, found := present[name]; !found {
+			missing = append(missing, strconv.Quote(name))
+		}
+	}
+	return missing
+}
+
+func (c *compiledTemplate) parse(fields row) (trade, error) {
+	columns := c.Columns
+	t := trade{
+		base:        fields.get(columns.Symbol),
+		quote:       c.FiatCurrency,
+		feeCurrency: fields.get(columns.FeeCurrency),
+		externalID:  fields.get(columns.ExternalID),
+		notes:       fields.get(columns.Notes),
+	}
+	if columns.FiatCurrency != "" {
+		t.quote = fields.get(columns.FiatCurrency)
+	}
+
+	var err error
+	if t.timestamp, err = c.parseDate(fields.get(columns.Timestamp)); err != nil {
+		return trade{}, err
+	}
+	if t.amount, err = c.parseNumber(fields.get(columns.Amount)); err != nil {
+		return trade{}, fmt.Errorf("amount: %w", err)
+	}
+	if t.price, err = c.parseNumber(fields.get(columns.Price)); err != nil {
+		return trade{}, fmt.Errorf("price: %w", err)
+	}
+	total, err := c.parseNumber(fields.get(columns.Total))
+	if err != nil {
+		return trade{}, fmt.Errorf("total: %w", err)
+	}
+	if t.fee, err = c.parseNumber(fields.get(columns.Fee)); err != nil {
+		return trade{}, fmt.Errorf("fee: %w", err)
+	}
+
+	t.price = math.Abs(t.price)
+	if t.price == 0 && total != 0 && t.amount != 0 {
+		t.price = math.Abs(total / t.amount)
+	}
+
+	switch c.Sign {
+	case SignType:
+		value := strings.ToLower(fields.get(columns.Type))
+		if _, found := c.buy[value]; found {
+			t.side = "BUY"
+		} else if _, found := c.sell[value]; found {
+			t.side = "SELL"
+		} else {
+			return trade{}, fmt.Errorf("unknown type %q", fields.get(columns.Type))
+		}
+	case SignCashFlow:
+		// A blank or zero total says nothing about the side.
+		if total == 0 {
+			return trade{}, errors.New("total is needed to tell a buy from a sell")
+		}
+		t.side = "SELL"
+		if total < 0 {
+			t.side = "BUY"
+		}
+	default:
+		t.side = "BUY"
+		if t.amount < 0 {
+			t.side = "SELL"
+		}
+	}
+	return t, nil
+}
+
+func (c *compiledTemplate) parseDate(value string) (time.Time, error) {
+	switch c.DateFormat {
+	case DateUnix, DateUnixMs:
+		number, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date %q", value)
+		}
+		if c.DateFormat == DateUnix {
+			return time.Unix(number, 0), nil
+		}
+		return time.UnixMilli(number), nil
+	}
+	return parseTime(value, c.location, c.layouts...)
+}
+
+// parseNumber reads a number with the template's decimal separator.
+// Accounting negatives such as (12.50) are accepted.
+func (c *compiledTemplate) parseNumber(value string) (float64, error) {
+	value = strings.TrimSpace(value)
+	negative := strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")")
+	if negative {
+		value = value[1 : len(value)-1]
+	}
+
+	thousands := ","
+	if c.DecimalSeparator == "," {
+		thousands = "."
+	}
+	value = strings.NewReplacer(thousands, "", "'", "").Replace(value)
+	if c.DecimalSeparator == "," {
+		value = strings.ReplaceAll(value, ",", ".")
+	}
+
+	number, err := parseDecimal(value)
+	if negative {
+		number = -number
+	}
+	return number, err
+}
+
+// names lists the mapped header names.
+func (c Columns) names() []string {
+	var names []string
+	for _, name := range []string{c.Timestamp, c.Symbol, c.Amount, c.Price, c.Total, c.FiatCurrency, c.Fee, c.FeeCurrency, c.Type, c.ExternalID, c.Notes} {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func valueSet(values, defaults []string) map[string]struct{} {
+	if len(values) == 0 {
+		values = defaults
+	}
+	set := make(map[string]struct{}, len(values))
+	for _, value := range values {
+		set[strings.ToLower(strings.TrimSpace(value))] = struct{}{}
+	}
+	return set
+}
+
+// parseLocation accepts IANA names and fixed ±HH:MM offsets.
+func parseLocation(name string) (*time.Location, error) {
+	if offset, err := time.Parse("-07:00", name); err == nil {
+		_, seconds := offset.Zone()
+		return time.FixedZone(name, seconds), nil
+	}
+	if strings.EqualFold(name, "UTC") {
+		return time.UTC, nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, errors.New("unknown timezone")
+	}
+	return location, nil
+}
+
+// defaultPreviewRows and maxPreviewRows bound the transactions returned
+// by a preview.
+const (
+	defaultPreviewRows = 20
+	maxPreviewRows     = 200
+)
+
+// TemplateRequest is the body of POST /import/csv/template and
+// POST /import/csv/preview.
+type TemplateRequest struct {
+	CSV string `json:"csv"`
+	// Template is suggested from the header when omitted (preview only).
+	Template *Template `json:"template,omitempty"`
+	// Limit bounds the previewed transactions; 20 by default.
+	Limit int `json:"limit,omitempty"`
+}
+
+// Preview is a dry run of a template import.
+type Preview struct {
+	Template Template `json:"template"`
+	// Suggested is set when the template was guessed from the header.
+	Suggested bool     `json:"suggested"`
+	Headers   []string `json:"headers,omitempty"`
+	// Missing lists required fields the suggestion could not map; nothing
+	// is parsed until they are filled in.
+	Missing []string `json:"missing,omitempty"`
+	// DateFormats lists the date formats to choose from when the suggestion
+	// could not tell day-first from month-first dates.
+	DateFormats []string `json:"date_formats,omitempty"`
+	Rows        int      `json:"rows"`
+	// Valid counts the rows that would be imported.
+	Valid        int                     `json:"valid"`
+	Transactions []portfolio.Transaction `json:"transactions"`
+	Errors       []RowError              `json:"errors"`
+}
+
+// PreviewCSV parses a file as POST /import/csv/template would and returns
+// the first transactions with every row error, suggesting a template when
+// none is given.
+func PreviewCSV(request TemplateRequest) (*Preview, error) {
+	limit := request.Limit
+	if limit <= 0 {
+		limit = defaultPreviewRows
+	}
+	limit = min(limit, maxPreviewRows)
+
+	preview := &Preview{Transactions: make([]portfolio.Transaction, 0), Errors: make([]RowError, 0)}
+	if request.Template == nil {
+		suggestion, err := Suggest(strings.NewReader(request.CSV))
+		if err != nil {
+			return nil, err
+		}
+		preview.Template, preview.Suggested, preview.Headers, preview.Missing = suggestion.Template, true, suggestion.Headers, suggestion.Missing
+		preview.DateFormats = suggestion.DateFormats
+		if len(preview.Missing) > 0 {
+			return preview, nil
+		}
+	} else {
+		preview.Template = *request.Template
+	}
+
+	result, err := ParseTemplateCSV(strings.NewReader(request.CSV), preview.Template)
+	if err != nil {
+		return nil, err
+	}
+	preview.Rows = result.Rows
+	preview.Valid = len(result.Transactions)
+	preview.Transactions = result.Transactions[:min(limit, len(result.Transactions))]
+	preview.Errors = result.Errors
+	return preview, nil
+}
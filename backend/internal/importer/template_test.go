@@ -0,0 +1,250 @@
+package importer
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTemplateCSV(t *testing.T) {
+	tests := []struct {
+		name     string
+		template Template
+		input    string
+		txs      []string
+		errors   []RowError
+		times    []string
+		source   string
+	}{
+		{
+			name: "german spreadsheet",
+			template: Template{
+				Delimiter:        ";",
+				SkipRows:         1,
+				Columns:          Columns{Timestamp: "Datum", Symbol: "Coin", Amount: "Menge", Price: "Kurs", Type: "Art", Fee: "Gebühr", Notes: "Notiz"},
+				DateFormat:       "DD.MM.YYYY HH:mm",
+				Timezone:         "Europe/Berlin",
+				DecimalSeparator: ",",
+				BuyValues:        []string{"Kauf"},
+				SellValues:       []string{"Verkauf"},
+				FiatCurrency:     "eur",
+			},
+			input: "Mein Depot\n" +
+				"Datum;Coin;Art;Menge;Kurs;Gebühr;Notiz\n" +
+				"15.01.2024 10:30;btc;Kauf;0,5;\"38.500,25\";1,50;Sparplan\n" +
+				"16.01.2024 10:30;ETH;Tausch;1;2.000;0;\n" +
+				"01.07.2024 09:00;BTC;Verkauf;0,25;55.000;(2,00);\n",
+			txs:    []string{"BTC BUY 0.5 38500.25 EUR 1.5 EUR", "BTC SELL -0.25 55000 EUR 2 EUR"},
+			errors: []RowError{{Row: 4, Message: `unknown type "Tausch"`}},
+			times:  []string{"2024-01-15T09:30:00Z", "2024-07-01T07:00:00Z"},
+			source: "MANUAL",
+		},
+		{
+			name: "cash flow totals",
+			template: Template{
+				Columns:    Columns{Timestamp: "when", Symbol: "asset", Amount: "qty", Total: "cash", FiatCurrency: "ccy", ExternalID: "ref"},
+				DateFormat: DateUnix,
+				Sign:       SignCashFlow,
+				Source:     "exchange",
+			},
+			input: "when,asset,qty,cash,ccy,ref\n" +
+				"1704067200,SOL,10,-1000,USDT,r1\n" +
+				"1704153600,SOL,4,480,USD,r2\n" +
+				"1704153600,SOL,4,480,USD,r2\n" +
+				"1704240000,SOL,1,,USD,r3\n",
+			txs:    []string{"SOL BUY 10 100 USD", "SOL SELL -4 120 USD"},
+			errors: []RowError{{Row: 4, Message: "duplicate of row 3"}, {Row: 5, Message: "total is needed to tell a buy from a sell"}},
+			times:  []string{"2024-01-01T00:00:00Z", "2024-01-02T00:00:00Z"},
+			source: "EXCHANGE",
+		},
+		{
+			name: "signed amounts with offset",
+			template: Template{
+				Columns:  Columns{Timestamp: "Date", Symbol: "Symbol", Amount: "Amount", Price: "Price"},
+				Timezone: "+09:00",
+			},
+			input: "Date,Symbol,Amount,Price\n" +
+				"2024-03-01 09:00:00,BTC,0.1,\"60,000\"\n" +
+				"2024-03-02,BTC,-0.05,61000\n" +
+				"yesterday,BTC,1,1\n",
+			txs:    []string{"BTC BUY 0.1 60000 USD", "BTC SELL -0.05 61000 USD"},
+			errors: []RowError{{Row: 4, Message: `invalid date "yesterday"`}},
+			times:  []string{"2024-03-01T00:00:00Z", "2024-03-01T15:00:00Z"},
+			source: "MANUAL",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseTemplateCSV(strings.NewReader(tt.input), tt.template)
+			if err != nil {
+				t.Fatalf("ParseTemplateCSV: %v", err)
+			}
+
+			var txs, times []string
+			for _, tx := range result.Transactions {
+				txs = append(txs, describeTransaction(tx))
+				times = append(times, time.UnixMilli(tx.Timestamp).UTC().Format(time.RFC3339))
+				if tx.Source != tt.source || tx.ExternalID == nil || !strings.HasPrefix(*tx.ExternalID, "csv:") {
+					t.Errorf("unexpected source or external id: %s %v", tx.Source, tx.ExternalID)
+				}
+			}
+			if !reflect.DeepEqual(txs, tt.txs) {
+				t.Errorf("transactions:\n got %q\nwant %q", txs, tt.txs)
+			}
+			if !reflect.DeepEqual(times, tt.times) {
+				t.Errorf("times: got %v, want %v", times, tt.times)
+			}
+			if !reflect.DeepEqual(result.Errors, tt.errors) {
+				t.Errorf("errors: got %+v, want %+v", result.Errors, tt.errors)
+			}
+		})
+	}
+}
+
+func TestParseTemplateCSVInvalid(t *testing.T) {
+	columns := Columns{Timestamp: "Date", Symbol: "Symbol", Amount: "Amount", Price: "Price"}
+	tests := []struct {
+		name     string
+		template Template
+	}{
+		{name: "missing columns", template: Template{Columns: Columns{Timestamp: "Date", Symbol: "Symbol"}}},
+		{name: "no price or total", template: Template{Columns: Columns{Timestamp: "Date", Symbol: "Symbol", Amount: "Amount"}}},
+		{name: "unknown header", template: Template{Columns: Columns{Timestamp: "Date", Symbol: "Symbol", Amount: "Amount", Price: "Rate"}}},
+		{name: "bad delimiter", template: Template{Columns: columns, Delimiter: ";;"}},
+		{name: "bad separator", template: Template{Columns: columns, DecimalSeparator: "'"}},
+		{name: "bad timezone", template: Template{Columns: columns, Timezone: "Mars/Olympus"}},
+		{name: "type sign without column", template: Template{Columns: columns, Sign: SignType}},
+		{name: "bad source", template: Template{Columns: columns, Source: "BANK"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseTemplateCSV(strings.NewReader("Date,Symbol,Amount,Price\n"), tt.template)
+			if !errors.Is(err, ErrInvalidImport) {
+				t.Errorf("expected ErrInvalidImport, got %v", err)
+			}
+		})
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    Template
+		missing []string
+		formats []string
+	}{
+		{
+			name: "semicolons and decimal commas",
+			input: "Date;Type;Coin;Quantity;Price (EUR);Fee;Comment\n" +
+				"31/01/2024 10:00;Buy;BTC;0,5;38000,5;1,2;\n" +
+				"01/02/2024 10:00;Sell;BTC;0,25;39000;1;\n",
+			want: Template{
+				Delimiter:        ";",
+				Columns:          Columns{Timestamp: "Date", Type: "Type", Symbol: "Coin", Amount: "Quantity", Price: "Price (EUR)", Fee: "Fee", Notes: "Comment"},
+				DateFormat:       "DD/MM/YYYY HH:mm",
+				DecimalSeparator: ",",
+				Sign:             SignType,
+				FiatCurrency:     "EUR",
+			},
+		},
+		{
+			name: "preamble and compact dates",
+			input: "Account statement\n" +
+				"Exported 2024-02-01\n" +
+				"\n" +
+				"Date,Asset,Side,Qty,Total [GBP]\n" +
+				"20240131,BTC,buy,1,30000\n",
+			want: Template{
+				SkipRows:     2,
+				Columns:      Columns{Timestamp: "Date", Symbol: "Asset", Type: "Side", Amount: "Qty", Total: "Total [GBP]"},
+				DateFormat:   "YYYYMMDD",
+				Sign:         SignType,
+				FiatCurrency: "GBP",
+			},
+		},
+		{
+			name:  "epoch milliseconds and totals",
+			input: "time,token,amount,value,currency,txid\n1704067200000,ETH,-1,2000,USD,a\n",
+			want: Template{
+				Columns:    Columns{Timestamp: "time", Symbol: "token", Amount: "amount", Total: "value", FiatCurrency: "currency", ExternalID: "txid"},
+				DateFormat: DateUnixMs,
+				Sign:       SignAmount,
+			},
+		},
+		{
+			name:  "ambiguous day and month",
+			input: "Date,Coin,Quantity,Price\n01/02/2024,BTC,1,40000\n03/04/2024,BTC,-1,60000\n",
+			want: Template{
+				Columns: Columns{Timestamp: "Date", Symbol: "Coin", Amount: "Quantity", Price: "Price"},
+				Sign:    SignAmount,
+			},
+			missing: []string{"date_format"},
+			formats: []string{"DD/MM/YYYY", "MM/DD/YYYY"},
+		},
+		{
+			name:    "unmapped columns",
+			input:   "when,what\n2024-01-01,x\n",
+			want:    Template{Columns: Columns{}, Sign: SignAmount},
+			missing: []string{"amount", "price", "symbol", "timestamp"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			suggestion, err := Suggest(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("Suggest: %v", err)
+			}
+			if !reflect.DeepEqual(suggestion.Template, tt.want) {
+				t.Errorf("template:\n got %+v\nwant %+v", suggestion.Template, tt.want)
+			}
+			if !reflect.DeepEqual(suggestion.Missing, tt.missing) {
+				t.Errorf("missing: got %v, want %v", suggestion.Missing, tt.missing)
+			}
+			if !reflect.DeepEqual(suggestion.DateFormats, tt.formats) {
+				t.Errorf("date formats: got %v, want %v", suggestion.DateFormats, tt.formats)
+			}
+		})
+	}
+}
+
+func TestPreviewCSV(t *testing.T) {
+	input := "Date,Asset,Side,Qty,Price\n" +
+		"2024-01-01,BTC,buy,1,40000\n" +
+		"2024-01-02,BTC,buy,1,41000\n" +
+		"2024-01-03,BTC,swap,1,42000\n"
+
+	preview, err := PreviewCSV(TemplateRequest{CSV: input, Limit: 1})
+	if err != nil {
+		t.Fatalf("PreviewCSV: %v", err)
+	}
+	if !preview.Suggested || preview.Template.Columns.Type != "Side" {
+		t.Errorf("expected a suggested template, got %+v", preview.Template)
+	}
+	if preview.Rows != 3 || preview.Valid != 2 || len(preview.Transactions) != 1 || len(preview.Errors) != 1 {
+		t.Errorf("got rows=%d valid=%d transactions=%d errors=%+v", preview.Rows, preview.Valid, len(preview.Transactions), preview.Errors)
+	}
+
+	preview, err = PreviewCSV(TemplateRequest{CSV: "My exchange export\nDate,Asset,Side,Qty,Price (EUR)\n2024-01-01,BTC,buy,1,40000\n2024-01-02,BTC,in,1,40000\n"})
+	if err != nil {
+		t.Fatalf("PreviewCSV: %v", err)
+	}
+	if preview.Template.SkipRows != 1 || preview.Valid != 1 || preview.Transactions[0].FiatCurrency != "EUR" {
+		t.Errorf("preamble: got template %+v, valid=%d, transactions %+v", preview.Template, preview.Valid, preview.Transactions)
+	}
+	if len(preview.Errors) != 1 {
+		t.Errorf("expected the ambiguous type \"in\" to be a row error, got %+v", preview.Errors)
+	}
+
+	preview, err = PreviewCSV(TemplateRequest{CSV: "a,b\n1,2\n"})
+	if err != nil {
+		t.Fatalf("PreviewCSV: %v", err)
+	}
+	if len(preview.Missing) == 0 || len(preview.Transactions) != 0 {
+		t.Errorf("expected missing fields and no transactions, got %+v", preview)
+	}
+}
@@ -0,0 +1,238 @@
+package normalize
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	// transferTolerance is the share of a transfer that may go missing
+	// between its legs without a declared fee.
+	transferTolerance = 0.01
+	// clockSkew lets the receiving side book a transfer slightly before
+	// the sending side does.
+	clockSkew = 10 * 60 * 1000
+	// amountEpsilon absorbs float noise when comparing leg amounts.
+	amountEpsilon = 1e-9
+)
+
+// classifier assigns every event to exactly one entry.
+type classifier struct {
+	events  []Event
+	used    []bool
+	entries []Entry
+}
+
+// classify pairs swap legs, then links transfers, and books what is left
+// as single-leg entries of the event's type.
+func classify(events []Event, window int64) []Entry {
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].Timestamp != events[j].Timestamp {
+			return events[i].Timestamp < events[j].Timestamp
+		}
+		return events[i].ID < events[j].ID
+	})
+	c := &classifier{events: events, used: make([]bool, len(events))}
+	c.pairSwaps()
+	c.linkTransfers(window)
+	for i, event := range c.events {
+		if c.used[i] {
+			continue
+		}
+		entryType := event.Type
+		if entryType == TypeSwap {
+			// An unpaired swap leg is a plain inflow or outflow.
+			entryType = TypeDeposit
+			if event.Amount < 0 {
+				entryType = TypeWithdrawal
+			}
+		}
+		c.add(Entry{ID: event.ID, Type: entryType, Legs: []Leg{legOf(event)}, Notes: event.Notes}, i)
+	}
+
+	sort.SliceStable(c.entries, func(i, j int) bool {
+		if c.entries[i].Timestamp != c.entries[j].Timestamp {
+			return c.entries[i].Timestamp < c.entries[j].Timestamp
+		}
+		return c.entries[i].ID < c.entries[j].ID
+	})
+	return c.entries
+}
+
+// pairSwaps books the events of one account and group as a swap when
+// exactly one asset left and another arrived; fee events of the group
+// belong to the swap.
+func (c *classifier) pairSwaps() {
+	var keys []string
+	groups := make(map[string][]int)
+	for i, event := range c.events {
+		if event.Group == "" {
+			continue
+		}
+		switch event.Type {
+		case TypeDeposit, TypeWithdrawal, TypeSwap, TypeFee:
+		default:
+			continue
+		}
+		key := event.Account + "\x00" + event.Group
+		if _, found := groups[key]; !found {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], i)
+	}
+
+	for _, key := range keys {
+		var ins, outs, fees []int
+		for _, i := range groups[key] {
+			switch {
+			case c.events[i].Type == TypeFee:
+				fees = append(fees, i)
+			case c.events[i].Amount > 0:
+				ins = append(ins, i)
+			default:
+				outs = append(outs, i)
+			}
+		}
+		if len(ins) != 1 || len(outs) != 1 || c.events[ins[0]].Asset == c.events[outs[0]].Asset {
+			continue
+		}
+
+		out, in := c.events[outs[0]], c.events[ins[0]]
+		c.add(Entry{
+			ID:    out.ID,
+			Type:  TypeSwap,
+			Legs:  []Leg{legOf(out), legOf(in)},
+			Notes: "Swap " + out.Asset + " → " + in.Asset,
+		}, append([]int{outs[0], ins[0]}, fees...)...)
+	}
+}
+
+// linkTransfers pairs each withdrawal with a deposit of the same asset in
+// another account: the one with the same transaction hash, or else the
+// closest one within window whose amount matches within
+// transferTolerance.
+func (c *classifier) linkTransfers(window int64) {
+	deposits := make(map[string][]int)
+	for i, event := range c.events {
+		if !c.used[i] && event.Type == TypeDeposit {
+			deposits[event.Asset] = append(deposits[event.Asset], i)
+		}
+	}
+
+	for i, out := range c.events {
+		if c.used[i] || out.Type != TypeWithdrawal {
+			continue
+		}
+		sent := math.Abs(out.Amount)
+		best, bestGap, bestHash := -1, int64(math.MaxInt64), false
+		for _, j := range deposits[out.Asset] {
+			in := c.events[j]
+			if c.used[j] || in.Account == out.Account {
+				continue
+			}
+			if out.TxHash != "" && in.TxHash != "" {
+				if out.TxHash == in.TxHash && !bestHash {
+					best, bestHash = j, true
+				}
+				continue
+			}
+			if bestHash || in.Timestamp < out.Timestamp-clockSkew || in.Timestamp > out.Timestamp+window {
+				continue
+			}
+			if in.Amount > sent*(1+amountEpsilon) || in.Amount < sent*(1-transferTolerance) {
+				continue
+			}
+			if gap := abs64(in.Timestamp - out.Timestamp); gap < bestGap {
+				best, bestGap = j, gap
+			}
+		}
+		if best < 0 {
+			continue
+		}
+
+		in := c.events[best]
+		entry := Entry{
+			ID:    out.ID,
+			Type:  TypeTransfer,
+			Legs:  []Leg{legOf(out), legOf(in)},
+			Notes: "Transfer " + out.Account + " → " + in.Account,
+		}
+		// What left but never arrived was lost on the way.
+		if shortfall := roundAmount(sent - in.Amount); shortfall > sent*amountEpsilon {
+			shortfallLeg := legOf(out)
+			shortfallLeg.Amount = -shortfall
+			shortfallLeg.PricePerUnitFiat = priceOf(out, in)
+			entry.Fees = append(entry.Fees, shortfallLeg)
+		}
+		c.add(entry, i, best)
+	}
+}
+
+// add records entry for the events at indexes, legs first. Fees declared
+// on the events and fee events become fee legs.
+func (c *classifier) add(entry Entry, indexes ...int) {
+	var fees []Leg
+	for _, i := range indexes {
+		c.used[i] = true
+		event := c.events[i]
+		entry.events = append(entry.events, event)
+		if event.Type == TypeFee && entry.Type != TypeFee {
+			fees = append(fees, legOf(event))
+			continue
+		}
+		if event.FeeAmount > 0 {
+			fee := Leg{
+				EventID:      event.ID,
+				Account:      event.Account,
+				Asset:        event.FeeCurrency,
+				Amount:       -event.FeeAmount,
+				FiatCurrency: event.FiatCurrency,
+				TxHash:       event.TxHash,
+			}
+			switch fee.Asset {
+			case event.Asset:
+				fee.PricePerUnitFiat = event.PricePerUnitFiat
+			case event.FiatCurrency:
+				fee.PricePerUnitFiat = 1
+			}
+			fees = append(fees, fee)
+		}
+	}
+	entry.Fees = append(fees, entry.Fees...)
+	entry.Timestamp = entry.events[0].Timestamp
+	c.entries = append(c.entries, entry)
+}
+
+func legOf(event Event) Leg {
+	return Leg{
+		EventID:          event.ID,
+		Account:          event.Account,
+		Asset:            event.Asset,
+		Amount:           event.Amount,
+		PricePerUnitFiat: event.PricePerUnitFiat,
+		FiatCurrency:     event.FiatCurrency,
+		TxHash:           event.TxHash,
+	}
+}
+
+// priceOf returns the first known price of the same asset among events.
+func priceOf(events ...Event) float64 {
+	for _, event := range events {
+		if event.PricePerUnitFiat > 0 {
+			return event.PricePerUnitFiat
+		}
+	}
+	return 0
+}
+
+// roundAmount drops the float noise left by subtracting leg amounts.
+func roundAmount(value float64) float64 {
+	return math.Round(value*1e12) / 1e12
+}
+
+func abs64(value int64) int64 {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
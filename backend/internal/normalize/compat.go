@@ -0,0 +1,149 @@
+package normalize
+
+import (
+	"math"
+
+	"crypto-portfolio-backend/internal/importer"
+	"crypto-portfolio-backend/internal/portfolio"
+)
+
+// defaultNotes label entries the mobile schema has no type for.
+var defaultNotes = map[string]string{
+	TypeDeposit:       "Deposit",
+	TypeWithdrawal:    "Withdrawal",
+	TypeStakingReward: "Staking reward",
+	TypeAirdrop:       "Airdrop",
+	TypeFee:           "Fee",
+}
+
+// compatible projects an entry onto BUY/SELL transactions. Inflows are
+// BUYs at their price, which makes rewards and airdrops income at market
+// value; outflows and fees are SELLs. A swap is a SELL and a BUY of the
+// same value, and a transfer between own accounts only books its fees.
+// Fiat legs are not holdings and are left out.
+func compatible(entry Entry) []portfolio.Transaction {
+	switch entry.Type {
+	case TypeSwap:
+		return swapTransactions(entry)
+	case TypeTransfer:
+		return feeTransactions(entry, entry.Notes+" fee")
+	}
+
+	event := entry.events[0]
+	if importer.IsFiat(event.Asset) {
+		return nil
+	}
+	notes := event.Notes
+	if notes == "" {
+		notes = defaultNotes[entry.Type]
+	}
+	return []portfolio.Transaction{transaction(event.ID, event, event.Amount, event.PricePerUnitFiat, event.FiatCurrency, notes, true)}
+}
+
+// swapTransactions values both legs at the sold leg's value, or at the
+// bought leg's when the sold one has no price, so the swap realizes the
+// same amount it adds as cost basis.
+func swapTransactions(entry Entry) []portfolio.Transaction {
+	out, in := entry.events[0], entry.events[1]
+	value, currency := math.Abs(out.Amount)*out.PricePerUnitFiat, out.FiatCurrency
+	if value == 0 {
+		value, currency = in.Amount*in.PricePerUnitFiat, in.FiatCurrency
+	}
+
+	var transactions []portfolio.Transaction
+	for _, leg := range []Event{out, in} {
+		if importer.IsFiat(leg.Asset) {
+			continue
+		}
+		transactions = append(transactions, transaction(leg.ID, leg, leg.Amount, value/math.Abs(leg.Amount), currency, entry.Notes, true))
+	}
+	for _, event := range entry.events[2:] {
+		notes := event.Notes
+		if notes == "" {
+			notes = defaultNotes[TypeFee]
+		}
+		transactions = append(transactions, transaction(event.ID, event, event.Amount, event.PricePerUnitFiat, event.FiatCurrency, notes, false))
+	}
+	return transactions
+}
+
+// feeTransactions books the fee legs of a transfer as one SELL per asset,
+// priced from either leg when the fee is in the transferred asset. The
+// first keeps the sending event's id.
+func feeTransactions(entry Entry, notes string) []portfolio.Transaction {
+	out, in := entry.events[0], entry.events[1]
+	var (
+		assets []string
+		totals = make(map[string]Leg)
+	)
+	for _, fee := range entry.Fees {
+		if importer.IsFiat(fee.Asset) {
+			continue
+		}
+		total, found := totals[fee.Asset]
+		if !found {
+			assets = append(assets, fee.Asset)
+			total = fee
+			total.Amount = 0
+		}
+		total.Amount = roundAmount(total.Amount + fee.Amount)
+		if total.PricePerUnitFiat == 0 {
+			total.PricePerUnitFiat = fee.PricePerUnitFiat
+		}
+		totals[fee.Asset] = total
+	}
+
+	var transactions []portfolio.Transaction
+	for i, asset := range assets {
+		total := totals[asset]
+		price, currency := total.PricePerUnitFiat, total.FiatCurrency
+		if asset == out.Asset && price == 0 {
+			price = priceOf(out, in)
+			if out.PricePerUnitFiat == 0 && in.PricePerUnitFiat > 0 {
+				currency = in.FiatCurrency
+			}
+		}
+		id := out.ID
+		if i > 0 {
+			id += ":fee:" + asset
+		}
+		event := out
+		event.Asset = asset
+		transactions = append(transactions, transaction(id, event, total.Amount, price, currency, notes, false))
+	}
+	return transactions
+}
+
+// transaction builds a mobile transaction; withFee carries over the
+// event's declared fee.
+func transaction(id string, event Event, amount, price float64, currency, notes string, withFee bool) portfolio.Transaction {
+	kind := portfolio.TransactionBuy
+	if amount < 0 {
+		kind = portfolio.TransactionSell
+	}
+	source := event.Source
+	if source == "" {
+		source = portfolio.SourceManual
+	}
+	externalID := id
+	tx := portfolio.Transaction{
+		AssetSymbol:      event.Asset,
+		Amount:           amount,
+		PricePerUnitFiat: price,
+		TotalFiat:        math.Abs(amount) * price,
+		FiatCurrency:     currency,
+		Type:             kind,
+		Source:           source,
+		ExternalID:       &externalID,
+		Timestamp:        event.Timestamp,
+	}
+	if withFee && event.FeeAmount > 0 {
+		fee, feeCurrency := event.FeeAmount, event.FeeCurrency
+		tx.FeeAmount = &fee
+		tx.FeeCurrency = &feeCurrency
+	}
+	if notes != "" {
+		tx.Notes = &notes
+	}
+	return tx
+}
@@ -0,0 +1,220 @@
+package normalize
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"crypto-portfolio-backend/internal/exchange"
+	"crypto-portfolio-backend/internal/portfolio"
+)
+
+// inflowTypes are booked with a positive amount, outflowTypes with a
+// negative one; SWAP legs keep their sign.
+var (
+	inflowTypes  = map[string]bool{TypeBuy: true, TypeDeposit: true, TypeStakingReward: true, TypeAirdrop: true}
+	outflowTypes = map[string]bool{TypeSell: true, TypeWithdrawal: true, TypeFee: true}
+)
+
+// collect turns every source into events, drops repeated events and
+// validates the rest. An event repeats another with the same account, id
+// and direction: both sides of a transfer between two wallets carry the
+// same transaction hash id.
+func collect(sources []Source) ([]Event, []string, error) {
+	var (
+		events     []Event
+		duplicates []string
+	)
+	seen := make(map[string]struct{})
+	add := func(event Event) error {
+		prepared, err := prepare(event)
+		if err != nil {
+			return err
+		}
+		key := prepared.Account + "\x00" + prepared.ID + "\x00" + strconv.FormatBool(prepared.Amount > 0)
+		if _, found := seen[key]; found {
+			duplicates = append(duplicates, prepared.ID)
+			return nil
+		}
+		if len(events) >= portfolio.MaxTransactions {
+			return fmt.Errorf("%w: at most %d events are allowed", ErrInvalidRequest, portfolio.MaxTransactions)
+		}
+		seen[key] = struct{}{}
+		events = append(events, prepared)
+		return nil
+	}
+
+	for i, source := range sources {
+		account := strings.TrimSpace(source.Account)
+		// The id prefix only tells accounts apart when there is one.
+		if account == "" && len(sources) > 1 {
+			return nil, nil, fmt.Errorf("%w: source %d has no account; every source needs one when several are given", ErrInvalidRequest, i+1)
+		}
+		for _, tx := range source.Transactions {
+			if err := add(fromTransaction(tx, account)); err != nil {
+				return nil, nil, err
+			}
+		}
+		for _, transfer := range source.Transfers {
+			if err := add(fromTransfer(transfer, account)); err != nil {
+				return nil, nil, err
+			}
+		}
+		for _, event := range source.Events {
+			if strings.TrimSpace(event.Account) == "" {
+				event.Account = account
+			}
+			if err := add(event); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	if len(events) == 0 {
+		return nil, nil, fmt.Errorf("%w: sources contain no events", ErrInvalidRequest)
+	}
+	return events, duplicates, nil
+}
+
+// fromTransaction reads a transaction returned by an import endpoint.
+// Wallet transactions carry "<chain>:<hash>[:<suffix>]" external ids: the
+// hash links transfers and groups swap legs, and inflows and outflows are
+// deposits and withdrawals until classified. Exchange and manual
+// transactions are trades unless their notes say otherwise.
+func fromTransaction(tx portfolio.Transaction, account string) Event {
+	event := Event{
+		ID:               tx.ID,
+		Account:          account,
+		Asset:            tx.AssetSymbol,
+		Amount:           tx.Amount,
+		PricePerUnitFiat: tx.PricePerUnitFiat,
+		FiatCurrency:     tx.FiatCurrency,
+		Source:           tx.Source,
+		Timestamp:        tx.Timestamp,
+	}
+	if tx.ExternalID != nil && *tx.ExternalID != "" {
+		event.ID = *tx.ExternalID
+	}
+	if tx.FeeAmount != nil {
+		event.FeeAmount = *tx.FeeAmount
+	}
+	if tx.FeeCurrency != nil {
+		event.FeeCurrency = *tx.FeeCurrency
+	}
+	if tx.Notes != nil {
+		event.Notes = *tx.Notes
+	}
+
+	inflow := strings.EqualFold(tx.Type, portfolio.TransactionBuy)
+	event.Type = notesType(event.Notes, inflow)
+	if strings.EqualFold(tx.Source, portfolio.SourceWallet) {
+		chain, rest, _ := strings.Cut(event.ID, ":")
+		hash, _, _ := strings.Cut(rest, ":")
+		if hash != "" {
+			event.TxHash = hash
+			event.Group = chain + ":" + hash
+		}
+		if event.Type == "" {
+			event.Type = TypeWithdrawal
+			if inflow {
+				event.Type = TypeDeposit
+			}
+		}
+	} else if event.Type == "" {
+		event.Type = strings.ToUpper(strings.TrimSpace(tx.Type))
+	}
+	return event
+}
+
+// fromTransfer reads a deposit or withdrawal returned by /exchange/sync.
+func fromTransfer(transfer exchange.Transfer, account string) Event {
+	event := Event{
+		ID:          transfer.ExternalID,
+		Account:     account,
+		Type:        TypeDeposit,
+		Asset:       transfer.Asset,
+		Amount:      transfer.Amount,
+		FeeAmount:   transfer.Fee,
+		FeeCurrency: transfer.Asset,
+		TxHash:      transfer.TxHash,
+		Source:      portfolio.SourceExchange,
+		Timestamp:   transfer.Timestamp,
+	}
+	if transfer.Kind == exchange.TransferWithdrawal {
+		event.Type = TypeWithdrawal
+	}
+	return event
+}
+
+// notesType recognizes rewards, airdrops and fees from free-text notes,
+// as written by templated imports and the wallet importers.
+func notesType(notes string, inflow bool) string {
+	notes = strings.ToLower(strings.TrimSpace(notes))
+	switch {
+	case notes == "":
+		return ""
+	case inflow && strings.Contains(notes, "airdrop"):
+		return TypeAirdrop
+	case inflow && (strings.Contains(notes, "staking") || strings.Contains(notes, "reward") || strings.Contains(notes, "interest")):
+		return TypeStakingReward
+	case !inflow && (notes == "fee" || strings.HasPrefix(notes, "network fee")):
+		return TypeFee
+	}
+	return ""
+}
+
+// prepare validates an event, fills in its account and currencies and
+// signs its amount by type.
+func prepare(event Event) (Event, error) {
+	event.ID = strings.TrimSpace(event.ID)
+	event.Asset = strings.ToUpper(strings.TrimSpace(event.Asset))
+	event.Type = strings.ToUpper(strings.TrimSpace(event.Type))
+	event.FiatCurrency = strings.ToUpper(strings.TrimSpace(event.FiatCurrency))
+	event.FeeCurrency = strings.ToUpper(strings.TrimSpace(event.FeeCurrency))
+	event.TxHash = strings.ToLower(strings.TrimSpace(event.TxHash))
+	event.Account = strings.TrimSpace(event.Account)
+	if event.Account == "" {
+		event.Account, _, _ = strings.Cut(event.ID, ":")
+	}
+	if event.FiatCurrency == "" {
+		event.FiatCurrency = "USD"
+	}
+	if event.FeeCurrency == "" {
+		event.FeeCurrency = event.Asset
+	}
+	if event.Type == TypeTransfer {
+		event.Type = TypeDeposit
+		if event.Amount < 0 {
+			event.Type = TypeWithdrawal
+		}
+	}
+
+	switch {
+	case event.ID == "":
+		return Event{}, fmt.Errorf("%w: every event needs an id or external_id", ErrInvalidRequest)
+	case event.Asset == "":
+		return Event{}, fmt.Errorf("%w: event %s has no asset", ErrInvalidRequest, event.ID)
+	case !inflowTypes[event.Type] && !outflowTypes[event.Type] && event.Type != TypeSwap:
+		return Event{}, fmt.Errorf("%w: event %s has unsupported type %q", ErrInvalidRequest, event.ID, event.Type)
+	case event.Timestamp <= 0:
+		return Event{}, fmt.Errorf("%w: event %s has no timestamp", ErrInvalidRequest, event.ID)
+	case !isFinite(event.Amount) || event.Amount == 0:
+		return Event{}, fmt.Errorf("%w: event %s has an invalid amount", ErrInvalidRequest, event.ID)
+	case !isFinite(event.PricePerUnitFiat) || event.PricePerUnitFiat < 0:
+		return Event{}, fmt.Errorf("%w: event %s has an invalid price", ErrInvalidRequest, event.ID)
+	case !isFinite(event.FeeAmount) || event.FeeAmount < 0:
+		return Event{}, fmt.Errorf("%w: event %s has an invalid fee", ErrInvalidRequest, event.ID)
+	}
+
+	switch {
+	case inflowTypes[event.Type]:
+		event.Amount = math.Abs(event.Amount)
+	case outflowTypes[event.Type]:
+		event.Amount = -math.Abs(event.Amount)
+	}
+	return event, nil
+}
+
+func isFinite(value float64) bool {
+	return !math.IsNaN(value) && !math.IsInf(value, 0)
+}
@@ -0,0 +1,158 @@
+// Package normalize classifies imported events into an extended set of
+// transaction types. It pairs the legs of swaps, links transfers between
+// the user's own wallets and exchanges, and projects the result back onto
+// the BUY/SELL transactions the mobile schema accepts. Nothing is stored.
+package normalize
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"crypto-portfolio-backend/internal/exchange"
+	"crypto-portfolio-backend/internal/portfolio"
+)
+
+// Extended transaction types. BUY and SELL are trades against fiat.
+const (
+	TypeBuy           = "BUY"
+	TypeSell          = "SELL"
+	TypeSwap          = "SWAP"
+	TypeTransfer      = "TRANSFER"
+	TypeDeposit       = "DEPOSIT"
+	TypeWithdrawal    = "WITHDRAWAL"
+	TypeStakingReward = "STAKING_REWARD"
+	TypeAirdrop       = "AIRDROP"
+	TypeFee           = "FEE"
+)
+
+const (
+	// DefaultTransferWindowHours is how long after a withdrawal the
+	// matching deposit may arrive.
+	DefaultTransferWindowHours = 48
+	// MaxTransferWindowHours bounds transfer_window_hours.
+	MaxTransferWindowHours = 14 * 24
+)
+
+// ErrInvalidRequest is wrapped by errors caused by the request itself.
+var ErrInvalidRequest = errors.New("invalid normalize request")
+
+// Event is one imported movement of an asset in one account. Amount is
+// signed (+in, -out). Type is a hint; events from the import endpoints are
+// classified from their shape when it is empty.
+type Event struct {
+	ID               string  `json:"id"`
+	Account          string  `json:"account,omitempty"`
+	Type             string  `json:"type,omitempty"`
+	Asset            string  `json:"asset"`
+	Amount           float64 `json:"amount"`
+	PricePerUnitFiat float64 `json:"price_per_unit_fiat,omitempty"`
+	FiatCurrency     string  `json:"fiat_currency,omitempty"`
+	FeeAmount        float64 `json:"fee_amount,omitempty"`
+	FeeCurrency      string  `json:"fee_currency,omitempty"`
+	// TxHash links the two sides of an on-chain transfer.
+	TxHash string `json:"tx_hash,omitempty"`
+	// Group ties the legs of one swap, such as a transaction hash or an
+	// order id.
+	Group     string `json:"group,omitempty"`
+	Source    string `json:"source,omitempty"`
+	Notes     string `json:"notes,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// Source is the output of one import for one account: transactions from
+// /import/csv, /import/wallet/* or /exchange/sync, transfers from
+// /exchange/sync, and raw events. Account names the wallet or exchange
+// ("binance", "ledger-btc") and defaults to the external id prefix.
+type Source struct {
+	Account      string                  `json:"account,omitempty"`
+	Transactions []portfolio.Transaction `json:"transactions,omitempty"`
+	Transfers    []exchange.Transfer     `json:"transfers,omitempty"`
+	Events       []Event                 `json:"events,omitempty"`
+}
+
+// Request is the body of POST /import/normalize.
+type Request struct {
+	Sources             []Source `json:"sources"`
+	TransferWindowHours int      `json:"transfer_window_hours,omitempty"`
+}
+
+// Leg is the part of an entry that moved one asset in one account.
+type Leg struct {
+	EventID          string  `json:"event_id"`
+	Account          string  `json:"account"`
+	Asset            string  `json:"asset"`
+	Amount           float64 `json:"amount"`
+	PricePerUnitFiat float64 `json:"price_per_unit_fiat,omitempty"`
+	FiatCurrency     string  `json:"fiat_currency,omitempty"`
+	TxHash           string  `json:"tx_hash,omitempty"`
+}
+
+// Entry is a classified event: a single leg, the sold and bought legs of a
+// swap, or the sending and receiving legs of a transfer. Fees are legs
+// with negative amounts.
+type Entry struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	Timestamp int64  `json:"timestamp"`
+	Legs      []Leg  `json:"legs"`
+	Fees      []Leg  `json:"fees,omitempty"`
+	Notes     string `json:"notes,omitempty"`
+
+	// events are the classified events, legs first, then fee events.
+	events []Event
+}
+
+// Result is returned for POST /import/normalize. Entries are the rich
+// form; Transactions are the same movements as BUY/SELL in the mobile
+// shape. Movements without a price, such as deposits whose origin is not
+// among the sources, are returned in Unpriced instead, with no price or
+// total, for the user to price before saving. Duplicates lists repeated
+// event ids that were dropped.
+type Result struct {
+	Entries      []Entry                 `json:"entries"`
+	Transactions []portfolio.Transaction `json:"transactions"`
+	Unpriced     []portfolio.Transaction `json:"unpriced,omitempty"`
+	Duplicates   []string                `json:"duplicates,omitempty"`
+}
+
+// Normalize classifies the events of all sources together, so transfers
+// between them can be linked.
+func Normalize(request Request) (*Result, error) {
+	windowHours := request.TransferWindowHours
+	if windowHours == 0 {
+		windowHours = DefaultTransferWindowHours
+	}
+	if windowHours < 0 || windowHours > MaxTransferWindowHours {
+		return nil, fmt.Errorf("%w: transfer_window_hours must be between 1 and %d", ErrInvalidRequest, MaxTransferWindowHours)
+	}
+
+	events, duplicates, err := collect(request.Sources)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := classify(events, int64(windowHours)*60*60*1000)
+	var transactions []portfolio.Transaction
+	for _, entry := range entries {
+		transactions = append(transactions, compatible(entry)...)
+	}
+	sort.SliceStable(transactions, func(i, j int) bool {
+		a, b := transactions[i], transactions[j]
+		if a.Timestamp != b.Timestamp {
+			return a.Timestamp < b.Timestamp
+		}
+		return *a.ExternalID < *b.ExternalID
+	})
+
+	result := &Result{Entries: entries, Transactions: []portfolio.Transaction{}, Duplicates: duplicates}
+	for _, tx := range transactions {
+		if tx.PricePerUnitFiat == 0 {
+			tx.FiatCurrency = ""
+			result.Unpriced = append(result.Unpriced, tx)
+			continue
+		}
+		result.Transactions = append(result.Transactions, tx)
+	}
+	return result, nil
+}
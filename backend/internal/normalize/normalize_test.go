@@ -0,0 +1,319 @@
+package normalize
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"crypto-portfolio-backend/internal/exchange"
+	"crypto-portfolio-backend/internal/portfolio"
+)
+
+const hour = int64(60 * 60 * 1000)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		request Request
+		// entries lists type, id and legs (account asset amount) per entry.
+		entries []string
+		// txs and unpriced list id, symbol, type, amount, price and notes
+		// per transaction in the mobile shape.
+		txs        []string
+		unpriced   []string
+		duplicates []string
+	}{
+		{
+			name: "dex swap grouped by transaction hash",
+			request: Request{Sources: []Source{{
+				Account: "metamask",
+				Transactions: []portfolio.Transaction{
+					walletTx("ethereum:0xabc", "ETH", -1, 3000, 0.002, 1000),
+					walletTx("ethereum:0xabc:7", "UNI", 400, 0, 0, 1000),
+				},
+			}}},
+			entries: []string{"SWAP ethereum:0xabc metamask ETH -1, metamask UNI 400 fees metamask ETH -0.002"},
+			txs: []string{
+				"ethereum:0xabc ETH SELL -1 3000 Swap ETH → UNI fee 0.002 ETH",
+				"ethereum:0xabc:7 UNI BUY 400 7.5 Swap ETH → UNI",
+			},
+		},
+		{
+			name: "token swap paying gas in a separate fee",
+			request: Request{Sources: []Source{{
+				Account: "metamask",
+				Transactions: []portfolio.Transaction{
+					withNotes(walletTx("ethereum:0xdef", "ETH", -0.001, 3000, 0, 2000), "Network fee"),
+					walletTx("ethereum:0xdef:3", "UNI", -100, 8, 0, 2000),
+					walletTx("ethereum:0xdef:internal", "ETH", 0.25, 0, 0, 2000),
+				},
+			}}},
+			entries: []string{"SWAP ethereum:0xdef:3 metamask UNI -100, metamask ETH 0.25 fees metamask ETH -0.001"},
+			txs: []string{
+				"ethereum:0xdef ETH SELL -0.001 3000 Network fee",
+				"ethereum:0xdef:3 UNI SELL -100 8 Swap UNI → ETH",
+				"ethereum:0xdef:internal ETH BUY 0.25 3200 Swap UNI → ETH",
+			},
+		},
+		{
+			name: "exchange withdrawal linked to a wallet deposit by hash",
+			request: Request{Sources: []Source{
+				{
+					Account: "kraken",
+					Transfers: []exchange.Transfer{{
+						Kind: exchange.TransferWithdrawal, Asset: "BTC", Amount: 0.5, Fee: 0.0002,
+						TxHash: "ABC123", ExternalID: "kraken:withdrawal:W1", Timestamp: 10 * hour,
+					}},
+				},
+				{
+					Account:      "ledger",
+					Transactions: []portfolio.Transaction{walletTx("btc:abc123", "BTC", 0.5, 60000, 0, 11*hour)},
+				},
+			}},
+			entries: []string{"TRANSFER kraken:withdrawal:W1 kraken BTC -0.5, ledger BTC 0.5 fees kraken BTC -0.0002"},
+			txs:     []string{"kraken:withdrawal:W1 BTC SELL -0.0002 60000 Transfer kraken → ledger fee"},
+		},
+		{
+			name: "wallet withdrawal linked to an exchange deposit by amount",
+			request: Request{Sources: []Source{
+				{
+					Account:      "metamask",
+					Transactions: []portfolio.Transaction{walletTx("ethereum:0x111", "ETH", -2, 2500, 0.001, 10*hour)},
+				},
+				{
+					Account: "binance",
+					Transfers: []exchange.Transfer{
+						{Kind: exchange.TransferDeposit, Asset: "ETH", Amount: 2, ExternalID: "binance:deposit:late", Timestamp: 80 * hour},
+						{Kind: exchange.TransferDeposit, Asset: "ETH", Amount: 1.995, ExternalID: "binance:deposit:D1", Timestamp: 10*hour + 600000},
+					},
+				},
+			}},
+			entries: []string{
+				"TRANSFER ethereum:0x111 metamask ETH -2, binance ETH 1.995 fees metamask ETH -0.001, metamask ETH -0.005",
+				"DEPOSIT binance:deposit:late binance ETH 2",
+			},
+			txs:      []string{"ethereum:0x111 ETH SELL -0.006 2500 Transfer metamask → binance fee"},
+			unpriced: []string{"binance:deposit:late ETH BUY 2 0 Deposit"},
+		},
+		{
+			name: "transfer between two wallets sharing the transaction id",
+			request: Request{Sources: []Source{
+				{
+					Account:      "metamask",
+					Transactions: []portfolio.Transaction{walletTx("ethereum:0x222", "ETH", -1, 2500, 0.001, 10*hour)},
+				},
+				{
+					Account:      "ledger",
+					Transactions: []portfolio.Transaction{walletTx("ethereum:0x222", "ETH", 1, 2500, 0, 10*hour)},
+				},
+			}},
+			entries: []string{"TRANSFER ethereum:0x222 metamask ETH -1, ledger ETH 1 fees metamask ETH -0.001"},
+			txs:     []string{"ethereum:0x222 ETH SELL -0.001 2500 Transfer metamask → ledger fee"},
+		},
+		{
+			name: "rewards, airdrops, trades and fiat",
+			request: Request{Sources: []Source{{
+				Account: "kraken",
+				Transactions: []portfolio.Transaction{
+					withNotes(exchangeTx("kraken:S1", "DOT", portfolio.TransactionBuy, 1.5, 6, 1000), "Staking reward"),
+					exchangeTx("kraken:T1", "BTC", portfolio.TransactionBuy, 0.1, 50000, 2000),
+				},
+				Events: []Event{
+					{ID: "kraken:A1", Type: "airdrop", Asset: "flr", Amount: -20, Timestamp: 3000},
+					{ID: "kraken:F1", Type: TypeDeposit, Asset: "EUR", Amount: 500, Timestamp: 4000},
+					{ID: "kraken:X1", Type: TypeFee, Asset: "BTC", Amount: 0.0001, PricePerUnitFiat: 50000, Notes: "Maintenance", Timestamp: 5000},
+				},
+			}}},
+			entries: []string{
+				"STAKING_REWARD kraken:S1 kraken DOT 1.5",
+				"BUY kraken:T1 kraken BTC 0.1",
+				"AIRDROP kraken:A1 kraken FLR 20",
+				"DEPOSIT kraken:F1 kraken EUR 500",
+				"FEE kraken:X1 kraken BTC -0.0001",
+			},
+			txs: []string{
+				"kraken:S1 DOT BUY 1.5 6 Staking reward",
+				"kraken:T1 BTC BUY 0.1 50000",
+				"kraken:X1 BTC SELL -0.0001 50000 Maintenance",
+			},
+			unpriced: []string{"kraken:A1 FLR BUY 20 0 Airdrop"},
+		},
+		{
+			name: "repeated ids across imports",
+			request: Request{Sources: []Source{
+				{Account: "binance", Transactions: []portfolio.Transaction{exchangeTx("binance:BTCUSDT-1", "BTC", portfolio.TransactionSell, 0.2, 60000, 1000)}},
+				{Account: "binance", Transactions: []portfolio.Transaction{exchangeTx("binance:BTCUSDT-1", "BTC", portfolio.TransactionSell, 0.2, 60000, 1000)}},
+			}},
+			entries:    []string{"SELL binance:BTCUSDT-1 binance BTC -0.2"},
+			txs:        []string{"binance:BTCUSDT-1 BTC SELL -0.2 60000"},
+			duplicates: []string{"binance:BTCUSDT-1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Normalize(tt.request)
+			if err != nil {
+				t.Fatalf("Normalize: %v", err)
+			}
+
+			var entries []string
+			for _, entry := range result.Entries {
+				entries = append(entries, describeEntry(entry))
+			}
+			if !reflect.DeepEqual(entries, tt.entries) {
+				t.Errorf("entries:\n got %q\nwant %q", entries, tt.entries)
+			}
+
+			var txs []string
+			for _, tx := range result.Transactions {
+				txs = append(txs, describeTransaction(tx))
+			}
+			if !reflect.DeepEqual(txs, tt.txs) {
+				t.Errorf("transactions:\n got %q\nwant %q", txs, tt.txs)
+			}
+			var unpriced []string
+			for _, tx := range result.Unpriced {
+				unpriced = append(unpriced, describeTransaction(tx))
+			}
+			if !reflect.DeepEqual(unpriced, tt.unpriced) {
+				t.Errorf("unpriced:\n got %q\nwant %q", unpriced, tt.unpriced)
+			}
+			if !reflect.DeepEqual(result.Duplicates, tt.duplicates) {
+				t.Errorf("duplicates = %q, want %q", result.Duplicates, tt.duplicates)
+			}
+		})
+	}
+}
+
+func TestNormalizeKeepsUnlinkedTransfersApart(t *testing.T) {
+	result, err := Normalize(Request{
+		TransferWindowHours: 1,
+		Sources: []Source{
+			{Account: "a", Events: []Event{
+				{ID: "a:1", Type: TypeTransfer, Asset: "SOL", Amount: -10, TxHash: "h1", Timestamp: hour},
+				{ID: "a:2", Type: TypeWithdrawal, Asset: "SOL", Amount: -5, Timestamp: hour},
+			}},
+			{Account: "b", Events: []Event{
+				// Another hash, an amount too small and a deposit after the
+				// window must not be linked.
+				{ID: "b:1", Type: TypeTransfer, Asset: "SOL", Amount: 10, TxHash: "h2", Timestamp: hour},
+				{ID: "b:2", Type: TypeDeposit, Asset: "SOL", Amount: 4.9, Timestamp: hour},
+				{ID: "b:3", Type: TypeDeposit, Asset: "SOL", Amount: 5, Timestamp: 3 * hour},
+			}},
+			{Account: "a", Events: []Event{
+				{ID: "a:3", Type: TypeDeposit, Asset: "SOL", Amount: 5, Timestamp: hour + 1},
+			}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Normalize: %v", err)
+	}
+	for _, entry := range result.Entries {
+		if entry.Type == TypeTransfer {
+			t.Errorf("unexpected transfer %s", describeEntry(entry))
+		}
+	}
+	// Nothing is priced, so every movement is returned apart.
+	if len(result.Entries) != 6 || len(result.Transactions) != 0 || len(result.Unpriced) != 6 {
+		t.Fatalf("entries = %d, transactions = %d, unpriced = %d, want 6, 0 and 6", len(result.Entries), len(result.Transactions), len(result.Unpriced))
+	}
+}
+
+func TestNormalizeInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		request Request
+		want    string
+	}{
+		{name: "no sources", request: Request{}, want: "no events"},
+		{name: "window", request: Request{TransferWindowHours: 400, Sources: []Source{{}}}, want: "transfer_window_hours"},
+		{name: "type", request: Request{Sources: []Source{{Events: []Event{{ID: "x", Type: "LOAN", Asset: "BTC", Amount: 1, Timestamp: 1}}}}}, want: "unsupported type"},
+		{name: "amount", request: Request{Sources: []Source{{Events: []Event{{ID: "x", Type: TypeDeposit, Asset: "BTC", Timestamp: 1}}}}}, want: "invalid amount"},
+		{name: "id", request: Request{Sources: []Source{{Events: []Event{{Type: TypeDeposit, Asset: "BTC", Amount: 1, Timestamp: 1}}}}}, want: "needs an id"},
+		{name: "account", request: Request{Sources: []Source{{Account: "a"}, {Events: []Event{{ID: "x", Type: TypeDeposit, Asset: "BTC", Amount: 1, Timestamp: 1}}}}}, want: "no account"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Normalize(tt.request)
+			if !errors.Is(err, ErrInvalidRequest) || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want ErrInvalidRequest mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func walletTx(externalID, symbol string, amount, price, fee float64, timestamp int64) portfolio.Transaction {
+	kind := portfolio.TransactionBuy
+	if amount < 0 {
+		kind = portfolio.TransactionSell
+	}
+	tx := portfolio.Transaction{
+		AssetSymbol:      symbol,
+		Amount:           amount,
+		PricePerUnitFiat: price,
+		FiatCurrency:     "USD",
+		Type:             kind,
+		Source:           portfolio.SourceWallet,
+		ExternalID:       &externalID,
+		Timestamp:        timestamp,
+	}
+	if fee > 0 {
+		tx.FeeAmount = &fee
+		tx.FeeCurrency = &symbol
+	}
+	return tx
+}
+
+func exchangeTx(externalID, symbol, kind string, amount, price float64, timestamp int64) portfolio.Transaction {
+	if kind == portfolio.TransactionSell {
+		amount = -amount
+	}
+	return portfolio.Transaction{
+		AssetSymbol:      symbol,
+		Amount:           amount,
+		PricePerUnitFiat: price,
+		FiatCurrency:     "USD",
+		Type:             kind,
+		Source:           portfolio.SourceExchange,
+		ExternalID:       &externalID,
+		Timestamp:        timestamp,
+	}
+}
+
+func withNotes(tx portfolio.Transaction, notes string) portfolio.Transaction {
+	tx.Notes = &notes
+	return tx
+}
+
+func describeEntry(entry Entry) string {
+	describe := func(legs []Leg) string {
+		var parts []string
+		for _, leg := range legs {
+			parts = append(parts, leg.Account+" "+leg.Asset+" "+formatFloat(leg.Amount))
+		}
+		return strings.Join(parts, ", ")
+	}
+	text := entry.Type + " " + entry.ID + " " + describe(entry.Legs)
+	if len(entry.Fees) > 0 {
+		text += " fees " + describe(entry.Fees)
+	}
+	return text
+}
+
+func describeTransaction(tx portfolio.Transaction) string {
+	text := strings.Join([]string{*tx.ExternalID, tx.AssetSymbol, tx.Type, formatFloat(tx.Amount), formatFloat(tx.PricePerUnitFiat)}, " ")
+	if tx.Notes != nil {
+		text += " " + *tx.Notes
+	}
+	if tx.FeeAmount != nil {
+		text += " fee " + formatFloat(*tx.FeeAmount) + " " + *tx.FeeCurrency
+	}
+	return text
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
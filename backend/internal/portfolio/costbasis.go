@@ -0,0 +1,199 @@
+package portfolio
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CostBasisRequest is the body of POST /portfolio/cost-basis.
+type CostBasisRequest struct {
+	Transactions []Transaction `json:"transactions"`
+	// Method is fifo, lifo, hifo or average; fifo by default.
+	Method   string `json:"method,omitempty"`
+	Currency string `json:"currency,omitempty"`
+	// AssetIDs optionally pins symbols to unified, CMC or CoinGecko ids
+	// used for current prices.
+	AssetIDs map[string]string `json:"asset_ids,omitempty"`
+}
+
+// OpenLot is an acquisition still held, in the response currency.
+type OpenLot struct {
+	TransactionID string  `json:"transaction_id"`
+	AcquiredAt    int64   `json:"acquired_at"`
+	Amount        float64 `json:"amount"`
+	UnitCost      float64 `json:"unit_cost"`
+	Cost          float64 `json:"cost"`
+	HoldingDays   int     `json:"holding_days"`
+}
+
+// AssetCostBasis is the position and PnL of one asset.
+type AssetCostBasis struct {
+	Symbol      string  `json:"symbol"`
+	AssetID     string  `json:"asset_id,omitempty"`
+	Amount      float64 `json:"amount"`
+	CostBasis   float64 `json:"cost_basis"`
+	AverageCost float64 `json:"average_cost"`
+	// Price and Value are omitted when no latest price is cached.
+	Price         *float64   `json:"price,omitempty"`
+	Value         *float64   `json:"value,omitempty"`
+	UnrealizedPnL *float64   `json:"unrealized_pnl,omitempty"`
+	RealizedPnL   float64    `json:"realized_pnl"`
+	Fees          float64    `json:"fees"`
+	Lots          []OpenLot  `json:"lots"`
+	Disposals     []Disposal `json:"disposals"`
+}
+
+// CostBasisResponse is returned to the mobile app for POST
+// /portfolio/cost-basis.
+type CostBasisResponse struct {
+	Method        Method           `json:"method"`
+	Currency      string           `json:"currency"`
+	Assets        []AssetCostBasis `json:"assets"`
+	CostBasis     float64          `json:"cost_basis"`
+	Value         float64          `json:"value"`
+	UnrealizedPnL float64          `json:"unrealized_pnl"`
+	RealizedPnL   float64          `json:"realized_pnl"`
+	Fees          float64          `json:"fees"`
+	// Unpriced lists held symbols left out of value and unrealized PnL.
+	Unpriced []string `json:"unpriced,omitempty"`
+	// Oversold lists symbols with sells beyond the amount held.
+	Oversold []string `json:"oversold,omitempty"`
+	// IgnoredFees lists transactions whose fee currency could not be valued.
+	IgnoredFees []string `json:"ignored_fees,omitempty"`
+	Timestamp   int64    `json:"timestamp"`
+}
+
+// CostBasis replays a transaction list through the requested cost basis
+// method and returns open lots, disposals and realized and unrealized PnL per
+// asset. Amounts are converted to the requested currency at the ECB rate of
+// each transaction's day; current value uses the latest prices cache.
+func (s *Service) CostBasis(request CostBasisRequest) (*CostBasisResponse, error) {
+	transactions, err := normalizeTransactions(request.Transactions)
+	if err != nil {
+		return nil, err
+	}
+
+	method, err := ParseMethod(request.Method)
+	if err != nil {
+		return nil, err
+	}
+
+	currency := strings.ToUpper(strings.TrimSpace(request.Currency))
+	if currency == "" {
+		currency = "USD"
+	}
+
+	converter, err := s.converterFor(currency, transactions)
+	if err != nil {
+		return nil, err
+	}
+
+	ledger := newLedger(method, currency, converter, s.feeAssetPrices(transactions, request.AssetIDs))
+	disposals := make(map[string][]Disposal)
+	for _, tx := range transactions {
+		matched, err := ledger.apply(tx)
+		if err != nil {
+			return nil, err
+		}
+		for _, disposal := range matched {
+			disposals[disposal.Symbol] = append(disposals[disposal.Symbol], disposal)
+		}
+	}
+
+	symbols := transactionSymbols(transactions)
+	sort.Strings(symbols)
+	assets := s.resolveAssetIDs(symbols, request.AssetIDs)
+	latest := s.latestPrices(assets)
+
+	now := time.Now()
+	response := &CostBasisResponse{
+		Method:      method,
+		Currency:    currency,
+		Assets:      make([]AssetCostBasis, 0, len(symbols)),
+		Oversold:    oversoldSymbols(ledger),
+		IgnoredFees: ledger.ignoredFees,
+		Timestamp:   now.UnixMilli(),
+	}
+
+	for _, symbol := range symbols {
+		book := ledger.book(symbol)
+		asset := AssetCostBasis{
+			Symbol:    symbol,
+			AssetID:   assets[symbol],
+			Amount:    book.amount(),
+			CostBasis: book.cost(),
+			Fees:      ledger.fees[symbol],
+			Lots:      make([]OpenLot, 0, len(book.lots)),
+			Disposals: disposals[symbol],
+		}
+		if asset.Amount > lotEpsilon {
+			asset.AverageCost = asset.CostBasis / asset.Amount
+		}
+		for _, open := range book.lots {
+			asset.Lots = append(asset.Lots, OpenLot{
+				TransactionID: open.transactionID,
+				AcquiredAt:    open.acquiredAt,
+				Amount:        open.amount,
+				UnitCost:      open.unitCost,
+				Cost:          open.amount * open.unitCost,
+				HoldingDays:   holdingDays(open.acquiredAt, now.UnixMilli()),
+			})
+		}
+		for _, disposal := range asset.Disposals {
+			asset.RealizedPnL += disposal.Gain
+		}
+		if asset.Disposals == nil {
+			asset.Disposals = []Disposal{}
+		}
+
+		if priceUSD, found := latest[symbol]; found {
+			price, err := converter.convert(priceUSD, "USD", currency, now.UnixMilli())
+			if err != nil {
+				return nil, err
+			}
+			value := asset.Amount * price
+			unrealized := value - asset.CostBasis
+			asset.Price, asset.Value, asset.UnrealizedPnL = &price, &value, &unrealized
+			response.Value += value
+			response.UnrealizedPnL += unrealized
+		} else if asset.Amount > lotEpsilon {
+			response.Unpriced = append(response.Unpriced, symbol)
+		}
+
+		response.CostBasis += asset.CostBasis
+		response.RealizedPnL += asset.RealizedPnL
+		response.Fees += asset.Fees
+		response.Assets = append(response.Assets, asset)
+	}
+
+	return response, nil
+}
+
+// latestPrices returns the latest USD price per symbol, in units of the
+// symbol for migrated coins.
+func (s *Service) latestPrices(assets map[string]string) map[string]float64 {
+	ids := make([]string, 0, len(assets))
+	for _, id := range assets {
+		ids = append(ids, id)
+	}
+	latest, err := s.prices.GetAssetPrices(ids)
+	if err != nil {
+		log.Printf("Failed to load latest prices for cost basis: %v", err)
+		return nil
+	}
+
+	result := make(map[string]float64, len(assets))
+	for symbol, id := range assets {
+		price, found := latest[id]
+		if !found {
+			continue
+		}
+		result[symbol] = price.USD
+		if price.MigrationRatio > 0 {
+			result[symbol] *= price.MigrationRatio
+		}
+	}
+	return result
+}
@@ -0,0 +1,236 @@
+package portfolio
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"crypto-portfolio-backend/internal/fx"
+	"crypto-portfolio-backend/internal/prices"
+)
+
+func TestServiceCostBasisMethods(t *testing.T) {
+	service := NewService(fakePrices{
+		latest:  map[string]prices.AssetPrice{"bitcoin": {USD: 500}},
+		symbols: map[string]string{"BTC": "bitcoin"},
+	}, fakeRates{"EUR": 0.5})
+
+	const start = int64(1_700_000_000_000)
+	transactions := []Transaction{
+		{ID: "b1", AssetSymbol: "BTC", Amount: 1, PricePerUnitFiat: 100, FiatCurrency: "USD", Type: "BUY", Timestamp: start},
+		{ID: "b2", AssetSymbol: "BTC", Amount: 1, PricePerUnitFiat: 300, FiatCurrency: "USD", Type: "BUY", Timestamp: start + 10*dayMillis},
+		{ID: "b3", AssetSymbol: "BTC", Amount: 1, PricePerUnitFiat: 200, FiatCurrency: "USD", Type: "BUY", Timestamp: start + 20*dayMillis},
+		{ID: "s1", AssetSymbol: "BTC", Amount: -1.5, PricePerUnitFiat: 400, FiatCurrency: "USD", Type: "SELL", Timestamp: start + 30*dayMillis},
+	}
+
+	tests := []struct {
+		name      string
+		method    string
+		costBasis float64
+		realized  float64
+		lots      []string
+		// holdingDays of each disposal, in the order lots were matched.
+		holdingDays []int
+	}{
+		{name: "fifo by default", method: "", costBasis: 350, realized: 350, lots: []string{"b2", "b3"}, holdingDays: []int{30, 20}},
+		{name: "lifo", method: "lifo", costBasis: 250, realized: 250, lots: []string{"b1", "b2"}, holdingDays: []int{10, 20}},
+		{name: "hifo", method: "HIFO", costBasis: 200, realized: 200, lots: []string{"b1", "b3"}, holdingDays: []int{20, 10}},
+		{name: "average", method: "average", costBasis: 300, realized: 300, lots: []string{"b2", "b3"}, holdingDays: []int{30, 20}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := service.CostBasis(CostBasisRequest{Transactions: transactions, Method: tt.method})
+			if err != nil {
+				t.Fatalf("CostBasis: %v", err)
+			}
+			if len(result.Assets) != 1 {
+				t.Fatalf("expected 1 asset, got %d", len(result.Assets))
+			}
+
+			btc := result.Assets[0]
+			if !closeTo(btc.Amount, 1.5) || !closeTo(btc.CostBasis, tt.costBasis) || !closeTo(btc.RealizedPnL, tt.realized) {
+				t.Errorf("got amount=%f cost=%f realized=%f", btc.Amount, btc.CostBasis, btc.RealizedPnL)
+			}
+			if btc.Value == nil || !closeTo(*btc.Value, 750) || !closeTo(*btc.UnrealizedPnL, 750-tt.costBasis) {
+				t.Errorf("unexpected value %v / unrealized %v", btc.Value, btc.UnrealizedPnL)
+			}
+
+			var lots []string
+			for _, open := range btc.Lots {
+				lots = append(lots, open.TransactionID)
+			}
+			var holding []int
+			proceeds := 0.0
+			for _, disposal := range btc.Disposals {
+				holding = append(holding, disposal.HoldingDays)
+				proceeds += disposal.Proceeds
+			}
+			if !reflect.DeepEqual(lots, tt.lots) || !reflect.DeepEqual(holding, tt.holdingDays) {
+				t.Errorf("lots=%v holding days=%v", lots, holding)
+			}
+			if !closeTo(proceeds, 600) {
+				t.Errorf("proceeds: got %f, want 600", proceeds)
+			}
+		})
+	}
+
+	if _, err := service.CostBasis(CostBasisRequest{Transactions: transactions, Method: "lofo"}); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("expected unsupported method to be rejected, got %v", err)
+	}
+}
+
+func TestServiceCostBasisFees(t *testing.T) {
+	service := NewService(fakePrices{}, fakeRates{"EUR": 0.5})
+	fee := func(amount float64, currency string) (*float64, *string) { return &amount, &currency }
+
+	buy := func(id, symbol string, amount, price float64, feeAmount float64, feeCurrency string) Transaction {
+		tx := Transaction{ID: id, AssetSymbol: symbol, Amount: amount, PricePerUnitFiat: price, FiatCurrency: "USD", Type: "BUY", Timestamp: 1_700_000_000_000}
+		if feeCurrency != "" {
+			tx.FeeAmount, tx.FeeCurrency = fee(feeAmount, feeCurrency)
+		}
+		return tx
+	}
+
+	tests := []struct {
+		name         string
+		transactions []Transaction
+		symbol       string
+		amount       float64
+		costBasis    float64
+		realized     float64
+		fees         float64
+		ignored      []string
+	}{
+		{name: "fiat fee", transactions: []Transaction{buy("b", "BTC", 1, 100, 10, "USD")}, symbol: "BTC", amount: 1, costBasis: 110, fees: 10},
+		{name: "fee in other fiat", transactions: []Transaction{buy("b", "BTC", 1, 100, 5, "EUR")}, symbol: "BTC", amount: 1, costBasis: 110, fees: 10},
+		{name: "fee in traded asset", transactions: []Transaction{buy("b", "BTC", 1, 100, 0.1, "BTC")}, symbol: "BTC", amount: 0.9, costBasis: 100, fees: 10},
+		{
+			name: "fee in other held asset",
+			transactions: []Transaction{
+				buy("e", "ETH", 1, 10, 0, ""),
+				buy("b", "BTC", 1, 100, 0.5, "ETH"),
+			},
+			symbol: "ETH", amount: 0.5, costBasis: 5,
+		},
+		{
+			name: "fee in other asset sold at its value",
+			transactions: []Transaction{
+				buy("e1", "ETH", 1, 10, 0, ""),
+				buy("e2", "ETH", 1, 20, 0, ""),
+				buy("b", "BTC", 1, 100, 0.5, "ETH"),
+			},
+			symbol: "ETH", amount: 1.5, costBasis: 25, realized: 5,
+		},
+		{
+			name: "fee in other asset capitalized",
+			transactions: []Transaction{
+				buy("e1", "ETH", 1, 10, 0, ""),
+				buy("e2", "ETH", 1, 20, 0, ""),
+				buy("b", "BTC", 1, 100, 0.5, "ETH"),
+			},
+			symbol: "BTC", amount: 1, costBasis: 110, fees: 10,
+		},
+		{
+			name: "sell fee lowers proceeds",
+			transactions: []Transaction{
+				buy("b", "BTC", 1, 100, 0, ""),
+				{ID: "s", AssetSymbol: "BTC", Amount: -1, PricePerUnitFiat: 150, FiatCurrency: "USD", FeeAmount: func() *float64 { v := 5.0; return &v }(), Type: "SELL", Timestamp: 1_700_000_000_001},
+			},
+			symbol: "BTC", realized: 45, fees: 5,
+		},
+		{name: "unknown fee currency", transactions: []Transaction{buy("b", "BTC", 1, 100, 3, "XYZ")}, symbol: "BTC", amount: 1, costBasis: 100, ignored: []string{"b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := service.CostBasis(CostBasisRequest{Transactions: tt.transactions})
+			if err != nil {
+				t.Fatalf("CostBasis: %v", err)
+			}
+
+			var asset *AssetCostBasis
+			for i := range result.Assets {
+				if result.Assets[i].Symbol == tt.symbol {
+					asset = &result.Assets[i]
+				}
+			}
+			if asset == nil {
+				t.Fatalf("no %s in %+v", tt.symbol, result.Assets)
+			}
+			if !closeTo(asset.Amount, tt.amount) || !closeTo(asset.CostBasis, tt.costBasis) || !closeTo(asset.RealizedPnL, tt.realized) || !closeTo(asset.Fees, tt.fees) {
+				t.Errorf("got amount=%f cost=%f realized=%f fees=%f", asset.Amount, asset.CostBasis, asset.RealizedPnL, asset.Fees)
+			}
+			if !reflect.DeepEqual(result.IgnoredFees, tt.ignored) {
+				t.Errorf("ignored fees: got %v, want %v", result.IgnoredFees, tt.ignored)
+			}
+		})
+	}
+}
+
+func closeTo(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestServiceCostBasisFeeAtMarketValue(t *testing.T) {
+	const day = int64(1_699_920_000_000) // a UTC day start
+	service := NewService(fakePrices{
+		symbols:   map[string]string{"ETH": "ethereum"},
+		histories: map[string][]prices.HistoryPoint{"ethereum": {{Timestamp: day, Price: 30}}},
+	}, fakeRates{})
+	fee, ethereum := 0.5, "ETH"
+	transactions := []Transaction{
+		{ID: "e", AssetSymbol: "ETH", Amount: 1, PricePerUnitFiat: 10, FiatCurrency: "USD", Type: "BUY", Timestamp: day - dayMillis},
+		{ID: "b", AssetSymbol: "BTC", Amount: 1, PricePerUnitFiat: 100, FiatCurrency: "USD", FeeAmount: &fee, FeeCurrency: &ethereum, Type: "BUY", Timestamp: day + 3600_000},
+	}
+
+	result, err := service.CostBasis(CostBasisRequest{Transactions: transactions})
+	if err != nil {
+		t.Fatalf("CostBasis: %v", err)
+	}
+	btc, eth := result.Assets[0], result.Assets[1]
+	if !closeTo(btc.CostBasis, 115) || !closeTo(btc.Fees, 15) {
+		t.Errorf("BTC cost=%f fees=%f, want the fee capitalized at the 30 USD close", btc.CostBasis, btc.Fees)
+	}
+	want := []Disposal{{TransactionID: "b", Symbol: "ETH", LotID: "e", AcquiredAt: day - dayMillis, DisposedAt: day + 3600_000, Amount: 0.5, Proceeds: 15, Cost: 5, Gain: 10, HoldingDays: 1, Fee: true}}
+	if !reflect.DeepEqual(eth.Disposals, want) {
+		t.Errorf("ETH disposals = %+v, want %+v", eth.Disposals, want)
+	}
+}
+
+// unavailableRates fails every rate lookup, like an unreachable ECB.
+type unavailableRates struct{}
+
+func (unavailableRates) GetRates() (*fx.RatesResponse, error) {
+	return nil, errors.New("ECB unavailable")
+}
+
+func (unavailableRates) GetHistoricalRates() (*fx.HistoricalRates, error) {
+	return nil, errors.New("ECB unavailable")
+}
+
+func TestServiceCostBasisCryptoFeeBeyondHoldings(t *testing.T) {
+	const day = int64(1_699_920_000_000) // a UTC day start
+	service := NewService(fakePrices{
+		symbols:   map[string]string{"ETH": "ethereum"},
+		histories: map[string][]prices.HistoryPoint{"ethereum": {{Timestamp: day, Price: 30}}},
+	}, unavailableRates{})
+	fee, ethereum := 1.5, "ETH"
+	transactions := []Transaction{
+		{ID: "e", AssetSymbol: "ETH", Amount: 1, PricePerUnitFiat: 10, FiatCurrency: "USD", Type: "BUY", Timestamp: day - dayMillis},
+		{ID: "b", AssetSymbol: "BTC", Amount: 1, PricePerUnitFiat: 100, FiatCurrency: "USD", FeeAmount: &fee, FeeCurrency: &ethereum, Type: "BUY", Timestamp: day + 3600_000},
+	}
+
+	// A crypto fee in a USD ledger needs no FX rates.
+	result, err := service.CostBasis(CostBasisRequest{Transactions: transactions})
+	if err != nil {
+		t.Fatalf("CostBasis: %v", err)
+	}
+	if !reflect.DeepEqual(result.Oversold, []string{"ETH"}) {
+		t.Errorf("oversold = %v, want the fee asset", result.Oversold)
+	}
+	if btc := result.Assets[0]; !closeTo(btc.Fees, 45) {
+		t.Errorf("BTC fees = %f, want the whole fee at the 30 USD close", btc.Fees)
+	}
+}
@@ -0,0 +1,423 @@
+package portfolio
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"crypto-portfolio-backend/internal/fx"
+	"crypto-portfolio-backend/internal/prices"
+)
+
+const dayMillis = int64(24 * time.Hour / time.Millisecond)
+
+// historyDays are the ranges served by POST /portfolio/history; they match
+// the cached /prices/history ranges.
+var historyDays = []int{7, 30, 90, 365}
+
+// HistoryRequest is the body of POST /portfolio/history.
+type HistoryRequest struct {
+	Transactions []Transaction `json:"transactions"`
+	Currency     string        `json:"currency,omitempty"`
+	Days         int           `json:"days,omitempty"`
+	// Method is the cost basis method; FIFO by default, like the app.
+	Method string `json:"method,omitempty"`
+	// AssetIDs optionally pins symbols to unified, CMC or CoinGecko ids;
+	// other symbols are resolved like /coins/resolve.
+	AssetIDs map[string]string `json:"asset_ids,omitempty"`
+}
+
+// HistoryPoint is the portfolio at the start of a UTC day, including that
+// day's transactions.
+type HistoryPoint struct {
+	Timestamp     int64   `json:"timestamp"`
+	Value         float64 `json:"value"`
+	CostBasis     float64 `json:"cost_basis"`
+	UnrealizedPnL float64 `json:"unrealized_pnl"`
+	RealizedPnL   float64 `json:"realized_pnl"`
+	PnL           float64 `json:"pnl"`
+}
+
+// HistoryResponse is returned to the mobile app for POST /portfolio/history.
+type HistoryResponse struct {
+	Currency string         `json:"currency"`
+	Method   Method         `json:"method"`
+	Days     int            `json:"days"`
+	Points   []HistoryPoint `json:"points"`
+	// Assets maps each symbol to the id its prices were taken from.
+	Assets map[string]string `json:"assets"`
+	// MissingHistory lists symbols valued from transaction prices only.
+	MissingHistory []string `json:"missing_history,omitempty"`
+	// Oversold lists symbols with sells beyond the amount held.
+	Oversold  []string `json:"oversold,omitempty"`
+	Timestamp int64    `json:"timestamp"`
+}
+
+// assetSeries holds the prices of one asset while walking the days.
+type assetSeries struct {
+	symbol string
+	// dailyUSD maps UTC day starts to cached USD prices.
+	dailyUSD  map[int64]float64
+	latestUSD float64
+	lastUSD   float64
+}
+
+// History rebuilds the daily value, cost basis and PnL of a transaction list
+// from cached price history. Cost basis follows the requested method and
+// amounts are converted to the requested currency with the ECB rates of each
+// day.
+func (s *Service) History(request HistoryRequest) (*HistoryResponse, error) {
+	transactions, err := normalizeTransactions(request.Transactions)
+	if err != nil {
+		return nil, err
+	}
+
+	method, err := ParseMethod(request.Method)
+	if err != nil {
+		return nil, err
+	}
+
+	days := request.Days
+	if days == 0 {
+		days = 365
+	}
+	if !isHistoryDays(days) {
+		return nil, fmt.Errorf("%w: days must be one of %v", ErrInvalidRequest, historyDays)
+	}
+
+	currency := strings.ToUpper(strings.TrimSpace(request.Currency))
+	if currency == "" {
+		currency = "USD"
+	}
+
+	converter, err := s.converterFor(currency, transactions)
+	if err != nil {
+		return nil, err
+	}
+
+	series := groupTransactions(transactions)
+	assets := s.resolveAssetIDs(transactionSymbols(transactions), request.AssetIDs)
+	missing := s.loadSeriesPrices(series, assets)
+
+	response := &HistoryResponse{
+		Currency:       currency,
+		Method:         method,
+		Days:           days,
+		Points:         make([]HistoryPoint, 0, days),
+		Assets:         assets,
+		MissingHistory: missing,
+		Timestamp:      time.Now().UnixMilli(),
+	}
+
+	ledger := newLedger(method, currency, converter, seriesPrices(series))
+	bySymbol := make(map[string]*assetSeries, len(series))
+	for _, asset := range series {
+		bySymbol[asset.symbol] = asset
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour).UnixMilli()
+	realized := 0.0
+	next := 0
+	for day := today - int64(days-1)*dayMillis; day <= today; day += dayMillis {
+		point := HistoryPoint{Timestamp: day}
+
+		for ; next < len(transactions) && transactions[next].Timestamp < day+dayMillis; next++ {
+			tx := transactions[next]
+			disposals, err := ledger.apply(tx)
+			if err != nil {
+				return nil, err
+			}
+			for _, disposal := range disposals {
+				realized += disposal.Gain
+			}
+
+			// Transaction prices are the fallback when no history is cached.
+			if tx.PricePerUnitFiat > 0 {
+				if priceUSD, err := converter.convert(tx.PricePerUnitFiat, tx.FiatCurrency, "USD", tx.Timestamp); err == nil {
+					bySymbol[tx.AssetSymbol].lastUSD = priceUSD
+				}
+			}
+		}
+
+		for _, asset := range series {
+			book := ledger.book(asset.symbol)
+			priceUSD := asset.priceOn(day, day == today)
+			value, err := converter.convert(book.amount()*priceUSD, "USD", currency, day)
+			if err != nil {
+				return nil, err
+			}
+			point.Value += value
+			point.CostBasis += book.cost()
+		}
+
+		point.RealizedPnL = realized
+		point.UnrealizedPnL = point.Value - point.CostBasis
+		point.PnL = point.RealizedPnL + point.UnrealizedPnL
+		response.Points = append(response.Points, point)
+	}
+
+	response.Oversold = oversoldSymbols(ledger)
+	return response, nil
+}
+
+// priceOn returns the USD price for a day: the cached daily price, the
+// latest price on the last day, else the last known price.
+func (a *assetSeries) priceOn(day int64, last bool) float64 {
+	if price, found := a.dailyUSD[day]; found && price > 0 {
+		a.lastUSD = price
+	} else if last && a.latestUSD > 0 && len(a.dailyUSD) > 0 {
+		a.lastUSD = a.latestUSD
+	}
+	return a.lastUSD
+}
+
+func groupTransactions(transactions []Transaction) []*assetSeries {
+	bySymbol := make(map[string]*assetSeries)
+	series := make([]*assetSeries, 0)
+	for _, tx := range transactions {
+		asset, found := bySymbol[tx.AssetSymbol]
+		if !found {
+			asset = &assetSeries{symbol: tx.AssetSymbol}
+			bySymbol[tx.AssetSymbol] = asset
+			series = append(series, asset)
+		}
+	}
+	sort.Slice(series, func(i, j int) bool {
+		return series[i].symbol < series[j].symbol
+	})
+	return series
+}
+
+// resolveAssetIDs returns the id used for each symbol's prices: the pinned
+// id if given, else the symbol's CoinGecko id. Unresolved symbols are left
+// out.
+func (s *Service) resolveAssetIDs(symbols []string, pinned map[string]string) map[string]string {
+	ids := make(map[string]string, len(symbols))
+	for symbol, id := range pinned {
+		symbol = strings.ToUpper(strings.TrimSpace(symbol))
+		if id = strings.TrimSpace(id); symbol != "" && id != "" {
+			ids[symbol] = id
+		}
+	}
+
+	var unpinned []string
+	for _, symbol := range symbols {
+		if _, found := ids[symbol]; !found {
+			unpinned = append(unpinned, symbol)
+		}
+	}
+	if len(unpinned) > 0 {
+		resolved, err := s.prices.ResolveSymbols(unpinned)
+		if err != nil {
+			log.Printf("Failed to resolve portfolio symbols: %v", err)
+		} else {
+			for symbol, resolution := range resolved.Resolved {
+				switch {
+				case resolution.CoinGeckoID != "":
+					ids[strings.ToUpper(symbol)] = resolution.CoinGeckoID
+				case resolution.CMCID != "":
+					ids[strings.ToUpper(symbol)] = resolution.CMCID
+				}
+			}
+		}
+	}
+
+	assets := make(map[string]string, len(symbols))
+	for _, symbol := range symbols {
+		if id, found := ids[symbol]; found {
+			assets[symbol] = id
+		}
+	}
+	return assets
+}
+
+// loadSeriesPrices fills cached daily and latest prices per asset and
+// returns the symbols without cached history.
+func (s *Service) loadSeriesPrices(series []*assetSeries, assets map[string]string) []string {
+	ids := make([]string, 0, len(assets))
+	for _, id := range assets {
+		ids = append(ids, id)
+	}
+	latest, err := s.prices.GetAssetPrices(ids)
+	if err != nil {
+		log.Printf("Failed to load latest prices for portfolio history: %v", err)
+	}
+
+	var missing []string
+	for _, asset := range series {
+		id, found := assets[asset.symbol]
+		if !found {
+			missing = append(missing, asset.symbol)
+			continue
+		}
+		if price, found := latest[id]; found {
+			asset.latestUSD = price.USD
+			if price.MigrationRatio > 0 {
+				asset.latestUSD *= price.MigrationRatio
+			}
+		}
+
+		history, err := s.cachedHistory(id)
+		if err != nil {
+			log.Printf("No cached history for %s (%s): %v", asset.symbol, id, err)
+			missing = append(missing, asset.symbol)
+			continue
+		}
+		asset.dailyUSD = make(map[int64]float64, len(history.Prices))
+		for _, point := range history.Prices {
+			day := point.Timestamp - point.Timestamp%dayMillis
+			asset.dailyUSD[day] = point.Price * migrationFactor(history)
+		}
+	}
+	return missing
+}
+
+// seriesPrices returns the cached daily USD closes of each series by symbol.
+func seriesPrices(series []*assetSeries) map[string]map[int64]float64 {
+	market := make(map[string]map[int64]float64, len(series))
+	for _, asset := range series {
+		if asset.dailyUSD != nil {
+			market[asset.symbol] = asset.dailyUSD
+		}
+	}
+	return market
+}
+
+// feeAssetPrices loads the cached daily USD closes of traded assets that
+// also pay fees of other transactions.
+func (s *Service) feeAssetPrices(transactions []Transaction, pinned map[string]string) map[string]map[int64]float64 {
+	traded := make(map[string]bool)
+	for _, tx := range transactions {
+		traded[tx.AssetSymbol] = true
+	}
+
+	var series []*assetSeries
+	var symbols []string
+	for _, tx := range transactions {
+		if tx.FeeAmount == nil || *tx.FeeAmount == 0 || tx.FeeCurrency == nil {
+			continue
+		}
+		symbol := *tx.FeeCurrency
+		if symbol == tx.AssetSymbol || !traded[symbol] {
+			continue
+		}
+		traded[symbol] = false
+		symbols = append(symbols, symbol)
+		series = append(series, &assetSeries{symbol: symbol})
+	}
+	if len(series) == 0 {
+		return nil
+	}
+
+	s.loadSeriesPrices(series, s.resolveAssetIDs(symbols, pinned))
+	return seriesPrices(series)
+}
+
+func (s *Service) cachedHistory(id string) (*prices.HistoryResponse, error) {
+	if _, err := strconv.Atoi(id); err == nil {
+		mapped, err := s.prices.ResolveCMCID(id)
+		if err != nil {
+			return nil, err
+		}
+		id = mapped
+	}
+	return s.prices.GetHistoryCachedOnly(id, "365", "daily")
+}
+
+// migrationFactor scales a successor's prices to units of the requested
+// coin.
+func migrationFactor(history *prices.HistoryResponse) float64 {
+	if history.MigrationRatio > 0 {
+		return history.MigrationRatio
+	}
+	return 1
+}
+
+// transactionSymbols returns the distinct symbols in order of appearance.
+func transactionSymbols(transactions []Transaction) []string {
+	seen := make(map[string]struct{})
+	var symbols []string
+	for _, tx := range transactions {
+		if _, found := seen[tx.AssetSymbol]; !found {
+			seen[tx.AssetSymbol] = struct{}{}
+			symbols = append(symbols, tx.AssetSymbol)
+		}
+	}
+	return symbols
+}
+
+// oversoldSymbols lists, sorted, the assets sold beyond the amount held.
+func oversoldSymbols(ledger *ledger) []string {
+	var symbols []string
+	for symbol, oversold := range ledger.oversold {
+		if oversold {
+			symbols = append(symbols, symbol)
+		}
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+func isHistoryDays(days int) bool {
+	for _, allowed := range historyDays {
+		if days == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// rateConverter converts between currencies at historical ECB rates. It is
+// a no-op when every amount is already in the target currency.
+type rateConverter struct {
+	rates *fx.HistoricalRates
+}
+
+// converterFor loads historical rates only if some conversion is needed.
+func (s *Service) converterFor(currency string, transactions []Transaction) (*rateConverter, error) {
+	needed := currency != "USD"
+	for _, tx := range transactions {
+		if tx.FiatCurrency != "USD" || tx.FiatCurrency != currency {
+			needed = true
+			break
+		}
+		// A fee in another fiat needs converting; crypto fees are valued
+		// from market closes in USD.
+		if tx.FeeCurrency != nil && *tx.FeeCurrency != tx.FiatCurrency && fx.IsFiat(*tx.FeeCurrency) {
+			needed = true
+			break
+		}
+	}
+	if !needed {
+		return &rateConverter{}, nil
+	}
+
+	if len(currency) != 3 {
+		return nil, fmt.Errorf("%w: currency must be a 3-letter code", ErrInvalidRequest)
+	}
+	rates, err := s.rates.GetHistoricalRates()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load historical FX rates: %w", err)
+	}
+	if latest, found := rates.RatesOn(time.Now()); !found || latest[currency] <= 0 {
+		return nil, fmt.Errorf("%w: unsupported currency %s", ErrInvalidRequest, currency)
+	}
+	return &rateConverter{rates: rates}, nil
+}
+
+func (c *rateConverter) convert(amount float64, from, to string, at int64) (float64, error) {
+	if from == to || amount == 0 {
+		return amount, nil
+	}
+	if c.rates == nil {
+		return 0, fmt.Errorf("no FX rates loaded for %s to %s", from, to)
+	}
+	converted, err := c.rates.Convert(amount, from, to, time.UnixMilli(at))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	return converted, nil
+}
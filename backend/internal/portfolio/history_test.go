@@ -0,0 +1,90 @@
+package portfolio
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"crypto-portfolio-backend/internal/prices"
+)
+
+func TestServiceHistory(t *testing.T) {
+	today := time.Now().UTC().Truncate(24 * time.Hour).UnixMilli()
+	day := func(offset int) int64 { return today + int64(offset)*dayMillis }
+
+	btcHistory := make([]prices.HistoryPoint, 0, 6)
+	for i := -6; i < 0; i++ {
+		btcHistory = append(btcHistory, prices.HistoryPoint{Timestamp: day(i), Price: float64(106 + i)})
+	}
+	source := fakePrices{
+		latest:    map[string]prices.AssetPrice{"bitcoin": {USD: 150}},
+		histories: map[string][]prices.HistoryPoint{"bitcoin": btcHistory},
+		symbols:   map[string]string{"BTC": "bitcoin"},
+	}
+	service := NewService(source, fakeRates{"EUR": 0.5})
+
+	usd := "USD"
+	fee := func(amount float64) *float64 { return &amount }
+	transactions := []Transaction{
+		{ID: "sell", AssetSymbol: "btc", Amount: -1, PricePerUnitFiat: 103, FiatCurrency: "USD", FeeAmount: fee(1), FeeCurrency: &usd, Type: "SELL", Timestamp: day(-3) + 3600_000},
+		{ID: "buy", AssetSymbol: "BTC", Amount: 2, PricePerUnitFiat: 90, FiatCurrency: "USD", FeeAmount: fee(2), FeeCurrency: &usd, Type: "BUY", Timestamp: day(-10)},
+		{ID: "eth", AssetSymbol: "ETH", Amount: 1, PricePerUnitFiat: 10, FiatCurrency: "EUR", Type: "BUY", Timestamp: day(-10)},
+		{ID: "sol", AssetSymbol: "SOL", Amount: -1, PricePerUnitFiat: 5, FiatCurrency: "USD", Type: "SELL", Timestamp: day(-1)},
+	}
+
+	tests := []struct {
+		name     string
+		currency string
+		index    int
+		want     HistoryPoint
+	}{
+		{name: "before sell", currency: "USD", index: 0, want: HistoryPoint{Value: 220, CostBasis: 202, UnrealizedPnL: 18, PnL: 18}},
+		{name: "sell day", currency: "USD", index: 3, want: HistoryPoint{Value: 123, CostBasis: 111, UnrealizedPnL: 12, RealizedPnL: 11, PnL: 23}},
+		{name: "latest price today", currency: "USD", index: 6, want: HistoryPoint{Value: 170, CostBasis: 111, UnrealizedPnL: 59, RealizedPnL: 11, PnL: 70}},
+		{name: "converted", currency: "EUR", index: 6, want: HistoryPoint{Value: 85, CostBasis: 55.5, UnrealizedPnL: 29.5, RealizedPnL: 5.5, PnL: 35}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			history, err := service.History(HistoryRequest{Transactions: transactions, Currency: tt.currency, Days: 7})
+			if err != nil {
+				t.Fatalf("History: %v", err)
+			}
+			if len(history.Points) != 7 {
+				t.Fatalf("expected 7 points, got %d", len(history.Points))
+			}
+
+			got := history.Points[tt.index]
+			tt.want.Timestamp = day(tt.index - 6)
+			if !historyPointsClose(got, tt.want) {
+				t.Errorf("point %d: got %+v, want %+v", tt.index, got, tt.want)
+			}
+			if !reflect.DeepEqual(history.MissingHistory, []string{"ETH", "SOL"}) || !reflect.DeepEqual(history.Oversold, []string{"SOL"}) {
+				t.Errorf("missing=%v oversold=%v", history.MissingHistory, history.Oversold)
+			}
+		})
+	}
+
+	if _, err := service.History(HistoryRequest{Transactions: transactions, Days: 14}); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("expected unsupported days to be rejected, got %v", err)
+	}
+	if _, err := service.History(HistoryRequest{Transactions: []Transaction{{AssetSymbol: "BTC", Amount: 1, Type: "SWAP", Timestamp: 1}}}); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("expected unsupported type to be rejected, got %v", err)
+	}
+	for _, tx := range []Transaction{
+		{AssetSymbol: "BTC", Amount: -1, Type: "BUY", Timestamp: 1},
+		{AssetSymbol: "BTC", Amount: 1, Type: "SELL", Timestamp: 1},
+	} {
+		if _, err := service.History(HistoryRequest{Transactions: []Transaction{tx}}); !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("expected a %s with amount %v to be rejected, got %v", tx.Type, tx.Amount, err)
+		}
+	}
+}
+
+func historyPointsClose(a, b HistoryPoint) bool {
+	close := func(x, y float64) bool { return math.Abs(x-y) < 1e-9 }
+	return a.Timestamp == b.Timestamp && close(a.Value, b.Value) && close(a.CostBasis, b.CostBasis) &&
+		close(a.UnrealizedPnL, b.UnrealizedPnL) && close(a.RealizedPnL, b.RealizedPnL) && close(a.PnL, b.PnL)
+}
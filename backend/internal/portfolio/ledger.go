@@ -0,0 +1,225 @@
+package portfolio
+
+import "math"
+
+// Disposal is the part of a sell, or of a fee paid in crypto, matched
+// against one lot. Amounts are in the ledger currency.
+type Disposal struct {
+	TransactionID string  `json:"transaction_id"`
+	Symbol        string  `json:"symbol"`
+	LotID         string  `json:"lot_id,omitempty"`
+	AcquiredAt    int64   `json:"acquired_at"`
+	DisposedAt    int64   `json:"disposed_at"`
+	Amount        float64 `json:"amount"`
+	Proceeds      float64 `json:"proceeds"`
+	Cost          float64 `json:"cost"`
+	Gain          float64 `json:"gain"`
+	HoldingDays   int     `json:"holding_days"`
+	// Fee marks units given up to pay a fee rather than sold.
+	Fee bool `json:"fee,omitempty"`
+}
+
+// ledger applies transactions to per-asset lot books in one currency.
+type ledger struct {
+	method    Method
+	currency  string
+	converter *rateConverter
+	books     map[string]*lotBook
+	// market holds cached daily USD closes per symbol, keyed by UTC day
+	// start, used to value fees paid in another asset.
+	market map[string]map[int64]float64
+	// lastPrices is the last price each symbol traded at, in the ledger
+	// currency; the fallback when no close is cached.
+	lastPrices map[string]float64
+	// fees are the fees paid per asset, in the ledger currency.
+	fees     map[string]float64
+	oversold map[string]bool
+	// ignoredFees lists transactions whose fee could not be valued.
+	ignoredFees []string
+}
+
+func newLedger(method Method, currency string, converter *rateConverter, market map[string]map[int64]float64) *ledger {
+	return &ledger{
+		method:     method,
+		currency:   currency,
+		converter:  converter,
+		books:      make(map[string]*lotBook),
+		market:     market,
+		lastPrices: make(map[string]float64),
+		fees:       make(map[string]float64),
+		oversold:   make(map[string]bool),
+	}
+}
+
+// book returns the lot book of an asset, creating it on first use.
+func (l *ledger) book(symbol string) *lotBook {
+	book, found := l.books[symbol]
+	if !found {
+		book = newLotBook(l.method)
+		l.books[symbol] = book
+	}
+	return book
+}
+
+// apply books one transaction and returns the disposals it caused.
+//
+// Fees are handled by fee_currency: a fee in the transaction's fiat is added
+// to the cost of a buy or taken off the proceeds of a sell; a fee in the
+// traded asset reduces the amount received by a buy or adds to the amount
+// leaving with a sell; a fee in another fiat is converted at that day's
+// rate; a fee in another asset is valued at its market price, disposes of
+// those units at that value, and is then treated like a fiat fee.
+func (l *ledger) apply(tx Transaction) ([]Disposal, error) {
+	l.notePrice(tx)
+	quantity := tx.quantity()
+	gross, err := l.converter.convert(quantity*tx.PricePerUnitFiat, tx.FiatCurrency, l.currency, tx.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	fiatFee, assetFee, disposals, err := l.applyFee(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	if tx.Type == TransactionBuy {
+		received := quantity - assetFee
+		if received > lotEpsilon {
+			l.book(tx.AssetSymbol).add(tx.ID, received, (gross+fiatFee)/received, tx.Timestamp)
+		}
+		return disposals, nil
+	}
+
+	leaving := quantity + assetFee
+	matches := l.book(tx.AssetSymbol).remove(leaving)
+	covered := 0.0
+	for _, match := range matches {
+		covered += match.amount
+	}
+	if covered < leaving-lotEpsilon {
+		l.oversold[tx.AssetSymbol] = true
+	}
+
+	// Proceeds are spread over every unit leaving, fee units included, so
+	// the fee lowers the gain of the sale.
+	proceeds := gross - fiatFee
+	for _, match := range matches {
+		disposals = append(disposals, newDisposal(tx, tx.AssetSymbol, match, proceeds*match.amount/leaving, false))
+	}
+	return disposals, nil
+}
+
+// applyFee returns the fee valued in the ledger currency when it was paid in
+// fiat or another asset, the part paid in the traded asset in its units, and
+// disposals of other assets used to pay it.
+func (l *ledger) applyFee(tx Transaction) (float64, float64, []Disposal, error) {
+	if tx.FeeAmount == nil || *tx.FeeAmount == 0 {
+		return 0, 0, nil, nil
+	}
+	fee := *tx.FeeAmount
+	feeCurrency := tx.FiatCurrency
+	if tx.FeeCurrency != nil && *tx.FeeCurrency != "" {
+		feeCurrency = *tx.FeeCurrency
+	}
+
+	switch {
+	case feeCurrency == tx.AssetSymbol:
+		l.fees[tx.AssetSymbol] += fee * l.unitValue(tx)
+		return 0, fee, nil, nil
+
+	case feeCurrency == tx.FiatCurrency:
+		converted, err := l.converter.convert(fee, tx.FiatCurrency, l.currency, tx.Timestamp)
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		l.fees[tx.AssetSymbol] += converted
+		return converted, 0, nil, nil
+	}
+
+	unitValue, valued := l.assetValue(feeCurrency, tx.Timestamp)
+	book, held := l.books[feeCurrency]
+	if !valued && !held {
+		converted, err := l.converter.convert(fee, feeCurrency, l.currency, tx.Timestamp)
+		if err != nil {
+			l.ignoredFees = append(l.ignoredFees, tx.ID)
+			return 0, 0, nil, nil
+		}
+		l.fees[tx.AssetSymbol] += converted
+		return converted, 0, nil, nil
+	}
+
+	// The fee units are disposed of at their market value, which is what
+	// the fee cost. Without any price they leave at cost, with no gain.
+	value := fee * unitValue
+	var disposals []Disposal
+	covered := 0.0
+	if held {
+		for _, match := range book.remove(fee) {
+			proceeds := match.amount * unitValue
+			if !valued {
+				proceeds = match.cost
+				value += match.cost
+			}
+			covered += match.amount
+			disposals = append(disposals, newDisposal(tx, feeCurrency, match, proceeds, true))
+		}
+	}
+	if covered < fee-lotEpsilon {
+		l.oversold[feeCurrency] = true
+	}
+	l.fees[tx.AssetSymbol] += value
+	return value, 0, disposals, nil
+}
+
+// notePrice records the price a transaction traded its asset at.
+func (l *ledger) notePrice(tx Transaction) {
+	if value := l.unitValue(tx); value > 0 {
+		l.lastPrices[tx.AssetSymbol] = value
+	}
+}
+
+// assetValue is the value of one unit of an asset in the ledger currency at
+// a time: its cached daily close, else the last price it traded at.
+func (l *ledger) assetValue(symbol string, at int64) (float64, bool) {
+	if priceUSD, found := l.market[symbol][at-at%dayMillis]; found && priceUSD > 0 {
+		if value, err := l.converter.convert(priceUSD, "USD", l.currency, at); err == nil {
+			return value, true
+		}
+	}
+	value, found := l.lastPrices[symbol]
+	return value, found
+}
+
+// unitValue is the transaction price in the ledger currency, or 0 if it
+// cannot be converted.
+func (l *ledger) unitValue(tx Transaction) float64 {
+	value, err := l.converter.convert(tx.PricePerUnitFiat, tx.FiatCurrency, l.currency, tx.Timestamp)
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+func newDisposal(tx Transaction, symbol string, match lotMatch, proceeds float64, fee bool) Disposal {
+	return Disposal{
+		TransactionID: tx.ID,
+		Symbol:        symbol,
+		LotID:         match.transactionID,
+		AcquiredAt:    match.acquiredAt,
+		DisposedAt:    tx.Timestamp,
+		Amount:        match.amount,
+		Proceeds:      proceeds,
+		Cost:          match.cost,
+		Gain:          proceeds - match.cost,
+		HoldingDays:   holdingDays(match.acquiredAt, tx.Timestamp),
+		Fee:           fee,
+	}
+}
+
+// holdingDays counts whole days between two unix millisecond timestamps.
+func holdingDays(from, to int64) int {
+	if to <= from {
+		return 0
+	}
+	return int(math.Floor(float64(to-from) / float64(dayMillis)))
+}
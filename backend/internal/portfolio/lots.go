@@ -0,0 +1,146 @@
+package portfolio
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Method selects which lots a disposal consumes.
+type Method string
+
+// Cost basis methods.
+const (
+	// MethodFIFO disposes of the oldest lots first.
+	MethodFIFO Method = "fifo"
+	// MethodLIFO disposes of the newest lots first.
+	MethodLIFO Method = "lifo"
+	// MethodHIFO disposes of the lots with the highest unit cost first.
+	MethodHIFO Method = "hifo"
+	// MethodAverage values every unit at the moving average cost; lots are
+	// still consumed oldest first so holding periods stay meaningful.
+	MethodAverage Method = "average"
+)
+
+// ParseMethod parses a method name; an empty name means FIFO.
+func ParseMethod(name string) (Method, error) {
+	switch method := Method(strings.ToLower(strings.TrimSpace(name))); method {
+	case "":
+		return MethodFIFO, nil
+	case MethodFIFO, MethodLIFO, MethodHIFO, MethodAverage:
+		return method, nil
+	default:
+		return "", fmt.Errorf("%w: unsupported method %q", ErrInvalidRequest, name)
+	}
+}
+
+// lot is an acquired amount still held, with its cost per unit.
+type lot struct {
+	transactionID string
+	amount        float64
+	unitCost      float64
+	acquiredAt    int64
+}
+
+// lotMatch is the part of a lot consumed by a disposal.
+type lotMatch struct {
+	transactionID string
+	amount        float64
+	cost          float64
+	acquiredAt    int64
+}
+
+// lotBook tracks the open lots of one asset.
+type lotBook struct {
+	method Method
+	lots   []lot
+}
+
+func newLotBook(method Method) *lotBook {
+	return &lotBook{method: method}
+}
+
+// add opens a lot. Under the average method every open lot is repriced to
+// the new moving average.
+func (b *lotBook) add(transactionID string, amount, unitCost float64, acquiredAt int64) {
+	b.lots = append(b.lots, lot{
+		transactionID: transactionID,
+		amount:        amount,
+		unitCost:      unitCost,
+		acquiredAt:    acquiredAt,
+	})
+
+	if b.method == MethodAverage {
+		held := b.amount()
+		if held <= lotEpsilon {
+			return
+		}
+		average := b.cost() / held
+		for i := range b.lots {
+			b.lots[i].unitCost = average
+		}
+	}
+}
+
+// remove disposes of up to amount and returns the consumed lot parts in
+// the order they were matched.
+func (b *lotBook) remove(amount float64) []lotMatch {
+	var matches []lotMatch
+	for amount > lotEpsilon && len(b.lots) > 0 {
+		index := b.next()
+		current := &b.lots[index]
+		used := min(current.amount, amount)
+
+		matches = append(matches, lotMatch{
+			transactionID: current.transactionID,
+			amount:        used,
+			cost:          used * current.unitCost,
+			acquiredAt:    current.acquiredAt,
+		})
+		current.amount -= used
+		amount -= used
+
+		if current.amount <= lotEpsilon {
+			b.lots = append(b.lots[:index], b.lots[index+1:]...)
+		}
+	}
+	return matches
+}
+
+// next returns the index of the lot the method disposes of first.
+func (b *lotBook) next() int {
+	switch b.method {
+	case MethodLIFO:
+		return len(b.lots) - 1
+	case MethodHIFO:
+		best := 0
+		for i, open := range b.lots {
+			if open.unitCost > b.lots[best].unitCost {
+				best = i
+			}
+		}
+		return best
+	default:
+		return 0
+	}
+}
+
+// amount returns the total amount held.
+func (b *lotBook) amount() float64 {
+	total := 0.0
+	for _, open := range b.lots {
+		total += open.amount
+	}
+	return total
+}
+
+// cost returns the cost basis of the amount held.
+func (b *lotBook) cost() float64 {
+	total := 0.0
+	for _, open := range b.lots {
+		total += open.amount * open.unitCost
+	}
+	return total
+}
+
+// lotEpsilon absorbs float rounding when lots are consumed exactly.
+const lotEpsilon = 1e-12
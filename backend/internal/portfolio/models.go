@@ -0,0 +1,43 @@
+package portfolio
+
+// Holding is an amount of an asset given by unified, CMC or CoinGecko id.
+type Holding struct {
+	AssetID string  `json:"asset_id"`
+	Amount  float64 `json:"amount"`
+}
+
+// ValueRequest is the body of POST /portfolio/value.
+type ValueRequest struct {
+	Holdings []Holding `json:"holdings"`
+	Currency string    `json:"currency,omitempty"`
+}
+
+// AssetValue is the value of one holding in the target currency.
+type AssetValue struct {
+	AssetID        string  `json:"asset_id"`
+	UnifiedID      string  `json:"unified_id,omitempty"`
+	CMCID          string  `json:"cmc_id,omitempty"`
+	CoinGeckoID    string  `json:"coingecko_id,omitempty"`
+	Symbol         string  `json:"symbol,omitempty"`
+	Amount         float64 `json:"amount"`
+	Price          float64 `json:"price"`
+	Value          float64 `json:"value"`
+	Allocation     float64 `json:"allocation"`
+	Source         string  `json:"source"`
+	LastUpdatedAt  int64   `json:"last_updated_at,omitempty"`
+	MigratedFrom   string  `json:"migrated_from,omitempty"`
+	MigrationRatio float64 `json:"migration_ratio,omitempty"`
+}
+
+// ValueResponse is returned to the mobile app for POST /portfolio/value.
+type ValueResponse struct {
+	Currency string `json:"currency"`
+	// USDRate is units of Currency per USD.
+	USDRate  float64      `json:"usd_rate"`
+	Total    float64      `json:"total"`
+	Assets   []AssetValue `json:"assets"`
+	Unpriced []string     `json:"unpriced,omitempty"`
+	// PricesUpdatedAt is when the oldest price used was fetched (unix ms).
+	PricesUpdatedAt int64 `json:"prices_updated_at,omitempty"`
+	Timestamp       int64 `json:"timestamp"`
+}
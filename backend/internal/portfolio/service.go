@@ -0,0 +1,188 @@
+package portfolio
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"crypto-portfolio-backend/internal/fx"
+	"crypto-portfolio-backend/internal/prices"
+)
+
+// MaxHoldings bounds the holdings of a single valuation request.
+const MaxHoldings = 500
+
+// ErrInvalidRequest is wrapped by errors caused by the request itself.
+var ErrInvalidRequest = errors.New("invalid portfolio request")
+
+// PriceSource provides latest and historical prices; implemented by
+// prices.Service.
+type PriceSource interface {
+	GetAssetPrices(ids []string) (map[string]prices.AssetPrice, error)
+	GetHistoryCachedOnly(id, days, interval string) (*prices.HistoryResponse, error)
+	ResolveSymbols(symbols []string) (*prices.SymbolResolveResponse, error)
+	ResolveCMCID(cmcID string) (string, error)
+	AssetID(id string) string
+}
+
+// RateSource provides USD-based FX rates; implemented by fx.Service.
+type RateSource interface {
+	GetRates() (*fx.RatesResponse, error)
+	GetHistoricalRates() (*fx.HistoricalRates, error)
+}
+
+// Service values portfolios sent by the device. Nothing is persisted; the
+// device stays the source of truth for holdings and transactions.
+type Service struct {
+	prices PriceSource
+	rates  RateSource
+}
+
+// NewService creates a new portfolio service.
+func NewService(priceSource PriceSource, rateSource RateSource) *Service {
+	return &Service{
+		prices: priceSource,
+		rates:  rateSource,
+	}
+}
+
+// Value prices each holding from the latest prices cache and converts the
+// result to the requested currency (USD by default). Holdings of the same
+// asset are merged whichever id form they use; holdings without a price are
+// listed as unpriced.
+func (s *Service) Value(request ValueRequest) (*ValueResponse, error) {
+	holdings, err := mergeHoldings(request.Holdings, s.prices.AssetID)
+	if err != nil {
+		return nil, err
+	}
+
+	currency, rate, err := s.usdRate(request.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(holdings))
+	for _, holding := range holdings {
+		ids = append(ids, holding.AssetID)
+	}
+	latest, err := s.prices.GetAssetPrices(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &ValueResponse{
+		Currency:  currency,
+		USDRate:   rate,
+		Assets:    make([]AssetValue, 0, len(holdings)),
+		Timestamp: time.Now().UnixMilli(),
+	}
+
+	var oldest time.Time
+	for _, holding := range holdings {
+		price, found := latest[holding.AssetID]
+		if !found {
+			response.Unpriced = append(response.Unpriced, holding.AssetID)
+			continue
+		}
+
+		// A migrated holding is worth ratio units of its successor.
+		unitPrice := price.USD * rate
+		if price.MigrationRatio > 0 {
+			unitPrice *= price.MigrationRatio
+		}
+
+		value := holding.Amount * unitPrice
+		response.Total += value
+		response.Assets = append(response.Assets, AssetValue{
+			AssetID:        holding.AssetID,
+			UnifiedID:      price.AssetID,
+			CMCID:          price.CMCID,
+			CoinGeckoID:    price.CoinGeckoID,
+			Symbol:         price.Symbol,
+			Amount:         holding.Amount,
+			Price:          unitPrice,
+			Value:          value,
+			Source:         price.Source,
+			LastUpdatedAt:  price.LastUpdatedAt,
+			MigratedFrom:   price.MigratedFrom,
+			MigrationRatio: price.MigrationRatio,
+		})
+
+		if !price.UpdatedAt.IsZero() && (oldest.IsZero() || price.UpdatedAt.Before(oldest)) {
+			oldest = price.UpdatedAt
+		}
+	}
+
+	for i := range response.Assets {
+		if response.Total != 0 {
+			response.Assets[i].Allocation = response.Assets[i].Value / response.Total
+		}
+	}
+	sort.SliceStable(response.Assets, func(i, j int) bool {
+		return response.Assets[i].Value > response.Assets[j].Value
+	})
+	if !oldest.IsZero() {
+		response.PricesUpdatedAt = oldest.UnixMilli()
+	}
+
+	return response, nil
+}
+
+// usdRate returns the normalized currency code and its units per USD.
+func (s *Service) usdRate(currency string) (string, float64, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" || currency == "USD" {
+		return "USD", 1, nil
+	}
+	if len(currency) != 3 {
+		return "", 0, fmt.Errorf("%w: currency must be a 3-letter code", ErrInvalidRequest)
+	}
+
+	rates, err := s.rates.GetRates()
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to load FX rates: %w", err)
+	}
+	rate, found := rates.Rates[currency]
+	if !found || rate <= 0 {
+		return "", 0, fmt.Errorf("%w: unsupported currency %s", ErrInvalidRequest, currency)
+	}
+	return currency, rate, nil
+}
+
+// mergeHoldings validates holdings and sums amounts per asset, keeping the
+// order in which assets first appear. Ids are replaced by the unified id
+// assetID returns for them, so "1", "ast_1" and "bitcoin" are one holding.
+func mergeHoldings(holdings []Holding, assetID func(string) string) ([]Holding, error) {
+	if len(holdings) == 0 {
+		return nil, fmt.Errorf("%w: holdings cannot be empty", ErrInvalidRequest)
+	}
+	if len(holdings) > MaxHoldings {
+		return nil, fmt.Errorf("%w: at most %d holdings are allowed", ErrInvalidRequest, MaxHoldings)
+	}
+
+	merged := make([]Holding, 0, len(holdings))
+	positions := make(map[string]int, len(holdings))
+	for index, holding := range holdings {
+		id := strings.TrimSpace(holding.AssetID)
+		if id == "" {
+			return nil, fmt.Errorf("%w: holding %d has no asset_id", ErrInvalidRequest, index)
+		}
+		if math.IsNaN(holding.Amount) || math.IsInf(holding.Amount, 0) {
+			return nil, fmt.Errorf("%w: holding %s has an invalid amount", ErrInvalidRequest, id)
+		}
+		if unified := assetID(id); unified != "" {
+			id = unified
+		}
+
+		if position, found := positions[id]; found {
+			merged[position].Amount += holding.Amount
+			continue
+		}
+		positions[id] = len(merged)
+		merged = append(merged, Holding{AssetID: id, Amount: holding.Amount})
+	}
+	return merged, nil
+}
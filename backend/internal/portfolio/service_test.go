@@ -0,0 +1,150 @@
+package portfolio
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"crypto-portfolio-backend/internal/fx"
+	"crypto-portfolio-backend/internal/prices"
+)
+
+type fakePrices struct {
+	latest    map[string]prices.AssetPrice
+	histories map[string][]prices.HistoryPoint
+	symbols   map[string]string
+	// assets maps any id form to its unified id.
+	assets map[string]string
+}
+
+func (f fakePrices) GetAssetPrices(ids []string) (map[string]prices.AssetPrice, error) {
+	result := make(map[string]prices.AssetPrice)
+	for _, id := range ids {
+		if price, found := f.latest[id]; found {
+			result[id] = price
+		}
+	}
+	return result, nil
+}
+
+func (f fakePrices) GetHistoryCachedOnly(id, days, interval string) (*prices.HistoryResponse, error) {
+	points, found := f.histories[id]
+	if !found {
+		return nil, fmt.Errorf("history not cached for %s", id)
+	}
+	return &prices.HistoryResponse{ID: id, Days: days, Interval: interval, Prices: points}, nil
+}
+
+func (f fakePrices) ResolveSymbols(symbols []string) (*prices.SymbolResolveResponse, error) {
+	resolved := make(map[string]prices.SymbolResolution)
+	for _, symbol := range symbols {
+		if id, found := f.symbols[symbol]; found {
+			resolved[symbol] = prices.SymbolResolution{Symbol: symbol, CoinGeckoID: id}
+		}
+	}
+	return &prices.SymbolResolveResponse{Resolved: resolved}, nil
+}
+
+func (f fakePrices) ResolveCMCID(cmcID string) (string, error) {
+	return "", fmt.Errorf("no coingecko mapping for cmc_id %s", cmcID)
+}
+
+func (f fakePrices) AssetID(id string) string {
+	return f.assets[id]
+}
+
+// fakeRates holds USD-based rates that apply on every day.
+type fakeRates map[string]float64
+
+func (f fakeRates) GetRates() (*fx.RatesResponse, error) {
+	return &fx.RatesResponse{Base: "USD", Rates: f}, nil
+}
+
+func (f fakeRates) GetHistoricalRates() (*fx.HistoricalRates, error) {
+	rates := map[string]float64{"USD": 1}
+	for currency, rate := range f {
+		rates[currency] = rate
+	}
+	return &fx.HistoricalRates{Base: "USD", Days: []fx.DailyRates{{Date: "1999-01-04", Rates: rates}}}, nil
+}
+
+func TestServiceValue(t *testing.T) {
+	updated := time.UnixMilli(1_700_000_000_000)
+	service := NewService(fakePrices{assets: map[string]string{"1": "ast_1", "ast_1": "ast_1", "bitcoin": "ast_1"}, latest: map[string]prices.AssetPrice{
+		"ast_1":         {AssetID: "ast_1", CMCID: "1", Symbol: "BTC", USD: 40000, Source: prices.ProviderCMC, UpdatedAt: updated},
+		"ethereum":      {AssetID: "ast_2", CoinGeckoID: "ethereum", Symbol: "ETH", USD: 2000, Source: prices.ProviderCoinGecko, UpdatedAt: updated.Add(time.Minute)},
+		"matic-network": {CoinGeckoID: "matic-network", USD: 0.5, Source: prices.ProviderCoinGecko, MigratedFrom: "matic-network", MigrationRatio: 1},
+	}}, fakeRates{"EUR": 0.5})
+
+	tests := []struct {
+		name     string
+		request  ValueRequest
+		currency string
+		total    float64
+		symbols  []string
+		unpriced []string
+		invalid  bool
+	}{
+		{
+			name: "usd with merged holdings",
+			request: ValueRequest{Holdings: []Holding{
+				{AssetID: "1", Amount: 0.25},
+				{AssetID: "ethereum", Amount: 1},
+				{AssetID: "ast_1", Amount: 0.1},
+				{AssetID: "bitcoin", Amount: 0.15},
+				{AssetID: "unknown-coin", Amount: 3},
+			}},
+			currency: "USD",
+			total:    22000,
+			symbols:  []string{"BTC", "ETH"},
+			unpriced: []string{"unknown-coin"},
+		},
+		{
+			name:     "converted currency",
+			request:  ValueRequest{Holdings: []Holding{{AssetID: "ethereum", Amount: 2}, {AssetID: "matic-network", Amount: 100}}, Currency: "eur"},
+			currency: "EUR",
+			total:    2025,
+			symbols:  []string{"ETH", ""},
+		},
+		{name: "empty", request: ValueRequest{}, invalid: true},
+		{name: "unsupported currency", request: ValueRequest{Holdings: []Holding{{AssetID: "1", Amount: 1}}, Currency: "XYZ"}, invalid: true},
+		{name: "invalid amount", request: ValueRequest{Holdings: []Holding{{AssetID: "1", Amount: math.Inf(1)}}}, invalid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := service.Value(tt.request)
+			if tt.invalid {
+				if !errors.Is(err, ErrInvalidRequest) {
+					t.Fatalf("expected ErrInvalidRequest, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Value: %v", err)
+			}
+
+			if value.Currency != tt.currency || value.Total != tt.total {
+				t.Errorf("got %s %.2f, want %s %.2f", value.Currency, value.Total, tt.currency, tt.total)
+			}
+			symbols := make([]string, 0, len(value.Assets))
+			allocation := 0.0
+			for _, asset := range value.Assets {
+				symbols = append(symbols, asset.Symbol)
+				allocation += asset.Allocation
+			}
+			if !reflect.DeepEqual(symbols, tt.symbols) {
+				t.Errorf("assets: got %v, want %v", symbols, tt.symbols)
+			}
+			if math.Abs(allocation-1) > 1e-9 {
+				t.Errorf("allocations sum to %f", allocation)
+			}
+			if !reflect.DeepEqual(value.Unpriced, tt.unpriced) {
+				t.Errorf("unpriced: got %v, want %v", value.Unpriced, tt.unpriced)
+			}
+		})
+	}
+}
@@ -0,0 +1,439 @@
+package portfolio
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tax jurisdictions supported by POST /portfolio/tax-report.
+const (
+	JurisdictionUS = "us"
+	JurisdictionJP = "jp"
+)
+
+// totalAverage is the Japanese 総平均法: every disposal of a calendar year
+// uses the average cost of the units held at the start of the year and all
+// units bought during it.
+const totalAverage = "total_average"
+
+// jurisdiction describes how a country reports crypto disposals.
+type jurisdiction struct {
+	currency string
+	// location sets the calendar year and the dates printed in reports.
+	location *time.Location
+	// longTerm reports whether a disposal qualifies for long-term rates,
+	// given both times in the taxpayer's zone; nil when the jurisdiction does
+	// not distinguish holding periods.
+	longTerm func(acquired, disposed time.Time) bool
+	// totalAverage replaces lot matching with the yearly total average.
+	totalAverage bool
+}
+
+var jurisdictions = map[string]jurisdiction{
+	// Form 8949: assets held more than one year are long-term. The holding
+	// period counts calendar days, so an asset sold on the anniversary of
+	// its purchase date is still short-term whatever the time of day.
+	JurisdictionUS: {
+		currency: "USD",
+		location: time.UTC,
+		longTerm: func(acquired, disposed time.Time) bool {
+			return calendarDate(disposed).After(calendarDate(acquired).AddDate(1, 0, 0))
+		},
+	},
+	// Crypto gains are miscellaneous income computed in JPY with the total
+	// average method, whatever the holding period.
+	JurisdictionJP: {
+		currency:     "JPY",
+		location:     time.FixedZone("JST", 9*60*60),
+		totalAverage: true,
+	},
+}
+
+// Holding period terms of a disposal.
+const (
+	TermShort = "short"
+	TermLong  = "long"
+)
+
+// TaxRequest is the body of POST /portfolio/tax-report.
+type TaxRequest struct {
+	Transactions []Transaction `json:"transactions"`
+	Jurisdiction string        `json:"jurisdiction"`
+	// Year limits rows to disposals in that calendar year; earlier
+	// transactions still set the cost basis. 0 reports every year.
+	Year int `json:"year,omitempty"`
+	// Method is the US cost basis method; Japan always uses the total
+	// average method.
+	Method string `json:"method,omitempty"`
+	// Timezone is the taxpayer's IANA zone, which sets calendar years,
+	// holding periods and report dates; the jurisdiction's zone by default
+	// (UTC for the US, JST for Japan).
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// TaxRow is one disposal with its gain or loss in the report currency.
+type TaxRow struct {
+	TransactionID string  `json:"transaction_id"`
+	Symbol        string  `json:"symbol"`
+	Amount        float64 `json:"amount"`
+	// AcquiredAt is omitted when the cost is an average over many lots.
+	AcquiredAt  int64   `json:"acquired_at,omitempty"`
+	DisposedAt  int64   `json:"disposed_at"`
+	Proceeds    float64 `json:"proceeds"`
+	CostBasis   float64 `json:"cost_basis"`
+	Gain        float64 `json:"gain"`
+	HoldingDays int     `json:"holding_days,omitempty"`
+	Term        string  `json:"term,omitempty"`
+	// UnitCost is the yearly average cost used by the total average method.
+	UnitCost float64 `json:"unit_cost,omitempty"`
+	// Fee marks units given up to pay a fee rather than sold.
+	Fee bool `json:"fee,omitempty"`
+}
+
+// TaxSummary totals the rows of a report.
+type TaxSummary struct {
+	Disposals     int     `json:"disposals"`
+	Proceeds      float64 `json:"proceeds"`
+	CostBasis     float64 `json:"cost_basis"`
+	Gain          float64 `json:"gain"`
+	ShortTermGain float64 `json:"short_term_gain,omitempty"`
+	LongTermGain  float64 `json:"long_term_gain,omitempty"`
+}
+
+// TaxReport is returned for POST /portfolio/tax-report.
+type TaxReport struct {
+	Jurisdiction string     `json:"jurisdiction"`
+	Year         int        `json:"year,omitempty"`
+	Timezone     string     `json:"timezone"`
+	Currency     string     `json:"currency"`
+	Method       string     `json:"method"`
+	Rows         []TaxRow   `json:"rows"`
+	Summary      TaxSummary `json:"summary"`
+	// Oversold lists symbols with sells beyond the amount held; the excess
+	// has no cost basis.
+	Oversold []string `json:"oversold,omitempty"`
+	// IgnoredFees lists transactions whose fee currency could not be valued.
+	IgnoredFees []string `json:"ignored_fees,omitempty"`
+	Timestamp   int64    `json:"timestamp"`
+
+	location *time.Location
+}
+
+// TaxReport computes the gain or loss of every disposal under the rules of
+// a jurisdiction, converted to its currency at the ECB rate of each
+// transaction's day.
+func (s *Service) TaxReport(request TaxRequest) (*TaxReport, error) {
+	code := strings.ToLower(strings.TrimSpace(request.Jurisdiction))
+	profile, found := jurisdictions[code]
+	if !found {
+		return nil, fmt.Errorf("%w: jurisdiction must be %s or %s", ErrInvalidRequest, JurisdictionUS, JurisdictionJP)
+	}
+	if request.Year < 0 || request.Year > 9999 {
+		return nil, fmt.Errorf("%w: invalid year %d", ErrInvalidRequest, request.Year)
+	}
+	if name := strings.TrimSpace(request.Timezone); name != "" {
+		location, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unknown timezone %q", ErrInvalidRequest, name)
+		}
+		profile.location = location
+	}
+
+	transactions, err := normalizeTransactions(request.Transactions)
+	if err != nil {
+		return nil, err
+	}
+
+	method := totalAverage
+	if profile.totalAverage {
+		if request.Method != "" && !strings.EqualFold(request.Method, totalAverage) {
+			return nil, fmt.Errorf("%w: %s reports use the %s method", ErrInvalidRequest, code, totalAverage)
+		}
+	} else {
+		parsed, err := ParseMethod(request.Method)
+		if err != nil {
+			return nil, err
+		}
+		method = string(parsed)
+	}
+
+	converter, err := s.converterFor(profile.currency, transactions)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &TaxReport{
+		Jurisdiction: code,
+		Year:         request.Year,
+		Timezone:     profile.location.String(),
+		Currency:     profile.currency,
+		Method:       method,
+		Rows:         make([]TaxRow, 0),
+		Timestamp:    time.Now().UnixMilli(),
+		location:     profile.location,
+	}
+
+	market := s.feeAssetPrices(transactions, nil)
+	var rows []TaxRow
+	if profile.totalAverage {
+		rows, report.Oversold, report.IgnoredFees, err = totalAverageRows(transactions, profile, converter, market)
+	} else {
+		rows, report.Oversold, report.IgnoredFees, err = lotRows(transactions, Method(method), profile, converter, market)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		if request.Year != 0 && time.UnixMilli(row.DisposedAt).In(profile.location).Year() != request.Year {
+			continue
+		}
+		report.Rows = append(report.Rows, row)
+
+		report.Summary.Disposals++
+		report.Summary.Proceeds += row.Proceeds
+		report.Summary.CostBasis += row.CostBasis
+		report.Summary.Gain += row.Gain
+		switch row.Term {
+		case TermShort:
+			report.Summary.ShortTermGain += row.Gain
+		case TermLong:
+			report.Summary.LongTermGain += row.Gain
+		}
+	}
+
+	return report, nil
+}
+
+// lotRows matches disposals against lots and classifies their holding
+// period.
+func lotRows(transactions []Transaction, method Method, profile jurisdiction, converter *rateConverter, market map[string]map[int64]float64) ([]TaxRow, []string, []string, error) {
+	ledger := newLedger(method, profile.currency, converter, market)
+	var rows []TaxRow
+	for _, tx := range transactions {
+		disposals, err := ledger.apply(tx)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		for _, disposal := range disposals {
+			row := TaxRow{
+				TransactionID: disposal.TransactionID,
+				Symbol:        disposal.Symbol,
+				Amount:        disposal.Amount,
+				AcquiredAt:    disposal.AcquiredAt,
+				DisposedAt:    disposal.DisposedAt,
+				Proceeds:      disposal.Proceeds,
+				CostBasis:     disposal.Cost,
+				Gain:          disposal.Gain,
+				HoldingDays:   disposal.HoldingDays,
+				Fee:           disposal.Fee,
+			}
+			if profile.longTerm != nil {
+				row.Term = TermShort
+				if profile.longTerm(time.UnixMilli(row.AcquiredAt).In(profile.location), time.UnixMilli(row.DisposedAt).In(profile.location)) {
+					row.Term = TermLong
+				}
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows, oversoldSymbols(ledger), ledger.ignoredFees, nil
+}
+
+// position is the amount and cost of an asset carried into a year.
+type position struct {
+	amount float64
+	cost   float64
+}
+
+// totalAverageRows prices every sell of a calendar year at the average cost
+// of the units carried into the year plus all units bought during it.
+func totalAverageRows(transactions []Transaction, profile jurisdiction, converter *rateConverter, market map[string]map[int64]float64) ([]TaxRow, []string, []string, error) {
+	// The ledger only values fees; no lots are opened in it. Units of
+	// another traded asset used to pay a fee are sold from that asset's
+	// average at the fee's value below.
+	fees := newLedger(MethodAverage, profile.currency, converter, market)
+
+	type pricedTx struct {
+		tx      Transaction
+		gross   float64
+		fiatFee float64
+		units   float64
+		fee     bool
+	}
+
+	traded := make(map[string]bool)
+	for _, tx := range transactions {
+		traded[tx.AssetSymbol] = true
+	}
+
+	years := make(map[string]map[int][]pricedTx)
+	var symbols []string
+	add := func(symbol string, priced pricedTx) {
+		if years[symbol] == nil {
+			years[symbol] = make(map[int][]pricedTx)
+			symbols = append(symbols, symbol)
+		}
+		year := time.UnixMilli(priced.tx.Timestamp).In(profile.location).Year()
+		years[symbol][year] = append(years[symbol][year], priced)
+	}
+
+	for _, tx := range transactions {
+		gross, err := converter.convert(tx.quantity()*tx.PricePerUnitFiat, tx.FiatCurrency, profile.currency, tx.Timestamp)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		fees.notePrice(tx)
+		fiatFee, assetFee, _, err := fees.applyFee(tx)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		units := tx.quantity() - assetFee
+		if tx.Type == TransactionSell {
+			units = tx.quantity() + assetFee
+		}
+		add(tx.AssetSymbol, pricedTx{tx: tx, gross: gross, fiatFee: fiatFee, units: units})
+
+		if tx.FeeCurrency != nil && *tx.FeeCurrency != tx.AssetSymbol && traded[*tx.FeeCurrency] && fiatFee > 0 {
+			disposal := tx
+			disposal.AssetSymbol, disposal.Type = *tx.FeeCurrency, TransactionSell
+			add(disposal.AssetSymbol, pricedTx{tx: disposal, gross: fiatFee, units: *tx.FeeAmount, fee: true})
+		}
+	}
+
+	var rows []TaxRow
+	var oversold []string
+	for _, symbol := range symbols {
+		order := make([]int, 0, len(years[symbol]))
+		for year := range years[symbol] {
+			order = append(order, year)
+		}
+		sort.Ints(order)
+
+		held := position{}
+		flagged := false
+		for _, year := range order {
+			bought := position{}
+			for _, priced := range years[symbol][year] {
+				if priced.tx.Type == TransactionBuy && priced.units > lotEpsilon {
+					bought.amount += priced.units
+					bought.cost += priced.gross + priced.fiatFee
+				}
+			}
+
+			unitCost := 0.0
+			if total := held.amount + bought.amount; total > lotEpsilon {
+				unitCost = (held.cost + bought.cost) / total
+			}
+
+			for _, priced := range years[symbol][year] {
+				if priced.tx.Type == TransactionBuy {
+					held.amount += max(priced.units, 0)
+					continue
+				}
+
+				covered := min(priced.units, max(held.amount, 0))
+				if covered < priced.units-lotEpsilon && !flagged {
+					oversold = append(oversold, symbol)
+					flagged = true
+				}
+				held.amount -= covered
+
+				proceeds := priced.gross - priced.fiatFee
+				cost := covered * unitCost
+				rows = append(rows, TaxRow{
+					TransactionID: priced.tx.ID,
+					Symbol:        symbol,
+					Amount:        priced.units,
+					DisposedAt:    priced.tx.Timestamp,
+					Proceeds:      proceeds,
+					CostBasis:     cost,
+					Gain:          proceeds - cost,
+					UnitCost:      unitCost,
+					Fee:           priced.fee,
+				})
+			}
+			held.cost = held.amount * unitCost
+		}
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].DisposedAt < rows[j].DisposedAt
+	})
+	sort.Strings(oversold)
+	return rows, oversold, fees.ignoredFees, nil
+}
+
+// calendarDate returns midnight UTC of the date t falls on in its own zone.
+func calendarDate(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// WriteCSV writes the report rows in the jurisdiction's layout: Form 8949
+// columns for the US with short-term rows (Part I) before long-term rows
+// (Part II), and a JPY disposal ledger for Japan.
+func (r *TaxReport) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	location := r.location
+	if location == nil {
+		location = jurisdictions[r.Jurisdiction].location
+	}
+	if location == nil {
+		location = time.UTC
+	}
+	date := func(millis int64, layout string) string {
+		return time.UnixMilli(millis).In(location).Format(layout)
+	}
+	amount := func(value float64) string {
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+
+	var records [][]string
+	switch r.Jurisdiction {
+	case JurisdictionJP:
+		yen := func(value float64) string {
+			return strconv.FormatFloat(value, 'f', 0, 64)
+		}
+		records = append(records, []string{"Date", "Asset", "Amount", "Proceeds (JPY)", "Average unit cost (JPY)", "Cost (JPY)", "Gain (JPY)"})
+		for _, row := range r.Rows {
+			records = append(records, []string{
+				date(row.DisposedAt, "2006/01/02"), row.Symbol, amount(row.Amount),
+				yen(row.Proceeds), yen(row.UnitCost), yen(row.CostBasis), yen(row.Gain),
+			})
+		}
+
+	default:
+		money := func(value float64) string {
+			return strconv.FormatFloat(value, 'f', 2, 64)
+		}
+		records = append(records, []string{
+			"Part", "(a) Description of property", "(b) Date acquired", "(c) Date sold or disposed of",
+			"(d) Proceeds", "(e) Cost or other basis", "(f) Code", "(g) Amount of adjustment", "(h) Gain or (loss)",
+		})
+		for _, part := range []struct{ name, term string }{{"I", TermShort}, {"II", TermLong}} {
+			for _, row := range r.Rows {
+				if row.Term != part.term {
+					continue
+				}
+				records = append(records, []string{
+					part.name, amount(row.Amount) + " " + row.Symbol,
+					date(row.AcquiredAt, "01/02/2006"), date(row.DisposedAt, "01/02/2006"),
+					money(row.Proceeds), money(row.CostBasis), "", "", money(row.Gain),
+				})
+			}
+		}
+	}
+
+	if err := writer.WriteAll(records); err != nil {
+		return fmt.Errorf("failed to write tax report CSV: %w", err)
+	}
+	return nil
+}
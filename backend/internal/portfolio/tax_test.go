@@ -0,0 +1,258 @@
+package portfolio
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServiceTaxReport(t *testing.T) {
+	service := NewService(fakePrices{}, fakeRates{"JPY": 150})
+	at := func(date string) int64 {
+		parsed, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed.UnixMilli()
+	}
+	tx := func(id, kind string, amount, price float64, date string) Transaction {
+		return Transaction{ID: id, AssetSymbol: "BTC", Amount: amount, PricePerUnitFiat: price, FiatCurrency: "USD", Type: kind, Timestamp: at(date)}
+	}
+
+	usTransactions := []Transaction{
+		tx("b1", "BUY", 1, 100, "2023-01-10"),
+		tx("b2", "BUY", 1, 200, "2024-01-05"),
+		tx("s1", "SELL", -1, 300, "2024-01-10"),
+		tx("s2", "SELL", -0.5, 400, "2025-02-01"),
+	}
+	jpTransactions := []Transaction{
+		tx("b1", "BUY", 1, 100, "2023-06-01"),
+		tx("b2", "BUY", 1, 300, "2024-02-01"),
+		tx("s1", "SELL", -1, 400, "2024-03-01"),
+		tx("b3", "BUY", 2, 50, "2024-12-01"),
+		tx("s2", "SELL", -1, 100, "2025-01-15"),
+	}
+
+	tests := []struct {
+		name     string
+		request  TaxRequest
+		currency string
+		terms    []string
+		gains    []float64
+		summary  TaxSummary
+	}{
+		{
+			name:     "us held exactly a year is short-term",
+			request:  TaxRequest{Transactions: usTransactions, Jurisdiction: "US", Year: 2024},
+			currency: "USD",
+			terms:    []string{TermShort},
+			gains:    []float64{200},
+			summary:  TaxSummary{Disposals: 1, Proceeds: 300, CostBasis: 100, Gain: 200, ShortTermGain: 200},
+		},
+		{
+			name:     "us all years",
+			request:  TaxRequest{Transactions: usTransactions, Jurisdiction: "us"},
+			currency: "USD",
+			terms:    []string{TermShort, TermLong},
+			gains:    []float64{200, 100},
+			summary:  TaxSummary{Disposals: 2, Proceeds: 500, CostBasis: 200, Gain: 300, ShortTermGain: 200, LongTermGain: 100},
+		},
+		{
+			name:     "jp total average over the year",
+			request:  TaxRequest{Transactions: jpTransactions, Jurisdiction: "jp", Year: 2024},
+			currency: "JPY",
+			terms:    []string{""},
+			gains:    []float64{60000 - 18750},
+			summary:  TaxSummary{Disposals: 1, Proceeds: 60000, CostBasis: 18750, Gain: 41250},
+		},
+		{
+			name:     "jp carries the average into the next year",
+			request:  TaxRequest{Transactions: jpTransactions, Jurisdiction: "jp", Year: 2025},
+			currency: "JPY",
+			terms:    []string{""},
+			gains:    []float64{15000 - 18750},
+			summary:  TaxSummary{Disposals: 1, Proceeds: 15000, CostBasis: 18750, Gain: -3750},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := service.TaxReport(tt.request)
+			if err != nil {
+				t.Fatalf("TaxReport: %v", err)
+			}
+			if report.Currency != tt.currency {
+				t.Errorf("currency: got %s, want %s", report.Currency, tt.currency)
+			}
+			if len(report.Rows) != len(tt.gains) {
+				t.Fatalf("expected %d rows, got %+v", len(tt.gains), report.Rows)
+			}
+			for i, row := range report.Rows {
+				if row.Term != tt.terms[i] || !closeTo(row.Gain, tt.gains[i]) {
+					t.Errorf("row %d: got %s %f, want %s %f", i, row.Term, row.Gain, tt.terms[i], tt.gains[i])
+				}
+			}
+
+			got := report.Summary
+			if got.Disposals != tt.summary.Disposals || !closeTo(got.Proceeds, tt.summary.Proceeds) || !closeTo(got.CostBasis, tt.summary.CostBasis) ||
+				!closeTo(got.Gain, tt.summary.Gain) || !closeTo(got.ShortTermGain, tt.summary.ShortTermGain) || !closeTo(got.LongTermGain, tt.summary.LongTermGain) {
+				t.Errorf("summary: got %+v, want %+v", got, tt.summary)
+			}
+		})
+	}
+
+	invalid := []TaxRequest{
+		{Transactions: usTransactions, Jurisdiction: "fr"},
+		{Transactions: usTransactions, Jurisdiction: "jp", Method: "fifo"},
+		{Transactions: usTransactions, Jurisdiction: "us", Method: "total_average"},
+		{Transactions: usTransactions, Jurisdiction: "us", Timezone: "Mars/Olympus"},
+	}
+	for _, request := range invalid {
+		if _, err := service.TaxReport(request); !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("expected %+v to be rejected, got %v", request, err)
+		}
+	}
+}
+
+func TestTaxReportHoldingPeriodInTaxpayerZone(t *testing.T) {
+	service := NewService(fakePrices{}, fakeRates{})
+	losAngeles, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skipf("no tz database: %v", err)
+	}
+	at := func(value string) int64 {
+		parsed, err := time.ParseInLocation("2006-01-02 15:04", value, losAngeles)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed.UnixMilli()
+	}
+	transactions := []Transaction{
+		{ID: "b", AssetSymbol: "BTC", Amount: 2, PricePerUnitFiat: 100, FiatCurrency: "USD", Type: "BUY", Timestamp: at("2023-01-10 09:00")},
+		// The anniversary date, later in the day than the purchase: one year
+		// has not passed yet.
+		{ID: "s1", AssetSymbol: "BTC", Amount: -1, PricePerUnitFiat: 300, FiatCurrency: "USD", Type: "SELL", Timestamp: at("2024-01-10 15:00")},
+		// The day after the anniversary.
+		{ID: "s2", AssetSymbol: "BTC", Amount: -1, PricePerUnitFiat: 300, FiatCurrency: "USD", Type: "SELL", Timestamp: at("2024-01-11 00:30")},
+	}
+
+	report, err := service.TaxReport(TaxRequest{Transactions: transactions, Jurisdiction: "us", Timezone: "America/Los_Angeles"})
+	if err != nil {
+		t.Fatalf("TaxReport: %v", err)
+	}
+	if report.Timezone != "America/Los_Angeles" || len(report.Rows) != 2 || report.Rows[0].Term != TermShort || report.Rows[1].Term != TermLong {
+		t.Errorf("got timezone %s, rows %+v", report.Timezone, report.Rows)
+	}
+}
+
+func TestTaxReportWriteCSVInRequestedZone(t *testing.T) {
+	service := NewService(fakePrices{}, fakeRates{})
+	losAngeles, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skipf("no tz database: %v", err)
+	}
+	at := func(value string) int64 {
+		parsed, err := time.ParseInLocation("2006-01-02 15:04", value, losAngeles)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed.UnixMilli()
+	}
+	transactions := []Transaction{
+		{ID: "b", AssetSymbol: "BTC", Amount: 1, PricePerUnitFiat: 100, FiatCurrency: "USD", Type: "BUY", Timestamp: at("2024-06-01 09:00")},
+		// Already 2025 in UTC.
+		{ID: "s", AssetSymbol: "BTC", Amount: -1, PricePerUnitFiat: 300, FiatCurrency: "USD", Type: "SELL", Timestamp: at("2024-12-31 20:00")},
+	}
+
+	report, err := service.TaxReport(TaxRequest{Transactions: transactions, Jurisdiction: "us", Year: 2024, Timezone: "America/Los_Angeles"})
+	if err != nil {
+		t.Fatalf("TaxReport: %v", err)
+	}
+	var body bytes.Buffer
+	if err := report.WriteCSV(&body); err != nil {
+		t.Fatalf("WriteCSV: %v", err)
+	}
+	want := "Part,(a) Description of property,(b) Date acquired,(c) Date sold or disposed of,(d) Proceeds,(e) Cost or other basis,(f) Code,(g) Amount of adjustment,(h) Gain or (loss)\n" +
+		"I,1 BTC,06/01/2024,12/31/2024,300.00,100.00,,,200.00\n"
+	if got := strings.ReplaceAll(body.String(), "\r\n", "\n"); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestTaxReportFeeDisposalsAtFairValue(t *testing.T) {
+	service := NewService(fakePrices{}, fakeRates{"JPY": 150})
+	fee, ethereum := 0.5, "ETH"
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+	transactions := []Transaction{
+		{ID: "e", AssetSymbol: "ETH", Amount: 1, PricePerUnitFiat: 10, FiatCurrency: "USD", Type: "BUY", Timestamp: start},
+		{ID: "e2", AssetSymbol: "ETH", Amount: 1, PricePerUnitFiat: 30, FiatCurrency: "USD", Type: "BUY", Timestamp: start + dayMillis},
+		{ID: "b", AssetSymbol: "BTC", Amount: 1, PricePerUnitFiat: 100, FiatCurrency: "USD", FeeAmount: &fee, FeeCurrency: &ethereum, Type: "BUY", Timestamp: start + 2*dayMillis},
+	}
+
+	for _, tt := range []struct {
+		jurisdiction string
+		proceeds     float64
+		cost         float64
+	}{
+		{jurisdiction: "us", proceeds: 15, cost: 5},
+		{jurisdiction: "jp", proceeds: 15 * 150, cost: 0.5 * 20 * 150},
+	} {
+		report, err := service.TaxReport(TaxRequest{Transactions: transactions, Jurisdiction: tt.jurisdiction})
+		if err != nil {
+			t.Fatalf("%s: TaxReport: %v", tt.jurisdiction, err)
+		}
+		if len(report.Rows) != 1 {
+			t.Fatalf("%s: expected one fee row, got %+v", tt.jurisdiction, report.Rows)
+		}
+		row := report.Rows[0]
+		if !row.Fee || row.Symbol != "ETH" || !closeTo(row.Proceeds, tt.proceeds) || !closeTo(row.CostBasis, tt.cost) {
+			t.Errorf("%s: fee row = %+v, want proceeds %v and cost %v", tt.jurisdiction, row, tt.proceeds, tt.cost)
+		}
+	}
+}
+
+func TestTaxReportWriteCSV(t *testing.T) {
+	day := func(date string) int64 {
+		parsed, _ := time.Parse("2006-01-02", date)
+		return parsed.UnixMilli()
+	}
+
+	tests := []struct {
+		name   string
+		report TaxReport
+		want   string
+	}{
+		{
+			name: "form 8949",
+			report: TaxReport{Jurisdiction: JurisdictionUS, Rows: []TaxRow{
+				{Symbol: "ETH", Amount: 2, AcquiredAt: day("2020-01-01"), DisposedAt: day("2024-03-01"), Proceeds: 6000, CostBasis: 300, Gain: 5700, Term: TermLong},
+				{Symbol: "BTC", Amount: 0.5, AcquiredAt: day("2024-01-05"), DisposedAt: day("2024-03-01"), Proceeds: 20000.004, CostBasis: 21000, Gain: -999.996, Term: TermShort},
+			}},
+			want: "Part,(a) Description of property,(b) Date acquired,(c) Date sold or disposed of,(d) Proceeds,(e) Cost or other basis,(f) Code,(g) Amount of adjustment,(h) Gain or (loss)\n" +
+				"I,0.5 BTC,01/05/2024,03/01/2024,20000.00,21000.00,,,-1000.00\n" +
+				"II,2 ETH,01/01/2020,03/01/2024,6000.00,300.00,,,5700.00\n",
+		},
+		{
+			name: "japan in JST",
+			report: TaxReport{Jurisdiction: JurisdictionJP, Rows: []TaxRow{
+				{Symbol: "BTC", Amount: 1, DisposedAt: day("2024-12-31") + 16*60*60*1000, Proceeds: 60000.4, CostBasis: 18750, Gain: 41250.4, UnitCost: 18750},
+			}},
+			want: "Date,Asset,Amount,Proceeds (JPY),Average unit cost (JPY),Cost (JPY),Gain (JPY)\n" +
+				"2025/01/01,BTC,1,60000,18750,18750,41250\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body bytes.Buffer
+			if err := tt.report.WriteCSV(&body); err != nil {
+				t.Fatalf("WriteCSV: %v", err)
+			}
+			if got := strings.ReplaceAll(body.String(), "\r\n", "\n"); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
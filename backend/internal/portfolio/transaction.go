@@ -0,0 +1,131 @@
+package portfolio
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Transaction types accepted by the mobile schema.
+const (
+	TransactionBuy  = "BUY"
+	TransactionSell = "SELL"
+)
+
+// Transaction sources accepted by the mobile schema.
+const (
+	SourceManual   = "MANUAL"
+	SourceWallet   = "WALLET"
+	SourceExchange = "EXCHANGE"
+)
+
+// Transaction mirrors the mobile Transaction shape. Amount is signed (+in,
+// -out) and prices are in FiatCurrency; timestamps are unix milliseconds.
+type Transaction struct {
+	ID               string   `json:"id"`
+	PortfolioID      string   `json:"portfolio_id,omitempty"`
+	AssetSymbol      string   `json:"asset_symbol"`
+	Amount           float64  `json:"amount"`
+	PricePerUnitFiat float64  `json:"price_per_unit_fiat"`
+	TotalFiat        float64  `json:"total_fiat"`
+	FiatCurrency     string   `json:"fiat_currency"`
+	FeeAmount        *float64 `json:"fee_amount"`
+	FeeCurrency      *string  `json:"fee_currency"`
+	Notes            *string  `json:"notes"`
+	Type             string   `json:"type"`
+	Source           string   `json:"source"`
+	ExternalID       *string  `json:"external_id"`
+	Timestamp        int64    `json:"timestamp"`
+	CreatedAt        int64    `json:"created_at,omitempty"`
+	UpdatedAt        int64    `json:"updated_at,omitempty"`
+}
+
+// MaxTransactions bounds the transactions of a single request.
+const MaxTransactions = 20000
+
+// MaxAssets bounds the distinct assets of a single request.
+const MaxAssets = 200
+
+// normalizeTransactions validates transactions and returns copies with
+// upper-case symbols and currencies, ordered by timestamp.
+func normalizeTransactions(transactions []Transaction) ([]Transaction, error) {
+	if len(transactions) == 0 {
+		return nil, fmt.Errorf("%w: transactions cannot be empty", ErrInvalidRequest)
+	}
+	if len(transactions) > MaxTransactions {
+		return nil, fmt.Errorf("%w: at most %d transactions are allowed", ErrInvalidRequest, MaxTransactions)
+	}
+
+	normalized := make([]Transaction, 0, len(transactions))
+	symbols := make(map[string]struct{})
+	for index, tx := range transactions {
+		label := tx.ID
+		if label == "" {
+			label = fmt.Sprintf("#%d", index)
+		}
+
+		tx.AssetSymbol = strings.ToUpper(strings.TrimSpace(tx.AssetSymbol))
+		tx.FiatCurrency = strings.ToUpper(strings.TrimSpace(tx.FiatCurrency))
+		tx.Type = strings.ToUpper(strings.TrimSpace(tx.Type))
+		if tx.FiatCurrency == "" {
+			tx.FiatCurrency = "USD"
+		}
+		if tx.FeeCurrency != nil {
+			currency := strings.ToUpper(strings.TrimSpace(*tx.FeeCurrency))
+			tx.FeeCurrency = &currency
+		}
+
+		switch {
+		case tx.AssetSymbol == "":
+			return nil, fmt.Errorf("%w: transaction %s has no asset_symbol", ErrInvalidRequest, label)
+		case tx.Type != TransactionBuy && tx.Type != TransactionSell:
+			return nil, fmt.Errorf("%w: transaction %s has unsupported type %q", ErrInvalidRequest, label, tx.Type)
+		case tx.Timestamp <= 0:
+			return nil, fmt.Errorf("%w: transaction %s has no timestamp", ErrInvalidRequest, label)
+		case !isFinite(tx.Amount) || tx.Amount == 0:
+			return nil, fmt.Errorf("%w: transaction %s has an invalid amount", ErrInvalidRequest, label)
+		case (tx.Type == TransactionBuy) != (tx.Amount > 0):
+			return nil, fmt.Errorf("%w: transaction %s is a %s with a %s amount", ErrInvalidRequest, label, tx.Type, amountSign(tx.Amount))
+		case !isFinite(tx.PricePerUnitFiat) || tx.PricePerUnitFiat < 0:
+			return nil, fmt.Errorf("%w: transaction %s has an invalid price", ErrInvalidRequest, label)
+		case tx.FeeAmount != nil && (!isFinite(*tx.FeeAmount) || *tx.FeeAmount < 0):
+			return nil, fmt.Errorf("%w: transaction %s has an invalid fee", ErrInvalidRequest, label)
+		}
+
+		symbols[tx.AssetSymbol] = struct{}{}
+		normalized = append(normalized, tx)
+	}
+	if len(symbols) > MaxAssets {
+		return nil, fmt.Errorf("%w: at most %d assets are allowed", ErrInvalidRequest, MaxAssets)
+	}
+
+	sort.SliceStable(normalized, func(i, j int) bool {
+		return normalized[i].Timestamp < normalized[j].Timestamp
+	})
+	return normalized, nil
+}
+
+// quantity is the unsigned amount moved by the transaction.
+func (tx Transaction) quantity() float64 {
+	return math.Abs(tx.Amount)
+}
+
+// fiatFee is the fee paid in the transaction's fiat currency, if any.
+func (tx Transaction) fiatFee() float64 {
+	if tx.FeeAmount == nil || tx.FeeCurrency == nil || *tx.FeeCurrency != tx.FiatCurrency {
+		return 0
+	}
+	return *tx.FeeAmount
+}
+
+func amountSign(amount float64) string {
+	if amount < 0 {
+		return "negative"
+	}
+	return "positive"
+}
+
+func isFinite(value float64) bool {
+	return !math.IsNaN(value) && !math.IsInf(value, 0)
+}
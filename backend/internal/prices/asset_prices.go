@@ -0,0 +1,87 @@
+package prices
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AssetPrice is the latest USD price for an asset requested by unified,
+// CMC or CoinGecko id.
+type AssetPrice struct {
+	AssetID       string
+	CMCID         string
+	CoinGeckoID   string
+	Symbol        string
+	USD           float64
+	LastUpdatedAt int64
+	// Source is the provider whose latest prices were used.
+	Source         string
+	MigratedFrom   string
+	MigrationRatio float64
+	UpdatedAt      time.Time
+}
+
+// GetAssetPrices returns the latest price per requested id, keyed by the id
+// as given. CMC prices are preferred and CoinGecko prices fill the gaps. Ids
+// without a price in either cache are left out.
+func (s *Service) GetAssetPrices(ids []string) (map[string]AssetPrice, error) {
+	cmcTop, cmcErr := s.getCMCTopPrices()
+	cgTop, cgErr := s.getTopPrices()
+	if cmcErr != nil && cgErr != nil {
+		return nil, fmt.Errorf("failed to load latest prices: %w", cmcErr)
+	}
+
+	assets := s.syncedAssets()
+	now := time.Now()
+	result := make(map[string]AssetPrice, len(ids))
+
+	for _, requested := range ids {
+		id := strings.TrimSpace(requested)
+		if id == "" {
+			continue
+		}
+
+		price := AssetPrice{}
+		if asset, found := assets.Lookup(id); found {
+			price.AssetID = asset.ID
+			price.CMCID = asset.CMCID
+			price.CoinGeckoID = asset.CoinGeckoID
+			price.Symbol = asset.Symbol
+		} else if isNumericID(id) {
+			price.CMCID = id
+		} else if !IsAssetID(id) {
+			price.CoinGeckoID = strings.ToLower(id)
+		}
+
+		if cmcTop != nil && price.CMCID != "" && pickAssetPrice(&price, s.registry, ProviderCMC, price.CMCID, cmcTop, now) {
+			result[requested] = price
+			continue
+		}
+		if cgTop != nil && price.CoinGeckoID != "" && pickAssetPrice(&price, s.registry, ProviderCoinGecko, price.CoinGeckoID, cgTop, now) {
+			result[requested] = price
+		}
+	}
+
+	return result, nil
+}
+
+// pickAssetPrice fills price from top if the id, or its successor after a
+// rename or migration, is listed there.
+func pickAssetPrice(price *AssetPrice, registry *CoinRegistry, provider, id string, top *LatestPricesResponse, now time.Time) bool {
+	resolved, redirect := registry.Resolve(provider, id, now)
+	point, found := top.Prices[resolved]
+	if !found {
+		return false
+	}
+
+	price.USD = point.USD
+	price.LastUpdatedAt = point.LastUpdatedAt
+	price.Source = provider
+	price.UpdatedAt = top.UpdatedAt
+	if redirect != nil {
+		price.MigratedFrom = redirect.From
+		price.MigrationRatio = redirect.Ratio
+	}
+	return true
+}
@@ -0,0 +1,555 @@
+package prices
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// assetIDPrefix marks unified asset ids so they can't be confused with
+// CoinGecko slugs or numeric CMC ids.
+const assetIDPrefix = "ast_"
+
+// Asset is a coin with a stable internal id and its id at every provider.
+type Asset struct {
+	ID          string `json:"id"`
+	Symbol      string `json:"symbol"`
+	Name        string `json:"name"`
+	CoinGeckoID string `json:"coingecko_id,omitempty"`
+	CMCID       string `json:"cmc_id,omitempty"`
+	// MergedInto is set on an asset retired by a mapping correction; its id
+	// resolves to the asset it was merged into.
+	MergedInto string    `json:"merged_into,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// AssetsResponse is returned to the mobile app for /assets.
+type AssetsResponse struct {
+	Assets    []Asset   `json:"assets"`
+	Timestamp int64     `json:"timestamp"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ExpiresAt returns when clients should revalidate the registry.
+func (r *AssetsResponse) ExpiresAt() time.Time {
+	return r.UpdatedAt.Add(cmcMapTTL)
+}
+
+type assetRegistryFile struct {
+	NextSeq   int       `json:"next_seq"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Assets    []Asset   `json:"assets"`
+}
+
+// IsAssetID reports whether id is a unified asset id.
+func IsAssetID(id string) bool {
+	seq, found := strings.CutPrefix(strings.ToLower(strings.TrimSpace(id)), assetIDPrefix)
+	if !found || seq == "" {
+		return false
+	}
+	_, err := strconv.Atoi(seq)
+	return err == nil
+}
+
+// AssetRegistry assigns stable internal ids to coins. Assets are created
+// from the CMC mapping and CoinGecko metadata and are never renumbered or
+// removed, so ids stored on devices keep resolving; provider ids follow
+// mapping corrections.
+type AssetRegistry struct {
+	mu          sync.RWMutex
+	path        string
+	assets      []Asset
+	byID        map[string]int
+	byCoinGecko map[string]int
+	byCMC       map[string]int
+	nextSeq     int
+	updatedAt   time.Time
+	// synced is the fingerprint of the sources of the last Sync.
+	synced string
+	loaded bool
+}
+
+// NewAssetRegistry creates a registry backed by the given file path.
+func NewAssetRegistry(path string) *AssetRegistry {
+	return &AssetRegistry{
+		path: path,
+	}
+}
+
+// Get returns the asset for a unified id.
+func (r *AssetRegistry) Get(id string) (Asset, bool) {
+	return r.find(func() (int, bool) {
+		position, found := r.byID[strings.ToLower(strings.TrimSpace(id))]
+		return position, found
+	})
+}
+
+// ByCoinGecko returns the asset for a CoinGecko id.
+func (r *AssetRegistry) ByCoinGecko(id string) (Asset, bool) {
+	return r.find(func() (int, bool) {
+		position, found := r.byCoinGecko[strings.ToLower(strings.TrimSpace(id))]
+		return position, found
+	})
+}
+
+// ByCMC returns the asset for a CMC id.
+func (r *AssetRegistry) ByCMC(id string) (Asset, bool) {
+	return r.find(func() (int, bool) {
+		position, found := r.byCMC[strings.TrimSpace(id)]
+		return position, found
+	})
+}
+
+// Lookup returns the asset for a unified id, numeric CMC id or CoinGecko id.
+func (r *AssetRegistry) Lookup(id string) (Asset, bool) {
+	switch {
+	case IsAssetID(id):
+		return r.Get(id)
+	case isNumericID(strings.TrimSpace(id)):
+		return r.ByCMC(id)
+	default:
+		return r.ByCoinGecko(id)
+	}
+}
+
+// All returns every asset ordered by id sequence.
+func (r *AssetRegistry) All() ([]Asset, time.Time, error) {
+	if r == nil {
+		return nil, time.Time{}, fmt.Errorf("asset registry not configured")
+	}
+	if err := r.ensureLoaded(); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	assets := make([]Asset, len(r.assets))
+	copy(assets, r.assets)
+	return assets, r.updatedAt, nil
+}
+
+// UpdatedAt returns when the registry last changed.
+func (r *AssetRegistry) UpdatedAt() time.Time {
+	if r == nil {
+		return time.Time{}
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.updatedAt
+}
+
+// Synced reports whether the registry was last synced from sources with
+// the given fingerprint.
+func (r *AssetRegistry) Synced(fingerprint string) bool {
+	if r == nil {
+		return true
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.loaded && r.synced == fingerprint
+}
+
+// Sync creates assets for mapping entries and CoinGecko coins that have none
+// and updates provider ids of existing ones. A CMC id keeps its asset when
+// its CoinGecko mapping changes, unless the new CoinGecko id already has a
+// CoinGecko-only asset: the CMC id then moves to that asset and its old
+// asset is retired into it. It returns the number of new assets.
+func (r *AssetRegistry) Sync(entries []CMCMappingEntry, coins []CoinMeta, fingerprint string) (int, error) {
+	if r == nil {
+		return 0, fmt.Errorf("asset registry not configured")
+	}
+	if err := r.ensureLoaded(); err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	assets := make([]Asset, len(r.assets))
+	copy(assets, r.assets)
+	nextSeq := r.nextSeq
+	now := time.Now()
+	added, changed := 0, false
+
+	byCMC := make(map[string]int, len(assets))
+	byCoinGecko := make(map[string]int, len(assets))
+	for i, asset := range assets {
+		if asset.CMCID != "" {
+			byCMC[asset.CMCID] = i
+		}
+		if asset.CoinGeckoID != "" {
+			byCoinGecko[asset.CoinGeckoID] = i
+		}
+	}
+
+	create := func(asset Asset) int {
+		nextSeq++
+		asset.ID = assetIDPrefix + strconv.Itoa(nextSeq)
+		asset.CreatedAt = now
+		assets = append(assets, asset)
+		added++
+		changed = true
+		return len(assets) - 1
+	}
+
+	for _, entry := range entries {
+		if entry.CMCID == "" {
+			continue
+		}
+		coinGeckoID := strings.ToLower(entry.CoinGeckoID)
+
+		position, found := byCMC[entry.CMCID]
+		if !found && coinGeckoID != "" {
+			// Adopt a CoinGecko-only asset once the coin gets a CMC mapping.
+			if existing, ok := byCoinGecko[coinGeckoID]; ok && assets[existing].CMCID == "" {
+				position, found = existing, true
+			}
+		}
+		if !found {
+			position = create(Asset{Symbol: strings.ToUpper(entry.Symbol), Name: entry.Name})
+		}
+
+		asset := &assets[position]
+		if asset.CMCID != entry.CMCID {
+			asset.CMCID = entry.CMCID
+			byCMC[entry.CMCID] = position
+			changed = true
+		}
+		if coinGeckoID == "" || asset.CoinGeckoID == coinGeckoID {
+			continue
+		}
+		if owner, taken := byCoinGecko[coinGeckoID]; taken && owner != position {
+			if assets[owner].CMCID != "" {
+				// Several CMC ids (bridged or duplicate listings) can map to
+				// one CoinGecko id; the first asset keeps it.
+				continue
+			}
+			assets[owner].CMCID = entry.CMCID
+			byCMC[entry.CMCID] = owner
+			if asset.CoinGeckoID != "" && byCoinGecko[asset.CoinGeckoID] == position {
+				delete(byCoinGecko, asset.CoinGeckoID)
+			}
+			asset.CMCID, asset.CoinGeckoID, asset.MergedInto = "", "", assets[owner].ID
+			changed = true
+			continue
+		}
+		if asset.CoinGeckoID != "" && byCoinGecko[asset.CoinGeckoID] == position {
+			delete(byCoinGecko, asset.CoinGeckoID)
+		}
+		asset.CoinGeckoID = coinGeckoID
+		byCoinGecko[coinGeckoID] = position
+		changed = true
+	}
+
+	for _, coin := range coins {
+		coinGeckoID := strings.ToLower(coin.ID)
+		if coinGeckoID == "" {
+			continue
+		}
+		if _, found := byCoinGecko[coinGeckoID]; found {
+			continue
+		}
+		byCoinGecko[coinGeckoID] = create(Asset{
+			Symbol:      strings.ToUpper(coin.Symbol),
+			Name:        coin.Name,
+			CoinGeckoID: coinGeckoID,
+		})
+	}
+
+	if changed {
+		if err := r.persistLocked(assets, nextSeq, now); err != nil {
+			return 0, err
+		}
+		r.setLocked(assets, nextSeq, now)
+	}
+	r.synced = fingerprint
+
+	return added, nil
+}
+
+func (r *AssetRegistry) find(lookup func() (int, bool)) (Asset, bool) {
+	if r == nil {
+		return Asset{}, false
+	}
+	if err := r.ensureLoaded(); err != nil {
+		log.Printf("Failed to load asset registry: %v", err)
+		return Asset{}, false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	position, found := lookup()
+	if !found {
+		return Asset{}, false
+	}
+	// Follow retired assets to the asset they were merged into.
+	for hops := 0; r.assets[position].MergedInto != "" && hops < len(r.assets); hops++ {
+		next, ok := r.byID[r.assets[position].MergedInto]
+		if !ok {
+			break
+		}
+		position = next
+	}
+	return r.assets[position], true
+}
+
+func (r *AssetRegistry) setLocked(assets []Asset, nextSeq int, updatedAt time.Time) {
+	r.assets = assets
+	r.nextSeq = nextSeq
+	r.updatedAt = updatedAt
+	r.byID = make(map[string]int, len(assets))
+	r.byCoinGecko = make(map[string]int, len(assets))
+	r.byCMC = make(map[string]int, len(assets))
+	for i, asset := range assets {
+		r.byID[asset.ID] = i
+		if asset.CoinGeckoID != "" {
+			r.byCoinGecko[asset.CoinGeckoID] = i
+		}
+		if asset.CMCID != "" {
+			r.byCMC[asset.CMCID] = i
+		}
+	}
+}
+
+func (r *AssetRegistry) persistLocked(assets []Asset, nextSeq int, updatedAt time.Time) error {
+	// Without a path the registry lives in memory only.
+	if r.path == "" {
+		return nil
+	}
+	bytes, err := json.Marshal(assetRegistryFile{
+		NextSeq:   nextSeq,
+		UpdatedAt: updatedAt,
+		Assets:    assets,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal asset registry: %w", err)
+	}
+
+	dir := filepath.Dir(r.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create asset registry directory: %w", err)
+	}
+
+	tmpFile, err := os.CreateTemp(dir, "assets_*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create temp asset registry file: %w", err)
+	}
+
+	if _, err := tmpFile.Write(bytes); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to write asset registry file: %w", err)
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("failed to close asset registry file: %w", err)
+	}
+
+	if err := os.Rename(tmpFile.Name(), r.path); err != nil {
+		return fmt.Errorf("failed to move asset registry file: %w", err)
+	}
+
+	return nil
+}
+
+func (r *AssetRegistry) ensureLoaded() error {
+	r.mu.RLock()
+	loaded := r.loaded
+	r.mu.RUnlock()
+	if loaded {
+		return nil
+	}
+
+	var payload assetRegistryFile
+	bytes, err := os.ReadFile(r.path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read asset registry file: %w", err)
+	}
+	if len(bytes) > 0 {
+		if err := json.Unmarshal(bytes, &payload); err != nil {
+			return fmt.Errorf("failed to unmarshal asset registry file: %w", err)
+		}
+	}
+
+	r.mu.Lock()
+	if !r.loaded {
+		r.setLocked(payload.Assets, payload.NextSeq, payload.UpdatedAt)
+		r.loaded = true
+	}
+	r.mu.Unlock()
+
+	return nil
+}
+
+// GetAssets returns the assets for unified, CMC or CoinGecko ids, or the
+// whole registry when ids is empty.
+func (s *Service) GetAssets(ids []string) (*AssetsResponse, error) {
+	registry := s.syncedAssets()
+	all, updatedAt, err := registry.All()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &AssetsResponse{
+		Assets:    all,
+		Timestamp: time.Now().UnixMilli(),
+		UpdatedAt: updatedAt,
+	}
+	if len(ids) == 0 {
+		return response, nil
+	}
+
+	response.Assets = make([]Asset, 0, len(ids))
+	seen := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		asset, found := registry.Lookup(id)
+		if !found {
+			continue
+		}
+		if _, dup := seen[asset.ID]; dup {
+			continue
+		}
+		seen[asset.ID] = struct{}{}
+		response.Assets = append(response.Assets, asset)
+	}
+	sort.Slice(response.Assets, func(i, j int) bool {
+		return assetSeq(response.Assets[i].ID) < assetSeq(response.Assets[j].ID)
+	})
+	return response, nil
+}
+
+// syncedAssets returns the asset registry after syncing it with the current
+// CMC mapping and CoinGecko metadata if either changed. Sync failures are
+// logged and the previous registry is served.
+func (s *Service) syncedAssets() *AssetRegistry {
+	var index *CMCMapIndex
+	var coins []CoinMeta
+	var mappingUpdatedAt, metaUpdatedAt time.Time
+
+	if mapping, found, err := s.cmcMapStore.Index(); err != nil {
+		log.Printf("Failed to load CMC mapping for assets: %v", err)
+	} else if found {
+		index = mapping
+		mappingUpdatedAt = mapping.UpdatedAt()
+	}
+	if meta, found, err := s.metaStore.Latest(); err != nil {
+		log.Printf("Failed to load coin metadata for assets: %v", err)
+	} else if found {
+		coins = meta.Coins
+		metaUpdatedAt = meta.UpdatedAt
+	}
+
+	sources := len(coins)
+	if index != nil {
+		sources += index.Len()
+	}
+	fingerprint := fmt.Sprintf("%d:%d:%d", mappingUpdatedAt.UnixNano(), metaUpdatedAt.UnixNano(), sources)
+	if sources == 0 || s.assets.Synced(fingerprint) {
+		return s.assets
+	}
+
+	_, err, _ := s.group.Do("asset_sync", func() (interface{}, error) {
+		var entries []CMCMappingEntry
+		if index != nil {
+			entries = index.Entries()
+		}
+		added, err := s.assets.Sync(entries, coins, fingerprint)
+		if err == nil && added > 0 {
+			log.Printf("Asset registry: %d new assets", added)
+		}
+		return nil, err
+	})
+	if err != nil {
+		log.Printf("Failed to sync asset registry: %v", err)
+	}
+	return s.assets
+}
+
+// providerID translates a unified asset id to the asset's id at provider.
+// Other ids are returned unchanged.
+func (s *Service) providerID(provider, id string) string {
+	if !IsAssetID(id) {
+		return id
+	}
+	asset, found := s.syncedAssets().Get(id)
+	if !found {
+		return id
+	}
+	switch provider {
+	case ProviderCMC:
+		if asset.CMCID != "" {
+			return asset.CMCID
+		}
+	case ProviderCoinGecko:
+		if asset.CoinGeckoID != "" {
+			return asset.CoinGeckoID
+		}
+	}
+	return id
+}
+
+// coinID translates a unified asset id to its CoinGecko id, or its CMC id
+// when the asset is not listed on CoinGecko.
+func (s *Service) coinID(id string) string {
+	if translated := s.providerID(ProviderCoinGecko, id); translated != id {
+		return translated
+	}
+	return s.providerID(ProviderCMC, id)
+}
+
+// AssetID returns the unified id for a unified, CoinGecko or numeric CMC id,
+// or "" when the registry has no asset for it.
+func (s *Service) AssetID(id string) string {
+	asset, found := s.syncedAssets().Lookup(id)
+	if !found {
+		return ""
+	}
+	return asset.ID
+}
+
+// annotateAssetIDs sets the unified id on prices keyed by provider id.
+func (s *Service) annotateAssetIDs(provider string, prices map[string]PricePoint) {
+	registry := s.syncedAssets()
+	for id, price := range prices {
+		var asset Asset
+		var found bool
+		if provider == ProviderCMC {
+			asset, found = registry.ByCMC(id)
+		} else {
+			asset, found = registry.ByCoinGecko(id)
+		}
+		if found {
+			price.AssetID = asset.ID
+			prices[id] = price
+		}
+	}
+}
+
+func assetSeq(id string) int {
+	seq, _ := strconv.Atoi(strings.TrimPrefix(id, assetIDPrefix))
+	return seq
+}
+
+// annotateMappingAssetIDs sets the unified id on mapping entries.
+func (s *Service) annotateMappingAssetIDs(entries []CMCMappingEntry) []CMCMappingEntry {
+	assets := s.syncedAssets()
+	for i, entry := range entries {
+		entries[i].AssetID = assetIDOf(assets.ByCMC(entry.CMCID))
+	}
+	return entries
+}
+
+func assetIDOf(asset Asset, found bool) string {
+	if !found {
+		return ""
+	}
+	return asset.ID
+}
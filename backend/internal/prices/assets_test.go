@@ -0,0 +1,106 @@
+package prices
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestAssetRegistrySync(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "assets.json")
+	registry := NewAssetRegistry(path)
+
+	coins := []CoinMeta{
+		{ID: "bitcoin", Symbol: "btc", Name: "Bitcoin"},
+		{ID: "new-coin", Symbol: "new", Name: "New Coin"},
+	}
+	entries := []CMCMappingEntry{
+		{CMCID: "1", Symbol: "BTC", Name: "Bitcoin", CoinGeckoID: "bitcoin"},
+		{CMCID: "3794", Symbol: "ATOM", Name: "Cosmos", CoinGeckoID: "atom-clone"},
+		{CMCID: "9999", Symbol: "BTC", Name: "Bridged Bitcoin", CoinGeckoID: "bitcoin"},
+	}
+	added, err := registry.Sync(entries, coins, "first")
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if added != 4 {
+		t.Fatalf("expected 4 new assets, got %d", added)
+	}
+
+	bitcoin, found := registry.ByCoinGecko("bitcoin")
+	if !found || bitcoin.CMCID != "1" || bitcoin.ID != "ast_1" {
+		t.Fatalf("unexpected bitcoin asset: %+v", bitcoin)
+	}
+	if bridged, _ := registry.ByCMC("9999"); bridged.CoinGeckoID != "" {
+		t.Errorf("expected the bridged listing not to take bitcoin's CoinGecko id: %+v", bridged)
+	}
+	cosmos, _ := registry.ByCMC("3794")
+	newCoin, _ := registry.ByCoinGecko("new-coin")
+
+	// A mapping correction keeps the asset; a CoinGecko-only asset is adopted
+	// once its CMC listing appears.
+	entries[1].CoinGeckoID = "cosmos"
+	entries = append(entries, CMCMappingEntry{CMCID: "5000", Symbol: "NEW", CoinGeckoID: "new-coin"})
+	if added, err := NewAssetRegistry(path).Sync(entries, coins, "second"); err != nil || added != 0 {
+		t.Fatalf("resync: added=%d err=%v", added, err)
+	}
+
+	reloaded := NewAssetRegistry(path)
+	for _, tt := range []struct {
+		lookup string
+		want   string
+	}{
+		{lookup: "cosmos", want: cosmos.ID},
+		{lookup: "3794", want: cosmos.ID},
+		{lookup: "5000", want: newCoin.ID},
+		{lookup: "AST_1", want: bitcoin.ID},
+		{lookup: "atom-clone", want: ""},
+	} {
+		asset, _ := reloaded.Lookup(tt.lookup)
+		if asset.ID != tt.want {
+			t.Errorf("Lookup(%s) = %q, want %q", tt.lookup, asset.ID, tt.want)
+		}
+	}
+
+	if !IsAssetID("ast_12") || IsAssetID("ast_") || IsAssetID("bitcoin") || IsAssetID("1027") {
+		t.Error("unexpected IsAssetID result")
+	}
+}
+
+func TestAssetRegistrySyncMergesCorrectionIntoCoinGeckoAsset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "assets.json")
+	coins := []CoinMeta{{ID: "cosmos", Symbol: "atom", Name: "Cosmos Hub"}}
+	entries := []CMCMappingEntry{{CMCID: "3794", Symbol: "ATOM", Name: "Cosmos", CoinGeckoID: "atom-clone"}}
+	if _, err := NewAssetRegistry(path).Sync(entries, coins, "first"); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	registry := NewAssetRegistry(path)
+	clone, _ := registry.ByCMC("3794")
+	cosmos, _ := registry.ByCoinGecko("cosmos")
+	if clone.ID == cosmos.ID || cosmos.CMCID != "" {
+		t.Fatalf("expected separate assets: clone %+v, cosmos %+v", clone, cosmos)
+	}
+
+	// CMC 3794 is corrected to the coin that already has a CoinGecko-only
+	// asset: the CMC id joins it and the clone's asset is retired into it.
+	entries[0].CoinGeckoID = "cosmos"
+	if _, err := registry.Sync(entries, coins, "second"); err != nil {
+		t.Fatalf("resync: %v", err)
+	}
+
+	reloaded := NewAssetRegistry(path)
+	for _, lookup := range []string{"3794", "cosmos", clone.ID} {
+		asset, found := reloaded.Lookup(lookup)
+		if !found || asset.ID != cosmos.ID || asset.CMCID != "3794" || asset.CoinGeckoID != "cosmos" {
+			t.Errorf("Lookup(%s) = %+v, want %s with both provider ids", lookup, asset, cosmos.ID)
+		}
+	}
+	if _, found := reloaded.ByCoinGecko("atom-clone"); found {
+		t.Error("expected atom-clone to be released by the retired asset")
+	}
+	all, _, _ := reloaded.All()
+	for _, asset := range all {
+		if asset.ID == clone.ID && (asset.MergedInto != cosmos.ID || asset.CMCID != "") {
+			t.Errorf("retired asset = %+v", asset)
+		}
+	}
+}
@@ -58,6 +58,7 @@ func (c *Cache) SetLatestPrices(key string, prices *LatestPricesResponse) {
 	defer c.mu.Unlock()
 
 	prices.UpdatedAt = time.Now()
+	prices.encoded = newEncodedPayloads()
 	c.latestPrices[key] = prices
 }
 
@@ -87,6 +88,7 @@ func (c *Cache) SetHistory(key string, history *HistoryResponse) {
 	defer c.mu.Unlock()
 
 	history.UpdatedAt = time.Now()
+	history.encoded = newEncodedPayloads()
 	c.historyPrices[key] = history
 }
 
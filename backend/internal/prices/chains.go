@@ -0,0 +1,143 @@
+package prices
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// chainAliases maps provider-specific platform names to a canonical chain id.
+// CoinGecko uses asset platform ids, CoinMarketCap uses platform slugs.
+var chainAliases = map[string]string{
+	"ethereum":            "ethereum",
+	"eth":                 "ethereum",
+	"binance-smart-chain": "bsc",
+	"bnb":                 "bsc",
+	"bsc":                 "bsc",
+	"bnb-smart-chain":     "bsc",
+	"binance-coin":        "bsc",
+	"polygon-pos":         "polygon",
+	"polygon":             "polygon",
+	"matic-network":       "polygon",
+	"arbitrum-one":        "arbitrum",
+	"arbitrum":            "arbitrum",
+	"optimistic-ethereum": "optimism",
+	"optimism":            "optimism",
+	"optimism-ethereum":   "optimism",
+	"base":                "base",
+	"avalanche":           "avalanche",
+	"avalanche-c-chain":   "avalanche",
+	"solana":              "solana",
+	"tron":                "tron",
+	"tron10":              "tron",
+	"tron20":              "tron",
+	"fantom":              "fantom",
+	"the-open-network":    "ton",
+	"toncoin":             "ton",
+	"ton":                 "ton",
+	"sui":                 "sui",
+	"aptos":               "aptos",
+	"near-protocol":       "near",
+	"near":                "near",
+	"cronos":              "cronos",
+	"linea":               "linea",
+	"zksync":              "zksync",
+	"zksync-era":          "zksync",
+	"stellar":             "stellar",
+	"cardano":             "cardano",
+	"xrp":                 "xrp",
+	"ripple":              "xrp",
+}
+
+// evmChains lists canonical chains whose contract addresses are 0x-prefixed
+// hex and compare case-insensitively.
+var evmChains = map[string]struct{}{
+	"ethereum":  {},
+	"bsc":       {},
+	"polygon":   {},
+	"arbitrum":  {},
+	"optimism":  {},
+	"base":      {},
+	"avalanche": {},
+	"fantom":    {},
+	"cronos":    {},
+	"linea":     {},
+	"zksync":    {},
+}
+
+// canonicalChain returns the canonical chain id for a provider platform name.
+// Unknown platforms are returned lowercased so they still compare equal
+// across providers that happen to agree.
+func canonicalChain(name string) string {
+	key := strings.ToLower(strings.TrimSpace(name))
+	key = strings.ReplaceAll(key, " ", "-")
+	if canonical, found := chainAliases[key]; found {
+		return canonical
+	}
+	return key
+}
+
+func isEVMChain(chain string) bool {
+	_, found := evmChains[canonicalChain(chain)]
+	return found
+}
+
+// normalizeContractAddress trims an address and lowercases EVM addresses so
+// they can be compared and indexed. Non-EVM addresses are case-sensitive.
+func normalizeContractAddress(chain, address string) string {
+	address = strings.TrimSpace(address)
+	if isEVMChain(chain) {
+		return strings.ToLower(address)
+	}
+	return address
+}
+
+// contractKey is the canonical chain:address key for a token contract.
+func contractKey(chain, address string) string {
+	canonical := canonicalChain(chain)
+	return canonical + ":" + normalizeContractAddress(canonical, address)
+}
+
+// checksumEVMAddress validates a 0x-prefixed EVM address and returns its
+// EIP-55 checksummed form. All-lowercase and all-uppercase addresses are
+// accepted as-is; mixed-case input must already carry a valid checksum.
+func checksumEVMAddress(address string) (string, error) {
+	address = strings.TrimSpace(address)
+	if len(address) != 42 || !strings.HasPrefix(strings.ToLower(address), "0x") {
+		return "", fmt.Errorf("invalid EVM address %q", address)
+	}
+
+	body := address[2:]
+	if _, err := hex.DecodeString(body); err != nil {
+		return "", fmt.Errorf("invalid EVM address %q", address)
+	}
+
+	lower := strings.ToLower(body)
+	hash := sha3.NewLegacyKeccak256()
+	hash.Write([]byte(lower))
+	digest := hash.Sum(nil)
+
+	checksummed := []byte(lower)
+	for i, c := range checksummed {
+		if c < 'a' || c > 'f' {
+			continue
+		}
+		// Uppercase when the matching nibble of the hash is >= 8.
+		nibble := digest[i/2]
+		if i%2 == 0 {
+			nibble >>= 4
+		}
+		if nibble&0x0f >= 8 {
+			checksummed[i] = c - 'a' + 'A'
+		}
+	}
+
+	result := "0x" + string(checksummed)
+	if body != lower && body != strings.ToUpper(body) && address[2:] != result[2:] {
+		return "", fmt.Errorf("invalid EIP-55 checksum for address %q", address)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,53 @@
+package prices
+
+import "testing"
+
+func TestChecksumEVMAddress(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"0x1f9840a85d5af5bf1d1762f925bdaddc4201f984", "0x1f9840a85d5aF5bf1D1762F925BDADdC4201F984", false},
+		{"0x1F9840A85D5AF5BF1D1762F925BDADDC4201F984", "0x1f9840a85d5aF5bf1D1762F925BDADdC4201F984", false},
+		{"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", false},
+		{"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeD", "", true},
+		{"0x1234", "", true},
+		{"0xzz9840a85d5af5bf1d1762f925bdaddc4201f984", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := checksumEVMAddress(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("checksumEVMAddress(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("checksumEVMAddress(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestContractIndexLookup(t *testing.T) {
+	index := NewContractIndex()
+	index.Update(ProviderCoinGecko, contractCoinsFromList([]CoinGeckoListCoin{
+		{ID: "uniswap", Symbol: "uni", Name: "Uniswap", Platforms: map[string]string{
+			"ethereum":            "0x1f9840a85d5af5bf1d1762f925bdaddc4201f984",
+			"binance-smart-chain": "0xBf5140A22578168FD562DCcF235E5D43A02ce9B1",
+		}},
+		{ID: "bitcoin", Symbol: "btc", Name: "Bitcoin", Platforms: map[string]string{"": ""}},
+	}))
+
+	if index.Len(ProviderCoinGecko) != 2 {
+		t.Fatalf("expected 2 indexed contracts, got %d", index.Len(ProviderCoinGecko))
+	}
+	if refs := index.Lookup(ProviderCoinGecko, "bsc", "0xbf5140a22578168fd562dccf235e5d43a02ce9b1"); len(refs) != 1 || refs[0].id != "uniswap" {
+		t.Errorf("expected bsc lookup to find uniswap, got %+v", refs)
+	}
+	if refs := index.Lookup(ProviderCoinGecko, "eth", "0x1f9840a85d5aF5bf1D1762F925BDADdC4201F984"); len(refs) != 1 {
+		t.Errorf("expected checksummed ethereum lookup to match, got %+v", refs)
+	}
+	if refs := index.Lookup(ProviderCoinGecko, "polygon", "0x1f9840a85d5af5bf1d1762f925bdaddc4201f984"); len(refs) != 0 {
+		t.Errorf("expected no match on another chain, got %+v", refs)
+	}
+}
@@ -1,58 +1,260 @@
 package prices
 
-import "strings"
+import (
+	"math"
+	"sort"
+	"strings"
+)
 
-func buildCMCMapEntries(cmcCoins []CoinMeta, cgMarkets []CoinGeckoMarketCoin) []CMCMappingEntry {
-	bySymbol := make(map[string][]CoinGeckoMarketCoin, len(cgMarkets))
-	for _, coin := range cgMarkets {
-		symbol := strings.ToUpper(strings.TrimSpace(coin.Symbol))
-		if symbol == "" {
-			continue
-		}
-		bySymbol[symbol] = append(bySymbol[symbol], coin)
-	}
+// minMappingConfidence is the score below which a CMC coin is left unmapped
+// rather than risk pointing it at a different token sharing the ticker.
+const minMappingConfidence = 0.6
+
+// Match reasons recorded on CMCMappingEntry.
+const (
+	matchReasonContract      = "contract"
+	matchReasonNameExact     = "name_exact"
+	matchReasonNameRank      = "name_and_rank"
+	matchReasonSoleCandidate = "sole_candidate"
+	matchReasonLowConfidence = "low_confidence"
+	matchReasonNoCandidates  = "no_candidates"
+)
+
+// mappingCandidate is a CoinGecko coin sharing a CMC coin's symbol, merged
+// from /coins/markets (rank) and /coins/list (platform contracts).
+type mappingCandidate struct {
+	id        string
+	name      string
+	rank      int
+	contracts map[string]struct{}
+}
+
+type scoredCandidate struct {
+	candidate  mappingCandidate
+	confidence float64
+	reason     string
+	// ranked is set when both coins have a market-cap rank.
+	ranked bool
+}
+
+func buildCMCMapEntries(cmcCoins []CoinMeta, cgMarkets []CoinGeckoMarketCoin, cgList []CoinGeckoListCoin) []CMCMappingEntry {
+	bySymbol := buildMappingCandidates(cgMarkets, cgList)
 
 	entries := make([]CMCMappingEntry, 0, len(cmcCoins))
-	for _, cmcCoin := range cmcCoins {
+	for position, cmcCoin := range cmcCoins {
 		symbol := strings.ToUpper(strings.TrimSpace(cmcCoin.Symbol))
 		if symbol == "" {
 			continue
 		}
 
+		cmcRank := cmcCoin.MarketCapRank
+		if cmcRank <= 0 {
+			cmcRank = position + 1
+		}
+
+		entry := CMCMappingEntry{
+			CMCID:  cmcCoin.ID,
+			Symbol: symbol,
+			Name:   cmcCoin.Name,
+		}
+
 		candidates := bySymbol[symbol]
 		if len(candidates) == 0 {
+			entry.MatchReason = matchReasonNoCandidates
+			entries = append(entries, entry)
 			continue
 		}
 
-		best := pickBestCandidate(cmcCoin.Name, candidates)
-		if best.ID == "" {
+		scored := scoreMappingCandidates(cmcCoin, cmcRank, candidates)
+		best := scored[0]
+
+		for _, alternative := range scored[1:] {
+			entry.Candidates = append(entry.Candidates, alternative.candidate.id)
+		}
+
+		entry.Confidence = roundConfidence(best.confidence)
+		if best.confidence < minMappingConfidence {
+			entry.MatchReason = matchReasonLowConfidence
+			entry.Candidates = append([]string{best.candidate.id}, entry.Candidates...)
+			entries = append(entries, entry)
 			continue
 		}
 
-		entries = append(entries, CMCMappingEntry{
-			CMCID:       cmcCoin.ID,
-			Symbol:      symbol,
-			Name:        cmcCoin.Name,
-			CoinGeckoID: best.ID,
-		})
+		entry.CoinGeckoID = best.candidate.id
+		entry.MatchReason = best.reason
+		entries = append(entries, entry)
 	}
 
 	return entries
 }
 
-func pickBestCandidate(cmcName string, candidates []CoinGeckoMarketCoin) CoinGeckoMarketCoin {
-	if len(candidates) == 1 {
-		return candidates[0]
+func buildMappingCandidates(cgMarkets []CoinGeckoMarketCoin, cgList []CoinGeckoListCoin) map[string][]mappingCandidate {
+	byID := make(map[string]*mappingCandidate, len(cgMarkets)+len(cgList))
+	symbols := make(map[string]string, len(cgMarkets)+len(cgList))
+	order := make([]string, 0, len(cgMarkets)+len(cgList))
+
+	upsert := func(id, symbol, name string) *mappingCandidate {
+		if candidate, found := byID[id]; found {
+			return candidate
+		}
+		candidate := &mappingCandidate{id: id, name: name, contracts: make(map[string]struct{})}
+		byID[id] = candidate
+		symbols[id] = strings.ToUpper(strings.TrimSpace(symbol))
+		order = append(order, id)
+		return candidate
+	}
+
+	for _, coin := range cgMarkets {
+		if coin.ID == "" || strings.TrimSpace(coin.Symbol) == "" {
+			continue
+		}
+		candidate := upsert(coin.ID, coin.Symbol, coin.Name)
+		candidate.rank = coin.MarketCapRank
+	}
+
+	for _, coin := range cgList {
+		if coin.ID == "" || strings.TrimSpace(coin.Symbol) == "" {
+			continue
+		}
+		candidate := upsert(coin.ID, coin.Symbol, coin.Name)
+		for chain, address := range coin.Platforms {
+			if chain == "" || strings.TrimSpace(address) == "" {
+				continue
+			}
+			candidate.contracts[contractKey(chain, address)] = struct{}{}
+		}
+	}
+
+	bySymbol := make(map[string][]mappingCandidate)
+	for _, id := range order {
+		symbol := symbols[id]
+		if symbol == "" {
+			continue
+		}
+		bySymbol[symbol] = append(bySymbol[symbol], *byID[id])
+	}
+
+	return bySymbol
+}
+
+// scoreMappingCandidates scores every candidate for a CMC coin and returns
+// them best first. A shared contract address is conclusive; otherwise name
+// similarity is blended with market-cap rank proximity.
+func scoreMappingCandidates(cmcCoin CoinMeta, cmcRank int, candidates []mappingCandidate) []scoredCandidate {
+	cmcContracts := make([]string, 0, len(cmcCoin.Contracts))
+	for _, contract := range cmcCoin.Contracts {
+		if contract.Address != "" {
+			cmcContracts = append(cmcContracts, contractKey(contract.Chain, contract.Address))
+		}
 	}
 
-	target := normalizeName(cmcName)
+	scored := make([]scoredCandidate, 0, len(candidates))
+	closest := 0.0
 	for _, candidate := range candidates {
-		if normalizeName(candidate.Name) == target {
-			return candidate
+		score := scoreMappingCandidate(cmcCoin.Name, cmcRank, cmcContracts, candidate, len(candidates) == 1)
+		if score.ranked && score.reason != matchReasonContract {
+			closest = math.Max(closest, score.confidence)
+		}
+		scored = append(scored, score)
+	}
+
+	// An unranked candidate, often a clone reusing the name, never outscores
+	// a ranked one on name and rank alone.
+	for i := range scored {
+		if !scored[i].ranked && scored[i].reason != matchReasonContract && scored[i].confidence >= closest && closest > 0 {
+			scored[i].confidence = math.Max(0, closest-0.01)
+		}
+	}
+
+	sort.SliceStable(scored, func(i, j int) bool {
+		if scored[i].confidence != scored[j].confidence {
+			return scored[i].confidence > scored[j].confidence
+		}
+		return rankLess(scored[i].candidate.rank, scored[j].candidate.rank)
+	})
+
+	return scored
+}
+
+func scoreMappingCandidate(cmcName string, cmcRank int, cmcContracts []string, candidate mappingCandidate, sole bool) scoredCandidate {
+	for _, key := range cmcContracts {
+		if _, found := candidate.contracts[key]; found {
+			return scoredCandidate{candidate: candidate, confidence: 1, reason: matchReasonContract}
+		}
+	}
+
+	nameScore := nameSimilarity(cmcName, candidate.name)
+	rankScore, ranked := rankProximity(cmcRank, candidate.rank)
+	if !ranked {
+		// A missing rank is neutral evidence.
+		rankScore = 0.5
+	}
+
+	confidence := 0.6*nameScore + 0.4*rankScore
+	reason := matchReasonNameRank
+	if nameScore == 1 && ranked && rankScore >= 0.5 {
+		confidence = 0.95
+		reason = matchReasonNameExact
+	}
+
+	if sole && confidence < minMappingConfidence && nameScore >= 0.5 {
+		// The only coin trading under the ticker with a plausible name.
+		confidence = minMappingConfidence
+		reason = matchReasonSoleCandidate
+	}
+
+	return scoredCandidate{candidate: candidate, confidence: confidence, reason: reason, ranked: ranked}
+}
+
+// nameSimilarity returns the Dice coefficient of character bigrams of the
+// normalized names, or 1 for an exact normalized match.
+func nameSimilarity(a, b string) float64 {
+	a = normalizeName(a)
+	b = normalizeName(b)
+	if a == "" || b == "" {
+		return 0
+	}
+	if a == b {
+		return 1
+	}
+	if len(a) < 2 || len(b) < 2 {
+		return 0
+	}
+
+	bigrams := make(map[string]int, len(a))
+	for i := 0; i < len(a)-1; i++ {
+		bigrams[a[i:i+2]]++
+	}
+
+	matches := 0
+	for i := 0; i < len(b)-1; i++ {
+		bigram := b[i : i+2]
+		if bigrams[bigram] > 0 {
+			bigrams[bigram]--
+			matches++
 		}
 	}
 
-	return candidates[0]
+	return 2 * float64(matches) / float64(len(a)-1+len(b)-1)
+}
+
+// rankProximity scores how close two market-cap ranks are on a log scale:
+// 1 for equal ranks, 0 once they are 50x apart.
+func rankProximity(a, b int) (float64, bool) {
+	if a <= 0 || b <= 0 {
+		return 0, false
+	}
+
+	distance := math.Abs(math.Log(float64(a)) - math.Log(float64(b)))
+	score := 1 - distance/math.Log(50)
+	if score < 0 {
+		score = 0
+	}
+	return score, true
+}
+
+func roundConfidence(value float64) float64 {
+	return math.Round(value*1000) / 1000
 }
 
 func normalizeName(name string) string {
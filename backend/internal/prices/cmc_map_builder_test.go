@@ -0,0 +1,67 @@
+package prices
+
+import "testing"
+
+func TestBuildCMCMapEntries(t *testing.T) {
+	cmcCoins := []CoinMeta{
+		{ID: "1", Symbol: "BTC", Name: "Bitcoin", MarketCapRank: 1},
+		{ID: "7083", Symbol: "UNI", Name: "Uniswap", MarketCapRank: 25,
+			Contracts: []ContractAddress{{Chain: "ethereum", Address: "0x1F9840a85d5aF5bf1D1762F925BDADdC4201F984"}}},
+		{ID: "3794", Symbol: "ATOM", Name: "Cosmos", MarketCapRank: 30},
+		{ID: "6636", Symbol: "DOT", Name: "Polkadot", MarketCapRank: 12},
+		{ID: "9999", Symbol: "XYZ", Name: "Xylophone Network", MarketCapRank: 90},
+		{ID: "8888", Symbol: "NONE", Name: "Nothing", MarketCapRank: 95},
+	}
+	cgMarkets := []CoinGeckoMarketCoin{
+		{ID: "bitcoin", Symbol: "btc", Name: "Bitcoin", MarketCapRank: 1},
+		{ID: "uniswap", Symbol: "uni", Name: "Uniswap", MarketCapRank: 24},
+		{ID: "cosmos", Symbol: "atom", Name: "Cosmos Hub", MarketCapRank: 31},
+		{ID: "atom-clone", Symbol: "atom", Name: "Atomic Clone", MarketCapRank: 4000},
+		{ID: "xyz-token", Symbol: "xyz", Name: "Universe XYZ", MarketCapRank: 3000},
+		{ID: "polkadot", Symbol: "dot", Name: "Polkadot Relay Chain", MarketCapRank: 13},
+	}
+	cgList := []CoinGeckoListCoin{
+		{ID: "uni-fork", Symbol: "uni", Name: "Uniswap", Platforms: map[string]string{"binance-smart-chain": "0xabc"}},
+		{ID: "uniswap", Symbol: "uni", Name: "Uniswap", Platforms: map[string]string{"ethereum": "0x1f9840a85d5af5bf1d1762f925bdaddc4201f984"}},
+		// Unranked clones reusing the exact names.
+		{ID: "cosmos-clone", Symbol: "atom", Name: "Cosmos"},
+		{ID: "dot-clone", Symbol: "dot", Name: "Polkadot"},
+	}
+
+	entries := buildCMCMapEntries(cmcCoins, cgMarkets, cgList)
+	byID := make(map[string]CMCMappingEntry, len(entries))
+	for _, entry := range entries {
+		byID[entry.CMCID] = entry
+	}
+
+	tests := []struct {
+		cmcID       string
+		coinGeckoID string
+		reason      string
+	}{
+		{"1", "bitcoin", matchReasonNameExact},
+		{"7083", "uniswap", matchReasonContract},
+		{"3794", "cosmos", matchReasonNameRank},
+		{"6636", "polkadot", matchReasonNameRank},
+		{"9999", "", matchReasonLowConfidence},
+		{"8888", "", matchReasonNoCandidates},
+	}
+
+	for _, tt := range tests {
+		entry, found := byID[tt.cmcID]
+		if !found {
+			t.Fatalf("missing entry for cmc_id %s", tt.cmcID)
+		}
+		if entry.CoinGeckoID != tt.coinGeckoID || entry.MatchReason != tt.reason {
+			t.Errorf("cmc_id %s: got %q (%s, %.3f), want %q (%s)",
+				tt.cmcID, entry.CoinGeckoID, entry.MatchReason, entry.Confidence, tt.coinGeckoID, tt.reason)
+		}
+	}
+
+	if byID["7083"].Confidence != 1 {
+		t.Errorf("expected contract match to have confidence 1, got %.3f", byID["7083"].Confidence)
+	}
+	if candidates := byID["9999"].Candidates; len(candidates) != 1 || candidates[0] != "xyz-token" {
+		t.Errorf("expected low-confidence entry to keep xyz-token as a candidate, got %v", candidates)
+	}
+}
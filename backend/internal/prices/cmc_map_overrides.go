@@ -0,0 +1,480 @@
+package prices
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Override actions and the match reasons they leave on mapping entries.
+const (
+	OverrideActionPin    = "pin"
+	OverrideActionBlock  = "block"
+	OverrideActionRemove = "remove"
+
+	matchReasonManualPin   = "manual_pin"
+	matchReasonManualBlock = "manual_block"
+)
+
+const maxMappingChanges = 1000
+
+// MappingOverride pins a CMC id to a CoinGecko id or blocks a mapping. A
+// block without a CoinGecko id blocks any automatic mapping for the CMC id.
+type MappingOverride struct {
+	CMCID       string    `json:"cmc_id"`
+	Action      string    `json:"action"`
+	CoinGeckoID string    `json:"coingecko_id,omitempty"`
+	Symbol      string    `json:"symbol,omitempty"`
+	Name        string    `json:"name,omitempty"`
+	Author      string    `json:"author"`
+	Reason      string    `json:"reason"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// MappingChange is an audit log record of a mapping change.
+type MappingChange struct {
+	CMCID               string    `json:"cmc_id"`
+	Action              string    `json:"action"`
+	PreviousCoinGeckoID string    `json:"previous_coingecko_id,omitempty"`
+	CoinGeckoID         string    `json:"coingecko_id,omitempty"`
+	Author              string    `json:"author"`
+	Reason              string    `json:"reason"`
+	At                  time.Time `json:"at"`
+}
+
+// MappingOverridesResponse is returned by the admin overrides endpoint.
+type MappingOverridesResponse struct {
+	Overrides []MappingOverride `json:"overrides"`
+	Changes   []MappingChange   `json:"changes"`
+	Timestamp int64             `json:"timestamp"`
+}
+
+type mappingOverridesFile struct {
+	Overrides []MappingOverride `json:"overrides"`
+	Changes   []MappingChange   `json:"changes"`
+}
+
+// MappingOverrideStore manages manual mapping overrides and their change log
+// on disk. Overrides live apart from the generated mapping file so rebuilds
+// never discard them.
+type MappingOverrideStore struct {
+	mu        sync.RWMutex
+	path      string
+	overrides map[string]MappingOverride
+	changes   []MappingChange
+	updatedAt time.Time
+	loaded    bool
+}
+
+// NewMappingOverrideStore creates a new override store using the given file path.
+func NewMappingOverrideStore(path string) *MappingOverrideStore {
+	return &MappingOverrideStore{
+		path: path,
+	}
+}
+
+// List returns all overrides sorted by CMC id and the newest changes first.
+func (m *MappingOverrideStore) List() ([]MappingOverride, []MappingChange, error) {
+	if m == nil {
+		return nil, nil, fmt.Errorf("mapping override store not configured")
+	}
+	if err := m.ensureLoaded(); err != nil {
+		return nil, nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	overrides := make([]MappingOverride, 0, len(m.overrides))
+	for _, override := range m.overrides {
+		overrides = append(overrides, override)
+	}
+	sort.Slice(overrides, func(i, j int) bool {
+		return overrides[i].CMCID < overrides[j].CMCID
+	})
+
+	changes := make([]MappingChange, len(m.changes))
+	for i, change := range m.changes {
+		changes[len(m.changes)-1-i] = change
+	}
+
+	return overrides, changes, nil
+}
+
+// UpdatedAt returns when an override was last set or removed.
+func (m *MappingOverrideStore) UpdatedAt() time.Time {
+	if m == nil {
+		return time.Time{}
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.updatedAt
+}
+
+// Put stores or replaces the override for its CMC id and records change.
+func (m *MappingOverrideStore) Put(override MappingOverride, change MappingChange) error {
+	if m == nil {
+		return fmt.Errorf("mapping override store not configured")
+	}
+	if err := m.ensureLoaded(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	overrides := m.copyOverridesLocked()
+	overrides[override.CMCID] = override
+	if err := m.persistLocked(overrides, change); err != nil {
+		return err
+	}
+	m.updatedAt = change.At
+	return nil
+}
+
+// Delete removes the override for cmcID and records change.
+func (m *MappingOverrideStore) Delete(cmcID string, change MappingChange) (bool, error) {
+	if m == nil {
+		return false, fmt.Errorf("mapping override store not configured")
+	}
+	if err := m.ensureLoaded(); err != nil {
+		return false, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, found := m.overrides[cmcID]; !found {
+		return false, nil
+	}
+
+	overrides := m.copyOverridesLocked()
+	delete(overrides, cmcID)
+	if err := m.persistLocked(overrides, change); err != nil {
+		return false, err
+	}
+	m.updatedAt = change.At
+	return true, nil
+}
+
+// Record appends changes to the log without touching overrides.
+func (m *MappingOverrideStore) Record(changes ...MappingChange) error {
+	if m == nil {
+		return fmt.Errorf("mapping override store not configured")
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	if err := m.ensureLoaded(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.persistLocked(m.copyOverridesLocked(), changes...)
+}
+
+// Apply returns entries with overrides applied on top of the automatic
+// mapping. The input slice is not modified.
+func (m *MappingOverrideStore) Apply(entries []CMCMappingEntry) ([]CMCMappingEntry, error) {
+	if m == nil {
+		return entries, nil
+	}
+	if err := m.ensureLoaded(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return applyMappingOverrides(entries, m.overrides), nil
+}
+
+func applyMappingOverrides(entries []CMCMappingEntry, overrides map[string]MappingOverride) []CMCMappingEntry {
+	if len(overrides) == 0 {
+		return entries
+	}
+
+	applied := make([]CMCMappingEntry, 0, len(entries)+len(overrides))
+	seen := make(map[string]struct{}, len(entries))
+	for _, entry := range entries {
+		seen[entry.CMCID] = struct{}{}
+		if override, found := overrides[entry.CMCID]; found {
+			entry = applyMappingOverride(entry, override)
+		}
+		applied = append(applied, entry)
+	}
+
+	// Pins may add coins the automatic builder never saw.
+	pinned := make([]string, 0)
+	for cmcID, override := range overrides {
+		if _, found := seen[cmcID]; !found && override.Action == OverrideActionPin {
+			pinned = append(pinned, cmcID)
+		}
+	}
+	sort.Strings(pinned)
+	for _, cmcID := range pinned {
+		override := overrides[cmcID]
+		applied = append(applied, applyMappingOverride(CMCMappingEntry{
+			CMCID:  cmcID,
+			Symbol: strings.ToUpper(override.Symbol),
+			Name:   override.Name,
+		}, override))
+	}
+
+	return applied
+}
+
+func applyMappingOverride(entry CMCMappingEntry, override MappingOverride) CMCMappingEntry {
+	switch override.Action {
+	case OverrideActionPin:
+		entry.CoinGeckoID = override.CoinGeckoID
+		entry.Confidence = 1
+		entry.MatchReason = matchReasonManualPin
+	case OverrideActionBlock:
+		if override.CoinGeckoID == "" || override.CoinGeckoID == entry.CoinGeckoID {
+			entry.CoinGeckoID = ""
+			entry.Confidence = 0
+			entry.MatchReason = matchReasonManualBlock
+		}
+	}
+	return entry
+}
+
+func isOverrideAction(action string) bool {
+	return action == OverrideActionPin || action == OverrideActionBlock || action == OverrideActionRemove
+}
+
+func (m *MappingOverrideStore) copyOverridesLocked() map[string]MappingOverride {
+	overrides := make(map[string]MappingOverride, len(m.overrides)+1)
+	for key, override := range m.overrides {
+		overrides[key] = override
+	}
+	return overrides
+}
+
+func (m *MappingOverrideStore) persistLocked(overrides map[string]MappingOverride, changes ...MappingChange) error {
+	history := append(append([]MappingChange(nil), m.changes...), changes...)
+	if len(history) > maxMappingChanges {
+		history = history[len(history)-maxMappingChanges:]
+	}
+
+	payload := mappingOverridesFile{
+		Overrides: make([]MappingOverride, 0, len(overrides)),
+		Changes:   history,
+	}
+	for _, override := range overrides {
+		payload.Overrides = append(payload.Overrides, override)
+	}
+	sort.Slice(payload.Overrides, func(i, j int) bool {
+		return payload.Overrides[i].CMCID < payload.Overrides[j].CMCID
+	})
+
+	bytes, err := json.MarshalIndent(payload, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal mapping overrides: %w", err)
+	}
+
+	dir := filepath.Dir(m.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create overrides directory: %w", err)
+	}
+
+	tmpFile, err := os.CreateTemp(dir, "cmc_mapping_overrides_*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create temp overrides file: %w", err)
+	}
+
+	if _, err := tmpFile.Write(bytes); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to write overrides file: %w", err)
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("failed to close overrides file: %w", err)
+	}
+
+	if err := os.Rename(tmpFile.Name(), m.path); err != nil {
+		return fmt.Errorf("failed to move overrides file: %w", err)
+	}
+
+	m.overrides = overrides
+	m.changes = history
+	return nil
+}
+
+func (m *MappingOverrideStore) ensureLoaded() error {
+	m.mu.RLock()
+	loaded := m.loaded
+	m.mu.RUnlock()
+	if loaded {
+		return nil
+	}
+
+	bytes, err := os.ReadFile(m.path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read overrides file: %w", err)
+	}
+
+	var payload mappingOverridesFile
+	if len(bytes) > 0 {
+		if err := json.Unmarshal(bytes, &payload); err != nil {
+			return fmt.Errorf("failed to unmarshal overrides file: %w", err)
+		}
+	}
+
+	overrides := make(map[string]MappingOverride, len(payload.Overrides))
+	for _, override := range payload.Overrides {
+		if override.CMCID != "" {
+			overrides[override.CMCID] = override
+		}
+	}
+
+	var updatedAt time.Time
+	for _, change := range payload.Changes {
+		if isOverrideAction(change.Action) && change.At.After(updatedAt) {
+			updatedAt = change.At
+		}
+	}
+
+	m.mu.Lock()
+	if !m.loaded {
+		m.overrides = overrides
+		m.changes = payload.Changes
+		m.updatedAt = updatedAt
+		m.loaded = true
+	}
+	m.mu.Unlock()
+
+	return nil
+}
+
+// ListMappingOverrides returns the manual overrides and the change log.
+func (s *Service) ListMappingOverrides() (*MappingOverridesResponse, error) {
+	overrides, changes, err := s.overrides.List()
+	if err != nil {
+		return nil, err
+	}
+
+	return &MappingOverridesResponse{
+		Overrides: overrides,
+		Changes:   changes,
+		Timestamp: time.Now().UnixMilli(),
+	}, nil
+}
+
+// SetMappingOverride pins or blocks the mapping of a CMC id and records who
+// changed it and why.
+func (s *Service) SetMappingOverride(override MappingOverride) (*MappingOverride, error) {
+	override.CMCID = strings.TrimSpace(override.CMCID)
+	override.Action = strings.ToLower(strings.TrimSpace(override.Action))
+	override.CoinGeckoID = strings.TrimSpace(override.CoinGeckoID)
+	override.Symbol = strings.ToUpper(strings.TrimSpace(override.Symbol))
+	override.Name = strings.TrimSpace(override.Name)
+	override.Author = strings.TrimSpace(override.Author)
+	override.Reason = strings.TrimSpace(override.Reason)
+
+	if !isNumericID(override.CMCID) {
+		return nil, fmt.Errorf("cmc_id must be numeric")
+	}
+	switch override.Action {
+	case OverrideActionPin:
+		if override.CoinGeckoID == "" {
+			return nil, fmt.Errorf("coingecko_id is required to pin a mapping")
+		}
+	case OverrideActionBlock:
+	default:
+		return nil, fmt.Errorf("action must be %q or %q", OverrideActionPin, OverrideActionBlock)
+	}
+	if override.Author == "" || override.Reason == "" {
+		return nil, fmt.Errorf("author and reason are required")
+	}
+
+	previous, err := s.effectiveCoinGeckoID(override.CMCID)
+	if err != nil {
+		return nil, err
+	}
+
+	override.UpdatedAt = time.Now().UTC()
+	change := MappingChange{
+		CMCID:               override.CMCID,
+		Action:              override.Action,
+		PreviousCoinGeckoID: previous,
+		CoinGeckoID:         override.CoinGeckoID,
+		Author:              override.Author,
+		Reason:              override.Reason,
+		At:                  override.UpdatedAt,
+	}
+
+	if err := s.overrides.Put(override, change); err != nil {
+		return nil, err
+	}
+	s.cmcMapStore.Invalidate()
+
+	log.Printf("Mapping override for cmc_id %s set by %s: %s %s (was %q)", override.CMCID, override.Author, override.Action, override.CoinGeckoID, previous)
+	return &override, nil
+}
+
+// RemoveMappingOverride drops the override for a CMC id so the automatic
+// mapping applies again. It reports false when no override existed.
+func (s *Service) RemoveMappingOverride(cmcID, author, reason string) (bool, error) {
+	cmcID = strings.TrimSpace(cmcID)
+	author = strings.TrimSpace(author)
+	reason = strings.TrimSpace(reason)
+
+	if !isNumericID(cmcID) {
+		return false, fmt.Errorf("cmc_id must be numeric")
+	}
+	if author == "" || reason == "" {
+		return false, fmt.Errorf("author and reason are required")
+	}
+
+	previous, err := s.effectiveCoinGeckoID(cmcID)
+	if err != nil {
+		return false, err
+	}
+
+	removed, err := s.overrides.Delete(cmcID, MappingChange{
+		CMCID:               cmcID,
+		Action:              OverrideActionRemove,
+		PreviousCoinGeckoID: previous,
+		Author:              author,
+		Reason:              reason,
+		At:                  time.Now().UTC(),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if removed {
+		s.cmcMapStore.Invalidate()
+		log.Printf("Mapping override for cmc_id %s removed by %s", cmcID, author)
+	}
+	return removed, nil
+}
+
+// effectiveCoinGeckoID returns the CoinGecko id a CMC id currently maps to,
+// with overrides applied, or "" when it is unmapped.
+func (s *Service) effectiveCoinGeckoID(cmcID string) (string, error) {
+	index, _, err := s.cmcMapStore.Index()
+	if err != nil {
+		return "", err
+	}
+
+	coinGeckoID, _ := index.CoinGeckoID(cmcID)
+	return coinGeckoID, nil
+}
+
+func isNumericID(id string) bool {
+	value, err := strconv.Atoi(id)
+	return err == nil && value > 0
+}
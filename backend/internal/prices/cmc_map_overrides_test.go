@@ -0,0 +1,78 @@
+package prices
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyMappingOverrides(t *testing.T) {
+	entries := []CMCMappingEntry{
+		{CMCID: "1", Symbol: "BTC", Name: "Bitcoin", CoinGeckoID: "bitcoin", Confidence: 0.95, MatchReason: matchReasonNameExact},
+		{CMCID: "3794", Symbol: "ATOM", Name: "Cosmos", CoinGeckoID: "atom-clone", Confidence: 0.7, MatchReason: matchReasonNameRank},
+		{CMCID: "9999", Symbol: "XYZ", Name: "Xylophone", MatchReason: matchReasonLowConfidence},
+	}
+	overrides := map[string]MappingOverride{
+		"3794": {CMCID: "3794", Action: OverrideActionPin, CoinGeckoID: "cosmos"},
+		"9999": {CMCID: "9999", Action: OverrideActionBlock},
+		"1":    {CMCID: "1", Action: OverrideActionBlock, CoinGeckoID: "wrapped-bitcoin"},
+		"5000": {CMCID: "5000", Action: OverrideActionPin, CoinGeckoID: "new-coin", Symbol: "new", Name: "New Coin"},
+	}
+
+	applied := applyMappingOverrides(entries, overrides)
+	byID := make(map[string]CMCMappingEntry, len(applied))
+	for _, entry := range applied {
+		byID[entry.CMCID] = entry
+	}
+
+	tests := []struct {
+		cmcID       string
+		coinGeckoID string
+		reason      string
+	}{
+		{"1", "bitcoin", matchReasonNameExact},
+		{"3794", "cosmos", matchReasonManualPin},
+		{"9999", "", matchReasonManualBlock},
+		{"5000", "new-coin", matchReasonManualPin},
+	}
+
+	for _, tt := range tests {
+		entry, found := byID[tt.cmcID]
+		if !found {
+			t.Fatalf("missing entry for cmc_id %s", tt.cmcID)
+		}
+		if entry.CoinGeckoID != tt.coinGeckoID || entry.MatchReason != tt.reason {
+			t.Errorf("cmc_id %s: got %q (%s), want %q (%s)", tt.cmcID, entry.CoinGeckoID, entry.MatchReason, tt.coinGeckoID, tt.reason)
+		}
+	}
+
+	if entries[1].CoinGeckoID != "atom-clone" {
+		t.Errorf("expected input entries to be left untouched, got %q", entries[1].CoinGeckoID)
+	}
+	if byID["5000"].Symbol != "NEW" {
+		t.Errorf("expected pinned entry symbol NEW, got %q", byID["5000"].Symbol)
+	}
+}
+
+func TestMappingOverrideStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "overrides.json")
+	store := NewMappingOverrideStore(path)
+
+	override := MappingOverride{CMCID: "3794", Action: OverrideActionPin, CoinGeckoID: "cosmos", Author: "ops", Reason: "wrong ticker match"}
+	if err := store.Put(override, MappingChange{CMCID: "3794", Action: OverrideActionPin, CoinGeckoID: "cosmos", Author: "ops", Reason: "wrong ticker match"}); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if _, err := store.Delete("3794", MappingChange{CMCID: "3794", Action: OverrideActionRemove, Author: "ops", Reason: "fixed upstream"}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	overrides, changes, err := NewMappingOverrideStore(path).List()
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(overrides) != 0 {
+		t.Errorf("expected no overrides after delete, got %d", len(overrides))
+	}
+	if len(changes) != 2 || changes[0].Action != OverrideActionRemove || changes[1].Action != OverrideActionPin {
+		t.Errorf("expected remove then pin in change log, got %+v", changes)
+	}
+}
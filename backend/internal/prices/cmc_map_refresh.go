@@ -0,0 +1,215 @@
+package prices
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// Change log actions recorded when a rebuild changes the automatic mapping.
+const (
+	MappingChangeAdded   = "added"
+	MappingChangeChanged = "changed"
+
+	mappingRebuildAuthor = "rebuild"
+)
+
+// CMCMappingEntryChange is a CMC id whose CoinGecko id changed in a rebuild.
+type CMCMappingEntryChange struct {
+	CMCID               string `json:"cmc_id"`
+	Symbol              string `json:"symbol"`
+	PreviousCoinGeckoID string `json:"previous_coingecko_id,omitempty"`
+	CoinGeckoID         string `json:"coingecko_id,omitempty"`
+}
+
+// CMCMappingDiff describes how a rebuilt mapping differs from the stored one.
+// Rebuilds are merged into the stored mapping, so no entry is ever removed.
+type CMCMappingDiff struct {
+	Added   []CMCMappingEntry       `json:"added,omitempty"`
+	Changed []CMCMappingEntryChange `json:"changed,omitempty"`
+}
+
+// Empty reports whether the rebuild left every entry's CoinGecko id as it was.
+func (d *CMCMappingDiff) Empty() bool {
+	return d == nil || len(d.Added) == 0 && len(d.Changed) == 0
+}
+
+// String summarises the diff for logs.
+func (d *CMCMappingDiff) String() string {
+	if d == nil {
+		return "no changes"
+	}
+	return fmt.Sprintf("%d added, %d changed", len(d.Added), len(d.Changed))
+}
+
+// RefreshCMCMapping rebuilds the CMC->CoinGecko mapping for cmcCoins and
+// merges it into the stored one, returning what changed. Coins missing from
+// cmcCoins, such as those that left the top list, keep their mapping. The
+// diff is nil when no mapping was stored before. Concurrent refreshes share
+// one rebuild and its diff.
+func (s *Service) RefreshCMCMapping(cmcCoins []CoinMeta) (*CMCMappingDiff, error) {
+	if s.cmcMapStore == nil {
+		return nil, fmt.Errorf("cmc map store not configured")
+	}
+
+	result, err, _ := s.group.Do("cmc_mapping_refresh", func() (interface{}, error) {
+		s.mappingMu.Lock()
+		defer s.mappingMu.Unlock()
+
+		previous, found, err := s.cmcMapStore.Get()
+		if err != nil {
+			return nil, err
+		}
+
+		entries, err := s.buildCMCMapping(cmcCoins)
+		if err != nil {
+			return nil, err
+		}
+		entries = mergeCMCMapEntries(previous, entries)
+
+		if err := s.cmcMapStore.Set(entries); err != nil {
+			return nil, fmt.Errorf("failed to store CMC mapping: %w", err)
+		}
+
+		if !found {
+			return (*CMCMappingDiff)(nil), nil
+		}
+
+		diff := diffCMCMapEntries(previous, entries)
+		if err := s.overrides.Record(diff.changes(time.Now().UTC())...); err != nil {
+			log.Printf("Failed to record CMC mapping changes: %v", err)
+		}
+		return diff, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*CMCMappingDiff), nil
+}
+
+// refreshCMCMappingAndPrewarm rebuilds the mapping after a CMC metadata
+// refresh and prewarms history only for coins that were not mapped before.
+func (s *Service) refreshCMCMappingAndPrewarm(cmcCoins []CoinMeta) {
+	before, _, err := s.mappingEntries()
+	if err != nil {
+		log.Printf("Failed to load CMC mapping before rebuild: %v", err)
+		return
+	}
+
+	diff, err := s.RefreshCMCMapping(cmcCoins)
+	if err != nil {
+		log.Printf("Failed to rebuild CMC mapping: %v", err)
+		return
+	}
+	if diff == nil {
+		// First build; the scheduled full prewarm covers every coin.
+		log.Printf("Built CMC mapping for %d coins", len(cmcCoins))
+		return
+	}
+
+	log.Printf("Rebuilt CMC mapping: %s", diff)
+	if diff.Empty() {
+		return
+	}
+
+	after, _, err := s.mappingEntries()
+	if err != nil {
+		log.Printf("Failed to load CMC mapping after rebuild: %v", err)
+		return
+	}
+
+	ids := newCoinGeckoIDs(before, after)
+	if len(ids) == 0 {
+		return
+	}
+
+	log.Printf("Prewarming history for %d newly mapped coins", len(ids))
+	if err := s.prewarmHistory(ids); err != nil {
+		log.Printf("Failed to prewarm history for newly mapped coins: %v", err)
+	}
+}
+
+// mergeCMCMapEntries returns rebuilt followed by the previous entries for
+// CMC ids that were not rebuilt.
+func mergeCMCMapEntries(previous, rebuilt []CMCMappingEntry) []CMCMappingEntry {
+	rebuiltIDs := make(map[string]struct{}, len(rebuilt))
+	for _, entry := range rebuilt {
+		rebuiltIDs[entry.CMCID] = struct{}{}
+	}
+
+	merged := append(make([]CMCMappingEntry, 0, len(rebuilt)+len(previous)), rebuilt...)
+	for _, entry := range previous {
+		if _, found := rebuiltIDs[entry.CMCID]; !found {
+			merged = append(merged, entry)
+		}
+	}
+	return merged
+}
+
+// diffCMCMapEntries compares next, a merge of previous, with previous.
+func diffCMCMapEntries(previous, next []CMCMappingEntry) *CMCMappingDiff {
+	previousByID := make(map[string]CMCMappingEntry, len(previous))
+	for _, entry := range previous {
+		previousByID[entry.CMCID] = entry
+	}
+
+	diff := &CMCMappingDiff{}
+	for _, entry := range next {
+		old, found := previousByID[entry.CMCID]
+		switch {
+		case !found:
+			diff.Added = append(diff.Added, entry)
+		case old.CoinGeckoID != entry.CoinGeckoID:
+			diff.Changed = append(diff.Changed, CMCMappingEntryChange{
+				CMCID:               entry.CMCID,
+				Symbol:              entry.Symbol,
+				PreviousCoinGeckoID: old.CoinGeckoID,
+				CoinGeckoID:         entry.CoinGeckoID,
+			})
+		}
+	}
+
+	return diff
+}
+
+// changes converts the diff into change log records. Entries added without
+// a CoinGecko id did not change any mapping and are left out.
+func (d *CMCMappingDiff) changes(at time.Time) []MappingChange {
+	if d.Empty() {
+		return nil
+	}
+
+	const reason = "automatic rebuild"
+	changes := make([]MappingChange, 0, len(d.Added)+len(d.Changed))
+	for _, entry := range d.Added {
+		if entry.CoinGeckoID == "" {
+			continue
+		}
+		changes = append(changes, MappingChange{CMCID: entry.CMCID, Action: MappingChangeAdded, CoinGeckoID: entry.CoinGeckoID, Author: mappingRebuildAuthor, Reason: reason, At: at})
+	}
+	for _, change := range d.Changed {
+		changes = append(changes, MappingChange{CMCID: change.CMCID, Action: MappingChangeChanged, PreviousCoinGeckoID: change.PreviousCoinGeckoID, CoinGeckoID: change.CoinGeckoID, Author: mappingRebuildAuthor, Reason: reason, At: at})
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].CMCID < changes[j].CMCID
+	})
+	return changes
+}
+
+// newCoinGeckoIDs returns the CoinGecko ids mapped in after but not before.
+func newCoinGeckoIDs(before, after []CMCMappingEntry) []string {
+	known := make(map[string]struct{}, len(before))
+	for _, id := range mappedCoinGeckoIDs(before) {
+		known[id] = struct{}{}
+	}
+
+	var ids []string
+	for _, id := range mappedCoinGeckoIDs(after) {
+		if _, found := known[id]; !found {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
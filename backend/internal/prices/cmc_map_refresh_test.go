@@ -0,0 +1,75 @@
+package prices
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffCMCMapEntries(t *testing.T) {
+	previous := []CMCMappingEntry{
+		{CMCID: "1", Symbol: "BTC", CoinGeckoID: "bitcoin"},
+		{CMCID: "3794", Symbol: "ATOM", CoinGeckoID: "atom-clone"},
+		{CMCID: "4000", Symbol: "OLD", CoinGeckoID: "old-coin"},
+	}
+	next := []CMCMappingEntry{
+		{CMCID: "1", Symbol: "BTC", CoinGeckoID: "bitcoin", Confidence: 0.95},
+		{CMCID: "3794", Symbol: "ATOM", CoinGeckoID: "cosmos"},
+		{CMCID: "5000", Symbol: "NEW", CoinGeckoID: "new-coin"},
+		{CMCID: "5001", Symbol: "UNM"},
+		{CMCID: "4000", Symbol: "OLD", CoinGeckoID: "old-coin"},
+	}
+
+	diff := diffCMCMapEntries(previous, next)
+	if got := diff.String(); got != "2 added, 1 changed" {
+		t.Fatalf("unexpected diff summary: %s", got)
+	}
+	if diff.Changed[0].PreviousCoinGeckoID != "atom-clone" || diff.Changed[0].CoinGeckoID != "cosmos" {
+		t.Errorf("unexpected change: %+v", diff.Changed[0])
+	}
+
+	changes := diff.changes(time.Unix(0, 0))
+	actions := make([]string, 0, len(changes))
+	for _, change := range changes {
+		actions = append(actions, change.CMCID+":"+change.Action)
+	}
+	want := []string{"3794:changed", "5000:added"}
+	if !reflect.DeepEqual(actions, want) {
+		t.Errorf("change log: got %v, want %v", actions, want)
+	}
+
+	if ids := newCoinGeckoIDs(previous, next); !reflect.DeepEqual(ids, []string{"cosmos", "new-coin"}) {
+		t.Errorf("new coingecko ids: got %v", ids)
+	}
+
+	if !diffCMCMapEntries(next, next).Empty() {
+		t.Error("expected identical mappings to produce an empty diff")
+	}
+}
+
+func TestMergeCMCMapEntries(t *testing.T) {
+	previous := []CMCMappingEntry{
+		{CMCID: "1", Symbol: "BTC", CoinGeckoID: "bitcoin"},
+		{CMCID: "3794", Symbol: "ATOM", CoinGeckoID: "atom-clone"},
+		{CMCID: "4000", Symbol: "OLD", CoinGeckoID: "old-coin"},
+	}
+	rebuilt := []CMCMappingEntry{
+		{CMCID: "1", Symbol: "BTC", CoinGeckoID: "bitcoin"},
+		{CMCID: "3794", Symbol: "ATOM", CoinGeckoID: "cosmos"},
+		{CMCID: "5000", Symbol: "NEW", CoinGeckoID: "new-coin"},
+	}
+
+	merged := mergeCMCMapEntries(previous, rebuilt)
+	ids := make([]string, 0, len(merged))
+	for _, entry := range merged {
+		ids = append(ids, entry.CMCID+"="+entry.CoinGeckoID)
+	}
+	// 4000 dropped out of the rebuilt top list and keeps its mapping.
+	want := []string{"1=bitcoin", "3794=cosmos", "5000=new-coin", "4000=old-coin"}
+	if !reflect.DeepEqual(ids, want) {
+		t.Errorf("merged: got %v, want %v", ids, want)
+	}
+	if diff := diffCMCMapEntries(previous, merged); len(diff.Changed) != 1 || len(diff.Added) != 1 {
+		t.Errorf("unexpected diff after merge: %s", diff)
+	}
+}
@@ -5,15 +5,24 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 )
 
+// cmcMapTTL is how long clients may reuse /cmc/map before revalidating;
+// overrides can change the mapping between rebuilds.
+const cmcMapTTL = time.Hour
+
 type CMCMappingEntry struct {
-	CMCID       string `json:"cmc_id"`
-	Symbol      string `json:"symbol"`
-	Name        string `json:"name"`
-	CoinGeckoID string `json:"coingecko_id"`
+	CMCID       string   `json:"cmc_id"`
+	AssetID     string   `json:"asset_id,omitempty"`
+	Symbol      string   `json:"symbol"`
+	Name        string   `json:"name"`
+	CoinGeckoID string   `json:"coingecko_id"`
+	Confidence  float64  `json:"confidence,omitempty"`
+	MatchReason string   `json:"match_reason,omitempty"`
+	Candidates  []string `json:"candidates,omitempty"`
 }
 
 type cmcMappingFile struct {
@@ -21,11 +30,123 @@ type cmcMappingFile struct {
 	Entries   []CMCMappingEntry `json:"entries"`
 }
 
+// CMCMapResponse is returned to the mobile app for /cmc/map.
+type CMCMapResponse struct {
+	Entries   []CMCMappingEntry `json:"entries"`
+	Timestamp int64             `json:"timestamp"`
+	UpdatedAt time.Time         `json:"updated_at"`
+	// AssetsUpdatedAt is when the asset registry behind the entries' asset
+	// ids last changed; it is part of the cache validator, not the body.
+	AssetsUpdatedAt time.Time `json:"-"`
+}
+
+// ExpiresAt returns when clients should revalidate the mapping: one TTL
+// after it was last rebuilt, not after it was served.
+func (r *CMCMapResponse) ExpiresAt() time.Time {
+	return r.UpdatedAt.Add(cmcMapTTL)
+}
+
+// CMCMapOverlay adjusts stored entries before they are indexed and reports
+// when its own inputs last changed.
+type CMCMapOverlay func(entries []CMCMappingEntry) ([]CMCMappingEntry, time.Time, error)
+
+// CMCMapIndex is an immutable view of the effective mapping indexed by CMC
+// id, CoinGecko id and symbol.
+type CMCMapIndex struct {
+	entries     []CMCMappingEntry
+	byCMCID     map[string]int
+	byCoinGecko map[string][]int
+	bySymbol    map[string][]int
+	updatedAt   time.Time
+}
+
+func newCMCMapIndex(entries []CMCMappingEntry, updatedAt time.Time) *CMCMapIndex {
+	index := &CMCMapIndex{
+		entries:     entries,
+		byCMCID:     make(map[string]int, len(entries)),
+		byCoinGecko: make(map[string][]int, len(entries)),
+		bySymbol:    make(map[string][]int, len(entries)),
+		updatedAt:   updatedAt,
+	}
+
+	for i, entry := range entries {
+		index.byCMCID[entry.CMCID] = i
+		if entry.CoinGeckoID != "" {
+			index.byCoinGecko[entry.CoinGeckoID] = append(index.byCoinGecko[entry.CoinGeckoID], i)
+		}
+		if symbol := strings.ToUpper(entry.Symbol); symbol != "" {
+			index.bySymbol[symbol] = append(index.bySymbol[symbol], i)
+		}
+	}
+
+	return index
+}
+
+// Len returns the number of entries.
+func (i *CMCMapIndex) Len() int {
+	return len(i.entries)
+}
+
+// UpdatedAt returns when the mapping or its overlay last changed.
+func (i *CMCMapIndex) UpdatedAt() time.Time {
+	return i.updatedAt
+}
+
+// Entries returns a copy of all entries.
+func (i *CMCMapIndex) Entries() []CMCMappingEntry {
+	entries := make([]CMCMappingEntry, len(i.entries))
+	copy(entries, i.entries)
+	return entries
+}
+
+// Entry returns the entry for a CMC id.
+func (i *CMCMapIndex) Entry(cmcID string) (CMCMappingEntry, bool) {
+	position, found := i.byCMCID[cmcID]
+	if !found {
+		return CMCMappingEntry{}, false
+	}
+	return i.entries[position], true
+}
+
+// CoinGeckoID returns the CoinGecko id a CMC id maps to, if any.
+func (i *CMCMapIndex) CoinGeckoID(cmcID string) (string, bool) {
+	entry, found := i.Entry(cmcID)
+	if !found || entry.CoinGeckoID == "" {
+		return "", false
+	}
+	return entry.CoinGeckoID, true
+}
+
+// CMCIDs returns the CMC ids mapped to a CoinGecko id in mapping order.
+func (i *CMCMapIndex) CMCIDs(coinGeckoID string) []string {
+	positions := i.byCoinGecko[coinGeckoID]
+	ids := make([]string, 0, len(positions))
+	for _, position := range positions {
+		ids = append(ids, i.entries[position].CMCID)
+	}
+	return ids
+}
+
+// BySymbol returns the entries trading under a symbol (case-insensitive).
+func (i *CMCMapIndex) BySymbol(symbol string) []CMCMappingEntry {
+	positions := i.bySymbol[strings.ToUpper(strings.TrimSpace(symbol))]
+	entries := make([]CMCMappingEntry, 0, len(positions))
+	for _, position := range positions {
+		entries = append(entries, i.entries[position])
+	}
+	return entries
+}
+
 // CMCMapStore manages cached CMC->CoinGecko mappings stored on disk.
 type CMCMapStore struct {
-	mu   sync.RWMutex
-	path string
-	data []CMCMappingEntry
+	mu        sync.RWMutex
+	path      string
+	data      []CMCMappingEntry
+	updatedAt time.Time
+	overlay   CMCMapOverlay
+	index     *CMCMapIndex
+	// generation changes whenever the index must be rebuilt.
+	generation uint64
 }
 
 // NewCMCMapStore creates a new mapping store using the given file path.
@@ -50,7 +171,7 @@ func (m *CMCMapStore) Get() ([]CMCMappingEntry, bool, error) {
 	}
 	m.mu.RUnlock()
 
-	data, err := m.loadFromFile()
+	data, updatedAt, err := m.loadFromFile()
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil, false, nil
@@ -63,10 +184,93 @@ func (m *CMCMapStore) Get() ([]CMCMappingEntry, bool, error) {
 	}
 
 	m.mu.Lock()
-	m.data = data
+	if m.data == nil {
+		m.data = data
+		m.updatedAt = updatedAt
+		m.index = nil
+		m.generation++
+	}
+	m.mu.Unlock()
+
+	copied := make([]CMCMappingEntry, len(data))
+	copy(copied, data)
+	return copied, true, nil
+}
+
+// SetOverlay registers a function applied to stored entries before they are
+// indexed, such as manual overrides.
+func (m *CMCMapStore) SetOverlay(overlay CMCMapOverlay) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	m.overlay = overlay
+	m.index = nil
+	m.generation++
+	m.mu.Unlock()
+}
+
+// Invalidate drops the index so the next lookup re-applies the overlay.
+func (m *CMCMapStore) Invalidate() {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	m.index = nil
+	m.generation++
+	m.mu.Unlock()
+}
+
+// Index returns the indexed effective mapping, building it on first use
+// after a change. It reports false when the mapping is empty.
+func (m *CMCMapStore) Index() (*CMCMapIndex, bool, error) {
+	if m == nil {
+		return nil, false, fmt.Errorf("cmc map store not configured")
+	}
+
+	m.mu.RLock()
+	index := m.index
+	m.mu.RUnlock()
+	if index != nil {
+		return index, index.Len() > 0, nil
+	}
+
+	// Load the file on first use.
+	if _, _, err := m.Get(); err != nil {
+		return nil, false, err
+	}
+
+	m.mu.RLock()
+	entries := make([]CMCMappingEntry, len(m.data))
+	copy(entries, m.data)
+	overlay := m.overlay
+	updatedAt := m.updatedAt
+	generation := m.generation
+	m.mu.RUnlock()
+
+	if overlay != nil {
+		applied, overlayUpdatedAt, err := overlay(entries)
+		if err != nil {
+			return nil, false, err
+		}
+		entries = applied
+		if overlayUpdatedAt.After(updatedAt) {
+			updatedAt = overlayUpdatedAt
+		}
+	}
+
+	index = newCMCMapIndex(entries, updatedAt)
+
+	m.mu.Lock()
+	// Only cache the index if nothing changed while it was being built.
+	if m.generation == generation {
+		m.index = index
+	}
 	m.mu.Unlock()
 
-	return data, true, nil
+	return index, index.Len() > 0, nil
 }
 
 // Set stores mapping entries on disk and updates the in-memory cache.
@@ -113,21 +317,24 @@ func (m *CMCMapStore) Set(entries []CMCMappingEntry) error {
 
 	m.mu.Lock()
 	m.data = entries
+	m.updatedAt = payload.UpdatedAt
+	m.index = nil
+	m.generation++
 	m.mu.Unlock()
 
 	return nil
 }
 
-func (m *CMCMapStore) loadFromFile() ([]CMCMappingEntry, error) {
+func (m *CMCMapStore) loadFromFile() ([]CMCMappingEntry, time.Time, error) {
 	bytes, err := os.ReadFile(m.path)
 	if err != nil {
-		return nil, err
+		return nil, time.Time{}, err
 	}
 
 	var payload cmcMappingFile
 	if err := json.Unmarshal(bytes, &payload); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal mapping file: %w", err)
+		return nil, time.Time{}, fmt.Errorf("failed to unmarshal mapping file: %w", err)
 	}
 
-	return payload.Entries, nil
+	return payload.Entries, payload.UpdatedAt, nil
 }
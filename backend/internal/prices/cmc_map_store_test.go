@@ -0,0 +1,73 @@
+package prices
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCMCMapStoreIndex(t *testing.T) {
+	store := NewCMCMapStore(filepath.Join(t.TempDir(), "map.json"))
+	if err := store.Set([]CMCMappingEntry{
+		{CMCID: "1", Symbol: "BTC", CoinGeckoID: "bitcoin"},
+		{CMCID: "3794", Symbol: "ATOM", CoinGeckoID: "atom-clone"},
+		{CMCID: "9000", Symbol: "atom", CoinGeckoID: "atom-clone"},
+		{CMCID: "9999", Symbol: "XYZ"},
+	}); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+
+	overrides := map[string]MappingOverride{}
+	overlayAt := time.Time{}
+	store.SetOverlay(func(entries []CMCMappingEntry) ([]CMCMappingEntry, time.Time, error) {
+		return applyMappingOverrides(entries, overrides), overlayAt, nil
+	})
+
+	index, found, err := store.Index()
+	if err != nil || !found {
+		t.Fatalf("index: found=%v err=%v", found, err)
+	}
+	if id, ok := index.CoinGeckoID("1"); !ok || id != "bitcoin" {
+		t.Errorf("CoinGeckoID(1) = %q, %v", id, ok)
+	}
+	if _, ok := index.CoinGeckoID("9999"); ok {
+		t.Error("expected unmapped entry to have no CoinGecko id")
+	}
+	if ids := index.CMCIDs("atom-clone"); !reflect.DeepEqual(ids, []string{"3794", "9000"}) {
+		t.Errorf("CMCIDs(atom-clone) = %v", ids)
+	}
+	if entries := index.BySymbol("Atom"); len(entries) != 2 {
+		t.Errorf("BySymbol(Atom) returned %d entries", len(entries))
+	}
+
+	if again, _, _ := store.Index(); again != index {
+		t.Error("expected the index to be reused until something changes")
+	}
+
+	overrides["3794"] = MappingOverride{CMCID: "3794", Action: OverrideActionPin, CoinGeckoID: "cosmos"}
+	overlayAt = index.UpdatedAt().Add(time.Minute)
+	store.Invalidate()
+
+	index, _, err = store.Index()
+	if err != nil {
+		t.Fatalf("index after invalidate: %v", err)
+	}
+	if id, _ := index.CoinGeckoID("3794"); id != "cosmos" {
+		t.Errorf("expected override to apply after invalidate, got %q", id)
+	}
+	if ids := index.CMCIDs("cosmos"); !reflect.DeepEqual(ids, []string{"3794"}) {
+		t.Errorf("CMCIDs(cosmos) = %v", ids)
+	}
+	if !index.UpdatedAt().Equal(overlayAt) {
+		t.Errorf("expected UpdatedAt to follow the overlay, got %v", index.UpdatedAt())
+	}
+}
+
+func TestCMCMapResponseExpiresAfterUpdate(t *testing.T) {
+	updatedAt := time.Now().Add(-cmcMapTTL / 2)
+	response := &CMCMapResponse{Timestamp: time.Now().UnixMilli(), UpdatedAt: updatedAt}
+	if got := response.ExpiresAt(); !got.Equal(updatedAt.Add(cmcMapTTL)) {
+		t.Errorf("ExpiresAt = %v, want %v", got, updatedAt.Add(cmcMapTTL))
+	}
+}
@@ -0,0 +1,221 @@
+package prices
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	coinDetailTTL = 24 * time.Hour
+	// maxCoinDetails bounds the store; the least recently fetched details
+	// are dropped first.
+	maxCoinDetails = 2000
+)
+
+type coinDetailFileEntry struct {
+	UpdatedAt time.Time  `json:"updated_at"`
+	Coin      CoinDetail `json:"coin"`
+}
+
+type coinDetailFile struct {
+	Coins map[string]coinDetailFileEntry `json:"coins"`
+}
+
+// CoinDetailStore manages cached coin details stored on disk, keyed by the
+// id the client asked for.
+type CoinDetailStore struct {
+	mu     sync.RWMutex
+	path   string
+	data   map[string]coinDetailFileEntry
+	loaded bool
+}
+
+// NewCoinDetailStore creates a new coin detail store using the given file path.
+func NewCoinDetailStore(path string) *CoinDetailStore {
+	return &CoinDetailStore{
+		path: path,
+	}
+}
+
+// Get returns the cached detail for id if available and not stale.
+func (m *CoinDetailStore) Get(id string) (*CoinDetailResponse, bool, error) {
+	if m == nil {
+		return nil, false, fmt.Errorf("coin detail store not configured")
+	}
+
+	if err := m.ensureLoaded(); err != nil {
+		return nil, false, err
+	}
+
+	m.mu.RLock()
+	entry, found := m.data[strings.ToLower(id)]
+	m.mu.RUnlock()
+
+	if !found || isCoinDetailExpired(entry.UpdatedAt) {
+		return nil, false, nil
+	}
+
+	return &CoinDetailResponse{
+		Coin:      entry.Coin,
+		Timestamp: time.Now().UnixMilli(),
+		Cached:    true,
+		UpdatedAt: entry.UpdatedAt,
+	}, true, nil
+}
+
+// Set stores the detail for id on disk and updates the in-memory cache.
+func (m *CoinDetailStore) Set(id string, response *CoinDetailResponse) error {
+	if m == nil {
+		return fmt.Errorf("coin detail store not configured")
+	}
+	if response == nil {
+		return fmt.Errorf("coin detail response is nil")
+	}
+
+	if err := m.ensureLoaded(); err != nil {
+		return err
+	}
+
+	updatedAt := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	coins := make(map[string]coinDetailFileEntry, len(m.data)+1)
+	for key, entry := range m.data {
+		if !isCoinDetailExpired(entry.UpdatedAt) {
+			coins[key] = entry
+		}
+	}
+	coins[strings.ToLower(id)] = coinDetailFileEntry{
+		UpdatedAt: updatedAt,
+		Coin:      response.Coin,
+	}
+	if len(coins) > maxCoinDetails {
+		keys := make([]string, 0, len(coins))
+		for key := range coins {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return coins[keys[i]].UpdatedAt.Before(coins[keys[j]].UpdatedAt)
+		})
+		for _, key := range keys[:len(coins)-maxCoinDetails] {
+			delete(coins, key)
+		}
+	}
+
+	bytes, err := json.Marshal(coinDetailFile{Coins: coins})
+	if err != nil {
+		return fmt.Errorf("failed to marshal coin details: %w", err)
+	}
+
+	dir := filepath.Dir(m.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create coin detail directory: %w", err)
+	}
+
+	tmpFile, err := os.CreateTemp(dir, "coin_details_*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create temp coin detail file: %w", err)
+	}
+
+	if _, err := tmpFile.Write(bytes); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to write coin detail file: %w", err)
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("failed to close coin detail file: %w", err)
+	}
+
+	if err := os.Rename(tmpFile.Name(), m.path); err != nil {
+		return fmt.Errorf("failed to move coin detail file: %w", err)
+	}
+
+	response.UpdatedAt = updatedAt
+	m.data = coins
+
+	return nil
+}
+
+func (m *CoinDetailStore) ensureLoaded() error {
+	m.mu.RLock()
+	loaded := m.loaded
+	m.mu.RUnlock()
+	if loaded {
+		return nil
+	}
+
+	data, err := m.loadFromFile()
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if data == nil {
+		data = make(map[string]coinDetailFileEntry)
+	}
+
+	m.mu.Lock()
+	if !m.loaded {
+		m.data = data
+		m.loaded = true
+	}
+	m.mu.Unlock()
+
+	return nil
+}
+
+func (m *CoinDetailStore) loadFromFile() (map[string]coinDetailFileEntry, error) {
+	bytes, err := os.ReadFile(m.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload coinDetailFile
+	if err := json.Unmarshal(bytes, &payload); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal coin detail file: %w", err)
+	}
+
+	return payload.Coins, nil
+}
+
+func isCoinDetailExpired(updatedAt time.Time) bool {
+	if updatedAt.IsZero() {
+		return true
+	}
+	return time.Since(updatedAt) > coinDetailTTL
+}
+
+func nonEmptyStrings(values []string) []string {
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			result = append(result, value)
+		}
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+func firstString(values []string) string {
+	if cleaned := nonEmptyStrings(values); len(cleaned) > 0 {
+		return cleaned[0]
+	}
+	return ""
+}
+
+func sortContractAddresses(contracts []ContractAddress) {
+	sort.Slice(contracts, func(i, j int) bool {
+		if contracts[i].Chain != contracts[j].Chain {
+			return contracts[i].Chain < contracts[j].Chain
+		}
+		return contracts[i].Address < contracts[j].Address
+	})
+}
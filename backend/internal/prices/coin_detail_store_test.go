@@ -0,0 +1,85 @@
+package prices
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCoinDetailStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "coin_details.json")
+	store := NewCoinDetailStore(path)
+
+	if _, found, err := store.Get("bitcoin"); err != nil || found {
+		t.Fatalf("empty store: found=%v err=%v", found, err)
+	}
+	if err := store.Set("Bitcoin", &CoinDetailResponse{Coin: CoinDetail{ID: "bitcoin", Symbol: "BTC"}}); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+
+	reloaded := NewCoinDetailStore(path)
+	detail, found, err := reloaded.Get("BITCOIN")
+	if err != nil || !found {
+		t.Fatalf("reloaded get: found=%v err=%v", found, err)
+	}
+	if detail.Coin.Symbol != "BTC" || !detail.Cached || detail.UpdatedAt.IsZero() {
+		t.Errorf("detail = %+v", detail)
+	}
+
+	reloaded.data["bitcoin"] = coinDetailFileEntry{UpdatedAt: time.Now().Add(-coinDetailTTL - time.Minute), Coin: detail.Coin}
+	if _, found, _ := reloaded.Get("bitcoin"); found {
+		t.Error("expected an expired detail to be a miss")
+	}
+}
+
+func TestCoinDetailStoreDropsOldestBeyondCap(t *testing.T) {
+	store := NewCoinDetailStore(filepath.Join(t.TempDir(), "coin_details.json"))
+	if err := store.ensureLoaded(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	now := time.Now()
+	for i := 0; i < maxCoinDetails; i++ {
+		store.data[fmt.Sprintf("coin-%d", i)] = coinDetailFileEntry{UpdatedAt: now.Add(-time.Hour + time.Duration(i)*time.Millisecond)}
+	}
+
+	if err := store.Set("newest", &CoinDetailResponse{Coin: CoinDetail{ID: "newest"}}); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if len(store.data) != maxCoinDetails {
+		t.Fatalf("stored %d details, want %d", len(store.data), maxCoinDetails)
+	}
+	if _, found := store.data["coin-0"]; found {
+		t.Error("expected the oldest detail to be dropped")
+	}
+	if _, found := store.data["newest"]; !found {
+		t.Error("expected the new detail to be kept")
+	}
+}
+
+func TestGetCoinDetailRejectsUnknownIDs(t *testing.T) {
+	dir := t.TempDir()
+	meta := NewMetaStore(filepath.Join(dir, "coins_meta.json"))
+	if err := meta.Set(&CoinMetaResponse{Coins: []CoinMeta{{ID: "bitcoin", Symbol: "btc", Name: "Bitcoin"}}, UpdatedAt: time.Now()}); err != nil {
+		t.Fatalf("seed metadata: %v", err)
+	}
+	details := NewCoinDetailStore(filepath.Join(dir, "coin_details.json"))
+	if err := details.Set("bitcoin", &CoinDetailResponse{Coin: CoinDetail{ID: "bitcoin", Symbol: "BTC"}}); err != nil {
+		t.Fatalf("seed details: %v", err)
+	}
+
+	service := NewService(Config{
+		MetaPath:       filepath.Join(dir, "coins_meta.json"),
+		CoinDetailPath: filepath.Join(dir, "coin_details.json"),
+		CMCMapPath:     filepath.Join(dir, "cmc_coingecko_map.json"),
+	})
+
+	if _, err := service.GetCoinDetail("not-a-coin"); !errors.Is(err, ErrUnknownCoin) {
+		t.Fatalf("unknown id: err = %v, want ErrUnknownCoin", err)
+	}
+	detail, err := service.GetCoinDetail("bitcoin")
+	if err != nil || detail.Coin.Symbol != "BTC" {
+		t.Fatalf("known id: detail = %+v, err = %v", detail, err)
+	}
+}
@@ -121,6 +121,24 @@ func (c *CoinGeckoClient) GetCoinsMarketsBySymbols(symbols []string) ([]CoinGeck
 	return marketCoins, nil
 }
 
+// GetCoinsList fetches every CoinGecko coin with its platform contract addresses.
+func (c *CoinGeckoClient) GetCoinsList() ([]CoinGeckoListCoin, error) {
+	url := fmt.Sprintf("%s/coins/list?include_platform=true", coinGeckoBaseURL)
+
+	resp, err := c.doRequest(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var coins []CoinGeckoListCoin
+	if err := json.NewDecoder(resp.Body).Decode(&coins); err != nil {
+		return nil, fmt.Errorf("failed to decode CoinGecko coins list response: %w", err)
+	}
+
+	return coins, nil
+}
+
 // GetSimplePrices fetches current prices for specific CoinGecko IDs.
 func (c *CoinGeckoClient) GetSimplePrices(ids []string) (*LatestPricesResponse, error) {
 	if len(ids) == 0 {
@@ -196,3 +214,90 @@ func (c *CoinGeckoClient) GetMarketChart(id, days, interval string) (*HistoryRes
 		UpdatedAt: time.Now(),
 	}, nil
 }
+
+// GetCoinDetail fetches extended information for a CoinGecko ID.
+func (c *CoinGeckoClient) GetCoinDetail(id string) (*CoinDetail, error) {
+	if id == "" {
+		return nil, fmt.Errorf("id cannot be empty")
+	}
+
+	url := fmt.Sprintf("%s/coins/%s?localization=false&tickers=false&market_data=true&community_data=false&developer_data=false&sparkline=false",
+		coinGeckoBaseURL, id)
+
+	resp, err := c.doRequest(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var raw CoinGeckoCoinDetail
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("failed to decode CoinGecko coin response: %w", err)
+	}
+
+	detail := &CoinDetail{
+		ID:            raw.ID,
+		CoinGeckoID:   raw.ID,
+		Symbol:        strings.ToUpper(raw.Symbol),
+		Name:          raw.Name,
+		Image:         raw.Image.Large,
+		Description:   strings.TrimSpace(raw.Description.EN),
+		Categories:    nonEmptyStrings(raw.Categories),
+		MarketCapRank: raw.MarketCapRank,
+		Links: CoinLinks{
+			Homepage:   nonEmptyStrings(raw.Links.Homepage),
+			Explorers:  nonEmptyStrings(raw.Links.BlockchainSite),
+			SourceCode: nonEmptyStrings(raw.Links.ReposURL.GitHub),
+			Whitepaper: raw.Links.Whitepaper,
+			Reddit:     raw.Links.SubredditURL,
+		},
+		Supply: CoinSupply{
+			Circulating: raw.MarketData.CirculatingSupply,
+			Total:       raw.MarketData.TotalSupply,
+			Max:         raw.MarketData.MaxSupply,
+		},
+		ATH:    coinGeckoExtreme(raw.MarketData.ATH, raw.MarketData.ATHDate, raw.MarketData.ATHChangePercentage),
+		ATL:    coinGeckoExtreme(raw.MarketData.ATL, raw.MarketData.ATLDate, raw.MarketData.ATLChangePercentage),
+		Source: ProviderCoinGecko,
+	}
+	if raw.Links.TwitterScreenName != "" {
+		detail.Links.Twitter = "https://twitter.com/" + raw.Links.TwitterScreenName
+	}
+
+	for chain, platform := range raw.DetailPlatforms {
+		if chain == "" || platform.ContractAddress == "" {
+			continue
+		}
+		contract := ContractAddress{Chain: chain, Address: platform.ContractAddress}
+		if platform.DecimalPlace != nil {
+			contract.Decimals = *platform.DecimalPlace
+		}
+		detail.ContractAddresses = append(detail.ContractAddresses, contract)
+	}
+	if len(detail.ContractAddresses) == 0 {
+		for chain, address := range raw.Platforms {
+			if chain != "" && address != "" {
+				detail.ContractAddresses = append(detail.ContractAddresses, ContractAddress{Chain: chain, Address: address})
+			}
+		}
+	}
+	sortContractAddresses(detail.ContractAddresses)
+
+	return detail, nil
+}
+
+func coinGeckoExtreme(values map[string]float64, dates map[string]string, changes map[string]float64) *PriceExtreme {
+	value, ok := values["usd"]
+	if !ok {
+		return nil
+	}
+
+	extreme := &PriceExtreme{
+		USD:              value,
+		ChangePercentage: changes["usd"],
+	}
+	if parsed, err := time.Parse(time.RFC3339, dates["usd"]); err == nil {
+		extreme.Timestamp = parsed.UnixMilli()
+	}
+	return extreme
+}
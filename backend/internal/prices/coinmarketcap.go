@@ -7,10 +7,16 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
+
+	"crypto-portfolio-backend/internal/httpclient"
 )
 
-const coinMarketCapBaseURL = "https://pro-api.coinmarketcap.com/v1"
+const (
+	coinMarketCapBaseURL   = "https://pro-api.coinmarketcap.com/v1"
+	coinMarketCapV2BaseURL = "https://pro-api.coinmarketcap.com/v2"
+)
 
 // CoinMarketCapClient handles interactions with CoinMarketCap API.
 type CoinMarketCapClient struct {
@@ -21,10 +27,8 @@ type CoinMarketCapClient struct {
 // NewCoinMarketCapClient creates a new CoinMarketCap API client.
 func NewCoinMarketCapClient() *CoinMarketCapClient {
 	return &CoinMarketCapClient{
-		httpClient: &http.Client{
-			Timeout: requestTimeout,
-		},
-		apiKey: os.Getenv("CMC_API_KEY"),
+		httpClient: httpclient.New(requestTimeout),
+		apiKey:     os.Getenv("CMC_API_KEY"),
 	}
 }
 
@@ -56,12 +60,24 @@ func (c *CoinMarketCapClient) GetCoinMap(limit int) ([]CoinMeta, error) {
 
 	meta := make([]CoinMeta, 0, len(response.Data))
 	for _, coin := range response.Data {
-		meta = append(meta, CoinMeta{
-			ID:     strconv.Itoa(coin.ID),
-			Symbol: coin.Symbol,
-			Name:   coin.Name,
-			Image:  cmcImageURL(coin.ID),
-		})
+		entry := CoinMeta{
+			ID:            strconv.Itoa(coin.ID),
+			Symbol:        coin.Symbol,
+			Name:          coin.Name,
+			Image:         cmcImageURL(coin.ID),
+			MarketCapRank: coin.Rank,
+		}
+		if coin.Platform != nil && coin.Platform.TokenAddress != "" {
+			chain := coin.Platform.Slug
+			if chain == "" {
+				chain = coin.Platform.Name
+			}
+			entry.Contracts = []ContractAddress{{
+				Chain:   canonicalChain(chain),
+				Address: coin.Platform.TokenAddress,
+			}}
+		}
+		meta = append(meta, entry)
 	}
 
 	return meta, nil
@@ -110,45 +126,26 @@ func (c *CoinMarketCapClient) GetLatestListings(limit int) (*LatestPricesRespons
 }
 
 func (c *CoinMarketCapClient) doRequest(url string) (*http.Response, error) {
-	const maxAttempts = 3
-	backoff := []time.Duration{0, 750 * time.Millisecond, 1500 * time.Millisecond}
-	var lastErr error
-
-	for attempt := 1; attempt <= maxAttempts; attempt++ {
-		if attempt > 1 {
-			time.Sleep(backoff[attempt-1])
-		}
-
+	resp, err := httpclient.Do(c.httpClient, func() (*http.Request, error) {
 		req, err := http.NewRequest(http.MethodGet, url, nil)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create CMC request: %w", err)
+			return nil, err
 		}
-
 		req.Header.Set("X-CMC_PRO_API_KEY", c.apiKey)
 		req.Header.Set("Accept", "application/json")
+		return req, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch CMC data: %w", err)
+	}
 
-		resp, err := c.httpClient.Do(req)
-		if err != nil {
-			lastErr = fmt.Errorf("failed to fetch CMC data: %w", err)
-			continue
-		}
-
-		if resp.StatusCode == http.StatusOK {
-			return resp, nil
-		}
-
+	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		resp.Body.Close()
-
-		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode >= 500 {
-			lastErr = fmt.Errorf("CMC API retryable error: status %d, body: %s", resp.StatusCode, string(body))
-			continue
-		}
-
 		return nil, fmt.Errorf("CMC API error: status %d, body: %s", resp.StatusCode, string(body))
 	}
 
-	return nil, lastErr
+	return resp, nil
 }
 
 func cmcImageURL(id int) string {
@@ -160,9 +157,15 @@ type cmcMapResponse struct {
 }
 
 type cmcMapCoin struct {
-	ID     int    `json:"id"`
-	Name   string `json:"name"`
-	Symbol string `json:"symbol"`
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Symbol   string `json:"symbol"`
+	Rank     int    `json:"rank"`
+	Platform *struct {
+		Name         string `json:"name"`
+		Slug         string `json:"slug"`
+		TokenAddress string `json:"token_address"`
+	} `json:"platform"`
 }
 
 type cmcListingsResponse struct {
@@ -179,3 +182,128 @@ type cmcListingCoin struct {
 		} `json:"USD"`
 	} `json:"quote"`
 }
+
+// GetCoinInfo fetches extended information and supply for a CMC ID.
+func (c *CoinMarketCapClient) GetCoinInfo(id string) (*CoinDetail, error) {
+	if c.apiKey == "" {
+		return nil, fmt.Errorf("CMC API key not configured")
+	}
+	if id == "" {
+		return nil, fmt.Errorf("id cannot be empty")
+	}
+
+	url := fmt.Sprintf("%s/cryptocurrency/info?id=%s", coinMarketCapV2BaseURL, id)
+	resp, err := c.doRequest(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var info cmcInfoResponse
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("failed to decode CMC info response: %w", err)
+	}
+
+	coin, found := info.Data[id]
+	if !found {
+		return nil, fmt.Errorf("CMC info missing for id %s", id)
+	}
+
+	detail := &CoinDetail{
+		ID:          id,
+		CMCID:       id,
+		Symbol:      coin.Symbol,
+		Name:        coin.Name,
+		Image:       coin.Logo,
+		Description: strings.TrimSpace(coin.Description),
+		Categories:  nonEmptyStrings(coin.Tags),
+		Links: CoinLinks{
+			Homepage:   nonEmptyStrings(coin.URLs.Website),
+			Explorers:  nonEmptyStrings(coin.URLs.Explorer),
+			SourceCode: nonEmptyStrings(coin.URLs.SourceCode),
+			Whitepaper: firstString(coin.URLs.TechnicalDoc),
+			Twitter:    firstString(coin.URLs.Twitter),
+			Reddit:     firstString(coin.URLs.Reddit),
+		},
+		Source: ProviderCMC,
+	}
+
+	for _, contract := range coin.ContractAddress {
+		if contract.ContractAddress == "" {
+			continue
+		}
+		chain := contract.Platform.Coin.Slug
+		if chain == "" {
+			chain = strings.ToLower(contract.Platform.Name)
+		}
+		detail.ContractAddresses = append(detail.ContractAddresses, ContractAddress{
+			Chain:   chain,
+			Address: contract.ContractAddress,
+		})
+	}
+	sortContractAddresses(detail.ContractAddresses)
+
+	quotesURL := fmt.Sprintf("%s/cryptocurrency/quotes/latest?id=%s&convert=USD", coinMarketCapV2BaseURL, id)
+	quotesResp, err := c.doRequest(quotesURL)
+	if err != nil {
+		return nil, err
+	}
+	defer quotesResp.Body.Close()
+
+	var quotes cmcQuotesResponse
+	if err := json.NewDecoder(quotesResp.Body).Decode(&quotes); err != nil {
+		return nil, fmt.Errorf("failed to decode CMC quotes response: %w", err)
+	}
+	if quote, found := quotes.Data[id]; found {
+		detail.MarketCapRank = quote.CMCRank
+		detail.Supply = CoinSupply{
+			Circulating: quote.CirculatingSupply,
+			Total:       quote.TotalSupply,
+			Max:         quote.MaxSupply,
+		}
+	}
+
+	return detail, nil
+}
+
+type cmcInfoResponse struct {
+	Data map[string]cmcInfoCoin `json:"data"`
+}
+
+type cmcInfoCoin struct {
+	ID          int      `json:"id"`
+	Name        string   `json:"name"`
+	Symbol      string   `json:"symbol"`
+	Description string   `json:"description"`
+	Logo        string   `json:"logo"`
+	Tags        []string `json:"tags"`
+	URLs        struct {
+		Website      []string `json:"website"`
+		TechnicalDoc []string `json:"technical_doc"`
+		Twitter      []string `json:"twitter"`
+		Reddit       []string `json:"reddit"`
+		Explorer     []string `json:"explorer"`
+		SourceCode   []string `json:"source_code"`
+	} `json:"urls"`
+	ContractAddress []struct {
+		ContractAddress string `json:"contract_address"`
+		Platform        struct {
+			Name string `json:"name"`
+			Coin struct {
+				Slug string `json:"slug"`
+			} `json:"coin"`
+		} `json:"platform"`
+	} `json:"contract_address"`
+}
+
+type cmcQuotesResponse struct {
+	Data map[string]cmcQuoteCoin `json:"data"`
+}
+
+type cmcQuoteCoin struct {
+	ID                int      `json:"id"`
+	CMCRank           int      `json:"cmc_rank"`
+	CirculatingSupply *float64 `json:"circulating_supply"`
+	TotalSupply       *float64 `json:"total_supply"`
+	MaxSupply         *float64 `json:"max_supply"`
+}
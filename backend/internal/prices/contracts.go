@@ -0,0 +1,283 @@
+package prices
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrContractIndexUnavailable is returned when no contract metadata could be
+// loaded from either provider.
+var ErrContractIndexUnavailable = errors.New("contract index not available")
+
+// ContractMatch is a coin issued at a looked-up contract address.
+type ContractMatch struct {
+	AssetID       string `json:"asset_id,omitempty"`
+	CMCID         string `json:"cmc_id,omitempty"`
+	CoinGeckoID   string `json:"coingecko_id,omitempty"`
+	Symbol        string `json:"symbol"`
+	Name          string `json:"name"`
+	MarketCapRank int    `json:"market_cap_rank,omitempty"`
+	Decimals      int    `json:"decimals,omitempty"`
+}
+
+// ContractLookupResponse is returned to the mobile app for /coins/by-contract.
+type ContractLookupResponse struct {
+	Chain     string          `json:"chain"`
+	Address   string          `json:"address"`
+	Matches   []ContractMatch `json:"matches"`
+	Timestamp int64           `json:"timestamp"`
+	// SourcesUpdatedAt is when the caches behind the matches last
+	// changed; it is part of the cache validator, not the body.
+	SourcesUpdatedAt time.Time `json:"-"`
+}
+
+type contractRef struct {
+	id       string
+	symbol   string
+	name     string
+	rank     int
+	decimals int
+}
+
+// ContractIndex maps chain:address keys to the coins issued there. Like
+// SearchIndex, each provider's entries are replaced whenever its MetaStore
+// refreshes.
+type ContractIndex struct {
+	mu         sync.RWMutex
+	byProvider map[string]map[string][]contractRef
+}
+
+// NewContractIndex creates an empty contract index.
+func NewContractIndex() *ContractIndex {
+	return &ContractIndex{
+		byProvider: make(map[string]map[string][]contractRef),
+	}
+}
+
+// Update rebuilds the entries for a provider from its metadata.
+func (i *ContractIndex) Update(provider string, coins []CoinMeta) {
+	byKey := make(map[string][]contractRef)
+	for _, coin := range coins {
+		for _, contract := range coin.Contracts {
+			if coin.ID == "" || contract.Chain == "" || strings.TrimSpace(contract.Address) == "" {
+				continue
+			}
+			key := contractKey(contract.Chain, contract.Address)
+			byKey[key] = append(byKey[key], contractRef{
+				id:       coin.ID,
+				symbol:   strings.ToUpper(coin.Symbol),
+				name:     coin.Name,
+				rank:     coin.MarketCapRank,
+				decimals: contract.Decimals,
+			})
+		}
+	}
+
+	i.mu.Lock()
+	i.byProvider[provider] = byKey
+	i.mu.Unlock()
+}
+
+// Lookup returns the coins of a provider issued at chain:address.
+func (i *ContractIndex) Lookup(provider, chain, address string) []contractRef {
+	key := contractKey(chain, address)
+
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	refs := i.byProvider[provider][key]
+	return append([]contractRef(nil), refs...)
+}
+
+// Len returns the number of indexed contracts for a provider.
+func (i *ContractIndex) Len(provider string) int {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return len(i.byProvider[provider])
+}
+
+// LookupContract returns the coins issued at a token contract on chain.
+// EVM addresses may be given in any case; a mixed-case address must carry a
+// valid EIP-55 checksum.
+func (s *Service) LookupContract(chain, address string) (*ContractLookupResponse, error) {
+	chain = canonicalChain(chain)
+	address = strings.TrimSpace(address)
+	if chain == "" || address == "" {
+		return nil, fmt.Errorf("chain and address are required")
+	}
+
+	if isEVMChain(chain) {
+		checksummed, err := checksumEVMAddress(address)
+		if err != nil {
+			return nil, err
+		}
+		address = checksummed
+	}
+
+	if _, err := s.getContractMeta(); err != nil {
+		log.Printf("Failed to load CoinGecko contract metadata: %v", err)
+	}
+	if _, err := s.GetCMCCoinMeta(); err != nil {
+		log.Printf("Failed to load CMC metadata for contract lookup: %v", err)
+	}
+	if s.contractIndex.Len(ProviderCoinGecko) == 0 && s.contractIndex.Len(ProviderCMC) == 0 {
+		return nil, ErrContractIndexUnavailable
+	}
+
+	var mapping *CMCMapIndex
+	if index, found, err := s.cmcMapStore.Index(); err != nil {
+		log.Printf("Failed to load CMC mapping for contract lookup: %v", err)
+	} else if found {
+		mapping = index
+	}
+
+	matches := make([]ContractMatch, 0)
+	byCoinGecko := make(map[string]int)
+	for _, ref := range s.contractIndex.Lookup(ProviderCoinGecko, chain, address) {
+		match := ContractMatch{
+			CoinGeckoID: ref.id,
+			Symbol:      ref.symbol,
+			Name:        ref.name,
+			Decimals:    ref.decimals,
+		}
+		if mapping != nil {
+			if cmcIDs := mapping.CMCIDs(ref.id); len(cmcIDs) > 0 {
+				match.CMCID = cmcIDs[0]
+			}
+		}
+		byCoinGecko[ref.id] = len(matches)
+		matches = append(matches, match)
+	}
+
+	for _, ref := range s.contractIndex.Lookup(ProviderCMC, chain, address) {
+		coinGeckoID := ""
+		if mapping != nil {
+			coinGeckoID, _ = mapping.CoinGeckoID(ref.id)
+		}
+		if position, found := byCoinGecko[coinGeckoID]; found && coinGeckoID != "" {
+			matches[position].CMCID = ref.id
+			matches[position].MarketCapRank = ref.rank
+			if matches[position].Decimals == 0 {
+				matches[position].Decimals = ref.decimals
+			}
+			continue
+		}
+		matches = append(matches, ContractMatch{
+			CMCID:         ref.id,
+			CoinGeckoID:   coinGeckoID,
+			Symbol:        ref.symbol,
+			Name:          ref.name,
+			MarketCapRank: ref.rank,
+			Decimals:      ref.decimals,
+		})
+	}
+
+	assets := s.syncedAssets()
+	for i, match := range matches {
+		asset, found := assets.ByCMC(match.CMCID)
+		if !found {
+			asset, found = assets.ByCoinGecko(match.CoinGeckoID)
+		}
+		if found {
+			matches[i].AssetID = asset.ID
+		}
+	}
+
+	// Coins listed on CMC (the app prices from CMC) first, then by rank.
+	sort.SliceStable(matches, func(i, j int) bool {
+		if (matches[i].CMCID != "") != (matches[j].CMCID != "") {
+			return matches[i].CMCID != ""
+		}
+		return rankLess(matches[i].MarketCapRank, matches[j].MarketCapRank)
+	})
+
+	return &ContractLookupResponse{
+		Chain:            chain,
+		Address:          address,
+		Matches:          matches,
+		Timestamp:        time.Now().UnixMilli(),
+		SourcesUpdatedAt: s.lookupsUpdatedAt(),
+	}, nil
+}
+
+// getContractMeta returns CoinGecko coins with their platform contracts,
+// refreshing them from /coins/list when stale. Stale data is served if the
+// refresh fails.
+func (s *Service) getContractMeta() (*CoinMetaResponse, error) {
+	if s.contractMetaStore == nil {
+		return nil, fmt.Errorf("contract meta store not configured")
+	}
+
+	result, err, _ := s.group.Do("contract_meta", func() (interface{}, error) {
+		if cached, found, err := s.contractMetaStore.Get(); err != nil {
+			return nil, err
+		} else if found {
+			return cached, nil
+		}
+
+		log.Printf("Cache miss for contract metadata, fetching from CoinGecko")
+
+		list, err := s.client.GetCoinsList()
+		if err != nil {
+			if stale, found, latestErr := s.contractMetaStore.Latest(); latestErr == nil && found {
+				log.Printf("Serving stale contract metadata: %v", err)
+				return stale, nil
+			}
+			return nil, fmt.Errorf("failed to fetch contract metadata: %w", err)
+		}
+
+		response := &CoinMetaResponse{
+			Coins:     contractCoinsFromList(list),
+			Timestamp: time.Now().UnixMilli(),
+			Cached:    false,
+			UpdatedAt: time.Now(),
+		}
+
+		if err := s.contractMetaStore.Set(response); err != nil {
+			return nil, fmt.Errorf("failed to store contract metadata: %w", err)
+		}
+
+		return response, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result.(*CoinMetaResponse), nil
+}
+
+// contractCoinsFromList keeps the CoinGecko coins that have at least one
+// platform contract.
+func contractCoinsFromList(list []CoinGeckoListCoin) []CoinMeta {
+	coins := make([]CoinMeta, 0, len(list)/2)
+	for _, coin := range list {
+		var contracts []ContractAddress
+		for platform, address := range coin.Platforms {
+			address = strings.TrimSpace(address)
+			if platform == "" || address == "" {
+				continue
+			}
+			chain := canonicalChain(platform)
+			contracts = append(contracts, ContractAddress{
+				Chain:   chain,
+				Address: normalizeContractAddress(chain, address),
+			})
+		}
+		if len(contracts) == 0 {
+			continue
+		}
+		sortContractAddresses(contracts)
+		coins = append(coins, CoinMeta{
+			ID:        coin.ID,
+			Symbol:    coin.Symbol,
+			Name:      coin.Name,
+			Contracts: contracts,
+		})
+	}
+	return coins
+}
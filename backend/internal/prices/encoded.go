@@ -0,0 +1,64 @@
+package prices
+
+import (
+	"strconv"
+	"sync"
+)
+
+const maxEncodedVariants = 64
+
+// encodedPayloads memoizes serialized forms of a cache entry so hot payloads
+// are not re-encoded on every request. It is shared by every copy handed out
+// from the cache and is dropped together with the entry.
+type encodedPayloads struct {
+	mu       sync.RWMutex
+	variants map[string][]byte
+}
+
+func newEncodedPayloads() *encodedPayloads {
+	return &encodedPayloads{
+		variants: make(map[string][]byte),
+	}
+}
+
+func (e *encodedPayloads) getOrEncode(variant string, encode func() ([]byte, error)) ([]byte, error) {
+	if e == nil {
+		return encode()
+	}
+
+	e.mu.RLock()
+	payload, found := e.variants[variant]
+	e.mu.RUnlock()
+	if found {
+		return payload, nil
+	}
+
+	payload, err := encode()
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	if len(e.variants) < maxEncodedVariants {
+		e.variants[variant] = payload
+	}
+	e.mu.Unlock()
+
+	return payload, nil
+}
+
+// EncodedPayload returns the memoized bytes for variant (e.g. "cbor|zstd"),
+// calling encode on the first request for that variant.
+func (r *LatestPricesResponse) EncodedPayload(variant string, encode func() ([]byte, error)) ([]byte, error) {
+	return r.encoded.getOrEncode(encodedVariantKey(r.encodedScope, variant, r.Cached), encode)
+}
+
+// EncodedPayload returns the memoized bytes for variant (e.g. "cbor|zstd"),
+// calling encode on the first request for that variant.
+func (r *HistoryResponse) EncodedPayload(variant string, encode func() ([]byte, error)) ([]byte, error) {
+	return r.encoded.getOrEncode(encodedVariantKey(r.encodedScope, variant, r.Cached), encode)
+}
+
+func encodedVariantKey(scope, variant string, cached bool) string {
+	return scope + "|" + variant + "|" + strconv.FormatBool(cached)
+}
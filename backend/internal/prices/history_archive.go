@@ -0,0 +1,157 @@
+package prices
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+type archivedHistory struct {
+	ID        string         `json:"id"`
+	Days      string         `json:"days"`
+	Interval  string         `json:"interval,omitempty"`
+	Prices    []HistoryPoint `json:"prices"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+type historyArchiveFile struct {
+	Histories map[string]archivedHistory `json:"histories"`
+}
+
+// HistoryArchive keeps the last known history of delisted coins on disk
+// without a TTL so it can still be served once upstream stops returning it.
+type HistoryArchive struct {
+	mu     sync.RWMutex
+	path   string
+	data   map[string]archivedHistory
+	loaded bool
+}
+
+// NewHistoryArchive creates a new history archive using the given file path.
+func NewHistoryArchive(path string) *HistoryArchive {
+	return &HistoryArchive{
+		path: path,
+	}
+}
+
+// Get returns the archived history for a history cache key.
+func (a *HistoryArchive) Get(key string) (*HistoryResponse, bool, error) {
+	if a == nil {
+		return nil, false, fmt.Errorf("history archive not configured")
+	}
+	if err := a.ensureLoaded(); err != nil {
+		return nil, false, err
+	}
+
+	a.mu.RLock()
+	entry, found := a.data[strings.ToLower(key)]
+	a.mu.RUnlock()
+	if !found {
+		return nil, false, nil
+	}
+
+	return &HistoryResponse{
+		ID:        entry.ID,
+		Days:      entry.Days,
+		Interval:  entry.Interval,
+		Prices:    entry.Prices,
+		Timestamp: time.Now().UnixMilli(),
+		Cached:    true,
+		UpdatedAt: entry.UpdatedAt,
+	}, true, nil
+}
+
+// Set archives history under a history cache key.
+func (a *HistoryArchive) Set(key string, history *HistoryResponse) error {
+	if a == nil {
+		return fmt.Errorf("history archive not configured")
+	}
+	if history == nil || len(history.Prices) == 0 {
+		return nil
+	}
+	if err := a.ensureLoaded(); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	histories := make(map[string]archivedHistory, len(a.data)+1)
+	for existingKey, entry := range a.data {
+		histories[existingKey] = entry
+	}
+	histories[strings.ToLower(key)] = archivedHistory{
+		ID:        history.ID,
+		Days:      history.Days,
+		Interval:  history.Interval,
+		Prices:    history.Prices,
+		UpdatedAt: history.UpdatedAt,
+	}
+
+	bytes, err := json.Marshal(historyArchiveFile{Histories: histories})
+	if err != nil {
+		return fmt.Errorf("failed to marshal history archive: %w", err)
+	}
+
+	dir := filepath.Dir(a.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create history archive directory: %w", err)
+	}
+
+	tmpFile, err := os.CreateTemp(dir, "history_archive_*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create temp history archive file: %w", err)
+	}
+
+	if _, err := tmpFile.Write(bytes); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to write history archive file: %w", err)
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("failed to close history archive file: %w", err)
+	}
+
+	if err := os.Rename(tmpFile.Name(), a.path); err != nil {
+		return fmt.Errorf("failed to move history archive file: %w", err)
+	}
+
+	a.data = histories
+	return nil
+}
+
+func (a *HistoryArchive) ensureLoaded() error {
+	a.mu.RLock()
+	loaded := a.loaded
+	a.mu.RUnlock()
+	if loaded {
+		return nil
+	}
+
+	var payload historyArchiveFile
+	bytes, err := os.ReadFile(a.path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read history archive file: %w", err)
+	}
+	if len(bytes) > 0 {
+		if err := json.Unmarshal(bytes, &payload); err != nil {
+			return fmt.Errorf("failed to unmarshal history archive file: %w", err)
+		}
+	}
+	if payload.Histories == nil {
+		payload.Histories = make(map[string]archivedHistory)
+	}
+
+	a.mu.Lock()
+	if !a.loaded {
+		a.data = payload.Histories
+		a.loaded = true
+	}
+	a.mu.Unlock()
+
+	return nil
+}
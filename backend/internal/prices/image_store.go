@@ -0,0 +1,223 @@
+package prices
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const coinImageTTL = 30 * 24 * time.Hour
+
+// imageEntriesDir holds one manifest entry file per coin.
+const imageEntriesDir = "entries"
+
+// CoinImage is a resized coin logo ready to be served.
+type CoinImage struct {
+	ID          string
+	Size        int
+	Hash        string
+	ContentType string
+	Data        []byte
+	UpdatedAt   time.Time
+}
+
+type coinImageManifestEntry struct {
+	ID        string            `json:"id,omitempty"`
+	SourceURL string            `json:"source_url"`
+	UpdatedAt time.Time         `json:"updated_at"`
+	Hashes    map[string]string `json:"hashes"`
+}
+
+// CoinImageStore keeps resized coin logos on disk. Image files are content
+// addressed by their SHA-256 so identical logos are stored once; one small
+// manifest entry per coin maps its sizes to the current hashes, so storing a
+// logo never rewrites the others.
+type CoinImageStore struct {
+	mu     sync.RWMutex
+	dir    string
+	data   map[string]coinImageManifestEntry
+	loaded bool
+}
+
+// NewCoinImageStore creates a new image store in the given directory.
+func NewCoinImageStore(dir string) *CoinImageStore {
+	return &CoinImageStore{
+		dir: dir,
+	}
+}
+
+// Get returns the stored image for id and size. The second result reports
+// whether an image exists and the third whether it is due for a refresh.
+func (m *CoinImageStore) Get(id string, size int) (*CoinImage, bool, bool, error) {
+	if m == nil {
+		return nil, false, false, fmt.Errorf("coin image store not configured")
+	}
+
+	if err := m.ensureLoaded(); err != nil {
+		return nil, false, false, err
+	}
+
+	m.mu.RLock()
+	entry, found := m.data[strings.ToLower(id)]
+	m.mu.RUnlock()
+	if !found {
+		return nil, false, false, nil
+	}
+
+	hash, found := entry.Hashes[strconv.Itoa(size)]
+	if !found {
+		return nil, false, false, nil
+	}
+
+	data, err := os.ReadFile(m.imagePath(hash))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, false, false, nil
+		}
+		return nil, false, false, fmt.Errorf("failed to read coin image: %w", err)
+	}
+
+	return &CoinImage{
+		ID:          id,
+		Size:        size,
+		Hash:        hash,
+		ContentType: "image/png",
+		Data:        data,
+		UpdatedAt:   entry.UpdatedAt,
+	}, true, time.Since(entry.UpdatedAt) > coinImageTTL, nil
+}
+
+// Set stores PNG variants keyed by size for id and updates the manifest.
+func (m *CoinImageStore) Set(id, sourceURL string, variants map[int][]byte) (map[int]string, error) {
+	if m == nil {
+		return nil, fmt.Errorf("coin image store not configured")
+	}
+	if len(variants) == 0 {
+		return nil, fmt.Errorf("coin image variants are empty")
+	}
+
+	if err := m.ensureLoaded(); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create image directory: %w", err)
+	}
+
+	hashes := make(map[int]string, len(variants))
+	entry := coinImageManifestEntry{
+		SourceURL: sourceURL,
+		UpdatedAt: time.Now(),
+		Hashes:    make(map[string]string, len(variants)),
+	}
+	for size, data := range variants {
+		sum := sha256.Sum256(data)
+		hash := hex.EncodeToString(sum[:])
+		if err := m.writeFile(m.imagePath(hash), "coin_image_*.png", data); err != nil {
+			return nil, err
+		}
+		hashes[size] = hash
+		entry.Hashes[strconv.Itoa(size)] = hash
+	}
+
+	id = strings.ToLower(id)
+	entry.ID = id
+	bytes, err := json.Marshal(entry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal image manifest entry: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Join(m.dir, imageEntriesDir), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create image manifest directory: %w", err)
+	}
+	if err := m.writeFile(m.entryPath(id), "entry_*.json", bytes); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	m.data[id] = entry
+	m.mu.Unlock()
+	return hashes, nil
+}
+
+// entryPath names the manifest entry of id by its hash, so any id maps to a
+// safe file name.
+func (m *CoinImageStore) entryPath(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return filepath.Join(m.dir, imageEntriesDir, hex.EncodeToString(sum[:16])+".json")
+}
+
+func (m *CoinImageStore) imagePath(hash string) string {
+	return filepath.Join(m.dir, hash+".png")
+}
+
+// writeFile writes data atomically via a temp file in the image directory.
+func (m *CoinImageStore) writeFile(path, pattern string, data []byte) error {
+	tmpFile, err := os.CreateTemp(m.dir, pattern)
+	if err != nil {
+		return fmt.Errorf("failed to create temp image file: %w", err)
+	}
+
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpFile.Name())
+		return fmt.Errorf("failed to write image file: %w", err)
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		os.Remove(tmpFile.Name())
+		return fmt.Errorf("failed to close image file: %w", err)
+	}
+
+	if err := os.Rename(tmpFile.Name(), path); err != nil {
+		os.Remove(tmpFile.Name())
+		return fmt.Errorf("failed to move image file: %w", err)
+	}
+
+	return nil
+}
+
+func (m *CoinImageStore) ensureLoaded() error {
+	m.mu.RLock()
+	loaded := m.loaded
+	m.mu.RUnlock()
+	if loaded {
+		return nil
+	}
+
+	images := make(map[string]coinImageManifestEntry)
+	files, err := os.ReadDir(filepath.Join(m.dir, imageEntriesDir))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read image manifest entries: %w", err)
+	}
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+		bytes, err := os.ReadFile(filepath.Join(m.dir, imageEntriesDir, file.Name()))
+		if err != nil {
+			return fmt.Errorf("failed to read image manifest entry: %w", err)
+		}
+		var entry coinImageManifestEntry
+		if err := json.Unmarshal(bytes, &entry); err != nil || entry.ID == "" {
+			// A half-written or foreign file only costs a download.
+			continue
+		}
+		images[entry.ID] = entry
+	}
+
+	m.mu.Lock()
+	if !m.loaded {
+		m.data = images
+		m.loaded = true
+	}
+	m.mu.Unlock()
+
+	return nil
+}
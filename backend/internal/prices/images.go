@@ -0,0 +1,237 @@
+package prices
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// CoinImageSizes are the square logo sizes served by /coins/{id}/image.
+var CoinImageSizes = []int{32, 64, 128}
+
+// DefaultCoinImageSize is served when no size is requested.
+const DefaultCoinImageSize = 64
+
+const (
+	maxCoinImageBytes     = 2 << 20
+	maxCoinImageDimension = 4096
+)
+
+// coinImageHosts are the CDNs logos may be downloaded from.
+var coinImageHosts = []string{
+	"coin-images.coingecko.com",
+	"assets.coingecko.com",
+	"s2.coinmarketcap.com",
+}
+
+// IsCoinImageSize reports whether size is one of CoinImageSizes.
+func IsCoinImageSize(size int) bool {
+	for _, allowed := range CoinImageSizes {
+		if size == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// GetCoinImage returns the logo for a CoinGecko id or numeric CMC id at one
+// of CoinImageSizes, downloading and resizing it on first use. Migrated ids
+// get their successor's logo. A stale logo is served when the refresh fails.
+func (s *Service) GetCoinImage(id string, size int) (*CoinImage, error) {
+	id = strings.ToLower(strings.TrimSpace(id))
+	if id == "" {
+		return nil, fmt.Errorf("id cannot be empty")
+	}
+	if !IsCoinImageSize(size) {
+		return nil, fmt.Errorf("size must be one of %v", CoinImageSizes)
+	}
+	id, _ = s.resolveCoinID(s.coinID(id))
+
+	cached, found, stale, err := s.imageStore.Get(id, size)
+	if err != nil {
+		return nil, err
+	}
+	if found && !stale {
+		return cached, nil
+	}
+	if !found {
+		if err := s.checkKnownCoin(id); err != nil {
+			return nil, err
+		}
+	}
+
+	_, err, _ = s.group.Do("coin_image:"+id, func() (interface{}, error) {
+		sourceURL, err := s.coinImageURL(id)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("Fetching coin image for %s from %s", id, sourceURL)
+		variants, err := s.fetchCoinImageVariants(sourceURL)
+		if err != nil {
+			return nil, err
+		}
+
+		return s.imageStore.Set(id, sourceURL, variants)
+	})
+	if err != nil {
+		if found {
+			log.Printf("Serving stale coin image for %s: %v", id, err)
+			return cached, nil
+		}
+		return nil, fmt.Errorf("failed to fetch coin image: %w", err)
+	}
+
+	stored, found, _, err := s.imageStore.Get(id, size)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("coin image for %s not stored", id)
+	}
+	return stored, nil
+}
+
+// coinImageURL finds the upstream logo URL for id from metadata, the CMC
+// mapping or, as a last resort, coin details.
+func (s *Service) coinImageURL(id string) (string, error) {
+	if cmcID, err := strconv.Atoi(id); err == nil {
+		return cmcLargeImageURL(cmcID), nil
+	}
+
+	if meta, found, err := s.metaStore.Latest(); err == nil && found {
+		for _, coin := range meta.Coins {
+			if coin.ID == id && coin.Image != "" {
+				return coin.Image, nil
+			}
+		}
+	}
+
+	if index, found, err := s.cmcMapStore.Index(); err == nil && found {
+		if cmcIDs := index.CMCIDs(id); len(cmcIDs) > 0 {
+			if cmcID, err := strconv.Atoi(cmcIDs[0]); err == nil {
+				return cmcLargeImageURL(cmcID), nil
+			}
+		}
+	}
+
+	detail, err := s.GetCoinDetail(id)
+	if err != nil {
+		return "", err
+	}
+	if detail.Coin.Image == "" {
+		return "", fmt.Errorf("no image available for %s", id)
+	}
+	return detail.Coin.Image, nil
+}
+
+func cmcLargeImageURL(id int) string {
+	return fmt.Sprintf("https://s2.coinmarketcap.com/static/img/coins/128x128/%d.png", id)
+}
+
+// fetchCoinImageVariants downloads a logo and encodes a PNG per size.
+func (s *Service) fetchCoinImageVariants(sourceURL string) (map[int][]byte, error) {
+	parsed, err := url.Parse(sourceURL)
+	if err != nil || parsed.Scheme != "https" || !isCoinImageHost(parsed.Hostname()) {
+		return nil, fmt.Errorf("image host not allowed: %s", sourceURL)
+	}
+
+	resp, err := s.imageClient.Get(sourceURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("image download error: status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxCoinImageBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	if len(data) > maxCoinImageBytes {
+		return nil, fmt.Errorf("image exceeds %d bytes", maxCoinImageBytes)
+	}
+
+	return resizeCoinImage(data, CoinImageSizes)
+}
+
+// resizeCoinImage decodes data and returns a square PNG for each size. Non
+// square logos are scaled to fit and centred on a transparent canvas.
+func resizeCoinImage(data []byte, sizes []int) (map[int][]byte, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	if config.Width > maxCoinImageDimension || config.Height > maxCoinImageDimension {
+		return nil, fmt.Errorf("image dimensions %dx%d too large", config.Width, config.Height)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	bounds := src.Bounds()
+	if bounds.Dx() == 0 || bounds.Dy() == 0 {
+		return nil, fmt.Errorf("image is empty")
+	}
+
+	variants := make(map[int][]byte, len(sizes))
+	for _, size := range sizes {
+		width, height := size, size
+		if bounds.Dx() > bounds.Dy() {
+			height = max(1, size*bounds.Dy()/bounds.Dx())
+		} else if bounds.Dy() > bounds.Dx() {
+			width = max(1, size*bounds.Dx()/bounds.Dy())
+		}
+		offsetX, offsetY := (size-width)/2, (size-height)/2
+
+		dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+		draw.CatmullRom.Scale(dst, image.Rect(offsetX, offsetY, offsetX+width, offsetY+height), src, bounds, draw.Over, nil)
+
+		var buf bytes.Buffer
+		encoder := png.Encoder{CompressionLevel: png.BestCompression}
+		if err := encoder.Encode(&buf, dst); err != nil {
+			return nil, fmt.Errorf("failed to encode image: %w", err)
+		}
+		variants[size] = buf.Bytes()
+	}
+
+	return variants, nil
+}
+
+func isCoinImageHost(host string) bool {
+	for _, allowed := range coinImageHosts {
+		if strings.EqualFold(host, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+func newImageHTTPClient() *http.Client {
+	return &http.Client{
+		Timeout: 15 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 3 || req.URL.Scheme != "https" || !isCoinImageHost(req.URL.Hostname()) {
+				return fmt.Errorf("image redirect not allowed: %s", req.URL)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,119 @@
+package prices
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestResizeCoinImage(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 200, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 200; x++ {
+			src.Set(x, y, color.NRGBA{R: 255, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, src); err != nil {
+		t.Fatalf("encode source: %v", err)
+	}
+
+	variants, err := resizeCoinImage(buf.Bytes(), CoinImageSizes)
+	if err != nil {
+		t.Fatalf("resize: %v", err)
+	}
+
+	for _, size := range CoinImageSizes {
+		decoded, err := png.Decode(bytes.NewReader(variants[size]))
+		if err != nil {
+			t.Fatalf("decode %d: %v", size, err)
+		}
+		if bounds := decoded.Bounds(); bounds.Dx() != size || bounds.Dy() != size {
+			t.Errorf("size %d: got %dx%d", size, bounds.Dx(), bounds.Dy())
+		}
+		// A wide logo is letterboxed: the top row stays transparent.
+		if _, _, _, a := decoded.At(size/2, 0).RGBA(); a != 0 {
+			t.Errorf("size %d: expected transparent padding, got alpha %d", size, a)
+		}
+		if r, _, _, _ := decoded.At(size/2, size/2).RGBA(); r == 0 {
+			t.Errorf("size %d: expected logo pixels in the centre", size)
+		}
+	}
+
+	if _, err := resizeCoinImage([]byte("not an image"), CoinImageSizes); err == nil {
+		t.Error("expected an error for invalid image data")
+	}
+}
+
+func TestCoinImageStore(t *testing.T) {
+	store := NewCoinImageStore(t.TempDir())
+	hashes, err := store.Set("bitcoin", "https://example.com/btc.png", map[int][]byte{32: []byte("a"), 64: []byte("a")})
+	if err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if hashes[32] != hashes[64] {
+		t.Error("expected identical content to share a hash")
+	}
+
+	image, found, stale, err := store.Get("Bitcoin", 64)
+	if err != nil || !found || stale {
+		t.Fatalf("get: found=%v stale=%v err=%v", found, stale, err)
+	}
+	if string(image.Data) != "a" || image.Hash != hashes[64] {
+		t.Errorf("unexpected image %+v", image)
+	}
+	if _, found, _, _ := store.Get("bitcoin", 128); found {
+		t.Error("expected missing size to be not found")
+	}
+}
+
+func TestCoinImageStoreWritesOneEntryPerCoin(t *testing.T) {
+	dir := t.TempDir()
+	store := NewCoinImageStore(dir)
+	for _, id := range []string{"bitcoin", "solana"} {
+		if _, err := store.Set(id, "https://example.com/"+id+".png", map[int][]byte{64: []byte(id)}); err != nil {
+			t.Fatalf("set %s: %v", id, err)
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, imageEntriesDir))
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("entries = %d, err = %v, want 2", len(entries), err)
+	}
+
+	reloaded := NewCoinImageStore(dir)
+	for id, want := range map[string]string{"bitcoin": "bitcoin", "solana": "solana"} {
+		image, found, _, err := reloaded.Get(id, 64)
+		if err != nil || !found || string(image.Data) != want {
+			t.Errorf("%s: found=%v err=%v image=%+v", id, found, err, image)
+		}
+	}
+}
+
+func TestGetCoinImageRejectsUnknownIDs(t *testing.T) {
+	dir := t.TempDir()
+	meta := NewMetaStore(filepath.Join(dir, "coins_meta.json"))
+	if err := meta.Set(&CoinMetaResponse{Coins: []CoinMeta{{ID: "bitcoin", Symbol: "btc", Name: "Bitcoin"}}, UpdatedAt: time.Now()}); err != nil {
+		t.Fatalf("seed metadata: %v", err)
+	}
+	service := NewService(Config{
+		MetaPath:   filepath.Join(dir, "coins_meta.json"),
+		CMCMapPath: filepath.Join(dir, "cmc_coingecko_map.json"),
+		ImageDir:   filepath.Join(dir, "images"),
+	})
+
+	for _, id := range []string{"not-a-coin", "99999999"} {
+		if _, err := service.GetCoinImage(id, 64); !errors.Is(err, ErrUnknownCoin) {
+			t.Errorf("%s: err = %v, want ErrUnknownCoin", id, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "images")); !os.IsNotExist(err) {
+		t.Errorf("expected nothing stored for unknown ids, stat err = %v", err)
+	}
+}
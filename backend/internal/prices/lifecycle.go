@@ -0,0 +1,317 @@
+package prices
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Lifecycle event kinds tracked by CoinRegistry.
+const (
+	CoinEventRename    = "rename"
+	CoinEventMigration = "migration"
+	CoinEventDelisting = "delisting"
+)
+
+// maxRedirectHops bounds successor chains (A->B->C) and guards against loops.
+const maxRedirectHops = 8
+
+// CoinLifecycleEntry records that a provider id was renamed, migrated to a
+// successor token or delisted.
+type CoinLifecycleEntry struct {
+	Provider    string `json:"provider"`
+	ID          string `json:"id"`
+	Kind        string `json:"kind"`
+	SuccessorID string `json:"successor_id,omitempty"`
+	// Ratio is successor units received per old unit; 0 means 1:1.
+	Ratio       float64   `json:"ratio,omitempty"`
+	EffectiveAt time.Time `json:"effective_at"`
+	Note        string    `json:"note,omitempty"`
+}
+
+// CoinRedirect describes how a requested id was redirected to its successor.
+type CoinRedirect struct {
+	From  string
+	To    string
+	Kind  string
+	Ratio float64
+}
+
+// defaultCoinLifecycle ships with the backend; the registry file can add to
+// or replace these entries.
+var defaultCoinLifecycle = []CoinLifecycleEntry{
+	{
+		Provider:    ProviderCoinGecko,
+		ID:          "matic-network",
+		Kind:        CoinEventMigration,
+		SuccessorID: "polygon-ecosystem-token",
+		Ratio:       1,
+		EffectiveAt: time.Date(2024, time.September, 4, 0, 0, 0, 0, time.UTC),
+		Note:        "MATIC migrated to POL",
+	},
+	{
+		Provider:    ProviderCMC,
+		ID:          "3890",
+		Kind:        CoinEventMigration,
+		SuccessorID: "28321",
+		Ratio:       1,
+		EffectiveAt: time.Date(2024, time.September, 4, 0, 0, 0, 0, time.UTC),
+		Note:        "MATIC migrated to POL",
+	},
+}
+
+type coinLifecycleFile struct {
+	Entries []CoinLifecycleEntry `json:"entries"`
+}
+
+// CoinRegistry tracks renames, migrations and delistings per provider id.
+// Entries are read from a JSON file on first use and layered over the
+// built-in defaults.
+type CoinRegistry struct {
+	mu      sync.RWMutex
+	path    string
+	entries map[string]CoinLifecycleEntry
+	loaded  bool
+}
+
+// NewCoinRegistry creates a registry backed by the given file path.
+func NewCoinRegistry(path string) *CoinRegistry {
+	return &CoinRegistry{
+		path: path,
+	}
+}
+
+// Lookup returns the lifecycle entry for a provider id.
+func (r *CoinRegistry) Lookup(provider, id string) (CoinLifecycleEntry, bool) {
+	if r == nil {
+		return CoinLifecycleEntry{}, false
+	}
+	if err := r.ensureLoaded(); err != nil {
+		log.Printf("Failed to load coin registry: %v", err)
+		return CoinLifecycleEntry{}, false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entry, found := r.entries[lifecycleKey(provider, id)]
+	return entry, found
+}
+
+// Entries returns all entries sorted by provider and id.
+func (r *CoinRegistry) Entries() ([]CoinLifecycleEntry, error) {
+	if r == nil {
+		return nil, fmt.Errorf("coin registry not configured")
+	}
+	if err := r.ensureLoaded(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entries := make([]CoinLifecycleEntry, 0, len(r.entries))
+	for _, entry := range r.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Provider != entries[j].Provider {
+			return entries[i].Provider < entries[j].Provider
+		}
+		return entries[i].ID < entries[j].ID
+	})
+	return entries, nil
+}
+
+// Resolve follows renames and migrations that are in effect at now and
+// returns the current id. The redirect is nil when id is current.
+func (r *CoinRegistry) Resolve(provider, id string, now time.Time) (string, *CoinRedirect) {
+	current := id
+	var redirect *CoinRedirect
+	visited := map[string]struct{}{strings.ToLower(id): {}}
+
+	for hop := 0; hop < maxRedirectHops; hop++ {
+		entry, found := r.Lookup(provider, current)
+		if !found || entry.SuccessorID == "" || entry.Kind == CoinEventDelisting || entry.EffectiveAt.After(now) {
+			break
+		}
+		if _, loop := visited[entry.SuccessorID]; loop {
+			break
+		}
+		visited[entry.SuccessorID] = struct{}{}
+
+		if redirect == nil {
+			redirect = &CoinRedirect{From: id, Kind: entry.Kind, Ratio: 1}
+		} else if entry.Kind == CoinEventMigration {
+			// A migration anywhere in the chain is the stronger hint.
+			redirect.Kind = CoinEventMigration
+		}
+		redirect.Ratio *= entry.effectiveRatio()
+		redirect.To = entry.SuccessorID
+		current = entry.SuccessorID
+	}
+
+	return current, redirect
+}
+
+// Delisted returns the delisting entry for a provider id if one is in
+// effect at now.
+func (r *CoinRegistry) Delisted(provider, id string, now time.Time) (CoinLifecycleEntry, bool) {
+	entry, found := r.Lookup(provider, id)
+	if !found || entry.Kind != CoinEventDelisting || entry.EffectiveAt.After(now) {
+		return CoinLifecycleEntry{}, false
+	}
+	return entry, true
+}
+
+// Tracked reports whether a delisting is registered for a provider id,
+// whether or not it is in effect yet.
+func (r *CoinRegistry) Tracked(provider, id string) bool {
+	entry, found := r.Lookup(provider, id)
+	return found && entry.Kind == CoinEventDelisting
+}
+
+func (e CoinLifecycleEntry) effectiveRatio() float64 {
+	if e.Ratio <= 0 {
+		return 1
+	}
+	return e.Ratio
+}
+
+func lifecycleKey(provider, id string) string {
+	return provider + ":" + strings.ToLower(strings.TrimSpace(id))
+}
+
+func (r *CoinRegistry) ensureLoaded() error {
+	r.mu.RLock()
+	loaded := r.loaded
+	r.mu.RUnlock()
+	if loaded {
+		return nil
+	}
+
+	var payload coinLifecycleFile
+	if r.path != "" {
+		bytes, err := os.ReadFile(r.path)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read coin registry file: %w", err)
+		}
+		if len(bytes) > 0 {
+			if err := json.Unmarshal(bytes, &payload); err != nil {
+				return fmt.Errorf("failed to unmarshal coin registry file: %w", err)
+			}
+		}
+	}
+
+	entries := make(map[string]CoinLifecycleEntry, len(defaultCoinLifecycle)+len(payload.Entries))
+	for _, entry := range append(append([]CoinLifecycleEntry(nil), defaultCoinLifecycle...), payload.Entries...) {
+		if entry.Provider == "" || entry.ID == "" {
+			continue
+		}
+		entry.ID = strings.ToLower(strings.TrimSpace(entry.ID))
+		entry.SuccessorID = strings.ToLower(strings.TrimSpace(entry.SuccessorID))
+		entries[lifecycleKey(entry.Provider, entry.ID)] = entry
+	}
+
+	r.mu.Lock()
+	if !r.loaded {
+		r.entries = entries
+		r.loaded = true
+	}
+	r.mu.Unlock()
+
+	return nil
+}
+
+// resolveCoinID redirects a CoinGecko id or numeric CMC id to its successor.
+func (s *Service) resolveCoinID(id string) (string, *CoinRedirect) {
+	provider := ProviderCoinGecko
+	if isNumericID(id) {
+		provider = ProviderCMC
+	}
+	return s.registry.Resolve(provider, id, time.Now())
+}
+
+// filterPrices picks the requested ids from prices, keyed as requested.
+// Redirected ids get their successor's price with migrated_from/migrated_to
+// hints.
+func (s *Service) filterPrices(provider string, prices map[string]PricePoint, ids []string) map[string]PricePoint {
+	now := time.Now()
+	filtered := make(map[string]PricePoint, len(ids))
+	for _, id := range ids {
+		resolved, redirect := s.registry.Resolve(provider, s.providerID(provider, id), now)
+		price, found := prices[resolved]
+		if !found {
+			continue
+		}
+		if redirect != nil {
+			price.MigratedFrom = redirect.From
+			price.MigratedTo = resolved
+			price.MigrationRatio = redirect.Ratio
+		}
+		filtered[id] = price
+	}
+	return filtered
+}
+
+// annotateHistory returns a copy of history with asset id, redirect and
+// delisting hints. Its encoded scope differs so cached plain encodings are
+// not reused.
+func annotateHistory(history *HistoryResponse, assetID string, redirect *CoinRedirect, delisted bool) *HistoryResponse {
+	if assetID == "" && redirect == nil && !delisted {
+		return history
+	}
+
+	annotated := *history
+	annotated.AssetID = assetID
+	annotated.Delisted = delisted
+	scope := history.encodedScope
+	if assetID != "" {
+		scope += "|asset=" + assetID
+	}
+	if redirect != nil {
+		// Served under the requested id so callers find what they asked for.
+		annotated.ID = redirect.From
+		annotated.MigratedFrom = redirect.From
+		annotated.MigratedTo = history.ID
+		annotated.MigrationRatio = redirect.Ratio
+		scope += "|migrated_from=" + redirect.From
+	}
+	if delisted {
+		scope += "|delisted"
+	}
+	annotated.encodedScope = scope
+	return &annotated
+}
+
+// archivedHistory serves frozen history for a coin whose delisting is in
+// effect. found is false when the coin is not delisted or nothing was
+// archived before it stopped trading.
+func (s *Service) archivedHistory(id, key string) (*HistoryResponse, bool) {
+	if _, delisted := s.registry.Delisted(ProviderCoinGecko, id, time.Now()); !delisted {
+		return nil, false
+	}
+
+	history, found, err := s.historyArchive.Get(key)
+	if err != nil {
+		log.Printf("Failed to read archived history (%s): %v", key, err)
+		return nil, false
+	}
+	return history, found
+}
+
+// archiveHistory keeps history of coins with a registered delisting so it
+// can be served once upstream drops them.
+func (s *Service) archiveHistory(id, key string, history *HistoryResponse) {
+	if !s.registry.Tracked(ProviderCoinGecko, id) {
+		return
+	}
+	if err := s.historyArchive.Set(key, history); err != nil {
+		log.Printf("Failed to archive history (%s): %v", key, err)
+	}
+}
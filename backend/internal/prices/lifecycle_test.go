@@ -0,0 +1,144 @@
+package prices
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCoinRegistryResolve(t *testing.T) {
+	effective := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	future := time.Date(2099, time.January, 1, 0, 0, 0, 0, time.UTC)
+	entries := []CoinLifecycleEntry{
+		{Provider: ProviderCoinGecko, ID: "old-token", Kind: CoinEventRename, SuccessorID: "mid-token", EffectiveAt: effective},
+		{Provider: ProviderCoinGecko, ID: "mid-token", Kind: CoinEventMigration, SuccessorID: "new-token", Ratio: 1000, EffectiveAt: effective},
+		{Provider: ProviderCoinGecko, ID: "loop-a", Kind: CoinEventRename, SuccessorID: "loop-b", EffectiveAt: effective},
+		{Provider: ProviderCoinGecko, ID: "loop-b", Kind: CoinEventRename, SuccessorID: "loop-a", EffectiveAt: effective},
+		{Provider: ProviderCoinGecko, ID: "pending", Kind: CoinEventMigration, SuccessorID: "later", EffectiveAt: future},
+		{Provider: ProviderCoinGecko, ID: "dead-coin", Kind: CoinEventDelisting, EffectiveAt: effective},
+	}
+	registry := writeCoinRegistry(t, entries)
+	now := time.Date(2025, time.June, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		provider string
+		id       string
+		want     string
+		redirect bool
+		kind     string
+		ratio    float64
+	}{
+		{name: "current id", provider: ProviderCoinGecko, id: "bitcoin", want: "bitcoin"},
+		{name: "chain", provider: ProviderCoinGecko, id: "Old-Token", want: "new-token", redirect: true, kind: CoinEventMigration, ratio: 1000},
+		{name: "loop", provider: ProviderCoinGecko, id: "loop-a", want: "loop-b", redirect: true, kind: CoinEventRename, ratio: 1},
+		{name: "not yet effective", provider: ProviderCoinGecko, id: "pending", want: "pending"},
+		{name: "delisted", provider: ProviderCoinGecko, id: "dead-coin", want: "dead-coin"},
+		{name: "default", provider: ProviderCMC, id: "3890", want: "28321", redirect: true, kind: CoinEventMigration, ratio: 1},
+		{name: "provider scoped", provider: ProviderCMC, id: "old-token", want: "old-token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, redirect := registry.Resolve(tt.provider, tt.id, now)
+			if got != tt.want {
+				t.Fatalf("Resolve(%s) = %s, want %s", tt.id, got, tt.want)
+			}
+			if (redirect != nil) != tt.redirect {
+				t.Fatalf("redirect = %+v, want redirect %v", redirect, tt.redirect)
+			}
+			if redirect == nil {
+				return
+			}
+			if redirect.From != tt.id || redirect.To != tt.want || redirect.Kind != tt.kind || redirect.Ratio != tt.ratio {
+				t.Errorf("unexpected redirect: %+v", redirect)
+			}
+		})
+	}
+
+	if _, delisted := registry.Delisted(ProviderCoinGecko, "dead-coin", now); !delisted {
+		t.Error("expected dead-coin to be delisted")
+	}
+	if _, delisted := registry.Delisted(ProviderCoinGecko, "dead-coin", effective.Add(-time.Hour)); delisted {
+		t.Error("expected delisting to wait for its effective date")
+	}
+	if !registry.Tracked(ProviderCoinGecko, "dead-coin") || registry.Tracked(ProviderCoinGecko, "old-token") {
+		t.Error("unexpected tracked state")
+	}
+}
+
+func TestAnnotateHistory(t *testing.T) {
+	history := &HistoryResponse{ID: "polygon-ecosystem-token", Days: "30", encodedScope: "days=30"}
+	if annotateHistory(history, "", nil, false) != history {
+		t.Fatal("expected unannotated history to be returned as is")
+	}
+
+	redirect := &CoinRedirect{From: "matic-network", To: "polygon-ecosystem-token", Kind: CoinEventMigration, Ratio: 1}
+	annotated := annotateHistory(history, "", redirect, true)
+	if annotated.ID != "matic-network" || annotated.MigratedFrom != "matic-network" || annotated.MigratedTo != "polygon-ecosystem-token" ||
+		annotated.MigrationRatio != 1 || !annotated.Delisted {
+		t.Errorf("unexpected annotations: %+v", annotated)
+	}
+	if annotated.encodedScope == history.encodedScope || history.MigratedFrom != "" {
+		t.Error("expected annotation to copy history with a distinct encoded scope")
+	}
+}
+
+func TestFilterPricesKeepsRequestedIDs(t *testing.T) {
+	service := &Service{registry: writeCoinRegistry(t, []CoinLifecycleEntry{
+		{Provider: ProviderCoinGecko, ID: "matic-network", Kind: CoinEventMigration, SuccessorID: "polygon-ecosystem-token", Ratio: 1, EffectiveAt: time.Unix(0, 0)},
+	})}
+	top := map[string]PricePoint{
+		"polygon-ecosystem-token": {USD: 0.5},
+		"bitcoin":                 {USD: 60000},
+	}
+
+	filtered := service.filterPrices(ProviderCoinGecko, top, []string{"matic-network", "polygon-ecosystem-token", "bitcoin", "missing"})
+	if len(filtered) != 3 {
+		t.Fatalf("filtered = %+v, want matic-network, polygon-ecosystem-token and bitcoin", filtered)
+	}
+	matic := filtered["matic-network"]
+	if matic.USD != 0.5 || matic.MigratedFrom != "matic-network" || matic.MigratedTo != "polygon-ecosystem-token" {
+		t.Errorf("matic-network = %+v", matic)
+	}
+	if pol := filtered["polygon-ecosystem-token"]; pol.MigratedFrom != "" || pol.MigratedTo != "" {
+		t.Errorf("a directly requested successor should carry no hints: %+v", pol)
+	}
+}
+
+func TestHistoryArchiveRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history_archive.json")
+	history := &HistoryResponse{
+		ID:        "dead-coin",
+		Days:      "365",
+		Interval:  "daily",
+		Prices:    []HistoryPoint{{Timestamp: 1, Price: 0.5}},
+		UpdatedAt: time.Unix(100, 0),
+	}
+	if err := NewHistoryArchive(path).Set("dead-coin:365:daily", history); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	archived, found, err := NewHistoryArchive(path).Get("dead-coin:365:daily")
+	if err != nil || !found {
+		t.Fatalf("Get: found=%v err=%v", found, err)
+	}
+	if len(archived.Prices) != 1 || archived.Prices[0].Price != 0.5 || !archived.Cached {
+		t.Errorf("unexpected archived history: %+v", archived)
+	}
+}
+
+func writeCoinRegistry(t *testing.T, entries []CoinLifecycleEntry) *CoinRegistry {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "coin_lifecycle.json")
+	bytes, err := json.Marshal(coinLifecycleFile{Entries: entries})
+	if err != nil {
+		t.Fatalf("marshal registry: %v", err)
+	}
+	if err := os.WriteFile(path, bytes, 0o644); err != nil {
+		t.Fatalf("write registry: %v", err)
+	}
+	return NewCoinRegistry(path)
+}
@@ -18,9 +18,10 @@ type coinMetaFile struct {
 
 // MetaStore manages cached coin metadata stored on disk.
 type MetaStore struct {
-	mu   sync.RWMutex
-	path string
-	data *CoinMetaResponse
+	mu        sync.RWMutex
+	path      string
+	data      *CoinMetaResponse
+	listeners []func(*CoinMetaResponse)
 }
 
 // NewMetaStore creates a new metadata store using the given file path.
@@ -30,6 +31,29 @@ func NewMetaStore(path string) *MetaStore {
 	}
 }
 
+// OnUpdate registers fn to be called whenever metadata is loaded from disk or
+// refreshed. Listeners must not call back into the store.
+func (m *MetaStore) OnUpdate(fn func(*CoinMetaResponse)) {
+	if m == nil || fn == nil {
+		return
+	}
+
+	m.mu.Lock()
+	m.listeners = append(m.listeners, fn)
+	m.mu.Unlock()
+}
+
+func (m *MetaStore) notify(response *CoinMetaResponse) {
+	m.mu.RLock()
+	listeners := make([]func(*CoinMetaResponse), len(m.listeners))
+	copy(listeners, m.listeners)
+	m.mu.RUnlock()
+
+	for _, fn := range listeners {
+		fn(response)
+	}
+}
+
 // Get returns cached metadata if available and not stale.
 func (m *MetaStore) Get() (*CoinMetaResponse, bool, error) {
 	if m == nil {
@@ -53,9 +77,7 @@ func (m *MetaStore) Get() (*CoinMetaResponse, bool, error) {
 		return nil, false, err
 	}
 
-	m.mu.Lock()
-	m.data = data
-	m.mu.Unlock()
+	m.replace(data)
 
 	if isCoinMetaExpired(data.UpdatedAt) {
 		return nil, false, nil
@@ -66,6 +88,37 @@ func (m *MetaStore) Get() (*CoinMetaResponse, bool, error) {
 	return &cached, true, nil
 }
 
+// Latest returns the most recent metadata regardless of staleness, loading it
+// from disk if needed. It never triggers an upstream fetch.
+func (m *MetaStore) Latest() (*CoinMetaResponse, bool, error) {
+	if m == nil {
+		return nil, false, fmt.Errorf("meta store not configured")
+	}
+
+	m.mu.RLock()
+	if m.data != nil {
+		cached := *m.data
+		cached.Cached = true
+		m.mu.RUnlock()
+		return &cached, true, nil
+	}
+	m.mu.RUnlock()
+
+	data, err := m.loadFromFile()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	m.replace(data)
+
+	cached := *data
+	cached.Cached = true
+	return &cached, true, nil
+}
+
 // Set stores metadata on disk and updates the in-memory cache.
 func (m *MetaStore) Set(response *CoinMetaResponse) error {
 	if m == nil {
@@ -113,10 +166,27 @@ func (m *MetaStore) Set(response *CoinMetaResponse) error {
 	response.UpdatedAt = updatedAt
 	m.data = response
 	m.mu.Unlock()
+	m.notify(response)
 
 	return nil
 }
 
+// replace keeps metadata loaded from disk and notifies listeners, unless it
+// is the version already held: a stale file is re-read on every Get and
+// must not rebuild the indexes each time.
+func (m *MetaStore) replace(data *CoinMetaResponse) {
+	m.mu.Lock()
+	unchanged := m.data != nil && m.data.UpdatedAt.Equal(data.UpdatedAt)
+	if !unchanged {
+		m.data = data
+	}
+	m.mu.Unlock()
+
+	if !unchanged {
+		m.notify(data)
+	}
+}
+
 func (m *MetaStore) loadFromFile() (*CoinMetaResponse, error) {
 	bytes, err := os.ReadFile(m.path)
 	if err != nil {
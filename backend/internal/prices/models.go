@@ -16,10 +16,12 @@ type Coin struct {
 
 // CoinMeta represents static coin metadata used by the app.
 type CoinMeta struct {
-	ID     string `json:"id"`
-	Symbol string `json:"symbol"`
-	Name   string `json:"name"`
-	Image  string `json:"image"`
+	ID            string            `json:"id"`
+	Symbol        string            `json:"symbol"`
+	Name          string            `json:"name"`
+	Image         string            `json:"image"`
+	MarketCapRank int               `json:"market_cap_rank,omitempty"`
+	Contracts     []ContractAddress `json:"contracts,omitempty"`
 }
 
 // CoinsResponse is returned to mobile app
@@ -42,6 +44,12 @@ type CoinMetaResponse struct {
 type PricePoint struct {
 	USD           float64 `json:"usd"`
 	LastUpdatedAt int64   `json:"last_updated_at,omitempty"`
+	AssetID       string  `json:"asset_id,omitempty"`
+	// MigratedFrom is the requested id when it was redirected and
+	// MigratedTo the successor whose data is served under it.
+	MigratedFrom   string  `json:"migrated_from,omitempty"`
+	MigratedTo     string  `json:"migrated_to,omitempty"`
+	MigrationRatio float64 `json:"migration_ratio,omitempty"`
 }
 
 // LatestPricesResponse is returned to the mobile app for current prices.
@@ -50,6 +58,9 @@ type LatestPricesResponse struct {
 	Timestamp int64                 `json:"timestamp"`
 	Cached    bool                  `json:"cached"`
 	UpdatedAt time.Time             `json:"-"`
+
+	encoded      *encodedPayloads
+	encodedScope string
 }
 
 // HistoryPoint represents a price at a specific time.
@@ -61,12 +72,23 @@ type HistoryPoint struct {
 // HistoryResponse is returned to the mobile app for historical prices.
 type HistoryResponse struct {
 	ID        string         `json:"id"`
+	AssetID   string         `json:"asset_id,omitempty"`
 	Days      string         `json:"days"`
 	Interval  string         `json:"interval,omitempty"`
 	Prices    []HistoryPoint `json:"prices"`
 	Timestamp int64          `json:"timestamp"`
 	Cached    bool           `json:"cached"`
 	UpdatedAt time.Time      `json:"-"`
+	// MigratedFrom is the requested id when it was redirected and
+	// MigratedTo the successor whose history is served under it.
+	MigratedFrom   string  `json:"migrated_from,omitempty"`
+	MigratedTo     string  `json:"migrated_to,omitempty"`
+	MigrationRatio float64 `json:"migration_ratio,omitempty"`
+	// Delisted marks frozen history of a coin that no longer trades.
+	Delisted bool `json:"delisted,omitempty"`
+
+	encoded      *encodedPayloads
+	encodedScope string
 }
 
 // CoinGeckoMarketCoin represents a single coin from /coins/markets
@@ -81,7 +103,138 @@ type CoinGeckoMarketCoin struct {
 	PriceChangePercentage24h float64 `json:"price_change_percentage_24h"`
 }
 
+// CoinGeckoListCoin represents a single coin from /coins/list?include_platform=true.
+type CoinGeckoListCoin struct {
+	ID        string            `json:"id"`
+	Symbol    string            `json:"symbol"`
+	Name      string            `json:"name"`
+	Platforms map[string]string `json:"platforms"`
+}
+
 // CoinGeckoMarketChartResponse represents /coins/{id}/market_chart response.
 type CoinGeckoMarketChartResponse struct {
 	Prices [][]float64 `json:"prices"`
 }
+
+// ExpiresAt returns when the cached metadata becomes stale.
+func (r *CoinMetaResponse) ExpiresAt() time.Time {
+	return r.UpdatedAt.Add(coinMetaTTL)
+}
+
+// ExpiresAt returns when the cached latest prices become stale.
+func (r *LatestPricesResponse) ExpiresAt() time.Time {
+	return r.UpdatedAt.Add(latestPricesTTL)
+}
+
+// ExpiresAt returns when the cached history becomes stale.
+func (r *HistoryResponse) ExpiresAt() time.Time {
+	return r.UpdatedAt.Add(historyTTL(r.Days, r.Interval))
+}
+
+// CoinLinks groups external links for a coin.
+type CoinLinks struct {
+	Homepage   []string `json:"homepage,omitempty"`
+	Explorers  []string `json:"explorers,omitempty"`
+	SourceCode []string `json:"source_code,omitempty"`
+	Whitepaper string   `json:"whitepaper,omitempty"`
+	Twitter    string   `json:"twitter,omitempty"`
+	Reddit     string   `json:"reddit,omitempty"`
+}
+
+// CoinSupply holds supply figures; nil means unknown or uncapped.
+type CoinSupply struct {
+	Circulating *float64 `json:"circulating,omitempty"`
+	Total       *float64 `json:"total,omitempty"`
+	Max         *float64 `json:"max,omitempty"`
+}
+
+// PriceExtreme is an all-time high or low in USD.
+type PriceExtreme struct {
+	USD              float64 `json:"usd"`
+	Timestamp        int64   `json:"timestamp,omitempty"`
+	ChangePercentage float64 `json:"change_percentage,omitempty"`
+}
+
+// ContractAddress is a token contract on a specific chain.
+type ContractAddress struct {
+	Chain    string `json:"chain"`
+	Address  string `json:"address"`
+	Decimals int    `json:"decimals,omitempty"`
+}
+
+// CoinDetail is the extended coin information served by /coins/{id}.
+type CoinDetail struct {
+	ID                string            `json:"id"`
+	AssetID           string            `json:"asset_id,omitempty"`
+	CMCID             string            `json:"cmc_id,omitempty"`
+	CoinGeckoID       string            `json:"coingecko_id,omitempty"`
+	Symbol            string            `json:"symbol"`
+	Name              string            `json:"name"`
+	Image             string            `json:"image,omitempty"`
+	Description       string            `json:"description,omitempty"`
+	Links             CoinLinks         `json:"links"`
+	Categories        []string          `json:"categories,omitempty"`
+	MarketCapRank     int               `json:"market_cap_rank,omitempty"`
+	Supply            CoinSupply        `json:"supply"`
+	ATH               *PriceExtreme     `json:"ath,omitempty"`
+	ATL               *PriceExtreme     `json:"atl,omitempty"`
+	ContractAddresses []ContractAddress `json:"contract_addresses,omitempty"`
+	Source            string            `json:"source"`
+	MigratedFrom      string            `json:"migrated_from,omitempty"`
+	MigratedTo        string            `json:"migrated_to,omitempty"`
+	MigrationRatio    float64           `json:"migration_ratio,omitempty"`
+}
+
+// CoinDetailResponse is returned to the mobile app for coin details.
+type CoinDetailResponse struct {
+	Coin      CoinDetail `json:"coin"`
+	Timestamp int64      `json:"timestamp"`
+	Cached    bool       `json:"cached"`
+	UpdatedAt time.Time  `json:"-"`
+}
+
+// ExpiresAt returns when the cached coin detail becomes stale.
+func (r *CoinDetailResponse) ExpiresAt() time.Time {
+	return r.UpdatedAt.Add(coinDetailTTL)
+}
+
+// CoinGeckoCoinDetail represents the subset of /coins/{id} used for details.
+type CoinGeckoCoinDetail struct {
+	ID          string   `json:"id"`
+	Symbol      string   `json:"symbol"`
+	Name        string   `json:"name"`
+	Categories  []string `json:"categories"`
+	Description struct {
+		EN string `json:"en"`
+	} `json:"description"`
+	Links struct {
+		Homepage          []string `json:"homepage"`
+		Whitepaper        string   `json:"whitepaper"`
+		BlockchainSite    []string `json:"blockchain_site"`
+		TwitterScreenName string   `json:"twitter_screen_name"`
+		SubredditURL      string   `json:"subreddit_url"`
+		ReposURL          struct {
+			GitHub []string `json:"github"`
+		} `json:"repos_url"`
+	} `json:"links"`
+	Image struct {
+		Large string `json:"large"`
+	} `json:"image"`
+	MarketCapRank   int               `json:"market_cap_rank"`
+	Platforms       map[string]string `json:"platforms"`
+	DetailPlatforms map[string]struct {
+		DecimalPlace    *int   `json:"decimal_place"`
+		ContractAddress string `json:"contract_address"`
+	} `json:"detail_platforms"`
+	MarketData struct {
+		CirculatingSupply   *float64           `json:"circulating_supply"`
+		TotalSupply         *float64           `json:"total_supply"`
+		MaxSupply           *float64           `json:"max_supply"`
+		ATH                 map[string]float64 `json:"ath"`
+		ATHChangePercentage map[string]float64 `json:"ath_change_percentage"`
+		ATHDate             map[string]string  `json:"ath_date"`
+		ATL                 map[string]float64 `json:"atl"`
+		ATLChangePercentage map[string]float64 `json:"atl_change_percentage"`
+		ATLDate             map[string]string  `json:"atl_date"`
+	} `json:"market_data"`
+}
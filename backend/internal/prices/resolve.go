@@ -0,0 +1,229 @@
+package prices
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+const maxResolveSymbols = 200
+
+// SymbolCandidate is one coin that trades under a requested symbol.
+type SymbolCandidate struct {
+	CMCID         string `json:"cmc_id,omitempty"`
+	CoinGeckoID   string `json:"coingecko_id,omitempty"`
+	Symbol        string `json:"symbol"`
+	Name          string `json:"name"`
+	MarketCapRank int    `json:"market_cap_rank,omitempty"`
+}
+
+// SymbolResolution is the canonical coin for a symbol plus any alternatives
+// sharing the same ticker.
+type SymbolResolution struct {
+	Symbol        string            `json:"symbol"`
+	AssetID       string            `json:"asset_id,omitempty"`
+	CMCID         string            `json:"cmc_id,omitempty"`
+	CoinGeckoID   string            `json:"coingecko_id,omitempty"`
+	Name          string            `json:"name"`
+	MarketCapRank int               `json:"market_cap_rank,omitempty"`
+	Ambiguous     bool              `json:"ambiguous"`
+	Candidates    []SymbolCandidate `json:"candidates,omitempty"`
+}
+
+// SymbolResolveResponse is returned to the mobile app for /coins/resolve.
+type SymbolResolveResponse struct {
+	Resolved   map[string]SymbolResolution `json:"resolved"`
+	Unresolved []string                    `json:"unresolved,omitempty"`
+	Timestamp  int64                       `json:"timestamp"`
+	// SourcesUpdatedAt is when the caches behind the resolutions last
+	// changed; it is part of the cache validator, not the body.
+	SourcesUpdatedAt time.Time `json:"-"`
+}
+
+// ResolveSymbols maps tickers to canonical CMC and CoinGecko ids using the
+// CMC metadata, the CMC->CoinGecko mapping and CoinGecko metadata.
+func (s *Service) ResolveSymbols(symbols []string) (*SymbolResolveResponse, error) {
+	if len(symbols) == 0 {
+		return nil, fmt.Errorf("%w: symbols cannot be empty", ErrInvalidRequest)
+	}
+	if len(symbols) > maxResolveSymbols {
+		return nil, fmt.Errorf("%w: at most %d symbols are allowed", ErrInvalidRequest, maxResolveSymbols)
+	}
+
+	cmcMeta, err := s.GetCMCCoinMeta()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load CMC metadata: %w", err)
+	}
+
+	var cgCoins []CoinMeta
+	if cgMeta, found, err := s.metaStore.Latest(); err != nil {
+		log.Printf("Failed to load coin metadata for symbol resolution: %v", err)
+	} else if found {
+		cgCoins = cgMeta.Coins
+	}
+
+	entries, _, err := s.mappingEntries()
+	if err != nil {
+		log.Printf("Failed to load CMC mapping for symbol resolution: %v", err)
+	}
+
+	resolved, unresolved := resolveSymbols(symbols, cmcMeta.Coins, cgCoins, entries)
+	assets := s.syncedAssets()
+	for symbol, resolution := range resolved {
+		asset, found := assets.ByCMC(resolution.CMCID)
+		if !found {
+			asset, found = assets.ByCoinGecko(resolution.CoinGeckoID)
+		}
+		if found {
+			resolution.AssetID = asset.ID
+			resolved[symbol] = resolution
+		}
+	}
+	return &SymbolResolveResponse{
+		Resolved:         resolved,
+		Unresolved:       unresolved,
+		Timestamp:        time.Now().UnixMilli(),
+		SourcesUpdatedAt: s.lookupsUpdatedAt(),
+	}, nil
+}
+
+func resolveSymbols(symbols []string, cmcCoins, cgCoins []CoinMeta, entries []CMCMappingEntry) (map[string]SymbolResolution, []string) {
+	cmcBySymbol := groupCoinsBySymbol(cmcCoins)
+	cgBySymbol := groupCoinsBySymbol(cgCoins)
+
+	cgByID := make(map[string]CoinMeta, len(cgCoins))
+	for _, coins := range cgBySymbol {
+		for _, coin := range coins {
+			cgByID[coin.ID] = coin
+		}
+	}
+
+	mapping := make(map[string]CMCMappingEntry, len(entries))
+	for _, entry := range entries {
+		mapping[entry.CMCID] = entry
+	}
+
+	resolved := make(map[string]SymbolResolution)
+	var unresolved []string
+
+	for _, raw := range symbols {
+		symbol := strings.ToUpper(strings.TrimSpace(raw))
+		if symbol == "" {
+			continue
+		}
+		if _, done := resolved[symbol]; done {
+			continue
+		}
+
+		var candidates []SymbolCandidate
+		usedCG := make(map[string]struct{})
+
+		for _, coin := range cmcBySymbol[symbol] {
+			candidate := SymbolCandidate{
+				CMCID:         coin.ID,
+				Symbol:        symbol,
+				Name:          coin.Name,
+				MarketCapRank: coin.MarketCapRank,
+			}
+			if entry, found := mapping[coin.ID]; found && entry.CoinGeckoID != "" {
+				candidate.CoinGeckoID = entry.CoinGeckoID
+			} else {
+				// Unmapped CMC coin: link a CoinGecko coin with the same name.
+				for _, cgCoin := range cgBySymbol[symbol] {
+					if _, used := usedCG[cgCoin.ID]; !used && normalizeName(cgCoin.Name) == normalizeName(coin.Name) {
+						candidate.CoinGeckoID = cgCoin.ID
+						break
+					}
+				}
+			}
+			if candidate.CoinGeckoID != "" {
+				usedCG[candidate.CoinGeckoID] = struct{}{}
+			}
+			candidates = append(candidates, candidate)
+
+			if entry, found := mapping[coin.ID]; found {
+				for _, alternative := range entry.Candidates {
+					if _, used := usedCG[alternative]; used {
+						continue
+					}
+					usedCG[alternative] = struct{}{}
+					candidates = append(candidates, SymbolCandidate{
+						CoinGeckoID:   alternative,
+						Symbol:        symbol,
+						Name:          cgByID[alternative].Name,
+						MarketCapRank: cgByID[alternative].MarketCapRank,
+					})
+				}
+			}
+		}
+
+		for _, coin := range cgBySymbol[symbol] {
+			if _, used := usedCG[coin.ID]; used {
+				continue
+			}
+			usedCG[coin.ID] = struct{}{}
+			candidates = append(candidates, SymbolCandidate{
+				CoinGeckoID:   coin.ID,
+				Symbol:        symbol,
+				Name:          coin.Name,
+				MarketCapRank: coin.MarketCapRank,
+			})
+		}
+
+		if len(candidates) == 0 {
+			unresolved = append(unresolved, symbol)
+			continue
+		}
+
+		// CMC-listed coins come first (the app prices from CMC), then by rank.
+		sort.SliceStable(candidates, func(i, j int) bool {
+			if (candidates[i].CMCID != "") != (candidates[j].CMCID != "") {
+				return candidates[i].CMCID != ""
+			}
+			return rankLess(candidates[i].MarketCapRank, candidates[j].MarketCapRank)
+		})
+
+		best := candidates[0]
+		resolved[symbol] = SymbolResolution{
+			Symbol:        symbol,
+			CMCID:         best.CMCID,
+			CoinGeckoID:   best.CoinGeckoID,
+			Name:          best.Name,
+			MarketCapRank: best.MarketCapRank,
+			Ambiguous:     len(candidates) > 1,
+			Candidates:    candidates[1:],
+		}
+	}
+
+	return resolved, unresolved
+}
+
+// groupCoinsBySymbol groups coins by uppercase symbol, filling missing ranks
+// from list position since metadata is fetched in market-cap order.
+func groupCoinsBySymbol(coins []CoinMeta) map[string][]CoinMeta {
+	grouped := make(map[string][]CoinMeta, len(coins))
+	for position, coin := range coins {
+		symbol := strings.ToUpper(strings.TrimSpace(coin.Symbol))
+		if symbol == "" || coin.ID == "" {
+			continue
+		}
+		if coin.MarketCapRank <= 0 {
+			coin.MarketCapRank = position + 1
+		}
+		grouped[symbol] = append(grouped[symbol], coin)
+	}
+	return grouped
+}
+
+// rankLess orders known ranks before unknown (zero) ranks.
+func rankLess(a, b int) bool {
+	if a <= 0 {
+		return false
+	}
+	if b <= 0 {
+		return true
+	}
+	return a < b
+}
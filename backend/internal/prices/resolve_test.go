@@ -0,0 +1,45 @@
+package prices
+
+import "testing"
+
+func TestResolveSymbols(t *testing.T) {
+	cmcCoins := []CoinMeta{
+		{ID: "1", Symbol: "BTC", Name: "Bitcoin"},
+		{ID: "1027", Symbol: "ETH", Name: "Ethereum"},
+		{ID: "7083", Symbol: "UNI", Name: "Uniswap"},
+	}
+	cgCoins := []CoinMeta{
+		{ID: "bitcoin", Symbol: "BTC", Name: "Bitcoin", MarketCapRank: 1},
+		{ID: "ethereum", Symbol: "ETH", Name: "Ethereum", MarketCapRank: 2},
+		{ID: "uniswap", Symbol: "UNI", Name: "Uniswap", MarketCapRank: 20},
+		{ID: "universe-token", Symbol: "UNI", Name: "Universe", MarketCapRank: 900},
+	}
+	entries := []CMCMappingEntry{
+		{CMCID: "1", Symbol: "BTC", CoinGeckoID: "bitcoin"},
+		{CMCID: "7083", Symbol: "UNI", CoinGeckoID: "uniswap", Candidates: []string{"universe-token"}},
+	}
+
+	resolved, unresolved := resolveSymbols([]string{"btc", "ETH", "uni", "nope", "BTC"}, cmcCoins, cgCoins, entries)
+
+	if len(unresolved) != 1 || unresolved[0] != "NOPE" {
+		t.Fatalf("expected NOPE to be unresolved, got %v", unresolved)
+	}
+
+	btc := resolved["BTC"]
+	if btc.CMCID != "1" || btc.CoinGeckoID != "bitcoin" || btc.Ambiguous {
+		t.Fatalf("unexpected BTC resolution: %+v", btc)
+	}
+
+	eth := resolved["ETH"]
+	if eth.CMCID != "1027" || eth.CoinGeckoID != "ethereum" || eth.Ambiguous {
+		t.Fatalf("expected unmapped ETH to link the CoinGecko coin by name, got %+v", eth)
+	}
+
+	uni := resolved["UNI"]
+	if uni.CMCID != "7083" || uni.CoinGeckoID != "uniswap" || !uni.Ambiguous {
+		t.Fatalf("unexpected UNI resolution: %+v", uni)
+	}
+	if len(uni.Candidates) != 1 || uni.Candidates[0].CoinGeckoID != "universe-token" || uni.Candidates[0].Name != "Universe" {
+		t.Fatalf("expected universe-token as the only alternative, got %+v", uni.Candidates)
+	}
+}
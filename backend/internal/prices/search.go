@@ -0,0 +1,253 @@
+package prices
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+const (
+	ProviderCMC       = "cmc"
+	ProviderCoinGecko = "coingecko"
+)
+
+// Match scores, highest first. Within a score results are ordered by
+// market-cap rank.
+const (
+	scoreExactSymbol = 100
+	scoreExactID     = 95
+	scoreExactName   = 90
+	scorePrefixSym   = 80
+	scorePrefixName  = 70
+	scorePrefixID    = 65
+	scoreWordPrefix  = 60
+	scoreSubstring   = 50
+	scoreFuzzy       = 30
+)
+
+// CoinSearchResult is a single match returned by /coins/search.
+type CoinSearchResult struct {
+	Provider      string `json:"provider"`
+	ID            string `json:"id"`
+	AssetID       string `json:"asset_id,omitempty"`
+	Symbol        string `json:"symbol"`
+	Name          string `json:"name"`
+	Image         string `json:"image"`
+	MarketCapRank int    `json:"market_cap_rank,omitempty"`
+	Score         int    `json:"score"`
+}
+
+// CoinSearchResponse is returned to the mobile app for coin searches.
+type CoinSearchResponse struct {
+	Query     string             `json:"query"`
+	Results   []CoinSearchResult `json:"results"`
+	Timestamp int64              `json:"timestamp"`
+	// SourcesUpdatedAt is when the caches behind the results last
+	// changed; it is part of the cache validator, not the body.
+	SourcesUpdatedAt time.Time `json:"-"`
+}
+
+type searchEntry struct {
+	provider string
+	meta     CoinMeta
+	rank     int
+	id       string
+	symbol   string
+	name     string
+	words    []string
+}
+
+// SearchIndex is an in-memory index over CMC and CoinGecko metadata. Each
+// provider's entries are replaced whenever its MetaStore refreshes.
+type SearchIndex struct {
+	mu         sync.RWMutex
+	byProvider map[string][]searchEntry
+	updatedAt  time.Time
+}
+
+// NewSearchIndex creates an empty search index.
+func NewSearchIndex() *SearchIndex {
+	return &SearchIndex{
+		byProvider: make(map[string][]searchEntry),
+	}
+}
+
+// Update rebuilds the entries for a provider from its metadata.
+func (i *SearchIndex) Update(provider string, coins []CoinMeta) {
+	entries := make([]searchEntry, 0, len(coins))
+	for position, coin := range coins {
+		if coin.ID == "" {
+			continue
+		}
+
+		rank := coin.MarketCapRank
+		if rank <= 0 {
+			// Metadata lists are fetched in market-cap order.
+			rank = position + 1
+		}
+
+		name := strings.ToLower(strings.TrimSpace(coin.Name))
+		entries = append(entries, searchEntry{
+			provider: provider,
+			meta:     coin,
+			rank:     rank,
+			id:       strings.ToLower(coin.ID),
+			symbol:   strings.ToLower(strings.TrimSpace(coin.Symbol)),
+			name:     name,
+			words:    strings.FieldsFunc(name, isSearchSeparator),
+		})
+	}
+
+	i.mu.Lock()
+	i.byProvider[provider] = entries
+	i.updatedAt = time.Now()
+	i.mu.Unlock()
+}
+
+// Len returns the number of indexed entries for a provider.
+func (i *SearchIndex) Len(provider string) int {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return len(i.byProvider[provider])
+}
+
+// Search returns up to limit matches for query ordered by match quality and
+// market-cap rank.
+func (i *SearchIndex) Search(query string, limit int) []CoinSearchResult {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return nil
+	}
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+
+	i.mu.RLock()
+	results := make([]CoinSearchResult, 0, limit)
+	for _, entries := range i.byProvider {
+		for _, entry := range entries {
+			score := scoreSearchEntry(entry, query)
+			if score == 0 {
+				continue
+			}
+			results = append(results, CoinSearchResult{
+				Provider:      entry.provider,
+				ID:            entry.meta.ID,
+				Symbol:        entry.meta.Symbol,
+				Name:          entry.meta.Name,
+				Image:         entry.meta.Image,
+				MarketCapRank: entry.rank,
+				Score:         score,
+			})
+		}
+	}
+	i.mu.RUnlock()
+
+	sort.SliceStable(results, func(a, b int) bool {
+		if results[a].Score != results[b].Score {
+			return results[a].Score > results[b].Score
+		}
+		if results[a].MarketCapRank != results[b].MarketCapRank {
+			return results[a].MarketCapRank < results[b].MarketCapRank
+		}
+		if results[a].Provider != results[b].Provider {
+			return results[a].Provider < results[b].Provider
+		}
+		return results[a].ID < results[b].ID
+	})
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+func scoreSearchEntry(entry searchEntry, query string) int {
+	switch {
+	case entry.symbol == query:
+		return scoreExactSymbol
+	case entry.id == query:
+		return scoreExactID
+	case entry.name == query:
+		return scoreExactName
+	case strings.HasPrefix(entry.symbol, query):
+		return scorePrefixSym
+	case strings.HasPrefix(entry.name, query):
+		return scorePrefixName
+	case strings.HasPrefix(entry.id, query):
+		return scorePrefixID
+	}
+
+	for _, word := range entry.words {
+		if strings.HasPrefix(word, query) {
+			return scoreWordPrefix
+		}
+	}
+
+	if len(query) >= 3 && (strings.Contains(entry.name, query) || strings.Contains(entry.id, query)) {
+		return scoreSubstring
+	}
+
+	if len(query) >= 3 {
+		maxDistance := 1
+		if len(query) > 5 {
+			maxDistance = 2
+		}
+		if withinDistance(entry.symbol, query, maxDistance) || withinDistance(entry.name, query, maxDistance) {
+			return scoreFuzzy
+		}
+		for _, word := range entry.words {
+			if withinDistance(word, query, maxDistance) {
+				return scoreFuzzy
+			}
+		}
+	}
+
+	return 0
+}
+
+// withinDistance reports whether the Levenshtein distance between a and b is
+// at most max.
+func withinDistance(a, b string, max int) bool {
+	if diff := len(a) - len(b); diff > max || -diff > max {
+		return false
+	}
+
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		rowMin := current[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+			rowMin = min(rowMin, current[j])
+		}
+		if rowMin > max {
+			return false
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(b)] <= max
+}
+
+func isSearchSeparator(r rune) bool {
+	return r == ' ' || r == '-' || r == '_' || r == '.' || r == '(' || r == ')'
+}
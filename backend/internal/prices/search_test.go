@@ -0,0 +1,92 @@
+package prices
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSearchIndexRanking(t *testing.T) {
+	index := NewSearchIndex()
+	index.Update(ProviderCMC, []CoinMeta{
+		{ID: "1", Symbol: "BTC", Name: "Bitcoin"},
+		{ID: "1027", Symbol: "ETH", Name: "Ethereum"},
+		{ID: "1321", Symbol: "ETC", Name: "Ethereum Classic"},
+		{ID: "8000", Symbol: "LDO", Name: "Lido DAO"},
+	})
+	index.Update(ProviderCoinGecko, []CoinMeta{
+		{ID: "ethereum", Symbol: "ETH", Name: "Ethereum", MarketCapRank: 2},
+	})
+
+	results := index.Search("eth", 10)
+	if len(results) < 3 {
+		t.Fatalf("expected at least 3 results, got %d", len(results))
+	}
+	if results[0].Symbol != "ETH" || results[1].Symbol != "ETH" {
+		t.Fatalf("expected exact symbol matches first, got %+v", results[:2])
+	}
+	if results[2].Symbol != "ETC" {
+		t.Fatalf("expected name prefix match next, got %+v", results[2])
+	}
+
+	if results := index.Search("etherum", 10); len(results) == 0 || results[0].Name != "Ethereum" {
+		t.Fatalf("expected fuzzy match for misspelled name, got %+v", results)
+	}
+
+	if results := index.Search("dao", 10); len(results) != 1 || results[0].ID != "8000" {
+		t.Fatalf("expected word prefix match, got %+v", results)
+	}
+}
+
+func TestSearchIndexRebuildsOnMetaStoreUpdate(t *testing.T) {
+	store := NewMetaStore(t.TempDir() + "/meta.json")
+	index := NewSearchIndex()
+	store.OnUpdate(func(meta *CoinMetaResponse) {
+		index.Update(ProviderCoinGecko, meta.Coins)
+	})
+
+	if err := store.Set(&CoinMetaResponse{Coins: []CoinMeta{{ID: "solana", Symbol: "SOL", Name: "Solana"}}}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	if results := index.Search("sol", 5); len(results) != 1 || results[0].ID != "solana" {
+		t.Fatalf("expected index to be rebuilt after Set, got %+v", results)
+	}
+}
+
+func TestMetaStoreNotifiesOnlyOnChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "meta.json")
+	stale, err := json.Marshal(coinMetaFile{
+		UpdatedAt: time.Now().Add(-2 * coinMetaTTL),
+		Coins:     []CoinMeta{{ID: "solana", Symbol: "SOL", Name: "Solana"}},
+	})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if err := os.WriteFile(path, stale, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	store := NewMetaStore(path)
+	updates := 0
+	store.OnUpdate(func(*CoinMetaResponse) { updates++ })
+
+	// A stale file is re-read on every Get but only announced once.
+	for range 3 {
+		if _, found, err := store.Get(); err != nil || found {
+			t.Fatalf("Get = %v, %v; want a miss for stale metadata", found, err)
+		}
+	}
+	if updates != 1 {
+		t.Errorf("updates after reloading stale metadata = %d, want 1", updates)
+	}
+
+	if err := store.Set(&CoinMetaResponse{Coins: []CoinMeta{{ID: "solana", Symbol: "SOL", Name: "Solana"}}}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if updates != 2 {
+		t.Errorf("updates after Set = %d, want 2", updates)
+	}
+}
@@ -1,8 +1,10 @@
 package prices
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"sort"
 	"strconv"
 	"strings"
@@ -14,21 +16,56 @@ import (
 
 // Service handles price fetching with caching
 type Service struct {
-	client       *CoinGeckoClient
-	cmcClient    *CoinMarketCapClient
-	cache        *Cache
-	historyStore *HistoryStore
-	metaStore    *MetaStore
-	cmcMetaStore *MetaStore
-	cmcMapStore  *CMCMapStore
-	group        singleflight.Group
+	client            *CoinGeckoClient
+	cmcClient         *CoinMarketCapClient
+	cache             *Cache
+	historyStore      *HistoryStore
+	metaStore         *MetaStore
+	cmcMetaStore      *MetaStore
+	cmcMapStore       *CMCMapStore
+	overrides         *MappingOverrideStore
+	contractMetaStore *MetaStore
+	contractIndex     *ContractIndex
+	detailStore       *CoinDetailStore
+	imageStore        *CoinImageStore
+	imageClient       *http.Client
+	registry          *CoinRegistry
+	assets            *AssetRegistry
+	historyArchive    *HistoryArchive
+	stream            *PriceStream
+	searchIndex       *SearchIndex
+	group             singleflight.Group
+	// mappingMu serializes writes of the stored CMC mapping by the initial
+	// build and by refreshes.
+	mappingMu sync.Mutex
 }
 
+// ErrUnknownCoin is returned for ids missing from every metadata source.
+var ErrUnknownCoin = errors.New("unknown coin id")
+
+// ErrInvalidRequest is wrapped by errors caused by the request itself.
+var ErrInvalidRequest = errors.New("invalid request")
+
 const topPricesCacheKey = "top_prices"
 const cmcTopPricesCacheKey = "cmc_top_prices"
 
+// Config locates the files and directories the service persists to.
+type Config struct {
+	MetaPath         string
+	CMCMetaPath      string
+	CMCMapPath       string
+	CMCOverridesPath string
+	ContractsPath    string
+	CoinDetailPath   string
+	ImageDir         string
+	LifecyclePath    string
+	// HistoryArchivePath keeps frozen history of delisted coins.
+	HistoryArchivePath string
+	AssetsPath         string
+}
+
 // NewService creates a new price service
-func NewService(metaPath, cmcMetaPath, cmcMapPath string) *Service {
+func NewService(config Config) *Service {
 	historyStore, err := NewHistoryStoreFromEnv()
 	if err != nil {
 		log.Printf("Failed to initialize Turso history store: %v", err)
@@ -37,15 +74,48 @@ func NewService(metaPath, cmcMetaPath, cmcMapPath string) *Service {
 		log.Printf("Turso history store enabled")
 	}
 
-	return &Service{
-		client:       NewCoinGeckoClient(),
-		cmcClient:    NewCoinMarketCapClient(),
-		cache:        NewCache(),
-		historyStore: historyStore,
-		metaStore:    NewMetaStore(metaPath),
-		cmcMetaStore: NewMetaStore(cmcMetaPath),
-		cmcMapStore:  NewCMCMapStore(cmcMapPath),
-	}
+	service := &Service{
+		client:            NewCoinGeckoClient(),
+		cmcClient:         NewCoinMarketCapClient(),
+		cache:             NewCache(),
+		historyStore:      historyStore,
+		metaStore:         NewMetaStore(config.MetaPath),
+		cmcMetaStore:      NewMetaStore(config.CMCMetaPath),
+		cmcMapStore:       NewCMCMapStore(config.CMCMapPath),
+		overrides:         NewMappingOverrideStore(config.CMCOverridesPath),
+		contractMetaStore: NewMetaStore(config.ContractsPath),
+		contractIndex:     NewContractIndex(),
+		detailStore:       NewCoinDetailStore(config.CoinDetailPath),
+		imageStore:        NewCoinImageStore(config.ImageDir),
+		imageClient:       newImageHTTPClient(),
+		registry:          NewCoinRegistry(config.LifecyclePath),
+		assets:            NewAssetRegistry(config.AssetsPath),
+		historyArchive:    NewHistoryArchive(config.HistoryArchivePath),
+		stream:            NewPriceStream(),
+		searchIndex:       NewSearchIndex(),
+	}
+
+	service.metaStore.OnUpdate(func(meta *CoinMetaResponse) {
+		service.searchIndex.Update(ProviderCoinGecko, meta.Coins)
+	})
+	service.cmcMetaStore.OnUpdate(func(meta *CoinMetaResponse) {
+		service.searchIndex.Update(ProviderCMC, meta.Coins)
+		service.contractIndex.Update(ProviderCMC, meta.Coins)
+	})
+	service.contractMetaStore.OnUpdate(func(meta *CoinMetaResponse) {
+		service.contractIndex.Update(ProviderCoinGecko, meta.Coins)
+	})
+	service.cmcMapStore.SetOverlay(func(entries []CMCMappingEntry) ([]CMCMappingEntry, time.Time, error) {
+		applied, err := service.overrides.Apply(entries)
+		return applied, service.overrides.UpdatedAt(), err
+	})
+
+	return service
+}
+
+// PriceStream returns the stream that receives CMC latest price diffs.
+func (s *Service) PriceStream() *PriceStream {
+	return s.stream
 }
 
 // GetCoinMeta returns cached coin metadata or refreshes it if stale.
@@ -75,10 +145,11 @@ func (s *Service) GetCoinMeta() (*CoinMetaResponse, error) {
 
 			for _, coin := range coins.Coins {
 				meta = append(meta, CoinMeta{
-					ID:     coin.ID,
-					Symbol: coin.Symbol,
-					Name:   coin.Name,
-					Image:  coin.Image,
+					ID:            coin.ID,
+					Symbol:        coin.Symbol,
+					Name:          coin.Name,
+					Image:         coin.Image,
+					MarketCapRank: coin.MarketCapRank,
 				})
 			}
 		}
@@ -140,6 +211,9 @@ func (s *Service) GetCMCCoinMeta() (*CoinMetaResponse, error) {
 			return nil, fmt.Errorf("failed to store CMC coin metadata: %w", err)
 		}
 
+		// The top list may have changed; remap and prewarm newcomers.
+		go s.refreshCMCMappingAndPrewarm(response.Coins)
+
 		return response, nil
 	})
 
@@ -154,6 +228,185 @@ func (s *Service) GetCMCCoinMeta() (*CoinMetaResponse, error) {
 	return result.(*CoinMetaResponse), nil
 }
 
+// GetCoinDetail returns extended information for a CoinGecko id or a numeric
+// CMC id. CoinGecko is preferred because it carries ATH/ATL data; CMC info is
+// used when the coin has no CoinGecko mapping or CoinGecko fails.
+func (s *Service) GetCoinDetail(id string) (*CoinDetailResponse, error) {
+	id = strings.ToLower(strings.TrimSpace(id))
+	if id == "" {
+		return nil, fmt.Errorf("id cannot be empty")
+	}
+
+	id, redirect := s.resolveCoinID(s.coinID(id))
+	if err := s.checkKnownCoin(id); err != nil {
+		return nil, err
+	}
+
+	result, err, shared := s.group.Do("coin_detail:"+id, func() (interface{}, error) {
+		if cached, found, err := s.detailStore.Get(id); err != nil {
+			return nil, err
+		} else if found {
+			log.Printf("Cache hit for coin detail (%s)", id)
+			return cached, nil
+		}
+
+		cmcID := ""
+		cgID := id
+		if _, err := strconv.Atoi(id); err == nil {
+			cmcID = id
+			cgID = ""
+			if mappedID, err := s.ResolveCMCID(id); err == nil {
+				cgID = mappedID
+			}
+		}
+
+		var detail *CoinDetail
+		var fetchErr error
+		if cgID != "" {
+			log.Printf("Cache miss for coin detail (%s), fetching from CoinGecko", id)
+			detail, fetchErr = s.client.GetCoinDetail(cgID)
+		}
+		if detail == nil && cmcID != "" {
+			if fetchErr != nil {
+				log.Printf("CoinGecko detail failed for %s, falling back to CMC: %v", id, fetchErr)
+			}
+			log.Printf("Cache miss for coin detail (%s), fetching from CoinMarketCap", id)
+			detail, fetchErr = s.cmcClient.GetCoinInfo(cmcID)
+		}
+		if fetchErr != nil {
+			return nil, fmt.Errorf("failed to fetch coin detail: %w", fetchErr)
+		}
+
+		detail.ID = id
+		if cmcID != "" {
+			detail.CMCID = cmcID
+		}
+		if cgID != "" {
+			detail.CoinGeckoID = cgID
+		}
+
+		response := &CoinDetailResponse{
+			Coin:      *detail,
+			Timestamp: time.Now().UnixMilli(),
+			Cached:    false,
+			UpdatedAt: time.Now(),
+		}
+
+		if err := s.detailStore.Set(id, response); err != nil {
+			log.Printf("Failed to store coin detail (%s): %v", id, err)
+		}
+
+		return response, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if shared {
+		log.Printf("Shared coin detail singleflight result (%s)", id)
+	}
+
+	annotated := *result.(*CoinDetailResponse)
+	annotated.Coin.AssetID = s.AssetID(id)
+	if redirect != nil {
+		annotated.Coin.ID = redirect.From
+		annotated.Coin.MigratedFrom = redirect.From
+		annotated.Coin.MigratedTo = id
+		annotated.Coin.MigrationRatio = redirect.Ratio
+	}
+	return &annotated, nil
+}
+
+// checkKnownCoin returns ErrUnknownCoin unless id appears in CoinGecko or
+// CMC metadata or the CMC mapping, so arbitrary ids never reach upstream or
+// the on-disk caches. Only data already loaded or on disk is consulted.
+func (s *Service) checkKnownCoin(id string) error {
+	available := false
+	for _, store := range []*MetaStore{s.metaStore, s.cmcMetaStore, s.contractMetaStore} {
+		meta, found, err := store.Latest()
+		if err != nil || !found {
+			continue
+		}
+		available = true
+		for _, coin := range meta.Coins {
+			if strings.EqualFold(coin.ID, id) {
+				return nil
+			}
+		}
+	}
+
+	if index, found, err := s.cmcMapStore.Index(); err == nil && found {
+		available = true
+		if _, found := index.Entry(id); found {
+			return nil
+		}
+		if len(index.CMCIDs(id)) > 0 {
+			return nil
+		}
+	}
+
+	if !available {
+		return fmt.Errorf("coin metadata not available")
+	}
+	return fmt.Errorf("%w: %s", ErrUnknownCoin, id)
+}
+
+// SearchCoins searches CMC and CoinGecko metadata by symbol, name and id.
+func (s *Service) SearchCoins(query string, limit int) (*CoinSearchResponse, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, fmt.Errorf("query cannot be empty")
+	}
+
+	// Load metadata from disk on first use; stale files are still indexed so
+	// search never triggers an upstream fetch.
+	if s.searchIndex.Len(ProviderCMC) == 0 {
+		if _, _, err := s.cmcMetaStore.Get(); err != nil {
+			log.Printf("Failed to load CMC metadata for search: %v", err)
+		}
+	}
+	if s.searchIndex.Len(ProviderCoinGecko) == 0 {
+		if _, _, err := s.metaStore.Get(); err != nil {
+			log.Printf("Failed to load coin metadata for search: %v", err)
+		}
+	}
+
+	results := s.searchIndex.Search(query, limit)
+	assets := s.syncedAssets()
+	for i, result := range results {
+		if result.Provider == ProviderCMC {
+			results[i].AssetID = assetIDOf(assets.ByCMC(result.ID))
+		} else {
+			results[i].AssetID = assetIDOf(assets.ByCoinGecko(result.ID))
+		}
+	}
+
+	return &CoinSearchResponse{
+		Query:            strings.TrimSpace(query),
+		Results:          results,
+		Timestamp:        time.Now().UnixMilli(),
+		SourcesUpdatedAt: s.lookupsUpdatedAt(),
+	}, nil
+}
+
+// lookupsUpdatedAt returns when the newest of the caches read by search,
+// symbol resolution and contract lookups last changed.
+func (s *Service) lookupsUpdatedAt() time.Time {
+	latest := s.assets.UpdatedAt()
+	for _, store := range []*MetaStore{s.metaStore, s.cmcMetaStore, s.contractMetaStore} {
+		if store == nil {
+			continue
+		}
+		if meta, found, err := store.Latest(); err == nil && found && meta.UpdatedAt.After(latest) {
+			latest = meta.UpdatedAt
+		}
+	}
+	if index, found, err := s.cmcMapStore.Index(); err == nil && found && index.UpdatedAt().After(latest) {
+		latest = index.UpdatedAt()
+	}
+	return latest
+}
+
 // GetLatestPrices fetches current prices for specific CoinGecko IDs.
 func (s *Service) GetLatestPrices(ids []string) (*LatestPricesResponse, error) {
 	top, err := s.getTopPrices()
@@ -165,19 +418,16 @@ func (s *Service) GetLatestPrices(ids []string) (*LatestPricesResponse, error) {
 		return top, nil
 	}
 
-	_, normalized := normalizeIDs(ids)
-	filtered := make(map[string]PricePoint, len(normalized))
-	for _, id := range normalized {
-		if price, found := top.Prices[id]; found {
-			filtered[id] = price
-		}
-	}
+	scope, normalized := normalizeIDs(ids)
+	filtered := s.filterPrices(ProviderCoinGecko, top.Prices, normalized)
 
 	return &LatestPricesResponse{
-		Prices:    filtered,
-		Timestamp: top.Timestamp,
-		Cached:    top.Cached,
-		UpdatedAt: top.UpdatedAt,
+		Prices:       filtered,
+		Timestamp:    top.Timestamp,
+		Cached:       top.Cached,
+		UpdatedAt:    top.UpdatedAt,
+		encoded:      top.encoded,
+		encodedScope: "ids=" + scope,
 	}, nil
 }
 
@@ -192,19 +442,16 @@ func (s *Service) GetCMCLatestPrices(ids []string) (*LatestPricesResponse, error
 		return top, nil
 	}
 
-	_, normalized := normalizeIDs(ids)
-	filtered := make(map[string]PricePoint, len(normalized))
-	for _, id := range normalized {
-		if price, found := top.Prices[id]; found {
-			filtered[id] = price
-		}
-	}
+	scope, normalized := normalizeIDs(ids)
+	filtered := s.filterPrices(ProviderCMC, top.Prices, normalized)
 
 	return &LatestPricesResponse{
-		Prices:    filtered,
-		Timestamp: top.Timestamp,
-		Cached:    top.Cached,
-		UpdatedAt: top.UpdatedAt,
+		Prices:       filtered,
+		Timestamp:    top.Timestamp,
+		Cached:       top.Cached,
+		UpdatedAt:    top.UpdatedAt,
+		encoded:      top.encoded,
+		encodedScope: "ids=" + scope,
 	}, nil
 }
 
@@ -217,6 +464,10 @@ func (s *Service) GetHistory(id, days, interval string) (*HistoryResponse, error
 		return nil, fmt.Errorf("days cannot be empty")
 	}
 
+	id, redirect := s.registry.Resolve(ProviderCoinGecko, s.providerID(ProviderCoinGecko, id), time.Now())
+	_, delisted := s.registry.Delisted(ProviderCoinGecko, id, time.Now())
+	assetID := s.AssetID(id)
+
 	resolvedInterval := interval
 	canonicalDays, canonicalInterval, err := canonicalizeHistoryRequest(days, resolvedInterval)
 	if err != nil {
@@ -243,6 +494,12 @@ func (s *Service) GetHistory(id, days, interval string) (*HistoryResponse, error
 			return persisted, nil
 		}
 
+		if archived, found := s.archivedHistory(id, key); found {
+			log.Printf("Serving archived history for delisted coin (%s)", key)
+			s.cache.SetHistory(key, archived)
+			return archived, nil
+		}
+
 		log.Printf("Cache miss for history (%s), fetching from CoinGecko", key)
 		history, err := s.client.GetMarketChart(id, canonicalDays, canonicalInterval)
 		if err != nil {
@@ -253,6 +510,7 @@ func (s *Service) GetHistory(id, days, interval string) (*HistoryResponse, error
 		if err := s.historyStore.Set(key, history); err != nil {
 			log.Printf("Failed to persist history (%s) to Turso: %v", key, err)
 		}
+		s.archiveHistory(id, key, history)
 		return history, nil
 	})
 
@@ -272,10 +530,10 @@ func (s *Service) GetHistory(id, days, interval string) (*HistoryResponse, error
 	if days != canonicalDays {
 		sliced := sliceHistory(history, days, canonicalInterval)
 		log.Printf("History sliced: days=%s points=%d", days, len(sliced.Prices))
-		return sliced, nil
+		return annotateHistory(sliced, assetID, redirect, delisted), nil
 	}
 
-	return history, nil
+	return annotateHistory(history, assetID, redirect, delisted), nil
 }
 
 // GetHistoryCachedOnly returns cached history or an error if not available.
@@ -292,6 +550,10 @@ func (s *Service) GetHistoryCachedOnly(id, days, interval string) (*HistoryRespo
 		return nil, err
 	}
 
+	id, redirect := s.registry.Resolve(ProviderCoinGecko, s.providerID(ProviderCoinGecko, id), time.Now())
+	_, delisted := s.registry.Delisted(ProviderCoinGecko, id, time.Now())
+	assetID := s.AssetID(id)
+
 	key := strings.ToLower(fmt.Sprintf("%s:%s:%s", id, canonicalDays, canonicalInterval))
 	history, found := s.cache.GetHistory(key)
 	if !found {
@@ -300,6 +562,9 @@ func (s *Service) GetHistoryCachedOnly(id, days, interval string) (*HistoryRespo
 		if err != nil {
 			return nil, err
 		}
+		if !found {
+			history, found = s.archivedHistory(id, key)
+		}
 		if !found {
 			return nil, fmt.Errorf("history not cached for %s", key)
 		}
@@ -309,10 +574,29 @@ func (s *Service) GetHistoryCachedOnly(id, days, interval string) (*HistoryRespo
 	if days != canonicalDays {
 		sliced := sliceHistory(history, days, canonicalInterval)
 		log.Printf("History sliced: days=%s points=%d", days, len(sliced.Prices))
-		return sliced, nil
+		return annotateHistory(sliced, assetID, redirect, delisted), nil
 	}
 
-	return history, nil
+	return annotateHistory(history, assetID, redirect, delisted), nil
+}
+
+// GetHistoryRangeCachedOnly returns cached history limited to the [from, to]
+// range in unix milliseconds. A zero bound leaves that side open.
+func (s *Service) GetHistoryRangeCachedOnly(id, days, interval string, from, to int64) (*HistoryResponse, error) {
+	if from > 0 && to > 0 && from > to {
+		return nil, fmt.Errorf("from must be before to")
+	}
+
+	history, err := s.GetHistoryCachedOnly(id, days, interval)
+	if err != nil {
+		return nil, err
+	}
+
+	if from == 0 && to == 0 {
+		return history, nil
+	}
+
+	return sliceHistoryRange(history, from, to), nil
 }
 
 func canonicalizeHistoryRequest(days, interval string) (string, string, error) {
@@ -337,13 +621,15 @@ func sliceHistory(history *HistoryResponse, days, interval string) *HistoryRespo
 
 	if len(history.Prices) == 0 {
 		return &HistoryResponse{
-			ID:        history.ID,
-			Days:      days,
-			Interval:  interval,
-			Prices:    history.Prices,
-			Timestamp: history.Timestamp,
-			Cached:    history.Cached,
-			UpdatedAt: history.UpdatedAt,
+			ID:           history.ID,
+			Days:         days,
+			Interval:     interval,
+			Prices:       history.Prices,
+			Timestamp:    history.Timestamp,
+			Cached:       history.Cached,
+			UpdatedAt:    history.UpdatedAt,
+			encoded:      history.encoded,
+			encodedScope: "days=" + days,
 		}
 	}
 
@@ -358,17 +644,49 @@ func sliceHistory(history *HistoryResponse, days, interval string) *HistoryRespo
 	}
 
 	return &HistoryResponse{
-		ID:        history.ID,
-		Days:      days,
-		Interval:  interval,
-		Prices:    filtered,
-		Timestamp: history.Timestamp,
-		Cached:    history.Cached,
-		UpdatedAt: history.UpdatedAt,
+		ID:           history.ID,
+		Days:         days,
+		Interval:     interval,
+		Prices:       filtered,
+		Timestamp:    history.Timestamp,
+		Cached:       history.Cached,
+		UpdatedAt:    history.UpdatedAt,
+		encoded:      history.encoded,
+		encodedScope: "days=" + days,
+	}
+}
+
+func sliceHistoryRange(history *HistoryResponse, from, to int64) *HistoryResponse {
+	filtered := make([]HistoryPoint, 0, len(history.Prices))
+	for _, point := range history.Prices {
+		if from > 0 && point.Timestamp < from {
+			continue
+		}
+		if to > 0 && point.Timestamp > to {
+			continue
+		}
+		filtered = append(filtered, point)
+	}
+
+	return &HistoryResponse{
+		ID:             history.ID,
+		Days:           history.Days,
+		Interval:       history.Interval,
+		Prices:         filtered,
+		Timestamp:      history.Timestamp,
+		Cached:         history.Cached,
+		UpdatedAt:      history.UpdatedAt,
+		AssetID:        history.AssetID,
+		MigratedFrom:   history.MigratedFrom,
+		MigratedTo:     history.MigratedTo,
+		MigrationRatio: history.MigrationRatio,
+		Delisted:       history.Delisted,
+		encoded:        history.encoded,
+		encodedScope:   fmt.Sprintf("%s|range=%d-%d", history.encodedScope, from, to),
 	}
 }
 
-// ResolveCMCID maps a CMC id to a CoinGecko id using the cached mapping file.
+// ResolveCMCID maps a CMC id to a CoinGecko id using the indexed mapping.
 func (s *Service) ResolveCMCID(cmcID string) (string, error) {
 	if s.cmcMapStore == nil {
 		return "", fmt.Errorf("cmc map store not configured")
@@ -377,24 +695,90 @@ func (s *Service) ResolveCMCID(cmcID string) (string, error) {
 		return "", fmt.Errorf("cmc_id cannot be empty")
 	}
 
-	entries, found, err := s.cmcMapStore.Get()
+	cmcID, _ = s.registry.Resolve(ProviderCMC, s.providerID(ProviderCMC, cmcID), time.Now())
+
+	index, found, err := s.cmcMapStore.Index()
 	if err != nil {
 		return "", err
 	}
-	if !found || len(entries) == 0 {
+	if !found {
 		return "", fmt.Errorf("cmc mapping not available")
 	}
 
-	for _, entry := range entries {
-		if entry.CMCID == cmcID {
-			if entry.CoinGeckoID == "" {
-				return "", fmt.Errorf("cmc_id %s has no coingecko mapping", cmcID)
-			}
-			return entry.CoinGeckoID, nil
+	entry, found := index.Entry(cmcID)
+	if !found {
+		return "", fmt.Errorf("no coingecko mapping for cmc_id %s", cmcID)
+	}
+	if entry.CoinGeckoID == "" {
+		return "", fmt.Errorf("cmc_id %s has no coingecko mapping", cmcID)
+	}
+
+	return entry.CoinGeckoID, nil
+}
+
+// GetCMCMap returns the effective CMC->CoinGecko mapping. When any filter is
+// given only entries matching a CMC id, CoinGecko id or symbol are returned.
+func (s *Service) GetCMCMap(cmcIDs, coinGeckoIDs, symbols []string) (*CMCMapResponse, error) {
+	index, found, err := s.cmcMapStore.Index()
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("cmc mapping not available")
+	}
+
+	response := &CMCMapResponse{
+		Timestamp: time.Now().UnixMilli(),
+		UpdatedAt: index.UpdatedAt(),
+	}
+
+	if len(cmcIDs) == 0 && len(coinGeckoIDs) == 0 && len(symbols) == 0 {
+		response.Entries = s.annotateMappingAssetIDs(index.Entries())
+		response.AssetsUpdatedAt = s.assets.UpdatedAt()
+		return response, nil
+	}
+
+	response.Entries = make([]CMCMappingEntry, 0)
+	seen := make(map[string]struct{})
+	add := func(entry CMCMappingEntry) {
+		if _, dup := seen[entry.CMCID]; dup {
+			return
+		}
+		seen[entry.CMCID] = struct{}{}
+		response.Entries = append(response.Entries, entry)
+	}
+
+	for _, cmcID := range cmcIDs {
+		if entry, found := index.Entry(strings.TrimSpace(cmcID)); found {
+			add(entry)
+		}
+	}
+	for _, coinGeckoID := range coinGeckoIDs {
+		for _, cmcID := range index.CMCIDs(strings.TrimSpace(coinGeckoID)) {
+			entry, _ := index.Entry(cmcID)
+			add(entry)
+		}
+	}
+	for _, symbol := range symbols {
+		for _, entry := range index.BySymbol(symbol) {
+			add(entry)
 		}
 	}
 
-	return "", fmt.Errorf("no coingecko mapping for cmc_id %s", cmcID)
+	response.Entries = s.annotateMappingAssetIDs(response.Entries)
+	response.AssetsUpdatedAt = s.assets.UpdatedAt()
+	return response, nil
+}
+
+// mappingEntries returns the generated CMC->CoinGecko mapping with manual
+// overrides applied on top.
+func (s *Service) mappingEntries() ([]CMCMappingEntry, bool, error) {
+	index, found, err := s.cmcMapStore.Index()
+	if err != nil {
+		return nil, false, err
+	}
+
+	return index.Entries(), found, nil
 }
 
 // EnsureCMCMapping builds a CMC->CoinGecko mapping file if missing.
@@ -414,36 +798,65 @@ func (s *Service) EnsureCMCMapping() error {
 		return fmt.Errorf("failed to load CMC metadata: %w", err)
 	}
 
-	symbols := make([]string, 0, len(cmcMeta.Coins))
-	for _, coin := range cmcMeta.Coins {
+	_, err, _ = s.group.Do("cmc_mapping", func() (interface{}, error) {
+		s.mappingMu.Lock()
+		defer s.mappingMu.Unlock()
+
+		if _, found, err := s.cmcMapStore.Get(); err != nil {
+			return nil, err
+		} else if found {
+			return nil, nil
+		}
+
+		entries, err := s.buildCMCMapping(cmcMeta.Coins)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := s.cmcMapStore.Set(entries); err != nil {
+			return nil, fmt.Errorf("failed to store CMC mapping: %w", err)
+		}
+		return nil, nil
+	})
+
+	return err
+}
+
+// buildCMCMapping matches CMC coins against CoinGecko markets and the
+// CoinGecko coins list.
+func (s *Service) buildCMCMapping(cmcCoins []CoinMeta) ([]CMCMappingEntry, error) {
+	symbols := make([]string, 0, len(cmcCoins))
+	for _, coin := range cmcCoins {
 		if coin.Symbol != "" {
 			symbols = append(symbols, coin.Symbol)
 		}
 	}
 
 	if len(symbols) == 0 {
-		return fmt.Errorf("no CMC symbols available for mapping")
+		return nil, fmt.Errorf("no CMC symbols available for mapping")
 	}
 
 	cgMarkets := make([]CoinGeckoMarketCoin, 0, len(symbols))
 	for _, batch := range chunkSymbols(symbols, 50) {
 		markets, err := s.client.GetCoinsMarketsBySymbols(batch)
 		if err != nil {
-			return fmt.Errorf("failed to fetch CoinGecko markets by symbols: %w", err)
+			return nil, fmt.Errorf("failed to fetch CoinGecko markets by symbols: %w", err)
 		}
 		cgMarkets = append(cgMarkets, markets...)
 	}
 
-	entries := buildCMCMapEntries(cmcMeta.Coins, cgMarkets)
-	if len(entries) == 0 {
-		return fmt.Errorf("no CMC mapping entries built")
+	cgList, err := s.client.GetCoinsList()
+	if err != nil {
+		// Contract matching is skipped; names and ranks still apply.
+		log.Printf("Failed to fetch CoinGecko coins list for mapping: %v", err)
 	}
 
-	if err := s.cmcMapStore.Set(entries); err != nil {
-		return fmt.Errorf("failed to store CMC mapping: %w", err)
+	entries := buildCMCMapEntries(cmcCoins, cgMarkets, cgList)
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no CMC mapping entries built")
 	}
 
-	return nil
+	return entries, nil
 }
 
 // PrewarmHistoryCache fetches and caches history for all mapped coins.
@@ -453,7 +866,7 @@ func (s *Service) PrewarmHistoryCache() error {
 		return fmt.Errorf("cmc map store not configured")
 	}
 
-	entries, found, err := s.cmcMapStore.Get()
+	entries, found, err := s.mappingEntries()
 	if err != nil {
 		return err
 	}
@@ -461,24 +874,17 @@ func (s *Service) PrewarmHistoryCache() error {
 		return fmt.Errorf("cmc mapping not available")
 	}
 
-	ids := make([]string, 0, len(entries))
-	seen := make(map[string]struct{}, len(entries))
-	for _, entry := range entries {
-		id := strings.TrimSpace(entry.CoinGeckoID)
-		if id == "" {
-			continue
-		}
-		if _, ok := seen[id]; ok {
-			continue
-		}
-		seen[id] = struct{}{}
-		ids = append(ids, id)
-	}
-
+	ids := mappedCoinGeckoIDs(entries)
 	if len(ids) == 0 {
 		return fmt.Errorf("no coingecko ids available for prewarm")
 	}
 
+	return s.prewarmHistory(ids)
+}
+
+// prewarmHistory fetches and caches 365d daily history for ids, rate
+// limited to stay within the CoinGecko free tier.
+func (s *Service) prewarmHistory(ids []string) error {
 	var failures []string
 	var failuresMu sync.Mutex
 
@@ -551,6 +957,25 @@ func (s *Service) prewarmHistoryForCoin(id, days, interval string) error {
 	return nil
 }
 
+// mappedCoinGeckoIDs returns the distinct CoinGecko ids of mapped entries in
+// mapping order.
+func mappedCoinGeckoIDs(entries []CMCMappingEntry) []string {
+	ids := make([]string, 0, len(entries))
+	seen := make(map[string]struct{}, len(entries))
+	for _, entry := range entries {
+		id := strings.TrimSpace(entry.CoinGeckoID)
+		if id == "" {
+			continue
+		}
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
 func chunkSymbols(symbols []string, size int) [][]string {
 	if size <= 0 || len(symbols) == 0 {
 		return nil
@@ -608,7 +1033,9 @@ func (s *Service) getCMCTopPrices() (*LatestPricesResponse, error) {
 			return nil, err
 		}
 
+		s.annotateAssetIDs(ProviderCMC, prices.Prices)
 		s.cache.SetLatestPrices(cmcTopPricesCacheKey, prices)
+		s.stream.Publish(prices.Prices)
 		return prices, nil
 	})
 
@@ -649,6 +1076,7 @@ func (s *Service) refreshTopPrices() (*LatestPricesResponse, error) {
 		return nil, fmt.Errorf("failed to fetch latest prices: %w", err)
 	}
 
+	s.annotateAssetIDs(ProviderCoinGecko, prices.Prices)
 	s.cache.SetLatestPrices(topPricesCacheKey, prices)
 	return prices, nil
 }
@@ -0,0 +1,218 @@
+package prices
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	streamBacklogSize    = 64
+	streamSubscriberSize = 16
+)
+
+// PriceUpdate is a diff of latest prices pushed to streaming subscribers.
+type PriceUpdate struct {
+	Seq       uint64                `json:"seq"`
+	Prices    map[string]PricePoint `json:"prices"`
+	Removed   []string              `json:"removed,omitempty"`
+	Snapshot  bool                  `json:"snapshot,omitempty"`
+	Timestamp int64                 `json:"timestamp"`
+}
+
+// PriceSubscription receives filtered price updates until unsubscribed.
+type PriceSubscription struct {
+	C   <-chan PriceUpdate
+	ch  chan PriceUpdate
+	ids map[string]struct{}
+}
+
+// PriceStream fans out latest price diffs to subscribers and keeps a short
+// backlog so reconnecting clients can resume from their last sequence.
+type PriceStream struct {
+	mu          sync.Mutex
+	seq         uint64
+	current     map[string]PricePoint
+	updatedAt   time.Time
+	backlog     []PriceUpdate
+	subscribers map[*PriceSubscription]struct{}
+}
+
+// NewPriceStream creates an empty price stream.
+func NewPriceStream() *PriceStream {
+	return &PriceStream{
+		current:     make(map[string]PricePoint),
+		subscribers: make(map[*PriceSubscription]struct{}),
+	}
+}
+
+// Publish computes the diff against the previous prices and pushes it to
+// subscribers. Nothing is sent when no price changed.
+func (p *PriceStream) Publish(latest map[string]PricePoint) {
+	if p == nil || latest == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	changed := make(map[string]PricePoint)
+	for id, price := range latest {
+		if previous, found := p.current[id]; !found || previous != price {
+			changed[id] = price
+		}
+	}
+
+	var removed []string
+	for id := range p.current {
+		if _, found := latest[id]; !found {
+			removed = append(removed, id)
+		}
+	}
+	sort.Strings(removed)
+
+	if len(changed) == 0 && len(removed) == 0 {
+		return
+	}
+
+	next := make(map[string]PricePoint, len(latest))
+	for id, price := range latest {
+		next[id] = price
+	}
+	p.current = next
+	p.updatedAt = time.Now()
+	p.seq++
+
+	update := PriceUpdate{
+		Seq:       p.seq,
+		Prices:    changed,
+		Removed:   removed,
+		Timestamp: p.updatedAt.UnixMilli(),
+	}
+
+	p.backlog = append(p.backlog, update)
+	if len(p.backlog) > streamBacklogSize {
+		p.backlog = p.backlog[len(p.backlog)-streamBacklogSize:]
+	}
+
+	for sub := range p.subscribers {
+		filtered, ok := filterPriceUpdate(update, sub.ids)
+		if !ok {
+			continue
+		}
+		select {
+		case sub.ch <- filtered:
+		default:
+			// Slow consumer: drop it so the client reconnects and resumes.
+			delete(p.subscribers, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+// Subscribe registers a subscriber for the given ids (all ids when empty).
+// When lastSeq is still in the backlog the missed diffs are returned for
+// replay, otherwise a filtered snapshot of the current prices is returned.
+func (p *PriceStream) Subscribe(ids []string, lastSeq uint64) (*PriceSubscription, []PriceUpdate) {
+	_, normalized := normalizeIDs(ids)
+	var filter map[string]struct{}
+	if len(normalized) > 0 {
+		filter = make(map[string]struct{}, len(normalized))
+		for _, id := range normalized {
+			filter[id] = struct{}{}
+		}
+	}
+
+	ch := make(chan PriceUpdate, streamSubscriberSize)
+	sub := &PriceSubscription{C: ch, ch: ch, ids: filter}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.subscribers[sub] = struct{}{}
+	return sub, p.replayLocked(filter, lastSeq)
+}
+
+// Unsubscribe removes a subscriber and closes its channel.
+func (p *PriceStream) Unsubscribe(sub *PriceSubscription) {
+	if p == nil || sub == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, found := p.subscribers[sub]; found {
+		delete(p.subscribers, sub)
+		close(sub.ch)
+	}
+}
+
+func (p *PriceStream) replayLocked(filter map[string]struct{}, lastSeq uint64) []PriceUpdate {
+	if p.seq == 0 {
+		return nil
+	}
+	if lastSeq == p.seq {
+		return nil
+	}
+
+	if lastSeq > 0 && len(p.backlog) > 0 && lastSeq >= p.backlog[0].Seq-1 && lastSeq < p.seq {
+		replay := make([]PriceUpdate, 0, len(p.backlog))
+		for _, update := range p.backlog {
+			if update.Seq <= lastSeq {
+				continue
+			}
+			if filtered, ok := filterPriceUpdate(update, filter); ok {
+				replay = append(replay, filtered)
+			}
+		}
+		return replay
+	}
+
+	snapshot := PriceUpdate{
+		Seq:       p.seq,
+		Prices:    make(map[string]PricePoint, len(p.current)),
+		Snapshot:  true,
+		Timestamp: p.updatedAt.UnixMilli(),
+	}
+	for id, price := range p.current {
+		if filter != nil {
+			if _, found := filter[id]; !found {
+				continue
+			}
+		}
+		snapshot.Prices[id] = price
+	}
+
+	return []PriceUpdate{snapshot}
+}
+
+func filterPriceUpdate(update PriceUpdate, filter map[string]struct{}) (PriceUpdate, bool) {
+	if filter == nil {
+		return update, true
+	}
+
+	filtered := PriceUpdate{
+		Seq:       update.Seq,
+		Prices:    make(map[string]PricePoint),
+		Snapshot:  update.Snapshot,
+		Timestamp: update.Timestamp,
+	}
+	for id, price := range update.Prices {
+		if _, found := filter[strings.ToLower(id)]; found {
+			filtered.Prices[id] = price
+		}
+	}
+	for _, id := range update.Removed {
+		if _, found := filter[strings.ToLower(id)]; found {
+			filtered.Removed = append(filtered.Removed, id)
+		}
+	}
+
+	if len(filtered.Prices) == 0 && len(filtered.Removed) == 0 {
+		return filtered, false
+	}
+
+	return filtered, true
+}
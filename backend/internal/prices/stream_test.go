@@ -0,0 +1,63 @@
+package prices
+
+import "testing"
+
+func TestPriceStreamPublishSendsDiff(t *testing.T) {
+	stream := NewPriceStream()
+	stream.Publish(map[string]PricePoint{
+		"1":    {USD: 100},
+		"1027": {USD: 10},
+	})
+
+	sub, replay := stream.Subscribe([]string{"1", "1027"}, 0)
+	defer stream.Unsubscribe(sub)
+
+	if len(replay) != 1 || !replay[0].Snapshot {
+		t.Fatalf("expected a single snapshot on subscribe, got %+v", replay)
+	}
+	if len(replay[0].Prices) != 2 {
+		t.Fatalf("expected 2 prices in snapshot, got %d", len(replay[0].Prices))
+	}
+
+	stream.Publish(map[string]PricePoint{
+		"1":    {USD: 101},
+		"1027": {USD: 10},
+	})
+
+	update := <-sub.C
+	if update.Seq != 2 {
+		t.Fatalf("expected seq 2, got %d", update.Seq)
+	}
+	if len(update.Prices) != 1 || update.Prices["1"].USD != 101 {
+		t.Fatalf("expected only the changed price, got %+v", update.Prices)
+	}
+}
+
+func TestPriceStreamFiltersAndResumes(t *testing.T) {
+	stream := NewPriceStream()
+	stream.Publish(map[string]PricePoint{"1": {USD: 100}, "1027": {USD: 10}})
+	stream.Publish(map[string]PricePoint{"1": {USD: 100}, "1027": {USD: 11}})
+	stream.Publish(map[string]PricePoint{"1": {USD: 102}})
+
+	sub, replay := stream.Subscribe([]string{"1027"}, 1)
+	defer stream.Unsubscribe(sub)
+
+	if len(replay) != 2 {
+		t.Fatalf("expected 2 replayed updates, got %d", len(replay))
+	}
+	if replay[0].Seq != 2 || replay[0].Prices["1027"].USD != 11 {
+		t.Fatalf("unexpected first replay: %+v", replay[0])
+	}
+	if replay[1].Seq != 3 || len(replay[1].Removed) != 1 || replay[1].Removed[0] != "1027" {
+		t.Fatalf("expected removal of 1027 in second replay, got %+v", replay[1])
+	}
+	if _, found := replay[1].Prices["1"]; found {
+		t.Fatalf("expected filtered replay to exclude id 1")
+	}
+
+	current, replay := stream.Subscribe(nil, 3)
+	defer stream.Unsubscribe(current)
+	if len(replay) != 0 {
+		t.Fatalf("expected no replay when already up to date, got %d", len(replay))
+	}
+}
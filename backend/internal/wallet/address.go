@@ -0,0 +1,141 @@
+package wallet
+
+import (
+	"fmt"
+	"strings"
+)
+
+// bitcoinAddress encodes a compressed public key as an address of the
+// given script type.
+func bitcoinAddress(publicKey *point, script string, testnet bool) (string, error) {
+	keyHash := hash160(publicKey.compress())
+
+	switch script {
+	case ScriptP2PKH:
+		version := byte(0x00)
+		if testnet {
+			version = 0x6f
+		}
+		return base58CheckEncode(append([]byte{version}, keyHash...)), nil
+
+	case ScriptP2SHP2WPKH:
+		// The redeem script is the witness v0 key hash program.
+		redeemScript := append([]byte{0x00, 0x14}, keyHash...)
+		version := byte(0x05)
+		if testnet {
+			version = 0xc4
+		}
+		return base58CheckEncode(append([]byte{version}, hash160(redeemScript)...)), nil
+
+	case ScriptP2WPKH:
+		hrp := "bc"
+		if testnet {
+			hrp = "tb"
+		}
+		return segwitAddress(hrp, 0, keyHash)
+
+	default:
+		return "", fmt.Errorf("unsupported script type %q", script)
+	}
+}
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// segwitAddress encodes a witness v0 program with bech32 (BIP173).
+func segwitAddress(hrp string, version byte, program []byte) (string, error) {
+	data, err := convertBits(program, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	data = append([]byte{version}, data...)
+
+	values := append(hrpExpand(hrp), data...)
+	polymod := bech32Polymod(append(values, 0, 0, 0, 0, 0, 0)) ^ 1
+
+	var encoded strings.Builder
+	encoded.WriteString(hrp)
+	encoded.WriteByte('1')
+	for _, value := range data {
+		encoded.WriteByte(bech32Charset[value])
+	}
+	for i := 0; i < 6; i++ {
+		encoded.WriteByte(bech32Charset[(polymod>>uint(5*(5-i)))&31])
+	}
+	return encoded.String(), nil
+}
+
+func bech32Polymod(values []byte) uint32 {
+	generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	checksum := uint32(1)
+	for _, value := range values {
+		top := checksum >> 25
+		checksum = (checksum&0x1ffffff)<<5 ^ uint32(value)
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				checksum ^= generator[i]
+			}
+		}
+	}
+	return checksum
+}
+
+func hrpExpand(hrp string) []byte {
+	expanded := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]>>5)
+	}
+	expanded = append(expanded, 0)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]&31)
+	}
+	return expanded
+}
+
+func convertBits(data []byte, from, to uint, pad bool) ([]byte, error) {
+	var (
+		accumulator uint32
+		bits        uint
+		converted   []byte
+	)
+	maxValue := uint32(1)<<to - 1
+	for _, value := range data {
+		accumulator = accumulator<<from | uint32(value)
+		bits += from
+		for bits >= to {
+			bits -= to
+			converted = append(converted, byte(accumulator>>bits&maxValue))
+		}
+	}
+	if pad && bits > 0 {
+		converted = append(converted, byte(accumulator<<(to-bits)&maxValue))
+	} else if !pad && (bits >= from || accumulator<<(to-bits)&maxValue != 0) {
+		return nil, fmt.Errorf("invalid padding")
+	}
+	return converted, nil
+}
+
+// looksLikeBitcoinAddress is a cheap shape check; the explorer validates
+// the address itself.
+func looksLikeBitcoinAddress(address string) bool {
+	if len(address) < 26 || len(address) > 90 {
+		return false
+	}
+	for _, r := range address {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// isTestnetAddress reports whether address uses a testnet prefix.
+func isTestnetAddress(address string) bool {
+	if strings.HasPrefix(strings.ToLower(address), "tb1") {
+		return true
+	}
+	switch address[0] {
+	case 'm', 'n', '2':
+		return true
+	}
+	return false
+}
@@ -0,0 +1,253 @@
+package wallet
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"crypto-portfolio-backend/internal/portfolio"
+)
+
+const (
+	// DefaultGapLimit is the BIP44 gap limit: scanning a chain stops after
+	// this many consecutive unused addresses.
+	DefaultGapLimit = 20
+	// MaxGapLimit bounds the gap limit of a single request.
+	MaxGapLimit = 100
+	// MaxAddresses bounds the addresses given directly in a request.
+	MaxAddresses = 100
+
+	// maxChainAddresses bounds the addresses derived per chain.
+	maxChainAddresses = 1000
+	satoshisPerBTC    = 1e8
+	bitcoinID         = "bitcoin"
+)
+
+// BitcoinRequest is the body of POST /import/wallet/btc. Either an account
+// extended public key (xpub, ypub, zpub or a testnet tpub, upub, vpub) or a
+// list of addresses is required; both may be combined.
+type BitcoinRequest struct {
+	XPub      string   `json:"xpub,omitempty"`
+	Addresses []string `json:"addresses,omitempty"`
+	// ScriptType overrides the script implied by the key prefix; wallets
+	// export BIP49/84 accounts as plain xpubs too. One of p2pkh,
+	// p2sh-p2wpkh or p2wpkh.
+	ScriptType string `json:"script_type,omitempty"`
+	GapLimit   int    `json:"gap_limit,omitempty"`
+}
+
+// bitcoinScan collects the activity of the wallet's addresses.
+type bitcoinScan struct {
+	ctx     context.Context
+	client  *EsploraClient
+	owned   map[string]struct{}
+	result  *Import
+	txs     map[string]esploraTx
+	txOrder []string
+}
+
+// ImportBitcoin scans the wallet's addresses and returns its confirmed
+// history as BUY (inflow) and SELL (outflow) transactions valued with
+// cached USD prices. Extended keys are scanned on the receive (0) and
+// change (1) chains until GapLimit consecutive addresses are unused. The
+// scan stops when ctx is done or after MaxImportDuration.
+func (s *Service) ImportBitcoin(ctx context.Context, request BitcoinRequest) (*Import, error) {
+	xpub := strings.TrimSpace(request.XPub)
+	if xpub == "" && len(request.Addresses) == 0 {
+		return nil, fmt.Errorf("%w: xpub or addresses is required", ErrInvalidRequest)
+	}
+	if len(request.Addresses) > MaxAddresses {
+		return nil, fmt.Errorf("%w: at most %d addresses are allowed", ErrInvalidRequest, MaxAddresses)
+	}
+	gapLimit := request.GapLimit
+	switch {
+	case gapLimit == 0:
+		gapLimit = DefaultGapLimit
+	case gapLimit < 1 || gapLimit > MaxGapLimit:
+		return nil, fmt.Errorf("%w: gap_limit must be between 1 and %d", ErrInvalidRequest, MaxGapLimit)
+	}
+	script := strings.ToLower(strings.TrimSpace(request.ScriptType))
+	switch script {
+	case "", ScriptP2PKH, ScriptP2SHP2WPKH, ScriptP2WPKH:
+	default:
+		return nil, fmt.Errorf("%w: unsupported script_type %q", ErrInvalidRequest, request.ScriptType)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, MaxImportDuration)
+	defer cancel()
+	scan := &bitcoinScan{
+		ctx:    ctx,
+		client: s.esplora,
+		owned:  make(map[string]struct{}),
+		result: &Import{Chain: "bitcoin", Addresses: []AddressActivity{}},
+		txs:    make(map[string]esploraTx),
+	}
+
+	testnet := false
+	if xpub != "" {
+		account, err := parseExtendedKey(xpub)
+		if err != nil {
+			return nil, fmt.Errorf("%w: xpub: %v", ErrInvalidRequest, err)
+		}
+		version := publicVersions[account.version]
+		testnet = version.testnet
+		if script == "" {
+			script = version.script
+		}
+		for chain := uint32(0); chain <= 1; chain++ {
+			if err := scan.chain(account, chain, script, testnet, gapLimit); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, address := range request.Addresses {
+		address = strings.TrimSpace(address)
+		if !looksLikeBitcoinAddress(address) {
+			return nil, fmt.Errorf("%w: invalid address %q", ErrInvalidRequest, address)
+		}
+		if _, found := scan.owned[address]; found {
+			continue
+		}
+		scan.owned[address] = struct{}{}
+		if _, err := scan.address(address, ""); err != nil {
+			return nil, err
+		}
+		testnet = testnet || isTestnetAddress(address)
+	}
+
+	result := scan.result
+	result.Transactions = scan.transactions()
+	sortTransactions(result.Transactions)
+	// Test networks have no market price.
+	coinID := bitcoinID
+	if testnet {
+		coinID = ""
+	}
+	result.Transactions, result.Unpriced = newValuer(s.prices).value(result.Transactions, func(portfolio.Transaction) string { return coinID })
+	return result, nil
+}
+
+// chain derives addresses on one chain until gapLimit consecutive ones
+// have no history.
+func (b *bitcoinScan) chain(account *extendedKey, chain uint32, script string, testnet bool, gapLimit int) error {
+	chainKey, err := account.child(chain)
+	if err != nil {
+		return fmt.Errorf("%w: xpub: %v", ErrInvalidRequest, err)
+	}
+
+	gap := 0
+	for index := uint32(0); gap < gapLimit && index < maxChainAddresses; index++ {
+		key, err := chainKey.child(index)
+		if err != nil {
+			// Invalid children are skipped as BIP32 prescribes.
+			continue
+		}
+		address, err := bitcoinAddress(key.publicKey, script, testnet)
+		if err != nil {
+			return err
+		}
+		b.owned[address] = struct{}{}
+
+		used, err := b.address(address, fmt.Sprintf("%d/%d", chain, index))
+		if err != nil {
+			return err
+		}
+		if used {
+			gap = 0
+		} else {
+			gap++
+		}
+	}
+	return nil
+}
+
+// address records the activity of one address and reports whether it was
+// ever used, including unconfirmed transactions.
+func (b *bitcoinScan) address(address, path string) (bool, error) {
+	b.result.Scanned++
+	stats, err := b.client.address(b.ctx, address)
+	if err != nil {
+		return false, err
+	}
+	txCount := stats.ChainStats.TxCount + stats.MempoolStats.TxCount
+	if txCount == 0 {
+		return false, nil
+	}
+
+	b.result.Addresses = append(b.result.Addresses, AddressActivity{
+		Address: address,
+		Path:    path,
+		TxCount: txCount,
+		Balance: float64(stats.ChainStats.FundedTxoSum-stats.ChainStats.SpentTxoSum) / satoshisPerBTC,
+	})
+	if stats.ChainStats.TxCount == 0 {
+		return true, nil
+	}
+
+	txs, err := b.client.transactions(b.ctx, address)
+	if err != nil {
+		return false, err
+	}
+	for _, tx := range txs {
+		if _, found := b.txs[tx.TxID]; !found && tx.Status.Confirmed {
+			b.txs[tx.TxID] = tx
+			b.txOrder = append(b.txOrder, tx.TxID)
+		}
+	}
+	return true, nil
+}
+
+// transactions nets each transaction's inputs and outputs over the owned
+// addresses. Transfers between the wallet's own addresses only cost the
+// network fee, which is recorded as a SELL of the fee amount.
+func (b *bitcoinScan) transactions() []portfolio.Transaction {
+	transactions := make([]portfolio.Transaction, 0, len(b.txOrder))
+	for _, txID := range b.txOrder {
+		tx := b.txs[txID]
+		var received, spent int64
+		for _, output := range tx.Vout {
+			if _, found := b.owned[output.Address]; found {
+				received += output.Value
+			}
+		}
+		for _, input := range tx.Vin {
+			if input.Prevout == nil {
+				continue
+			}
+			if _, found := b.owned[input.Prevout.Address]; found {
+				spent += input.Prevout.Value
+			}
+		}
+
+		externalID := "btc:" + txID
+		transaction := portfolio.Transaction{
+			AssetSymbol: "BTC",
+			Source:      portfolio.SourceWallet,
+			ExternalID:  &externalID,
+			Timestamp:   tx.Status.BlockTime * 1000,
+		}
+		net := received - spent
+		switch {
+		case net > 0:
+			transaction.Type = portfolio.TransactionBuy
+			transaction.Amount = float64(net) / satoshisPerBTC
+		case net < 0 && -net > tx.Fee:
+			fee := float64(tx.Fee) / satoshisPerBTC
+			feeCurrency := "BTC"
+			transaction.Type = portfolio.TransactionSell
+			transaction.Amount = -float64(-net-tx.Fee) / satoshisPerBTC
+			transaction.FeeAmount = &fee
+			transaction.FeeCurrency = &feeCurrency
+		case net < 0:
+			notes := "Network fee"
+			transaction.Type = portfolio.TransactionSell
+			transaction.Amount = float64(net) / satoshisPerBTC
+			transaction.Notes = &notes
+		default:
+			continue
+		}
+		transactions = append(transactions, transaction)
+	}
+	return transactions
+}
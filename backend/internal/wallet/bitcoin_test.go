@@ -0,0 +1,191 @@
+package wallet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"crypto-portfolio-backend/internal/prices"
+)
+
+const (
+	testZpub     = "zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs"
+	testReceive0 = "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu"
+	testReceive1 = "bc1qnjg0jd8228aq7egyzacy8cys3knf9xvrerkf9g"
+	testChange0  = "bc1q8c6fshw2dlwun7ekn9qwf37cu2rn755upcp6el"
+	testExternal = "bc1qxy2kgdygjrsqtzq2n0yrf2493p83kkfjhx0wlh"
+	testDay      = int64(1_699_920_000)
+)
+
+type fakePrices map[string][]prices.HistoryPoint
+
+func (f fakePrices) GetHistoryCachedOnly(id, days, interval string) (*prices.HistoryResponse, error) {
+	points, found := f[id]
+	if !found {
+		return nil, errors.New("not cached")
+	}
+	return &prices.HistoryResponse{ID: id, Days: days, Interval: interval, Prices: points}, nil
+}
+
+// fakeEsplora serves address stats and confirmed history from memory.
+func fakeEsplora(t *testing.T, txs []esploraTx) *httptest.Server {
+	t.Helper()
+	byAddress := make(map[string][]esploraTx)
+	for i := len(txs) - 1; i >= 0; i-- {
+		seen := make(map[string]bool)
+		for _, input := range txs[i].Vin {
+			seen[input.Prevout.Address] = true
+		}
+		for _, output := range txs[i].Vout {
+			seen[output.Address] = true
+		}
+		for address := range seen {
+			byAddress[address] = append(byAddress[address], txs[i])
+		}
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/address/"), "/")
+		history := byAddress[parts[0]]
+		switch {
+		case len(parts) == 1:
+			var stats esploraAddress
+			stats.Address = parts[0]
+			stats.ChainStats.TxCount = len(history)
+			json.NewEncoder(w).Encode(stats)
+		case len(parts) == 3 && parts[1] == "txs" && parts[2] == "chain":
+			json.NewEncoder(w).Encode(history)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func testTx(id string, day int64, fee int64, inputs, outputs []esploraOutput) esploraTx {
+	tx := esploraTx{TxID: id, Vout: outputs, Fee: fee}
+	for i := range inputs {
+		tx.Vin = append(tx.Vin, struct {
+			Prevout *esploraOutput `json:"prevout"`
+		}{Prevout: &inputs[i]})
+	}
+	tx.Status.Confirmed = true
+	tx.Status.BlockTime = testDay + day*86400 + 3600
+	return tx
+}
+
+func TestServiceImportBitcoin(t *testing.T) {
+	server := fakeEsplora(t, []esploraTx{
+		// Deposit of 1 BTC.
+		testTx("t1", 0, 200, []esploraOutput{{Address: testExternal, Value: 100_000_200}}, []esploraOutput{{Address: testReceive0, Value: 100_000_000}}),
+		// Payment of 0.3 BTC with change.
+		testTx("t2", 3, 10_000, []esploraOutput{{Address: testReceive0, Value: 100_000_000}},
+			[]esploraOutput{{Address: testExternal, Value: 30_000_000}, {Address: testChange0, Value: 69_990_000}}),
+		// Consolidation into the next receive address.
+		testTx("t3", 9, 10_000, []esploraOutput{{Address: testChange0, Value: 69_990_000}}, []esploraOutput{{Address: testReceive1, Value: 69_980_000}}),
+	})
+	defer server.Close()
+
+	dayMs := testDay * 1000
+	service := NewService(fakePrices{"bitcoin": {
+		{Timestamp: dayMs, Price: 40000},
+		{Timestamp: dayMs + 2*dayMillis, Price: 50000},
+	}}, Config{EsploraURL: server.URL})
+
+	result, err := service.ImportBitcoin(context.Background(), BitcoinRequest{XPub: testZpub, GapLimit: 2})
+	if err != nil {
+		t.Fatalf("ImportBitcoin: %v", err)
+	}
+
+	var paths []string
+	for _, address := range result.Addresses {
+		paths = append(paths, address.Path+" "+address.Address)
+	}
+	wantPaths := []string{"0/0 " + testReceive0, "0/1 " + testReceive1, "1/0 " + testChange0}
+	if !reflect.DeepEqual(paths, wantPaths) {
+		t.Errorf("addresses: got %v, want %v", paths, wantPaths)
+	}
+	if result.Scanned != 7 {
+		t.Errorf("scanned: got %d, want 7", result.Scanned)
+	}
+
+	var txs []string
+	for _, tx := range result.Transactions {
+		line := *tx.ExternalID + " " + tx.Type + " " + strconv.FormatFloat(tx.Amount, 'g', -1, 64) +
+			" " + strconv.FormatFloat(tx.PricePerUnitFiat, 'g', -1, 64)
+		if tx.FeeAmount != nil {
+			line += " fee " + strconv.FormatFloat(*tx.FeeAmount, 'g', -1, 64) + " " + *tx.FeeCurrency
+		}
+		if tx.Source != "WALLET" || tx.FiatCurrency != "USD" {
+			t.Errorf("unexpected source or currency: %+v", tx)
+		}
+		txs = append(txs, line)
+	}
+	wantTxs := []string{
+		"btc:t1 BUY 1 40000",
+		"btc:t2 SELL -0.3 50000 fee 0.0001 BTC",
+	}
+	if !reflect.DeepEqual(txs, wantTxs) {
+		t.Errorf("transactions:\n got %q\nwant %q", txs, wantTxs)
+	}
+	// Day t3 has no cached close: it is returned apart without a price.
+	if len(result.Unpriced) != 1 || *result.Unpriced[0].ExternalID != "btc:t3" ||
+		result.Unpriced[0].PricePerUnitFiat != 0 || result.Unpriced[0].FiatCurrency != "" {
+		t.Errorf("unpriced: got %+v", result.Unpriced)
+	}
+
+	// Seen from a single address, the change output leaves the wallet.
+	result, err = service.ImportBitcoin(context.Background(), BitcoinRequest{Addresses: []string{testReceive0}})
+	if err != nil {
+		t.Fatalf("ImportBitcoin addresses: %v", err)
+	}
+	if len(result.Transactions) != 2 || result.Transactions[1].Amount != -0.9999 || result.Transactions[1].FeeAmount == nil {
+		t.Errorf("unexpected address history: %+v", result.Transactions)
+	}
+}
+
+func TestServiceImportBitcoinStopsWithContext(t *testing.T) {
+	// The explorer hangs until the request is abandoned.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+	service := NewService(fakePrices{}, Config{EsploraURL: server.URL})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := service.ImportBitcoin(ctx, BitcoinRequest{XPub: testZpub}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("scan kept going for %s after the deadline", elapsed)
+	}
+}
+
+func TestServiceImportBitcoinInvalid(t *testing.T) {
+	service := NewService(fakePrices{}, Config{EsploraURL: "http://127.0.0.1:0"})
+	tests := []struct {
+		name    string
+		request BitcoinRequest
+	}{
+		{name: "empty", request: BitcoinRequest{}},
+		{name: "private key", request: BitcoinRequest{XPub: "xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi"}},
+		{name: "gap limit", request: BitcoinRequest{XPub: testZpub, GapLimit: MaxGapLimit + 1}},
+		{name: "script type", request: BitcoinRequest{XPub: testZpub, ScriptType: "p2tr"}},
+		{name: "address", request: BitcoinRequest{Addresses: []string{"not an address"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := service.ImportBitcoin(context.Background(), tt.request); !errors.Is(err, ErrInvalidRequest) {
+				t.Errorf("expected ErrInvalidRequest, got %v", err)
+			}
+		})
+	}
+}
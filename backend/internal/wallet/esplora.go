@@ -0,0 +1,121 @@
+package wallet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"crypto-portfolio-backend/internal/httpclient"
+)
+
+const (
+	defaultEsploraURL = "https://blockstream.info/api"
+
+	// esploraPageSize is the number of confirmed transactions Esplora
+	// returns per page.
+	esploraPageSize = 25
+	// maxTxPages bounds the history fetched for a single address.
+	maxTxPages = 40
+)
+
+// EsploraClient reads address history from an Esplora-compatible API
+// (Blockstream, mempool.space or a self-hosted electrs).
+type EsploraClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewEsploraClient creates a client for baseURL, defaulting to Blockstream.
+func NewEsploraClient(baseURL string) *EsploraClient {
+	if baseURL == "" {
+		baseURL = defaultEsploraURL
+	}
+	return &EsploraClient{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: httpclient.New(requestTimeout),
+	}
+}
+
+type esploraAddress struct {
+	Address      string       `json:"address"`
+	ChainStats   esploraStats `json:"chain_stats"`
+	MempoolStats esploraStats `json:"mempool_stats"`
+}
+
+type esploraStats struct {
+	FundedTxoSum int64 `json:"funded_txo_sum"`
+	SpentTxoSum  int64 `json:"spent_txo_sum"`
+	TxCount      int   `json:"tx_count"`
+}
+
+type esploraTx struct {
+	TxID string `json:"txid"`
+	Vin  []struct {
+		Prevout *esploraOutput `json:"prevout"`
+	} `json:"vin"`
+	Vout   []esploraOutput `json:"vout"`
+	Fee    int64           `json:"fee"`
+	Status struct {
+		Confirmed bool  `json:"confirmed"`
+		BlockTime int64 `json:"block_time"`
+	} `json:"status"`
+}
+
+type esploraOutput struct {
+	Address string `json:"scriptpubkey_address"`
+	Value   int64  `json:"value"`
+}
+
+// address fetches the funded/spent totals of an address.
+func (c *EsploraClient) address(ctx context.Context, address string) (*esploraAddress, error) {
+	var stats esploraAddress
+	if err := c.getJSON(ctx, "/address/"+url.PathEscape(address), &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// transactions fetches the confirmed history of an address, newest first.
+// Mempool transactions are skipped until they confirm.
+func (c *EsploraClient) transactions(ctx context.Context, address string) ([]esploraTx, error) {
+	var all []esploraTx
+	path := "/address/" + url.PathEscape(address) + "/txs/chain"
+	for page := 0; page < maxTxPages; page++ {
+		var txs []esploraTx
+		if err := c.getJSON(ctx, path, &txs); err != nil {
+			return nil, err
+		}
+		all = append(all, txs...)
+		if len(txs) < esploraPageSize {
+			return all, nil
+		}
+		path = "/address/" + url.PathEscape(address) + "/txs/chain/" + url.PathEscape(txs[len(txs)-1].TxID)
+	}
+	return nil, fmt.Errorf("%w: address %s has more than %d transactions", ErrInvalidRequest, address, maxTxPages*esploraPageSize)
+}
+
+func (c *EsploraClient) getJSON(ctx context.Context, path string, out any) error {
+	resp, err := doRequestWithContext(ctx, c.httpClient, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusBadRequest {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%w: %s", ErrInvalidRequest, strings.TrimSpace(string(body)))
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("Esplora API error: status %d, body: %s", resp.StatusCode, string(body))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode Esplora response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+package wallet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"crypto-portfolio-backend/internal/httpclient"
+)
+
+const (
+	defaultEtherscanURL = "https://api.etherscan.io/v2/api"
+
+	// etherscanPageSize is the page size requested from the explorer.
+	etherscanPageSize = 1000
+	// etherscanWindow is the most records an explorer query pages through;
+	// longer histories continue from the last block seen.
+	etherscanWindow = 10000
+	// maxExplorerRecords bounds the records fetched per action.
+	maxExplorerRecords = 50000
+)
+
+// EtherscanClient reads account history from an Etherscan-compatible API
+// (Etherscan v2 multichain, Blockscout or a local stand-in).
+type EtherscanClient struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// NewEtherscanClient creates a client for baseURL, defaulting to the
+// Etherscan v2 multichain endpoint.
+func NewEtherscanClient(baseURL, apiKey string) *EtherscanClient {
+	if baseURL == "" {
+		baseURL = defaultEtherscanURL
+	}
+	return &EtherscanClient{
+		baseURL:    baseURL,
+		apiKey:     apiKey,
+		httpClient: httpclient.New(requestTimeout),
+	}
+}
+
+type etherscanResponse struct {
+	Status  string          `json:"status"`
+	Message string          `json:"message"`
+	Result  json.RawMessage `json:"result"`
+}
+
+// etherscanRecord covers the fields of txlist, txlistinternal and tokentx.
+type etherscanRecord struct {
+	Hash            string `json:"hash"`
+	BlockNumber     string `json:"blockNumber"`
+	TimeStamp       string `json:"timeStamp"`
+	From            string `json:"from"`
+	To              string `json:"to"`
+	Value           string `json:"value"`
+	GasUsed         string `json:"gasUsed"`
+	GasPrice        string `json:"gasPrice"`
+	IsError         string `json:"isError"`
+	LogIndex        string `json:"logIndex"`
+	TraceID         string `json:"traceId"`
+	ContractAddress string `json:"contractAddress"`
+	TokenSymbol     string `json:"tokenSymbol"`
+	TokenDecimal    string `json:"tokenDecimal"`
+}
+
+// history fetches normal, internal and ERC-20 transfers of address.
+func (c *EtherscanClient) history(ctx context.Context, network evmNetwork, address string, fromBlock, toBlock uint64) (*evmHistory, error) {
+	history := &evmHistory{fees: make(map[string]evmFee)}
+
+	normal, err := c.records(ctx, network, "txlist", address, fromBlock, toBlock)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range normal {
+		hash := strings.ToLower(record.Hash)
+		timestamp := parseUnixMillis(record.TimeStamp)
+		failed := record.IsError == "1"
+		from := strings.ToLower(record.From)
+		if from == address {
+			gasUsed, _ := new(big.Int).SetString(record.GasUsed, 10)
+			gasPrice, _ := new(big.Int).SetString(record.GasPrice, 10)
+			if gasUsed != nil && gasPrice != nil {
+				history.fees[hash] = evmFee{wei: gasUsed.Mul(gasUsed, gasPrice), timestamp: timestamp, failed: failed}
+			}
+		}
+		if failed {
+			continue
+		}
+		history.transfers = append(history.transfers, evmTransfer{
+			kind:      transferNative,
+			hash:      hash,
+			timestamp: timestamp,
+			from:      from,
+			to:        strings.ToLower(record.To),
+			value:     parseBigInt(record.Value),
+		})
+	}
+
+	internal, err := c.records(ctx, network, "txlistinternal", address, fromBlock, toBlock)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range internal {
+		if record.IsError == "1" {
+			continue
+		}
+		history.transfers = append(history.transfers, evmTransfer{
+			kind:      transferInternal,
+			hash:      strings.ToLower(record.Hash),
+			timestamp: parseUnixMillis(record.TimeStamp),
+			from:      strings.ToLower(record.From),
+			to:        strings.ToLower(record.To),
+			value:     parseBigInt(record.Value),
+		})
+	}
+
+	tokens, err := c.records(ctx, network, "tokentx", address, fromBlock, toBlock)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range tokens {
+		decimals, err := strconv.Atoi(record.TokenDecimal)
+		history.transfers = append(history.transfers, evmTransfer{
+			kind:          transferToken,
+			hash:          strings.ToLower(record.Hash),
+			logIndex:      record.LogIndex,
+			timestamp:     parseUnixMillis(record.TimeStamp),
+			from:          strings.ToLower(record.From),
+			to:            strings.ToLower(record.To),
+			value:         parseBigInt(record.Value),
+			contract:      strings.ToLower(record.ContractAddress),
+			symbol:        record.TokenSymbol,
+			decimals:      decimals,
+			decimalsKnown: err == nil && decimals >= 0 && decimals <= 36,
+		})
+	}
+
+	return history, nil
+}
+
+// records pages through one account action in ascending block order. The
+// explorer caps a query at 10,000 records, so longer histories restart
+// from the last block seen and drop the records already returned.
+func (c *EtherscanClient) records(ctx context.Context, network evmNetwork, action, address string, fromBlock, toBlock uint64) ([]etherscanRecord, error) {
+	var all []etherscanRecord
+	seen := make(map[string]struct{})
+	startBlock := fromBlock
+	for {
+		var window []etherscanRecord
+		for page := 1; page*etherscanPageSize <= etherscanWindow; page++ {
+			records, err := c.page(ctx, network, action, address, startBlock, toBlock, page)
+			if err != nil {
+				return nil, err
+			}
+			window = append(window, records...)
+			if len(records) < etherscanPageSize {
+				break
+			}
+		}
+
+		added := 0
+		for _, record := range window {
+			key := record.Hash + "/" + record.LogIndex + "/" + record.TraceID + "/" + record.From + "/" + record.To + "/" + record.Value
+			if _, found := seen[key]; found {
+				continue
+			}
+			seen[key] = struct{}{}
+			all = append(all, record)
+			added++
+		}
+		if len(all) > maxExplorerRecords {
+			return nil, fmt.Errorf("%w: more than %d %s records; narrow the block range", ErrInvalidRequest, maxExplorerRecords, action)
+		}
+		if len(window) < etherscanWindow || added == 0 {
+			return all, nil
+		}
+		lastBlock, err := strconv.ParseUint(window[len(window)-1].BlockNumber, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid block number %q in %s response", window[len(window)-1].BlockNumber, action)
+		}
+		startBlock = lastBlock
+	}
+}
+
+func (c *EtherscanClient) page(ctx context.Context, network evmNetwork, action, address string, startBlock, endBlock uint64, page int) ([]etherscanRecord, error) {
+	query := url.Values{}
+	query.Set("chainid", strconv.Itoa(network.chainID))
+	query.Set("module", "account")
+	query.Set("action", action)
+	query.Set("address", address)
+	query.Set("startblock", strconv.FormatUint(startBlock, 10))
+	if endBlock > 0 {
+		query.Set("endblock", strconv.FormatUint(endBlock, 10))
+	} else {
+		query.Set("endblock", "latest")
+	}
+	query.Set("page", strconv.Itoa(page))
+	query.Set("offset", strconv.Itoa(etherscanPageSize))
+	query.Set("sort", "asc")
+	if c.apiKey != "" {
+		query.Set("apikey", c.apiKey)
+	}
+
+	separator := "?"
+	if strings.Contains(c.baseURL, "?") {
+		separator = "&"
+	}
+	resp, err := doRequestWithContext(ctx, c.httpClient, http.MethodGet, c.baseURL+separator+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", action, c.redact(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("explorer API error: status %d, body: %s", resp.StatusCode, string(body))
+	}
+
+	var envelope etherscanResponse
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return nil, fmt.Errorf("failed to decode explorer response: %w", err)
+	}
+
+	var records []etherscanRecord
+	if err := json.Unmarshal(envelope.Result, &records); err != nil {
+		// Errors carry a message string in result instead of a list.
+		var message string
+		json.Unmarshal(envelope.Result, &message)
+		if envelope.Status == "0" && strings.HasPrefix(envelope.Message, "No ") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("explorer API error: %s %s", envelope.Message, message)
+	}
+	return records, nil
+}
+
+// redact removes the API key from the request URL that net/http errors
+// carry, so it never reaches the logs.
+func (c *EtherscanClient) redact(err error) error {
+	var urlErr *url.Error
+	if c.apiKey != "" && errors.As(err, &urlErr) {
+		urlErr.URL = strings.ReplaceAll(urlErr.URL, url.QueryEscape(c.apiKey), "REDACTED")
+	}
+	return err
+}
+
+func parseBigInt(value string) *big.Int {
+	number, ok := new(big.Int).SetString(strings.TrimSpace(value), 10)
+	if !ok || number.Sign() < 0 {
+		return nil
+	}
+	return number
+}
+
+func parseUnixMillis(value string) int64 {
+	seconds, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return seconds * 1000
+}
@@ -0,0 +1,358 @@
+package wallet
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"strings"
+
+	"crypto-portfolio-backend/internal/portfolio"
+)
+
+// Providers of EVM history.
+const (
+	// ProviderExplorer reads an Etherscan-compatible API.
+	ProviderExplorer = "explorer"
+	// ProviderRPC scans blocks and logs on a JSON-RPC node.
+	ProviderRPC = "rpc"
+)
+
+// evmNetwork describes a supported EVM chain; names match the canonical
+// chains of the contract index.
+type evmNetwork struct {
+	chainID int
+	symbol  string
+	coinID  string
+}
+
+var evmNetworks = map[string]evmNetwork{
+	"ethereum":  {chainID: 1, symbol: "ETH", coinID: "ethereum"},
+	"bsc":       {chainID: 56, symbol: "BNB", coinID: "binancecoin"},
+	"polygon":   {chainID: 137, symbol: "POL", coinID: "polygon-ecosystem-token"},
+	"arbitrum":  {chainID: 42161, symbol: "ETH", coinID: "ethereum"},
+	"optimism":  {chainID: 10, symbol: "ETH", coinID: "ethereum"},
+	"base":      {chainID: 8453, symbol: "ETH", coinID: "ethereum"},
+	"avalanche": {chainID: 43114, symbol: "AVAX", coinID: "avalanche-2"},
+	"linea":     {chainID: 59144, symbol: "ETH", coinID: "ethereum"},
+}
+
+// EVMRequest is the body of POST /import/wallet/evm.
+type EVMRequest struct {
+	Address string `json:"address"`
+	Chain   string `json:"chain"`
+	// Provider is "explorer" (default) or "rpc"; the RPC provider needs a
+	// node configured for the chain and scans at most MaxRPCBlocks blocks.
+	Provider  string `json:"provider,omitempty"`
+	FromBlock uint64 `json:"from_block,omitempty"`
+	ToBlock   uint64 `json:"to_block,omitempty"`
+	// IncludeUnknownTokens keeps transfers of tokens without coin metadata,
+	// which are mostly spam airdrops.
+	IncludeUnknownTokens bool `json:"include_unknown_tokens,omitempty"`
+}
+
+// Token is a token contract seen in the wallet's history.
+type Token struct {
+	Contract    string `json:"contract"`
+	Symbol      string `json:"symbol"`
+	CoinGeckoID string `json:"coingecko_id,omitempty"`
+	AssetID     string `json:"asset_id,omitempty"`
+	Transfers   int    `json:"transfers"`
+	// Resolved is false for contracts without coin metadata; their
+	// transfers are skipped unless include_unknown_tokens is set.
+	Resolved bool `json:"resolved"`
+}
+
+// Kinds of EVM value movements.
+const (
+	transferNative   = "native"
+	transferInternal = "internal"
+	transferToken    = "token"
+)
+
+// evmTransfer is a value movement to or from the wallet.
+type evmTransfer struct {
+	kind      string
+	hash      string
+	logIndex  string
+	timestamp int64
+	from, to  string
+	value     *big.Int
+	contract  string
+	symbol    string
+	decimals  int
+	// decimalsKnown is false when the provider did not report decimals.
+	decimalsKnown bool
+}
+
+// evmFee is the gas a wallet paid for one of its transactions.
+type evmFee struct {
+	wei       *big.Int
+	timestamp int64
+	failed    bool
+}
+
+// evmHistory is the provider-independent history of an address.
+type evmHistory struct {
+	transfers []evmTransfer
+	fees      map[string]evmFee
+}
+
+// ImportEVM fetches the native, internal and ERC-20 transfers of an address
+// and returns them as BUY (inflow) and SELL (outflow) transactions. Token
+// contracts are resolved to coins through the contract index; gas is
+// attached to the native outflow of a transaction or recorded as a SELL of
+// the fee amount. The scan stops when ctx is done or after
+// MaxImportDuration.
+func (s *Service) ImportEVM(ctx context.Context, request EVMRequest) (*Import, error) {
+	address, err := parseEVMAddress(request.Address)
+	if err != nil {
+		return nil, err
+	}
+	chain := strings.ToLower(strings.TrimSpace(request.Chain))
+	if chain == "" {
+		chain = "ethereum"
+	}
+	network, found := evmNetworks[chain]
+	if !found {
+		return nil, fmt.Errorf("%w: unsupported chain %q", ErrInvalidRequest, request.Chain)
+	}
+	if request.ToBlock != 0 && request.FromBlock > request.ToBlock {
+		return nil, fmt.Errorf("%w: from_block is after to_block", ErrInvalidRequest)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, MaxImportDuration)
+	defer cancel()
+	var history *evmHistory
+	switch strings.ToLower(strings.TrimSpace(request.Provider)) {
+	case "", ProviderExplorer:
+		history, err = s.explorer.history(ctx, network, address, request.FromBlock, request.ToBlock)
+	case ProviderRPC:
+		url, configured := s.rpcURLs[chain]
+		if !configured {
+			return nil, fmt.Errorf("%w: no RPC node configured for %s", ErrInvalidRequest, chain)
+		}
+		history, err = newRPCClient(url).history(ctx, address, request.FromBlock, request.ToBlock)
+	default:
+		return nil, fmt.Errorf("%w: unsupported provider %q", ErrInvalidRequest, request.Provider)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	resolver := &tokenResolver{prices: s.prices, chain: chain, tokens: make(map[string]*Token), metadata: make(map[string]int)}
+	transactions, coinIDs, skipped := normalizeEVM(chain, network, address, history, resolver, request.IncludeUnknownTokens)
+	if len(transactions) > portfolio.MaxTransactions {
+		return nil, fmt.Errorf("%w: more than %d transactions", ErrInvalidRequest, portfolio.MaxTransactions)
+	}
+	sortTransactions(transactions)
+
+	result := &Import{
+		Chain:        chain,
+		Addresses:    []AddressActivity{{Address: address, TxCount: len(transactions)}},
+		Scanned:      1,
+		Transactions: transactions,
+		Tokens:       resolver.list(),
+		Errors:       skipped,
+	}
+	result.Transactions, result.Unpriced = newValuer(s.prices).value(result.Transactions, func(tx portfolio.Transaction) string {
+		return coinIDs[*tx.ExternalID]
+	})
+	return result, nil
+}
+
+// normalizeEVM nets the history into transactions and returns them with
+// the coin id pricing each external id and the transfers it had to skip.
+func normalizeEVM(chain string, network evmNetwork, address string, history *evmHistory, resolver *tokenResolver, includeUnknown bool) ([]portfolio.Transaction, map[string]string, []string) {
+	var transactions []portfolio.Transaction
+	var skipped []string
+	coinIDs := make(map[string]string)
+	feePaid := make(map[string]bool)
+	emit := func(tx portfolio.Transaction, coinID string) {
+		transactions = append(transactions, tx)
+		coinIDs[*tx.ExternalID] = coinID
+	}
+
+	// Internal transfers are netted per transaction.
+	internal := make(map[string]*evmTransfer)
+	var internalOrder []string
+
+	for _, transfer := range history.transfers {
+		if transfer.value == nil || transfer.value.Sign() == 0 || transfer.from == transfer.to ||
+			(transfer.from != address && transfer.to != address) {
+			continue
+		}
+		outflow := transfer.from == address
+
+		switch transfer.kind {
+		case transferNative:
+			tx := evmTransaction(network.symbol, chain+":"+transfer.hash, transfer.timestamp, transfer.value, 18, outflow)
+			if fee, found := history.fees[transfer.hash]; found && outflow {
+				amount := weiToFloat(fee.wei, 18)
+				tx.FeeAmount = &amount
+				tx.FeeCurrency = &network.symbol
+				feePaid[transfer.hash] = true
+			}
+			emit(tx, network.coinID)
+
+		case transferInternal:
+			signed := new(big.Int).Set(transfer.value)
+			if outflow {
+				signed.Neg(signed)
+			}
+			if net, found := internal[transfer.hash]; found {
+				net.value.Add(net.value, signed)
+				continue
+			}
+			netted := transfer
+			netted.value = signed
+			internal[transfer.hash] = &netted
+			internalOrder = append(internalOrder, transfer.hash)
+
+		case transferToken:
+			token := resolver.resolve(transfer)
+			token.Transfers++
+			if !token.Resolved && !includeUnknown {
+				continue
+			}
+			externalID := chain + ":" + transfer.hash + ":" + transfer.logIndex
+			decimals, known := resolver.decimals(transfer)
+			if !known {
+				skipped = append(skipped, fmt.Sprintf("transfer %s: unknown decimals for %s (%s)", externalID, token.Symbol, transfer.contract))
+				continue
+			}
+			tx := evmTransaction(token.Symbol, externalID, transfer.timestamp, transfer.value, decimals, outflow)
+			emit(tx, token.CoinGeckoID)
+		}
+	}
+
+	for _, hash := range internalOrder {
+		net := internal[hash]
+		if net.value.Sign() == 0 {
+			continue
+		}
+		value := new(big.Int).Abs(net.value)
+		emit(evmTransaction(network.symbol, chain+":"+hash+":internal", net.timestamp, value, 18, net.value.Sign() < 0), network.coinID)
+	}
+
+	// Gas of transactions without a native outflow: contract calls, token
+	// transfers, self-sends and failed transactions.
+	hashes := make([]string, 0, len(history.fees))
+	for hash := range history.fees {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	for _, hash := range hashes {
+		fee := history.fees[hash]
+		if feePaid[hash] || fee.wei == nil || fee.wei.Sign() == 0 {
+			continue
+		}
+		tx := evmTransaction(network.symbol, chain+":"+hash, fee.timestamp, fee.wei, 18, true)
+		notes := "Network fee"
+		if fee.failed {
+			notes = "Network fee (failed transaction)"
+		}
+		tx.Notes = &notes
+		emit(tx, network.coinID)
+	}
+
+	return transactions, coinIDs, skipped
+}
+
+func evmTransaction(symbol, externalID string, timestamp int64, value *big.Int, decimals int, outflow bool) portfolio.Transaction {
+	tx := portfolio.Transaction{
+		AssetSymbol: symbol,
+		Amount:      weiToFloat(value, decimals),
+		Type:        portfolio.TransactionBuy,
+		Source:      portfolio.SourceWallet,
+		ExternalID:  &externalID,
+		Timestamp:   timestamp,
+	}
+	if outflow {
+		tx.Type = portfolio.TransactionSell
+		tx.Amount = -tx.Amount
+	}
+	return tx
+}
+
+// weiToFloat scales an integer token amount by its decimals.
+func weiToFloat(value *big.Int, decimals int) float64 {
+	scaled := new(big.Float).SetInt(value)
+	if decimals > 0 {
+		scale := new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil))
+		scaled.Quo(scaled, scale)
+	}
+	amount, _ := scaled.Float64()
+	return amount
+}
+
+// parseEVMAddress validates a 0x-prefixed address and returns it
+// lowercased for comparisons.
+func parseEVMAddress(address string) (string, error) {
+	address = strings.ToLower(strings.TrimSpace(address))
+	if len(address) != 42 || !strings.HasPrefix(address, "0x") {
+		return "", fmt.Errorf("%w: invalid EVM address %q", ErrInvalidRequest, address)
+	}
+	if _, err := hex.DecodeString(address[2:]); err != nil {
+		return "", fmt.Errorf("%w: invalid EVM address %q", ErrInvalidRequest, address)
+	}
+	return address, nil
+}
+
+// tokenResolver maps token contracts to coins through the contract index,
+// looking each contract up once per import.
+type tokenResolver struct {
+	prices PriceSource
+	chain  string
+	tokens map[string]*Token
+	order  []string
+	// metadata holds the decimals listed in the coin metadata.
+	metadata map[string]int
+}
+
+func (r *tokenResolver) resolve(transfer evmTransfer) *Token {
+	if token, found := r.tokens[transfer.contract]; found {
+		return token
+	}
+
+	token := &Token{Contract: transfer.contract, Symbol: strings.ToUpper(strings.TrimSpace(transfer.symbol))}
+	if token.Symbol == "" {
+		token.Symbol = transfer.contract
+	}
+	lookup, err := r.prices.LookupContract(r.chain, transfer.contract)
+	if err != nil {
+		log.Printf("Contract lookup failed for %s:%s: %v", r.chain, transfer.contract, err)
+	} else if len(lookup.Matches) > 0 {
+		match := lookup.Matches[0]
+		token.Symbol = match.Symbol
+		token.CoinGeckoID = match.CoinGeckoID
+		token.AssetID = match.AssetID
+		token.Resolved = true
+		if match.Decimals > 0 {
+			r.metadata[transfer.contract] = match.Decimals
+		}
+	}
+
+	r.tokens[transfer.contract] = token
+	r.order = append(r.order, transfer.contract)
+	return token
+}
+
+// decimals prefers the provider's value, then the coin metadata.
+func (r *tokenResolver) decimals(transfer evmTransfer) (int, bool) {
+	if transfer.decimalsKnown {
+		return transfer.decimals, true
+	}
+	decimals, found := r.metadata[transfer.contract]
+	return decimals, found
+}
+
+func (r *tokenResolver) list() []Token {
+	tokens := make([]Token, 0, len(r.order))
+	for _, contract := range r.order {
+		tokens = append(tokens, *r.tokens[contract])
+	}
+	return tokens
+}
@@ -0,0 +1,274 @@
+package wallet
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"crypto-portfolio-backend/internal/portfolio"
+	"crypto-portfolio-backend/internal/prices"
+)
+
+const (
+	testEVMAddress = "0x1111111111111111111111111111111111111111"
+	testEVMOther   = "0x2222222222222222222222222222222222222222"
+	testUSDC       = "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48"
+	testSpam       = "0x3333333333333333333333333333333333333333"
+)
+
+// LookupContract knows USDC on Ethereum.
+func (f fakePrices) LookupContract(chain, address string) (*prices.ContractLookupResponse, error) {
+	response := &prices.ContractLookupResponse{Chain: chain, Address: address, Matches: []prices.ContractMatch{}}
+	if chain == "ethereum" && address == testUSDC {
+		response.Matches = append(response.Matches, prices.ContractMatch{CoinGeckoID: "usd-coin", Symbol: "USDC", Decimals: 6})
+	}
+	return response, nil
+}
+
+func evmPrices() fakePrices {
+	dayMs := testDay * 1000
+	return fakePrices{
+		"ethereum": {{Timestamp: dayMs, Price: 2000}},
+		"usd-coin": {{Timestamp: dayMs, Price: 1}},
+	}
+}
+
+func describeEVM(transactions []portfolio.Transaction) []string {
+	var lines []string
+	for _, tx := range transactions {
+		line := fmt.Sprintf("%s %s %s %s %s", *tx.ExternalID, tx.Type, tx.AssetSymbol,
+			strconv.FormatFloat(tx.Amount, 'g', -1, 64), strconv.FormatFloat(tx.PricePerUnitFiat, 'g', -1, 64))
+		if tx.FeeAmount != nil {
+			line += " fee " + strconv.FormatFloat(*tx.FeeAmount, 'g', -1, 64) + " " + *tx.FeeCurrency
+		}
+		if tx.Notes != nil {
+			line += " " + *tx.Notes
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestServiceImportEVMExplorer(t *testing.T) {
+	at := func(hour int64) string { return strconv.FormatInt(testDay+hour*3600, 10) }
+	records := map[string][]etherscanRecord{
+		"txlist": {
+			{Hash: "0xH1", BlockNumber: "10", TimeStamp: at(1), From: testEVMOther, To: testEVMAddress, Value: "1000000000000000000", GasUsed: "21000", GasPrice: "10000000000", IsError: "0"},
+			{Hash: "0xH2", BlockNumber: "11", TimeStamp: at(2), From: testEVMAddress, To: testEVMOther, Value: "500000000000000000", GasUsed: "21000", GasPrice: "10000000000", IsError: "0"},
+			// Token transfer call: no value, gas only.
+			{Hash: "0xH3", BlockNumber: "12", TimeStamp: at(3), From: testEVMAddress, To: testUSDC, Value: "0", GasUsed: "50000", GasPrice: "10000000000", IsError: "0"},
+			{Hash: "0xH4", BlockNumber: "13", TimeStamp: at(4), From: testEVMAddress, To: testEVMOther, Value: "1000000000000000000", GasUsed: "21000", GasPrice: "10000000000", IsError: "1"},
+		},
+		"txlistinternal": {
+			{Hash: "0xH5", BlockNumber: "14", TimeStamp: at(5), From: testEVMOther, To: testEVMAddress, Value: "200000000000000000", IsError: "0", TraceID: "0"},
+		},
+		"tokentx": {
+			{Hash: "0xH3", BlockNumber: "12", TimeStamp: at(3), From: testEVMAddress, To: testEVMOther, Value: "100000000", ContractAddress: testUSDC, TokenSymbol: "USDC", TokenDecimal: "6", LogIndex: "7"},
+			{Hash: "0xH6", BlockNumber: "15", TimeStamp: at(6), From: testSpam, To: testEVMAddress, Value: "1", ContractAddress: testSpam, TokenSymbol: "Claim reward", TokenDecimal: "0", LogIndex: "2"},
+			// No decimals reported and none in the coin metadata.
+			{Hash: "0xH7", BlockNumber: "16", TimeStamp: at(7), From: testSpam, To: testEVMAddress, Value: "5", ContractAddress: testSpam, TokenSymbol: "Claim reward", TokenDecimal: "", LogIndex: "3"},
+		},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("chainid") != "1" || query.Get("apikey") != "secret" || query.Get("address") != testEVMAddress {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+		result, found := records[query.Get("action")]
+		if !found || query.Get("page") != "1" {
+			json.NewEncoder(w).Encode(map[string]any{"status": "0", "message": "No transactions found", "result": []any{}})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"status": "1", "message": "OK", "result": result})
+	}))
+	defer server.Close()
+
+	service := NewService(evmPrices(), Config{EtherscanURL: server.URL, EtherscanAPIKey: "secret"})
+	result, err := service.ImportEVM(context.Background(), EVMRequest{Address: "0x1111111111111111111111111111111111111111", Chain: "Ethereum"})
+	if err != nil {
+		t.Fatalf("ImportEVM: %v", err)
+	}
+
+	want := []string{
+		"ethereum:0xh1 BUY ETH 1 2000",
+		"ethereum:0xh2 SELL ETH -0.5 2000 fee 0.00021 ETH",
+		"ethereum:0xh3 SELL ETH -0.0005 2000 Network fee",
+		"ethereum:0xh3:7 SELL USDC -100 1",
+		"ethereum:0xh4 SELL ETH -0.00021 2000 Network fee (failed transaction)",
+		"ethereum:0xh5:internal BUY ETH 0.2 2000",
+	}
+	if got := describeEVM(result.Transactions); !reflect.DeepEqual(got, want) {
+		t.Errorf("transactions:\n got %q\nwant %q", got, want)
+	}
+
+	wantTokens := []Token{
+		{Contract: testUSDC, Symbol: "USDC", CoinGeckoID: "usd-coin", Transfers: 1, Resolved: true},
+		{Contract: testSpam, Symbol: "CLAIM REWARD", Transfers: 2},
+	}
+	if !reflect.DeepEqual(result.Tokens, wantTokens) {
+		t.Errorf("tokens:\n got %+v\nwant %+v", result.Tokens, wantTokens)
+	}
+	if len(result.Unpriced) != 0 || len(result.Errors) != 0 {
+		t.Errorf("unpriced: got %v, errors: got %v", result.Unpriced, result.Errors)
+	}
+
+	result, err = service.ImportEVM(context.Background(), EVMRequest{Address: testEVMAddress, IncludeUnknownTokens: true})
+	if err != nil {
+		t.Fatalf("ImportEVM: %v", err)
+	}
+	// The unknown token has no price and is returned apart.
+	if len(result.Transactions) != len(want) {
+		t.Errorf("transactions: got %d, want %d", len(result.Transactions), len(want))
+	}
+	if got := describeEVM(result.Unpriced); !reflect.DeepEqual(got, []string{"ethereum:0xh6:2 BUY CLAIM REWARD 1 0"}) {
+		t.Errorf("unpriced: got %q", got)
+	}
+	wantErrors := []string{"transfer ethereum:0xh7:3: unknown decimals for CLAIM REWARD (" + testSpam + ")"}
+	if !reflect.DeepEqual(result.Errors, wantErrors) {
+		t.Errorf("errors: got %q, want %q", result.Errors, wantErrors)
+	}
+}
+
+func TestEtherscanErrorsHideAPIKey(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	client := NewEtherscanClient(server.URL, "secret-key")
+
+	_, err := client.history(context.Background(), evmNetworks["ethereum"], testEVMAddress, 0, 0)
+	if err == nil {
+		t.Fatal("expected an error from a closed explorer")
+	}
+	if strings.Contains(err.Error(), "secret-key") {
+		t.Errorf("error leaks the API key: %v", err)
+	}
+}
+
+// fakeNode answers the JSON-RPC calls of a three-block devnet.
+func fakeNode(t *testing.T) *httptest.Server {
+	t.Helper()
+	padded := func(address string) string { return "0x" + strings.Repeat("0", 24) + address[2:] }
+	blockTime := func(hour int64) string { return hexUint(uint64(testDay + hour*3600)) }
+	blocks := map[string]rpcBlock{
+		"0x0": {Number: "0x0", Timestamp: blockTime(0)},
+		"0x1": {Number: "0x1", Timestamp: blockTime(1), Transactions: []rpcTx{
+			{Hash: "0xR1", From: testEVMOther, To: testEVMAddress, Value: "0xde0b6b3a7640000"},
+			{Hash: "0xR9", From: testEVMOther, To: testUSDC, Value: "0x0"},
+		}},
+		"0x2": {Number: "0x2", Timestamp: blockTime(2), Transactions: []rpcTx{
+			{Hash: "0xR2", From: testEVMAddress, To: testUSDC, Value: "0x0"},
+		}},
+	}
+	symbol := make([]byte, 96)
+	symbol[63] = 4
+	copy(symbol[64:], "USDC")
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			ID     int64             `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+
+		var result any
+		switch request.Method {
+		case "eth_blockNumber":
+			result = "0x2"
+		case "eth_getBlockByNumber":
+			var number string
+			json.Unmarshal(request.Params[0], &number)
+			result = blocks[number]
+		case "eth_getTransactionReceipt":
+			result = rpcReceipt{GasUsed: "0x5208", EffectiveGasPrice: "0x2540be400", Status: "0x1"}
+		case "eth_getLogs":
+			var filter struct {
+				Topics []*string `json:"topics"`
+			}
+			json.Unmarshal(request.Params[0], &filter)
+			logs := []rpcLog{}
+			if len(filter.Topics) == 2 && *filter.Topics[1] == padded(testEVMAddress) {
+				logs = append(logs, rpcLog{
+					Address:         testUSDC,
+					Topics:          []string{transferTopic, padded(testEVMAddress), padded(testEVMOther)},
+					Data:            "0x0000000000000000000000000000000000000000000000000000000005f5e100",
+					BlockNumber:     "0x2",
+					TransactionHash: "0xR2",
+					LogIndex:        "0x1",
+				})
+			}
+			result = logs
+		case "eth_call":
+			var call struct {
+				Data string `json:"data"`
+			}
+			json.Unmarshal(request.Params[0], &call)
+			if call.Data == selectorDecimals {
+				result = "0x" + strings.Repeat("0", 63) + "6"
+			} else {
+				result = "0x" + hex.EncodeToString(symbol)
+			}
+		default:
+			json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": request.ID, "error": map[string]any{"code": -32601, "message": "method not found"}})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": request.ID, "result": result})
+	}))
+}
+
+func TestServiceImportEVMRPC(t *testing.T) {
+	node := fakeNode(t)
+	defer node.Close()
+
+	service := NewService(evmPrices(), Config{RPCURLs: ParseRPCURLs(" ethereum = " + node.URL + ",base=")})
+	result, err := service.ImportEVM(context.Background(), EVMRequest{Address: testEVMAddress, Chain: "ethereum", Provider: ProviderRPC})
+	if err != nil {
+		t.Fatalf("ImportEVM: %v", err)
+	}
+
+	want := []string{
+		"ethereum:0xr1 BUY ETH 1 2000",
+		"ethereum:0xr2 SELL ETH -0.00021 2000 Network fee",
+		"ethereum:0xr2:1 SELL USDC -100 1",
+	}
+	if got := describeEVM(result.Transactions); !reflect.DeepEqual(got, want) {
+		t.Errorf("transactions:\n got %q\nwant %q", got, want)
+	}
+
+	if _, err := service.ImportEVM(context.Background(), EVMRequest{Address: testEVMAddress, Provider: ProviderRPC, FromBlock: 1, ToBlock: MaxRPCBlocks + 1}); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("expected ErrInvalidRequest for a long range, got %v", err)
+	}
+}
+
+func TestServiceImportEVMInvalid(t *testing.T) {
+	service := NewService(evmPrices(), Config{EtherscanURL: "http://127.0.0.1:0"})
+	tests := []struct {
+		name    string
+		request EVMRequest
+	}{
+		{name: "address", request: EVMRequest{Address: "0x123"}},
+		{name: "chain", request: EVMRequest{Address: testEVMAddress, Chain: "solana"}},
+		{name: "provider", request: EVMRequest{Address: testEVMAddress, Provider: "graph"}},
+		{name: "rpc not configured", request: EVMRequest{Address: testEVMAddress, Provider: ProviderRPC}},
+		{name: "block range", request: EVMRequest{Address: testEVMAddress, FromBlock: 10, ToBlock: 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := service.ImportEVM(context.Background(), tt.request); !errors.Is(err, ErrInvalidRequest) {
+				t.Errorf("expected ErrInvalidRequest, got %v", err)
+			}
+		})
+	}
+}
+
+var _ PriceSource = (*prices.Service)(nil)
@@ -0,0 +1,187 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"golang.org/x/crypto/ripemd160"
+)
+
+// Script types of derived Bitcoin addresses.
+const (
+	// ScriptP2PKH is a legacy 1… address (BIP44).
+	ScriptP2PKH = "p2pkh"
+	// ScriptP2SHP2WPKH is a nested segwit 3… address (BIP49).
+	ScriptP2SHP2WPKH = "p2sh-p2wpkh"
+	// ScriptP2WPKH is a native segwit bc1q… address (BIP84).
+	ScriptP2WPKH = "p2wpkh"
+)
+
+// keyVersion describes a serialized extended public key prefix.
+type keyVersion struct {
+	script  string
+	testnet bool
+}
+
+// publicVersions maps SLIP-132 version bytes to their default script type.
+var publicVersions = map[uint32]keyVersion{
+	0x0488b21e: {script: ScriptP2PKH},                     // xpub
+	0x049d7cb2: {script: ScriptP2SHP2WPKH},                // ypub
+	0x04b24746: {script: ScriptP2WPKH},                    // zpub
+	0x043587cf: {script: ScriptP2PKH, testnet: true},      // tpub
+	0x044a5262: {script: ScriptP2SHP2WPKH, testnet: true}, // upub
+	0x045f1c42: {script: ScriptP2WPKH, testnet: true},     // vpub
+}
+
+// privateVersions are rejected so private keys never reach the backend.
+var privateVersions = map[uint32]struct{}{
+	0x0488ade4: {}, 0x049d7878: {}, 0x04b2430c: {}, 0x04358394: {}, 0x044a4e28: {}, 0x045f18bc: {},
+}
+
+var errHardenedChild = errors.New("hardened children cannot be derived from a public key")
+
+// extendedKey is a BIP32 extended public key.
+type extendedKey struct {
+	version   uint32
+	depth     byte
+	childNum  uint32
+	chainCode []byte
+	publicKey *point
+}
+
+// parseExtendedKey decodes an xpub, ypub, zpub or their testnet forms.
+func parseExtendedKey(encoded string) (*extendedKey, error) {
+	payload, err := base58CheckDecode(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) != 78 {
+		return nil, errors.New("extended key must be 78 bytes")
+	}
+
+	version := binary.BigEndian.Uint32(payload[:4])
+	if _, private := privateVersions[version]; private {
+		return nil, errors.New("extended private keys are not accepted; export the account xpub instead")
+	}
+	if _, found := publicVersions[version]; !found {
+		return nil, fmt.Errorf("unknown extended key version %08x", version)
+	}
+
+	publicKey, err := decompress(payload[45:78])
+	if err != nil {
+		return nil, err
+	}
+	return &extendedKey{
+		version:   version,
+		depth:     payload[4],
+		childNum:  binary.BigEndian.Uint32(payload[9:13]),
+		chainCode: bytes.Clone(payload[13:45]),
+		publicKey: publicKey,
+	}, nil
+}
+
+// child derives the non-hardened child at index (BIP32 CKDpub).
+func (k *extendedKey) child(index uint32) (*extendedKey, error) {
+	if index >= 1<<31 {
+		return nil, errHardenedChild
+	}
+
+	data := make([]byte, 37)
+	copy(data, k.publicKey.compress())
+	binary.BigEndian.PutUint32(data[33:], index)
+	mac := hmac.New(sha512.New, k.chainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	tweak := new(big.Int).SetBytes(sum[:32])
+	if tweak.Cmp(secp256k1.n) >= 0 {
+		return nil, fmt.Errorf("invalid child %d", index)
+	}
+	publicKey := scalarBaseMult(tweak).add(k.publicKey)
+	if publicKey == nil {
+		return nil, fmt.Errorf("invalid child %d", index)
+	}
+
+	return &extendedKey{
+		version:   k.version,
+		depth:     k.depth + 1,
+		childNum:  index,
+		chainCode: sum[32:],
+		publicKey: publicKey,
+	}, nil
+}
+
+// hash160 is RIPEMD160(SHA256(data)).
+func hash160(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	hasher := ripemd160.New()
+	hasher.Write(sum[:])
+	return hasher.Sum(nil)
+}
+
+func doubleSHA256(data []byte) []byte {
+	first := sha256.Sum256(data)
+	second := sha256.Sum256(first[:])
+	return second[:]
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+func base58CheckEncode(payload []byte) string {
+	data := append(bytes.Clone(payload), doubleSHA256(payload)[:4]...)
+
+	number := new(big.Int).SetBytes(data)
+	radix := big.NewInt(58)
+	remainder := new(big.Int)
+	var encoded []byte
+	for number.Sign() > 0 {
+		number.DivMod(number, radix, remainder)
+		encoded = append(encoded, base58Alphabet[remainder.Int64()])
+	}
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		encoded = append(encoded, base58Alphabet[0])
+	}
+	for i, j := 0, len(encoded)-1; i < j; i, j = i+1, j-1 {
+		encoded[i], encoded[j] = encoded[j], encoded[i]
+	}
+	return string(encoded)
+}
+
+func base58CheckDecode(encoded string) ([]byte, error) {
+	number := new(big.Int)
+	radix := big.NewInt(58)
+	for _, r := range encoded {
+		index := bytes.IndexRune([]byte(base58Alphabet), r)
+		if index < 0 {
+			return nil, fmt.Errorf("invalid base58 character %q", r)
+		}
+		number.Mul(number, radix)
+		number.Add(number, big.NewInt(int64(index)))
+	}
+
+	decoded := number.Bytes()
+	for _, r := range encoded {
+		if r != rune(base58Alphabet[0]) {
+			break
+		}
+		decoded = append([]byte{0}, decoded...)
+	}
+	if len(decoded) < 4 {
+		return nil, errors.New("base58 payload too short")
+	}
+
+	payload, checksum := decoded[:len(decoded)-4], decoded[len(decoded)-4:]
+	if !bytes.Equal(doubleSHA256(payload)[:4], checksum) {
+		return nil, errors.New("invalid checksum")
+	}
+	return payload, nil
+}
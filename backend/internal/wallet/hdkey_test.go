@@ -0,0 +1,108 @@
+package wallet
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+)
+
+func TestExtendedKeyChild(t *testing.T) {
+	// BIP32 test vector 1: m/0H/1 derived from the public m/0H.
+	parent, err := parseExtendedKey("xpub68Gmy5EdvgibQVfPdqkBBCHxA5htiqg55crXYuXoQRKfDBFA1WEjWgP6LHhwBZeNK1VTsfTFUHCdrfp1bgwQ9xv5ski8PX9rL2dZXvgGDnw")
+	if err != nil {
+		t.Fatalf("parseExtendedKey: %v", err)
+	}
+	child, err := parent.child(1)
+	if err != nil {
+		t.Fatalf("child: %v", err)
+	}
+	got := serializeExtendedKey(child, hash160(parent.publicKey.compress())[:4])
+	want := "xpub6ASuArnXKPbfEwhqN6e3mwBcDTgzisQN1wXN9BJcM47sSikHjJf3UFHKkNAWbWMiGj7Wf5uMash7SyYq527Hqck2AxYysAA7xmALppuCkwQ"
+	if got != want {
+		t.Errorf("child key:\n got %s\nwant %s", got, want)
+	}
+
+	if _, err := parent.child(1 << 31); err != errHardenedChild {
+		t.Errorf("expected errHardenedChild, got %v", err)
+	}
+}
+
+func TestParseExtendedKeyInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+	}{
+		{name: "private key", key: "xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi"},
+		{name: "bad checksum", key: "xpub68Gmy5EdvgibQVfPdqkBBCHxA5htiqg55crXYuXoQRKfDBFA1WEjWgP6LHhwBZeNK1VTsfTFUHCdrfp1bgwQ9xv5ski8PX9rL2dZXvgGDnx"},
+		{name: "not base58", key: "xpub0OIl"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseExtendedKey(tt.key); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestBitcoinAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		account string
+		chain   uint32
+		index   uint32
+		want    string
+	}{
+		// BIP84 test vectors.
+		{name: "bip84 receive 0", account: "zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs", want: "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu"},
+		{name: "bip84 receive 1", account: "zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs", index: 1, want: "bc1qnjg0jd8228aq7egyzacy8cys3knf9xvrerkf9g"},
+		{name: "bip84 change 0", account: "zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs", chain: 1, want: "bc1q8c6fshw2dlwun7ekn9qwf37cu2rn755upcp6el"},
+		{name: "bip44 receive 0", account: "xpub6BosfCnifzxcFwrSzQiqu2DBVTshkCXacvNsWGYJVVhhawA7d4R5WSWGFNbi8Aw6ZRc1brxMyWMzG3DSSSSoekkudhUd9yLb6qx39T9nMdj", want: "1LqBGSKuX5yYUonjxT5qGfpUsXKYYWeabA"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			account, err := parseExtendedKey(tt.account)
+			if err != nil {
+				t.Fatalf("parseExtendedKey: %v", err)
+			}
+			version := publicVersions[account.version]
+			chain, err := account.child(tt.chain)
+			if err != nil {
+				t.Fatalf("child: %v", err)
+			}
+			key, err := chain.child(tt.index)
+			if err != nil {
+				t.Fatalf("child: %v", err)
+			}
+			got, err := bitcoinAddress(key.publicKey, version.script, version.testnet)
+			if err != nil {
+				t.Fatalf("bitcoinAddress: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// BIP49 testnet vector.
+	key, _ := hex.DecodeString("03a1af804ac108a8a51782198c2d034b28bf90c8803f5a53f76276fa69a4eae77f")
+	publicKey, err := decompress(key)
+	if err != nil {
+		t.Fatalf("decompress: %v", err)
+	}
+	if got, _ := bitcoinAddress(publicKey, ScriptP2SHP2WPKH, true); got != "2Mww8dCYPUpKHofjgcXcBCEGmniw9CoaiD2" {
+		t.Errorf("p2sh-p2wpkh: got %s", got)
+	}
+}
+
+// serializeExtendedKey encodes a key as a base58check extended public key.
+func serializeExtendedKey(k *extendedKey, parentFingerprint []byte) string {
+	payload := make([]byte, 0, 78)
+	payload = binary.BigEndian.AppendUint32(payload, k.version)
+	payload = append(payload, k.depth)
+	payload = append(payload, parentFingerprint...)
+	payload = binary.BigEndian.AppendUint32(payload, k.childNum)
+	payload = append(payload, k.chainCode...)
+	payload = append(payload, k.publicKey.compress()...)
+	return base58CheckEncode(payload)
+}
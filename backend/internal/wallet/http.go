@@ -0,0 +1,33 @@
+package wallet
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"crypto-portfolio-backend/internal/httpclient"
+)
+
+const requestTimeout = 15 * time.Second
+
+// doRequestWithContext sends a JSON request, retrying as httpclient.Do
+// does until ctx is done.
+func doRequestWithContext(ctx context.Context, httpClient *http.Client, method, url string, body []byte) (*http.Response, error) {
+	return httpclient.Do(httpClient, func() (*http.Request, error) {
+		var reader io.Reader
+		if body != nil {
+			reader = bytes.NewReader(body)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, url, reader)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/json")
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		return req, nil
+	})
+}
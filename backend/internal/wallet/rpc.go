@@ -0,0 +1,282 @@
+package wallet
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"crypto-portfolio-backend/internal/httpclient"
+)
+
+// MaxRPCBlocks bounds the blocks scanned on a JSON-RPC node per request;
+// without a range the latest MaxRPCBlocks blocks are scanned.
+const MaxRPCBlocks = 5000
+
+const (
+	// transferTopic is keccak256("Transfer(address,address,uint256)").
+	transferTopic = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+	// Function selectors of the ERC-20 metadata calls.
+	selectorDecimals = "0x313ce567"
+	selectorSymbol   = "0x95d89b41"
+)
+
+// rpcClient reads history from an Ethereum JSON-RPC node. Nodes cannot
+// list an account's transactions, so blocks are scanned one by one; this
+// suits local devnets and short ranges. Internal transfers need tracing
+// and are only available through the explorer provider.
+type rpcClient struct {
+	url        string
+	httpClient *http.Client
+	nextID     atomic.Int64
+}
+
+func newRPCClient(url string) *rpcClient {
+	return &rpcClient{url: url, httpClient: httpclient.New(requestTimeout)}
+}
+
+type rpcRequest struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      int64  `json:"id"`
+	Method  string `json:"method"`
+	Params  []any  `json:"params"`
+}
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type rpcBlock struct {
+	Number       string  `json:"number"`
+	Timestamp    string  `json:"timestamp"`
+	Transactions []rpcTx `json:"transactions"`
+}
+
+type rpcTx struct {
+	Hash  string `json:"hash"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Value string `json:"value"`
+}
+
+type rpcReceipt struct {
+	GasUsed           string `json:"gasUsed"`
+	EffectiveGasPrice string `json:"effectiveGasPrice"`
+	Status            string `json:"status"`
+}
+
+type rpcLog struct {
+	Address         string   `json:"address"`
+	Topics          []string `json:"topics"`
+	Data            string   `json:"data"`
+	BlockNumber     string   `json:"blockNumber"`
+	TransactionHash string   `json:"transactionHash"`
+	LogIndex        string   `json:"logIndex"`
+}
+
+// history scans blocks fromBlock..toBlock for the address's transactions
+// and its ERC-20 Transfer logs.
+func (c *rpcClient) history(ctx context.Context, address string, fromBlock, toBlock uint64) (*evmHistory, error) {
+	if toBlock == 0 {
+		var latest string
+		if err := c.call(ctx, "eth_blockNumber", &latest); err != nil {
+			return nil, err
+		}
+		toBlock = parseHexUint(latest)
+		if fromBlock == 0 && toBlock >= MaxRPCBlocks {
+			fromBlock = toBlock - MaxRPCBlocks + 1
+		}
+	}
+	if fromBlock > toBlock {
+		return nil, fmt.Errorf("%w: from_block is after the latest block %d", ErrInvalidRequest, toBlock)
+	}
+	if toBlock-fromBlock+1 > MaxRPCBlocks {
+		return nil, fmt.Errorf("%w: the RPC provider scans at most %d blocks", ErrInvalidRequest, MaxRPCBlocks)
+	}
+
+	history := &evmHistory{fees: make(map[string]evmFee)}
+	blockTimes := make(map[uint64]int64)
+	for number := fromBlock; number <= toBlock; number++ {
+		var block *rpcBlock
+		if err := c.call(ctx, "eth_getBlockByNumber", &block, hexUint(number), true); err != nil {
+			return nil, err
+		}
+		if block == nil {
+			return nil, fmt.Errorf("%w: block %d not found", ErrInvalidRequest, number)
+		}
+		timestamp := int64(parseHexUint(block.Timestamp)) * 1000
+		blockTimes[number] = timestamp
+
+		for _, tx := range block.Transactions {
+			from, to := strings.ToLower(tx.From), strings.ToLower(tx.To)
+			if from != address && to != address {
+				continue
+			}
+			hash := strings.ToLower(tx.Hash)
+			failed := false
+			if from == address {
+				var receipt *rpcReceipt
+				if err := c.call(ctx, "eth_getTransactionReceipt", &receipt, tx.Hash); err != nil {
+					return nil, err
+				}
+				if receipt != nil {
+					fee := new(big.Int).Mul(parseHexBig(receipt.GasUsed), parseHexBig(receipt.EffectiveGasPrice))
+					failed = receipt.Status == "0x0"
+					history.fees[hash] = evmFee{wei: fee, timestamp: timestamp, failed: failed}
+				}
+			}
+			if failed {
+				continue
+			}
+			history.transfers = append(history.transfers, evmTransfer{
+				kind:      transferNative,
+				hash:      hash,
+				timestamp: timestamp,
+				from:      from,
+				to:        to,
+				value:     parseHexBig(tx.Value),
+			})
+		}
+	}
+
+	// Transfers from and to the address are separate log filters.
+	padded := "0x" + strings.Repeat("0", 24) + address[2:]
+	decimals := make(map[string]*int)
+	symbols := make(map[string]string)
+	for _, topics := range [][]any{{transferTopic, padded}, {transferTopic, nil, padded}} {
+		var logs []rpcLog
+		filter := map[string]any{"fromBlock": hexUint(fromBlock), "toBlock": hexUint(toBlock), "topics": topics}
+		if err := c.call(ctx, "eth_getLogs", &logs, filter); err != nil {
+			return nil, err
+		}
+		for _, entry := range logs {
+			// ERC-721 transfers index the token id as a fourth topic.
+			if len(entry.Topics) != 3 {
+				continue
+			}
+			contract := strings.ToLower(entry.Address)
+			if _, found := decimals[contract]; !found {
+				decimals[contract] = c.tokenDecimals(ctx, contract)
+				symbols[contract] = c.tokenSymbol(ctx, contract)
+			}
+			transfer := evmTransfer{
+				kind:      transferToken,
+				hash:      strings.ToLower(entry.TransactionHash),
+				logIndex:  strconv.FormatUint(parseHexUint(entry.LogIndex), 10),
+				timestamp: blockTimes[parseHexUint(entry.BlockNumber)],
+				from:      topicAddress(entry.Topics[1]),
+				to:        topicAddress(entry.Topics[2]),
+				value:     parseHexBig(entry.Data),
+				contract:  contract,
+				symbol:    symbols[contract],
+			}
+			if known := decimals[contract]; known != nil {
+				transfer.decimals, transfer.decimalsKnown = *known, true
+			}
+			history.transfers = append(history.transfers, transfer)
+		}
+	}
+	return history, nil
+}
+
+// tokenDecimals calls decimals(); nil when the contract does not answer.
+func (c *rpcClient) tokenDecimals(ctx context.Context, contract string) *int {
+	var result string
+	if err := c.call(ctx, "eth_call", &result, map[string]string{"to": contract, "data": selectorDecimals}, "latest"); err != nil {
+		return nil
+	}
+	value := parseHexBig(result)
+	if len(strings.TrimPrefix(result, "0x")) == 0 || value.Cmp(big.NewInt(36)) > 0 {
+		return nil
+	}
+	decimals := int(value.Int64())
+	return &decimals
+}
+
+// tokenSymbol calls symbol(), accepting both string and bytes32 returns.
+func (c *rpcClient) tokenSymbol(ctx context.Context, contract string) string {
+	var result string
+	if err := c.call(ctx, "eth_call", &result, map[string]string{"to": contract, "data": selectorSymbol}, "latest"); err != nil {
+		return ""
+	}
+	data, err := hex.DecodeString(strings.TrimPrefix(result, "0x"))
+	if err != nil || len(data) < 32 {
+		return ""
+	}
+	if len(data) >= 64 {
+		length := new(big.Int).SetBytes(data[32:64])
+		if length.IsInt64() && 64+length.Int64() <= int64(len(data)) {
+			return string(data[64 : 64+length.Int64()])
+		}
+	}
+	return strings.TrimRight(string(data[:32]), "\x00")
+}
+
+func (c *rpcClient) call(ctx context.Context, method string, out any, params ...any) error {
+	if params == nil {
+		params = []any{}
+	}
+	body, err := json.Marshal(rpcRequest{JSONRPC: "2.0", ID: c.nextID.Add(1), Method: method, Params: params})
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", method, err)
+	}
+
+	resp, err := doRequestWithContext(ctx, c.httpClient, http.MethodPost, c.url, body)
+	if err != nil {
+		return fmt.Errorf("failed to call %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("RPC error: status %d, body: %s", resp.StatusCode, string(body))
+	}
+
+	var response rpcResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", method, err)
+	}
+	if response.Error != nil {
+		return fmt.Errorf("%s failed: %s (code %d)", method, response.Error.Message, response.Error.Code)
+	}
+	if err := json.Unmarshal(response.Result, out); err != nil {
+		return fmt.Errorf("failed to decode %s result: %w", method, err)
+	}
+	return nil
+}
+
+func hexUint(value uint64) string {
+	return "0x" + strconv.FormatUint(value, 16)
+}
+
+func parseHexUint(value string) uint64 {
+	number, _ := strconv.ParseUint(strings.TrimPrefix(value, "0x"), 16, 64)
+	return number
+}
+
+func parseHexBig(value string) *big.Int {
+	number, ok := new(big.Int).SetString(strings.TrimPrefix(value, "0x"), 16)
+	if !ok {
+		return new(big.Int)
+	}
+	return number
+}
+
+// topicAddress extracts the address from a 32-byte indexed topic.
+func topicAddress(topic string) string {
+	topic = strings.ToLower(strings.TrimPrefix(topic, "0x"))
+	if len(topic) < 40 {
+		return ""
+	}
+	return "0x" + topic[len(topic)-40:]
+}
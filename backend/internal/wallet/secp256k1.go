@@ -0,0 +1,144 @@
+package wallet
+
+import (
+	"errors"
+	"math/big"
+)
+
+// secp256k1 holds the curve parameters used by Bitcoin and Ethereum keys.
+// Only public key operations are needed here, so plain affine arithmetic on
+// big.Int is fast enough and keeps the dependency list short.
+var secp256k1 = struct {
+	p, n, gx, gy *big.Int
+}{
+	p:  mustHex("fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2f"),
+	n:  mustHex("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141"),
+	gx: mustHex("79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"),
+	gy: mustHex("483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8"),
+}
+
+// point is an affine curve point; nil is the point at infinity.
+type point struct {
+	x, y *big.Int
+}
+
+var errInvalidPoint = errors.New("invalid public key")
+
+func mustHex(value string) *big.Int {
+	number, ok := new(big.Int).SetString(value, 16)
+	if !ok {
+		panic("invalid curve constant " + value)
+	}
+	return number
+}
+
+func (a *point) add(b *point) *point {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	p := secp256k1.p
+	if a.x.Cmp(b.x) == 0 {
+		if a.y.Cmp(b.y) != 0 || a.y.Sign() == 0 {
+			return nil
+		}
+		return a.double()
+	}
+
+	// λ = (y2 - y1) / (x2 - x1)
+	numerator := new(big.Int).Sub(b.y, a.y)
+	denominator := new(big.Int).Sub(b.x, a.x)
+	denominator.Mod(denominator, p)
+	lambda := numerator.Mul(numerator, denominator.ModInverse(denominator, p))
+	lambda.Mod(lambda, p)
+	return a.finish(lambda, b.x)
+}
+
+func (a *point) double() *point {
+	if a == nil || a.y.Sign() == 0 {
+		return nil
+	}
+	p := secp256k1.p
+	// λ = 3x² / 2y
+	numerator := new(big.Int).Mul(a.x, a.x)
+	numerator.Mul(numerator, big.NewInt(3))
+	denominator := new(big.Int).Lsh(a.y, 1)
+	denominator.Mod(denominator, p)
+	lambda := numerator.Mul(numerator, denominator.ModInverse(denominator, p))
+	lambda.Mod(lambda, p)
+	return a.finish(lambda, a.x)
+}
+
+// finish computes the sum with slope lambda of a and a point with x
+// coordinate otherX.
+func (a *point) finish(lambda, otherX *big.Int) *point {
+	p := secp256k1.p
+	x := new(big.Int).Mul(lambda, lambda)
+	x.Sub(x, a.x)
+	x.Sub(x, otherX)
+	x.Mod(x, p)
+
+	y := new(big.Int).Sub(a.x, x)
+	y.Mul(y, lambda)
+	y.Sub(y, a.y)
+	y.Mod(y, p)
+	return &point{x: x, y: y}
+}
+
+// scalarBaseMult returns k·G.
+func scalarBaseMult(k *big.Int) *point {
+	var result *point
+	addend := &point{x: secp256k1.gx, y: secp256k1.gy}
+	for i := 0; i < k.BitLen(); i++ {
+		if k.Bit(i) == 1 {
+			result = result.add(addend)
+		}
+		addend = addend.double()
+	}
+	return result
+}
+
+// compress serializes the point in 33-byte SEC form.
+func (a *point) compress() []byte {
+	out := make([]byte, 33)
+	out[0] = 0x02 + byte(a.y.Bit(0))
+	a.x.FillBytes(out[1:])
+	return out
+}
+
+// uncompressed serializes the point as 64 bytes of x and y.
+func (a *point) uncompressed() []byte {
+	out := make([]byte, 64)
+	a.x.FillBytes(out[:32])
+	a.y.FillBytes(out[32:])
+	return out
+}
+
+// decompress parses a 33-byte SEC public key.
+func decompress(key []byte) (*point, error) {
+	if len(key) != 33 || (key[0] != 0x02 && key[0] != 0x03) {
+		return nil, errInvalidPoint
+	}
+	p := secp256k1.p
+	x := new(big.Int).SetBytes(key[1:])
+	if x.Cmp(p) >= 0 {
+		return nil, errInvalidPoint
+	}
+
+	// y² = x³ + 7; p ≡ 3 mod 4 so y = (y²)^((p+1)/4).
+	ySquared := new(big.Int).Exp(x, big.NewInt(3), p)
+	ySquared.Add(ySquared, big.NewInt(7))
+	ySquared.Mod(ySquared, p)
+	exponent := new(big.Int).Add(p, big.NewInt(1))
+	exponent.Rsh(exponent, 2)
+	y := new(big.Int).Exp(ySquared, exponent, p)
+	if new(big.Int).Exp(y, big.NewInt(2), p).Cmp(ySquared) != 0 {
+		return nil, errInvalidPoint
+	}
+	if y.Bit(0) != uint(key[0]&1) {
+		y.Sub(p, y)
+	}
+	return &point{x: x, y: y}, nil
+}
@@ -0,0 +1,178 @@
+package wallet
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"crypto-portfolio-backend/internal/portfolio"
+	"crypto-portfolio-backend/internal/prices"
+)
+
+const dayMillis = 24 * 60 * 60 * 1000
+
+// MaxImportDuration bounds the explorer and node requests of one import;
+// a full xpub scan is thousands of sequential requests.
+const MaxImportDuration = 2 * time.Minute
+
+// ErrInvalidRequest is wrapped by errors caused by the request itself.
+var ErrInvalidRequest = errors.New("invalid wallet request")
+
+// PriceSource provides cached historical prices and token contract
+// metadata; implemented by prices.Service.
+type PriceSource interface {
+	GetHistoryCachedOnly(id, days, interval string) (*prices.HistoryResponse, error)
+	LookupContract(chain, address string) (*prices.ContractLookupResponse, error)
+}
+
+// Config holds the explorer endpoints. Empty values fall back to the public
+// defaults so a local stand-in can be swapped in through the environment.
+type Config struct {
+	EsploraURL      string
+	EtherscanURL    string
+	EtherscanAPIKey string
+	// RPCURLs maps EVM chains to JSON-RPC nodes for the rpc provider.
+	RPCURLs map[string]string
+}
+
+// Service imports on-chain history for watch-only wallets. Only public keys
+// and addresses are accepted and nothing is persisted.
+type Service struct {
+	prices   PriceSource
+	esplora  *EsploraClient
+	explorer *EtherscanClient
+	rpcURLs  map[string]string
+}
+
+// NewService creates a new wallet service.
+func NewService(priceSource PriceSource, config Config) *Service {
+	return &Service{
+		prices:   priceSource,
+		esplora:  NewEsploraClient(config.EsploraURL),
+		explorer: NewEtherscanClient(config.EtherscanURL, config.EtherscanAPIKey),
+		rpcURLs:  config.RPCURLs,
+	}
+}
+
+// ParseRPCURLs parses "chain=url" pairs separated by commas, as in
+// EVM_RPC_URLS="ethereum=http://127.0.0.1:8545,base=https://...".
+func ParseRPCURLs(value string) map[string]string {
+	urls := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		chain, url, found := strings.Cut(strings.TrimSpace(pair), "=")
+		if !found || strings.TrimSpace(url) == "" {
+			continue
+		}
+		urls[strings.ToLower(strings.TrimSpace(chain))] = strings.TrimSpace(url)
+	}
+	return urls
+}
+
+// AddressActivity summarizes a used address found while scanning.
+type AddressActivity struct {
+	Address string `json:"address"`
+	// Path is relative to the account key ("0/3" is the fourth receive
+	// address); empty for addresses given directly.
+	Path    string  `json:"path,omitempty"`
+	TxCount int     `json:"tx_count"`
+	Balance float64 `json:"balance"`
+}
+
+// Import is the normalized history of a wallet. Transactions use the
+// mobile schema with Source WALLET, inflows as BUY and outflows as SELL,
+// valued in USD at the daily close. Movements without a cached close are
+// returned in Unpriced instead, with no price or total, for the user to
+// price before saving.
+type Import struct {
+	Chain        string                  `json:"chain"`
+	Addresses    []AddressActivity       `json:"addresses"`
+	Scanned      int                     `json:"scanned"`
+	Transactions []portfolio.Transaction `json:"transactions"`
+	Tokens       []Token                 `json:"tokens,omitempty"`
+	Unpriced     []portfolio.Transaction `json:"unpriced,omitempty"`
+	// Errors lists movements that could not be imported, such as transfers
+	// of tokens whose decimals are unknown.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// valuer prices transactions from cached daily USD history, loading each
+// coin once per import.
+type valuer struct {
+	prices PriceSource
+	daily  map[string]map[int64]float64
+}
+
+func newValuer(priceSource PriceSource) *valuer {
+	return &valuer{prices: priceSource, daily: make(map[string]map[int64]float64)}
+}
+
+// priceAt returns the USD close of the transaction's day, falling back to
+// the previous day while today's close is not cached yet.
+func (v *valuer) priceAt(coinID string, timestamp int64) (float64, bool) {
+	daily, loaded := v.daily[coinID]
+	if !loaded {
+		daily = make(map[int64]float64)
+		history, err := v.prices.GetHistoryCachedOnly(coinID, "365", "daily")
+		if err != nil {
+			log.Printf("No cached history for %s: %v", coinID, err)
+		} else {
+			factor := 1.0
+			if history.MigrationRatio > 0 {
+				factor = history.MigrationRatio
+			}
+			for _, point := range history.Prices {
+				daily[point.Timestamp-point.Timestamp%dayMillis] = point.Price * factor
+			}
+		}
+		v.daily[coinID] = daily
+	}
+
+	day := timestamp - timestamp%dayMillis
+	for _, candidate := range []int64{day, day - dayMillis} {
+		if price, found := daily[candidate]; found && price > 0 {
+			return price, true
+		}
+	}
+	return 0, false
+}
+
+// value sets the USD price and total of each transaction priced by coinID
+// and splits off the transactions without a cached close.
+func (v *valuer) value(transactions []portfolio.Transaction, coinIDs func(portfolio.Transaction) string) (priced, unpriced []portfolio.Transaction) {
+	priced = make([]portfolio.Transaction, 0, len(transactions))
+	for _, tx := range transactions {
+		coinID := coinIDs(tx)
+		price, found := 0.0, false
+		if coinID != "" {
+			price, found = v.priceAt(coinID, tx.Timestamp)
+		}
+		if !found {
+			unpriced = append(unpriced, tx)
+			continue
+		}
+		tx.FiatCurrency = "USD"
+		tx.PricePerUnitFiat = price
+		tx.TotalFiat = abs(tx.Amount) * price
+		priced = append(priced, tx)
+	}
+	return priced, unpriced
+}
+
+// sortTransactions orders transactions by time, then external id.
+func sortTransactions(transactions []portfolio.Transaction) {
+	sort.SliceStable(transactions, func(i, j int) bool {
+		if transactions[i].Timestamp != transactions[j].Timestamp {
+			return transactions[i].Timestamp < transactions[j].Timestamp
+		}
+		return *transactions[i].ExternalID < *transactions[j].ExternalID
+	})
+}
+
+func abs(value float64) float64 {
+	if value < 0 {
+		return -value
+	}
+	return value
+}